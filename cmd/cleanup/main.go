@@ -2,8 +2,10 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log/slog"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/m0rjc/OsmDeviceAdapter/internal/config"
@@ -11,10 +13,53 @@ import (
 	"github.com/m0rjc/OsmDeviceAdapter/internal/db/devicecode"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/db/devicesession"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/db/scoreaudit"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db/scorebatch"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db/userdata"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/db/websession"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/logging"
 )
 
+// cleanupTasks are the names accepted by -task, in the order they run.
+var cleanupTasks = []string{"device-codes", "sessions", "unused-devices", "web-sessions", "audit", "batches"}
+
+// cleanupStep is one cleanup task: run deletes the rows, count reports how many
+// run would delete. attrs are logged with the task.
+type cleanupStep struct {
+	description string
+	attrs       []any
+	run         func() error
+	count       func() (int64, error)
+}
+
+// parseTasks returns the set of tasks named in a comma-separated list, or
+// every task when the list is empty.
+func parseTasks(list string) (map[string]bool, error) {
+	selected := make(map[string]bool)
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		known := false
+		for _, task := range cleanupTasks {
+			if name == task {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown cleanup task %q (valid tasks: %s)", name, strings.Join(cleanupTasks, ","))
+		}
+		selected[name] = true
+	}
+	if len(selected) == 0 {
+		for _, task := range cleanupTasks {
+			selected[task] = true
+		}
+	}
+	return selected, nil
+}
+
 func main() {
 	// Initialize structured logging
 	logging.InitLogger()
@@ -22,10 +67,34 @@ func main() {
 	// Parse command line flags
 	unusedThreshold := flag.Int("unused-threshold", 30, "Days of inactivity before a device is considered unused")
 	auditRetention := flag.Int("audit-retention", 14, "Days to retain score audit logs")
+	auditCompact := flag.Bool("audit-compact", false, "Keep daily per-patrol totals of expired score audit logs in score_audit_summary")
+	deleteUser := flag.Int("delete-user", 0, "Delete every record held for this OSM user ID instead of running the routine cleanup")
+	dryRun := flag.Bool("dry-run", false, "Log how many rows each task would delete without deleting them")
+	taskList := flag.String("task", "", "Comma-separated cleanup tasks to run ("+strings.Join(cleanupTasks, ",")+"); all when empty")
 	flag.Parse()
 
+	tasks, err := parseTasks(*taskList)
+	if err != nil {
+		slog.Error("invalid -task flag", "error", err)
+		os.Exit(2)
+	}
+
+	if *unusedThreshold < 1 || *auditRetention < 1 {
+		slog.Error("retention periods must be at least one day",
+			"unused_threshold_days", *unusedThreshold,
+			"audit_retention_days", *auditRetention,
+		)
+		os.Exit(2)
+	}
+	if *dryRun && *deleteUser > 0 {
+		slog.Error("-dry-run cannot be combined with -delete-user")
+		os.Exit(2)
+	}
+
 	slog.Info("starting database cleanup",
 		"unused_threshold_days", *unusedThreshold,
+		"tasks", *taskList,
+		"dry_run", *dryRun,
 	)
 
 	// Load minimal configuration (only database and Redis)
@@ -61,56 +130,84 @@ func main() {
 
 	slog.Info("database connections established")
 
-	// Run cleanup operations
-	exitCode := 0
-
-	// Clean up expired device codes
-	slog.Info("cleaning up expired device codes")
-	if err := devicecode.DeleteExpired(conns); err != nil {
-		slog.Error("failed to delete expired device codes", "error", err)
-		exitCode = 1
-	} else {
-		slog.Info("expired device codes cleaned up successfully")
+	// A user deletion is a one-off job for an account deletion request
+	if *deleteUser > 0 {
+		counts, err := userdata.DeleteUser(conns, *deleteUser)
+		if err != nil {
+			slog.Error("failed to delete user data", "osm_user_id", *deleteUser, "error", err)
+			os.Exit(1)
+		}
+		slog.Info("user data deleted", append([]any{"osm_user_id", *deleteUser}, counts.LogAttrs()...)...)
+		os.Exit(0)
 	}
 
-	// Clean up expired sessions
-	slog.Info("cleaning up expired device sessions")
-	if err := devicesession.DeleteExpired(conns); err != nil {
-		slog.Error("failed to delete expired device sessions", "error", err)
-		exitCode = 1
-	} else {
-		slog.Info("expired device sessions cleaned up successfully")
-	}
-
-	// Clean up unused devices
-	slog.Info("cleaning up unused devices",
-		"threshold_days", *unusedThreshold,
-	)
-	if err := devicecode.DeleteUnused(conns, time.Duration(*unusedThreshold)*24*time.Hour); err != nil {
-		slog.Error("failed to delete unused device codes", "error", err)
-		exitCode = 1
-	} else {
-		slog.Info("unused device codes cleaned up successfully")
+	// Run cleanup operations
+	unusedAge := time.Duration(*unusedThreshold) * 24 * time.Hour
+	auditAge := time.Duration(*auditRetention) * 24 * time.Hour
+	cleanupAudit := scoreaudit.DeleteExpired
+	if *auditCompact {
+		cleanupAudit = scoreaudit.CompactExpired
 	}
-
-	// Clean up expired web sessions
-	slog.Info("cleaning up expired web sessions")
-	if err := websession.DeleteExpired(conns); err != nil {
-		slog.Error("failed to delete expired web sessions", "error", err)
-		exitCode = 1
-	} else {
-		slog.Info("expired web sessions cleaned up successfully")
+	steps := map[string]cleanupStep{
+		"device-codes": {
+			description: "expired device codes",
+			run:         func() error { return devicecode.DeleteExpired(conns) },
+			count:       func() (int64, error) { return devicecode.CountExpired(conns) },
+		},
+		"sessions": {
+			description: "expired device sessions",
+			run:         func() error { return devicesession.DeleteExpired(conns) },
+			count:       func() (int64, error) { return devicesession.CountExpired(conns) },
+		},
+		"unused-devices": {
+			description: "unused device codes",
+			attrs:       []any{"threshold_days", *unusedThreshold},
+			run:         func() error { return devicecode.DeleteUnused(conns, unusedAge) },
+			count:       func() (int64, error) { return devicecode.CountUnused(conns, unusedAge) },
+		},
+		"web-sessions": {
+			description: "expired web sessions",
+			run:         func() error { return websession.DeleteExpired(conns) },
+			count:       func() (int64, error) { return websession.CountExpired(conns) },
+		},
+		"audit": {
+			description: "old score audit logs",
+			attrs:       []any{"retention_days", *auditRetention, "compact", *auditCompact},
+			run:         func() error { return cleanupAudit(conns, auditAge) },
+			count:       func() (int64, error) { return scoreaudit.CountExpired(conns, auditAge) },
+		},
+		"batches": {
+			description: "old score batch outcomes",
+			attrs:       []any{"retention_days", *auditRetention},
+			run:         func() error { return scorebatch.DeleteExpired(conns, auditAge) },
+			count:       func() (int64, error) { return scorebatch.CountExpired(conns, auditAge) },
+		},
 	}
 
-	// Clean up old score audit logs
-	slog.Info("cleaning up old score audit logs",
-		"retention_days", *auditRetention,
-	)
-	if err := scoreaudit.DeleteExpired(conns, time.Duration(*auditRetention)*24*time.Hour); err != nil {
-		slog.Error("failed to delete old score audit logs", "error", err)
-		exitCode = 1
-	} else {
-		slog.Info("old score audit logs cleaned up successfully")
+	exitCode := 0
+	for _, name := range cleanupTasks {
+		if !tasks[name] {
+			continue
+		}
+		step := steps[name]
+		if *dryRun {
+			count, err := step.count()
+			if err != nil {
+				slog.Error("failed to count "+step.description, "error", err)
+				exitCode = 1
+				continue
+			}
+			slog.Info("dry run: would delete "+step.description, append([]any{"count", count}, step.attrs...)...)
+			continue
+		}
+
+		slog.Info("cleaning up "+step.description, step.attrs...)
+		if err := step.run(); err != nil {
+			slog.Error("failed to delete "+step.description, "error", err)
+			exitCode = 1
+		} else {
+			slog.Info(step.description + " cleaned up successfully")
+		}
 	}
 
 	if exitCode == 0 {
@@ -0,0 +1,176 @@
+// Command clientadmin manages the client IDs allowed to start the device flow.
+//
+//	clientadmin list
+//	clientadmin add -client-id scoreboard-v2 -comment "Scoreboard v2" -email owner@example.com
+//	clientadmin disable -client-id scoreboard-v1
+//	clientadmin enable -client-id scoreboard-v1
+//	clientadmin rotate -old scoreboard-v1 -new scoreboard-v1b
+//	clientadmin timing -client-id scoreboard-v1 -expiry 900 -interval 30
+//
+// Rotating keeps the record's surrogate ID, so devices already authorized
+// through the old client ID keep working.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+
+	"github.com/m0rjc/OsmDeviceAdapter/internal/config"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db/allowedclient"
+)
+
+const usage = `usage: clientadmin <command> [flags]
+
+commands:
+  list                                            show every client ID
+  add -client-id ID -comment TEXT -email ADDRESS  allow a new client ID
+  disable -client-id ID                           stop a client ID authorizing devices
+  enable -client-id ID                            let a disabled client ID authorize devices again
+  rotate -old ID -new ID                          rename a client ID, keeping its devices
+  timing -client-id ID [-expiry S] [-interval S]  override device code expiry and poll interval (0 uses the default)
+`
+
+func main() {
+	// Logs go to stderr so stdout carries only the table
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, nil)))
+
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	command, args := os.Args[1], os.Args[2:]
+
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	clientID := flags.String("client-id", "", "Client ID to add, enable or disable")
+	comment := flags.String("comment", "", "Description of the client application (add)")
+	email := flags.String("email", "", "Contact email for the client owner (add)")
+	oldClientID := flags.String("old", "", "Client ID to replace (rotate)")
+	newClientID := flags.String("new", "", "Replacement client ID (rotate)")
+	expiry := flags.Int("expiry", 0, "Device code expiry in seconds, 0 for the service default (timing)")
+	interval := flags.Int("interval", 0, "Poll interval in seconds, 0 for the service default (timing)")
+	flags.Parse(args) //nolint:errcheck // ExitOnError
+
+	// Check arguments before connecting so mistakes fail fast
+	switch command {
+	case "list":
+	case "add":
+		requireFlags(command, map[string]string{"client-id": *clientID, "comment": *comment, "email": *email})
+	case "disable", "enable", "timing":
+		requireFlags(command, map[string]string{"client-id": *clientID})
+	case "rotate":
+		requireFlags(command, map[string]string{"old": *oldClientID, "new": *newClientID})
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", command, usage)
+		os.Exit(2)
+	}
+
+	// Load minimal configuration (only database and Redis)
+	cfg, err := config.LoadMinimal()
+	if err != nil {
+		slog.Error("failed to load configuration", "error", err)
+		os.Exit(1)
+	}
+
+	// Initialize database connection
+	dbConn, err := db.NewPostgresConnection(cfg.Database.DatabaseURL)
+	if err != nil {
+		slog.Error("failed to connect to database", "error", err)
+		os.Exit(1)
+	}
+	sqlDB, err := dbConn.DB()
+	if err != nil {
+		slog.Error("failed to get underlying database connection", "error", err)
+		os.Exit(1)
+	}
+	defer sqlDB.Close()
+
+	conns := db.NewConnections(dbConn, nil)
+
+	var shown string
+	switch command {
+	case "list":
+		clients, err := allowedclient.List(conns)
+		if err != nil {
+			slog.Error("failed to list client IDs", "error", err)
+			os.Exit(1)
+		}
+		printClients(os.Stdout, clients)
+		return
+	case "add":
+		err = allowedclient.Create(conns, &db.AllowedClientID{
+			ClientID:     *clientID,
+			Comment:      *comment,
+			ContactEmail: *email,
+			Enabled:      true,
+		})
+		shown = *clientID
+	case "disable", "enable":
+		err = allowedclient.UpdateEnabled(conns, *clientID, command == "enable")
+		shown = *clientID
+	case "rotate":
+		err = allowedclient.Rotate(conns, *oldClientID, *newClientID)
+		shown = *newClientID
+	case "timing":
+		err = allowedclient.UpdateDeviceFlowOverrides(conns, *clientID, overrideSeconds(*expiry), overrideSeconds(*interval))
+		shown = *clientID
+	}
+	if errors.Is(err, allowedclient.ErrNotFound) {
+		slog.Error("client ID not found", "command", command)
+		os.Exit(1)
+	}
+	if err != nil {
+		slog.Error("failed to update client ID", "command", command, "error", err)
+		os.Exit(1)
+	}
+
+	client, err := allowedclient.Find(conns, shown)
+	if err != nil || client == nil {
+		slog.Error("failed to read back client ID", "client_id", shown, "error", err)
+		os.Exit(1)
+	}
+	printClients(os.Stdout, []db.AllowedClientID{*client})
+}
+
+// requireFlags exits with usage if any of the named flags is empty.
+func requireFlags(command string, values map[string]string) {
+	for name, value := range values {
+		if value == "" {
+			fmt.Fprintf(os.Stderr, "%s requires -%s\n\n%s", command, name, usage)
+			os.Exit(2)
+		}
+	}
+}
+
+// overrideSeconds maps a flag value to a timing override, where 0 means none.
+func overrideSeconds(seconds int) *int {
+	if seconds == 0 {
+		return nil
+	}
+	return &seconds
+}
+
+// printClients writes clients as an aligned table.
+func printClients(out io.Writer, clients []db.AllowedClientID) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tCLIENT ID\tENABLED\tWRITE\tEXPIRY\tINTERVAL\tEMAIL\tCOMMENT\tCREATED")
+	for _, c := range clients {
+		fmt.Fprintf(w, "%d\t%s\t%t\t%t\t%s\t%s\t%s\t%s\t%s\n",
+			c.ID, c.ClientID, c.Enabled, c.WriteEnabled, formatSeconds(c.DeviceCodeExpirySeconds), formatSeconds(c.PollIntervalSeconds),
+			c.ContactEmail, c.Comment, c.CreatedAt.Format("2006-01-02"))
+	}
+	w.Flush()
+}
+
+// formatSeconds shows a timing override, or "default" when unset.
+func formatSeconds(seconds *int) string {
+	if seconds == nil {
+		return "default"
+	}
+	return fmt.Sprintf("%ds", *seconds)
+}
@@ -564,6 +564,7 @@ func handleResource(w http.ResponseWriter, r *http.Request) {
 			"section_id":   sec.SectionID,
 			"group_id":     sec.GroupID,
 			"section_type": sec.SectionType,
+			"permissions":  map[string]int{"member": 20, "badge": 20},
 			"terms": []map[string]interface{}{
 				{
 					"name":      sec.TermName,
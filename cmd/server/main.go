@@ -12,13 +12,19 @@ import (
 
 	"github.com/m0rjc/OsmDeviceAdapter/internal/config"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/db"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db/devicecode"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/deviceauth"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/devicetoken"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/geoip"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/handlers"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/logging"
 	_ "github.com/m0rjc/OsmDeviceAdapter/internal/metrics" // Initialize metrics
+	"github.com/m0rjc/OsmDeviceAdapter/internal/notify"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/osm"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/osm/demo"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/osm/oauthclient"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/server"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/services"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/services/scoreupdateservice"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/tokenrefresh"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/webauth"
@@ -60,6 +66,7 @@ func main() {
 		os.Exit(1)
 	}
 	defer redisClient.Close()
+	redisClient.SetOsmServiceBlockCooldown(time.Duration(cfg.RateLimit.OSMServiceBlockCooldown) * time.Second)
 	slog.Info("redis connection established", "key_prefix", cfg.Redis.RedisKeyPrefix)
 
 	// Create database connections wrapper
@@ -77,23 +84,55 @@ func main() {
 
 	// Create device auth service
 	deviceAuthService := deviceauth.NewService(conns, tokenRefreshService)
+	if cfg.DeviceOAuth.RevocationWebhookURL != "" {
+		deviceAuthService.SetNotifier(notify.NewWebhook(cfg.DeviceOAuth.RevocationWebhookURL))
+	}
+	if cfg.DeviceOAuth.TokenSigningSecret != "" {
+		deviceAuthService.SetTokenSigner(devicetoken.NewSigner(cfg.DeviceOAuth.TokenSigningSecret),
+			time.Duration(cfg.DeviceOAuth.TokenMaxAge)*time.Second,
+			time.Duration(cfg.DeviceOAuth.TokenRecheckInterval)*time.Second)
+	}
 
 	// Create web auth service for admin session management
 	webAuthService := webauth.NewService(conns, tokenRefreshService)
 
 	// Create OSM client (token refresh is handled via context-bound functions)
 	osmClient := osm.NewClient(cfg.ExternalDomains.OSMDomain, rlStore, recorder)
+	osmClient.SetInlineRetry(time.Duration(cfg.RateLimit.OSMInlineRetryMaxWait) * time.Second)
+
+	// In demo mode OSM is answered in-process from sample data
+	var demoOSM *demo.Server
+	if cfg.Server.DemoMode {
+		demoOSM = demo.NewServer()
+		osmClient.SetTransport(demoOSM.Transport())
+		oauthClient.SetTransport(demoOSM.Transport())
+		slog.Warn("demo mode enabled: OSM requests are served from sample data", "osm_domain", cfg.ExternalDomains.OSMDomain)
+	}
 
 	// Create score update service with distributed locking
-	scoreUpdateService := scoreupdateservice.New(osmClient, conns)
+	scoreUpdateService := scoreupdateservice.New(osmClient, conns, cfg.ScoreUpdate.MaxConcurrentUpdates, cfg.ScoreUpdate.OSMRetryBudget)
 
 	// Create WebSocket hub and start its pub/sub listener
 	wsHub := wsinternal.NewHub(redisClient)
+	wsHub.SetReconnectBackoff(time.Second, time.Duration(cfg.Redis.PubSubReconnectMaxBackoff)*time.Second)
+	wsHub.SetMaxConnections(cfg.Server.WebSocketMaxConnections)
+	wsHub.SetMaxSectionConnections(cfg.Server.WebSocketMaxSectionConnections)
+	wsHub.SetReadLimit(cfg.Server.WebSocketReadLimit)
+	wsHub.SetSectionLister(func(device *db.DeviceCode) ([]int, error) {
+		return devicecode.ListSections(conns, device)
+	})
 	hubCtx, hubCancel := context.WithCancel(context.Background())
 	defer hubCancel()
 	go wsHub.Run(hubCtx)
 	slog.Info("websocket hub started")
 
+	// Archive the final scores of terms that ended unseen by their devices
+	if interval := time.Duration(cfg.Cache.TermArchiveInterval) * time.Second; interval > 0 {
+		archiver := services.NewTermArchiver(osmClient, conns, cfg, deviceAuthService.CreateRefreshFunc)
+		go archiver.Run(hubCtx, interval)
+		slog.Info("term archiver started", "interval", interval)
+	}
+
 	// Create handler dependencies
 	deps := &handlers.Dependencies{
 		Config:             cfg,
@@ -105,6 +144,18 @@ func main() {
 		ScoreUpdateService: scoreUpdateService,
 		WebSocketHub:       wsHub,
 	}
+	if demoOSM != nil {
+		deps.DemoOSM = demoOSM
+	}
+	if cfg.GeoIP.LocationsCSV != "" {
+		countries, err := geoip.LoadCSV(cfg.GeoIP.LocationsCSV, cfg.GeoIP.ParseBlocksCSV()...)
+		if err != nil {
+			slog.Error("failed to load GeoIP database", "error", err)
+			os.Exit(1)
+		}
+		deps.Countries = countries
+		slog.Info("geoip database loaded", "locations", cfg.GeoIP.LocationsCSV)
+	}
 
 	// Create and configure HTTP server
 	srv := server.NewServer(cfg, deps)
@@ -125,8 +176,15 @@ func main() {
 	// Start main server in a goroutine
 	go func() {
 		addr := fmt.Sprintf(":%d", cfg.Server.Port)
-		slog.Info("server listening", "address", addr, "port", cfg.Server.Port)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		slog.Info("server listening", "address", addr, "port", cfg.Server.Port, "tls", cfg.Security.TLSEnabled())
+		var err error
+		if cfg.Security.TLSEnabled() {
+			// srv.TLSConfig carries the minimum TLS version
+			err = srv.ListenAndServeTLS(cfg.Security.TLSCertFile, cfg.Security.TLSKeyFile)
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			slog.Error("server error", "error", err)
 			os.Exit(1)
 		}
@@ -167,5 +225,15 @@ func main() {
 		}
 	}
 
+	// Shutdown does not wait for hijacked WebSocket connections, so tell devices
+	// to reconnect elsewhere and wait for their disconnect messages to go out.
+	// Close as well as cancelling so unregistering devices do not block on the
+	// stopped pub/sub loop.
+	hubCancel()
+	wsHub.Close()
+	if err := wsHub.Drain(ctx); err != nil {
+		slog.Warn("websocket hub did not drain", "error", err)
+	}
+
 	slog.Info("servers exited successfully")
 }
@@ -0,0 +1,79 @@
+// Command userdata exports or deletes everything stored for one OSM user, to
+// answer data subject access and erasure requests.
+//
+//	userdata -user 12345            # write the user's data to stdout as JSON
+//	userdata -user 12345 -delete    # erase it, printing the rows removed
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log/slog"
+	"os"
+
+	"github.com/m0rjc/OsmDeviceAdapter/internal/config"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db/userdata"
+)
+
+func main() {
+	// Logs go to stderr so stdout carries only the JSON document
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, nil)))
+
+	// Parse command line flags
+	osmUserID := flag.Int("user", 0, "OSM user ID whose data to export or delete")
+	deleteData := flag.Bool("delete", false, "Delete the user's data instead of exporting it")
+	flag.Parse()
+
+	if *osmUserID <= 0 {
+		slog.Error("an OSM user ID is required", "user", *osmUserID)
+		os.Exit(2)
+	}
+
+	// Load minimal configuration (only database and Redis)
+	cfg, err := config.LoadMinimal()
+	if err != nil {
+		slog.Error("failed to load configuration", "error", err)
+		os.Exit(1)
+	}
+
+	// Initialize database connection
+	dbConn, err := db.NewPostgresConnection(cfg.Database.DatabaseURL)
+	if err != nil {
+		slog.Error("failed to connect to database", "error", err)
+		os.Exit(1)
+	}
+	sqlDB, err := dbConn.DB()
+	if err != nil {
+		slog.Error("failed to get underlying database connection", "error", err)
+		os.Exit(1)
+	}
+	defer sqlDB.Close()
+
+	conns := db.NewConnections(dbConn, nil)
+
+	var output any
+	if *deleteData {
+		counts, err := userdata.DeleteUser(conns, *osmUserID)
+		if err != nil {
+			slog.Error("failed to delete user data", "osm_user_id", *osmUserID, "error", err)
+			os.Exit(1)
+		}
+		slog.Info("user data deleted", append([]any{"osm_user_id", *osmUserID}, counts.LogAttrs()...)...)
+		output = counts
+	} else {
+		export, err := userdata.ExportUser(conns, *osmUserID)
+		if err != nil {
+			slog.Error("failed to export user data", "osm_user_id", *osmUserID, "error", err)
+			os.Exit(1)
+		}
+		output = export
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(output); err != nil {
+		slog.Error("failed to write output", "error", err)
+		os.Exit(1)
+	}
+}
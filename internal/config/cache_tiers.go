@@ -0,0 +1,87 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CacheTTLTier caches patrol scores for TTL while OSM reports at least
+// MinRemaining requests left in the rate limit window.
+type CacheTTLTier struct {
+	MinRemaining int
+	TTL          time.Duration
+}
+
+// DefaultCacheTTLTiers caches for a minute while the rate limit has plenty of
+// room and for longer as it runs down.
+var DefaultCacheTTLTiers = []CacheTTLTier{
+	{MinRemaining: 501, TTL: 1 * time.Minute},
+	{MinRemaining: 200, TTL: 5 * time.Minute},
+	{MinRemaining: 100, TTL: 10 * time.Minute},
+	{MinRemaining: 50, TTL: 15 * time.Minute},
+	{MinRemaining: 0, TTL: 30 * time.Minute},
+}
+
+// ParseCacheTTLTiers parses a comma-separated list of "remaining:ttl" tiers,
+// for example "1000:30s,200:5m,0:20m". An empty list gives the defaults.
+func ParseCacheTTLTiers(list string) ([]CacheTTLTier, error) {
+	if strings.TrimSpace(list) == "" {
+		return DefaultCacheTTLTiers, nil
+	}
+
+	var tiers []CacheTTLTier
+	for _, part := range strings.Split(list, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		remaining, ttl, ok := strings.Cut(part, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid cache TTL tier %q in CACHE_TTL_TIERS (expected remaining:ttl)", part)
+		}
+		minRemaining, err := strconv.Atoi(strings.TrimSpace(remaining))
+		if err != nil {
+			return nil, fmt.Errorf("invalid remaining count in cache TTL tier %q: %w", part, err)
+		}
+		duration, err := time.ParseDuration(strings.TrimSpace(ttl))
+		if err != nil {
+			return nil, fmt.Errorf("invalid TTL in cache TTL tier %q: %w", part, err)
+		}
+		tiers = append(tiers, CacheTTLTier{MinRemaining: minRemaining, TTL: duration})
+	}
+	if err := ValidateCacheTTLTiers(tiers); err != nil {
+		return nil, err
+	}
+	return tiers, nil
+}
+
+// ValidateCacheTTLTiers checks tiers run from the most remaining requests to
+// the fewest, ending at 0 so every count has a tier, and that the TTL never
+// shortens as the rate limit runs down.
+func ValidateCacheTTLTiers(tiers []CacheTTLTier) error {
+	if len(tiers) == 0 {
+		return errors.New("CACHE_TTL_TIERS has no tiers")
+	}
+	for i, tier := range tiers {
+		if tier.TTL <= 0 {
+			return fmt.Errorf("cache TTL tier %d:%s must have a positive TTL", tier.MinRemaining, tier.TTL)
+		}
+		if i == 0 {
+			continue
+		}
+		previous := tiers[i-1]
+		if tier.MinRemaining >= previous.MinRemaining {
+			return fmt.Errorf("cache TTL tiers must list remaining counts in decreasing order (%d after %d)", tier.MinRemaining, previous.MinRemaining)
+		}
+		if tier.TTL < previous.TTL {
+			return fmt.Errorf("cache TTL tiers must not shorten the TTL as remaining requests fall (%s after %s)", tier.TTL, previous.TTL)
+		}
+	}
+	if last := tiers[len(tiers)-1]; last.MinRemaining != 0 {
+		return fmt.Errorf("the last cache TTL tier must start at 0 remaining requests, not %d", last.MinRemaining)
+	}
+	return nil
+}
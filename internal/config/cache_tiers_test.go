@@ -0,0 +1,64 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCacheTTLTiers(t *testing.T) {
+	tiers, err := ParseCacheTTLTiers(" 1000:30s, 200:5m ,0:20m,")
+	if err != nil {
+		t.Fatalf("ParseCacheTTLTiers failed: %v", err)
+	}
+	want := []CacheTTLTier{
+		{MinRemaining: 1000, TTL: 30 * time.Second},
+		{MinRemaining: 200, TTL: 5 * time.Minute},
+		{MinRemaining: 0, TTL: 20 * time.Minute},
+	}
+	if len(tiers) != len(want) {
+		t.Fatalf("expected %d tiers, got %v", len(want), tiers)
+	}
+	for i := range want {
+		if tiers[i] != want[i] {
+			t.Errorf("tier %d = %+v, expected %+v", i, tiers[i], want[i])
+		}
+	}
+
+	tiers, err = ParseCacheTTLTiers("")
+	if err != nil || len(tiers) != len(DefaultCacheTTLTiers) {
+		t.Errorf("expected the defaults for an unset list, got %v (%v)", tiers, err)
+	}
+}
+
+func TestParseCacheTTLTiers_RejectsInvalidTiers(t *testing.T) {
+	tests := []struct {
+		name string
+		list string
+	}{
+		{"no tiers", " , "},
+		{"remaining counts rising", "100:5m,200:10m,0:30m"},
+		{"repeated remaining count", "200:5m,200:10m,0:30m"},
+		{"TTL shortening", "200:10m,100:5m,0:30m"},
+		{"no tier for 0 remaining", "500:1m,100:10m"},
+		{"zero TTL", "0:0s"},
+		{"missing TTL", "500,0:30m"},
+		{"bad duration", "500:soon,0:30m"},
+		{"bad count", "lots:1m,0:30m"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseCacheTTLTiers(tt.list); err == nil {
+				t.Errorf("expected an error for %q", tt.list)
+			}
+		})
+	}
+}
+
+func TestValidateCacheTTLTiers_AcceptsDefaults(t *testing.T) {
+	if err := ValidateCacheTTLTiers(DefaultCacheTTLTiers); err != nil {
+		t.Errorf("expected the default tiers to be valid: %v", err)
+	}
+	if err := ValidateCacheTTLTiers(nil); err == nil {
+		t.Error("expected an error for an empty tier list")
+	}
+}
@@ -2,7 +2,9 @@ package config
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"net/url"
 	"strings"
 
 	"github.com/m0rjc/goconfig"
@@ -12,12 +14,31 @@ import (
 type ServerConfig struct {
 	Port int    `key:"PORT" default:"8080" min:"1" max:"65535"`
 	Host string `key:"HOST" default:"0.0.0.0"`
+	// EnablePprof mounts net/http/pprof handlers on the internal metrics server.
+	// Off by default; enable temporarily to diagnose goroutine leaks.
+	EnablePprof bool `key:"ENABLE_PPROF" default:"false"`
+	// DemoMode answers OSM requests in-process from built-in sample data, for demos
+	// and UI development without OSM credentials. Refused with the real OSM domain.
+	DemoMode bool `key:"DEMO_MODE" default:"false"`
+	// WebSocketMaxConnections caps concurrent device WebSocket connections on
+	// this server; further upgrades get 503 with Retry-After. 0 means no limit.
+	WebSocketMaxConnections int `key:"WEBSOCKET_MAX_CONNECTIONS" default:"0" min:"0"`
+	// WebSocketMaxSectionConnections caps connections to any one section (or one
+	// user's ad-hoc teams), so one section cannot exhaust the server. 0 means no limit.
+	WebSocketMaxSectionConnections int `key:"WEBSOCKET_MAX_SECTION_CONNECTIONS" default:"0" min:"0"`
+	// WebSocketReadLimit is the largest message, in bytes, accepted from a device
+	// over its WebSocket. Larger messages close the connection.
+	WebSocketReadLimit int `key:"WEBSOCKET_READ_LIMIT" default:"1024" min:"256" max:"65536"`
 }
 
 // ExternalDomainsConfig holds external domain configuration
+// A deployment reachable on more than one host lists the others in
+// TrustedForwardedHosts; verification URLs then follow the host a trusted proxy
+// reports rather than always using ExposedDomain.
 type ExternalDomainsConfig struct {
-	ExposedDomain string `key:"EXPOSED_DOMAIN" required:"true"` // The domain where this service is exposed
-	OSMDomain     string `key:"OSM_DOMAIN" default:"https://www.onlinescoutmanager.co.uk"`
+	ExposedDomain         string `key:"EXPOSED_DOMAIN" required:"true"` // The domain where this service is exposed
+	OSMDomain             string `key:"OSM_DOMAIN" default:"https://www.onlinescoutmanager.co.uk"`
+	TrustedForwardedHosts string `key:"TRUSTED_FORWARDED_HOSTS"` // Comma-separated hosts accepted from X-Forwarded-Host or Forwarded
 }
 
 // OAuthConfig holds OAuth configuration for OSM
@@ -36,20 +57,41 @@ type DatabaseConfig struct {
 type RedisConfig struct {
 	RedisURL       string `key:"REDIS_URL" default:"redis://localhost:6379"`
 	RedisKeyPrefix string `key:"REDIS_KEY_PREFIX"`
+	// PubSubReconnectMaxBackoff caps the delay between attempts to restore the
+	// WebSocket hub's pub/sub subscription after it drops.
+	PubSubReconnectMaxBackoff int `key:"REDIS_PUBSUB_RECONNECT_MAX_BACKOFF" default:"30" min:"1"` // seconds
 }
 
 // DeviceOAuthConfig holds device OAuth flow configuration
 type DeviceOAuthConfig struct {
-	DeviceCodeExpiry   int    `key:"DEVICE_CODE_EXPIRY" default:"300" min:"60"` // seconds (5 minutes default)
-	DevicePollInterval int    `key:"DEVICE_POLL_INTERVAL" default:"5" min:"1"`  // seconds
-	AllowedClientIDs   string `key:"ALLOWED_CLIENT_IDS"`                        // DEPRECATED: Use database table instead. Comma-separated list for backward compatibility.
+	DeviceCodeExpiry        int    `key:"DEVICE_CODE_EXPIRY" default:"300" min:"60"`                 // seconds (5 minutes default)
+	DevicePollInterval      int    `key:"DEVICE_POLL_INTERVAL" default:"5" min:"1"`                  // seconds
+	DeviceTokenExpiresIn    int    `key:"DEVICE_TOKEN_EXPIRES_IN" default:"0" min:"0"`               // expires_in reported with device tokens, seconds (0 = omitted, token does not expire)
+	SectionSelectionTimeout int    `key:"SECTION_SELECTION_TIMEOUT" default:"120" min:"0"`           // seconds allowed to pick a section after signing in to OSM (0 = until the device code expires)
+	RejectWhileOSMBlocked   bool   `key:"DEVICE_AUTHORIZE_REJECT_WHILE_OSM_BLOCKED" default:"false"` // refuse new device pairings while OSM has blocked the service
+	PrewarmOnPairing        bool   `key:"DEVICE_PREWARM_ON_PAIRING" default:"true"`                  // fetch scores into the cache as soon as a device is paired
+	AllowedClientIDs        string `key:"ALLOWED_CLIENT_IDS"`                                        // DEPRECATED: Use database table instead. Comma-separated list for backward compatibility.
+	RevocationWebhookURL    string `key:"REVOCATION_WEBHOOK_URL"`                                    // POSTed a JSON notice when OSM revokes a device's access (unset = no notice)
+	TokenSigningSecret      string `key:"DEVICE_TOKEN_SIGNING_SECRET"`                               // HMAC secret for signed device tokens (unset = opaque tokens looked up on every request)
+	TokenMaxAge             int    `key:"DEVICE_TOKEN_MAX_AGE" default:"0" min:"0"`                  // seconds a signed device token is accepted after issue (0 = no limit)
+	TokenRecheckInterval    int    `key:"DEVICE_TOKEN_RECHECK_INTERVAL" default:"60" min:"1"`        // seconds a device holding a signed token is trusted before its record is read again
 }
 
+// minTokenSigningSecretLength is the shortest DEVICE_TOKEN_SIGNING_SECRET
+// accepted, in bytes, matching the HMAC-SHA256 output size.
+const minTokenSigningSecretLength = 32
+
 // RateLimitConfig holds rate limiting configuration
 type RateLimitConfig struct {
-	DeviceAuthorizeRateLimit int `key:"DEVICE_AUTHORIZE_RATE_LIMIT" default:"6" min:"1"`  // max requests per minute per IP
-	DeviceTokenRateLimit     int `key:"DEVICE_TOKEN_RATE_LIMIT" default:"60" min:"1"`     // max requests per minute per IP
-	DeviceEntryRateLimit     int `key:"DEVICE_ENTRY_RATE_LIMIT" default:"5" min:"1"`      // seconds between entries
+	DeviceAuthorizeRateLimit int `key:"DEVICE_AUTHORIZE_RATE_LIMIT" default:"6" min:"1"` // max requests per minute per IP
+	DeviceTokenRateLimit     int `key:"DEVICE_TOKEN_RATE_LIMIT" default:"60" min:"1"`    // max requests per minute per IP
+	DeviceEntryRateLimit     int `key:"DEVICE_ENTRY_RATE_LIMIT" default:"5" min:"1"`     // seconds between entries
+	UserCodeMaxFailures      int `key:"USER_CODE_MAX_FAILURES" default:"5" min:"1"`      // failed attempts with one user code, from any IP, before it is locked
+	StatusRateLimit          int `key:"STATUS_RATE_LIMIT" default:"30" min:"1"`          // max /status requests per minute per IP
+	OSMServiceBlockCooldown  int `key:"OSM_SERVICE_BLOCK_COOLDOWN" default:"0" min:"0"`  // seconds to pause OSM calls after X-Blocked (0 = until cleared manually)
+	AdminScoreRateLimit      int `key:"ADMIN_SCORE_RATE_LIMIT" default:"30" min:"1"`     // max score submissions per minute per user per section
+	DeviceScoreRateLimit     int `key:"DEVICE_SCORE_RATE_LIMIT" default:"10" min:"1"`    // max score submissions per minute per write-enabled device
+	OSMInlineRetryMaxWait    int `key:"OSM_INLINE_RETRY_MAX_WAIT" default:"0" min:"0"`   // seconds of OSM Retry-After waited out before retrying a patrol score request once (0 = never)
 }
 
 // CacheConfig holds cache configuration for patrol scores and other data
@@ -58,14 +100,67 @@ type CacheConfig struct {
 	RateLimitCaution  int `key:"RATE_LIMIT_CAUTION" default:"200" min:"0"`    // remaining requests threshold for caution
 	RateLimitWarning  int `key:"RATE_LIMIT_WARNING" default:"100" min:"0"`    // remaining requests threshold for warning
 	RateLimitCritical int `key:"RATE_LIMIT_CRITICAL" default:"20" min:"0"`    // remaining requests threshold for critical
+	// TTLTierList sets how long patrol scores are cached for the OSM requests
+	// remaining, as "remaining:ttl" tiers; empty uses DefaultCacheTTLTiers.
+	TTLTierList string         `key:"CACHE_TTL_TIERS"`
+	TTLTiers    []CacheTTLTier // parsed from TTLTierList by Load
+	// TermArchiveInterval is how often ended terms whose sections no device
+	// polled are looked for and archived, in seconds; 0 turns the check off.
+	TermArchiveInterval int `key:"TERM_ARCHIVE_INTERVAL" default:"3600" min:"0"`
+}
+
+// ScoreUpdateConfig holds configuration for writing patrol scores to OSM
+type ScoreUpdateConfig struct {
+	MaxConcurrentUpdates    int `key:"SCORE_UPDATE_MAX_CONCURRENCY" default:"4" min:"1"`    // max OSM patrol score updates in flight at once
+	BatchSectionConcurrency int `key:"SCORE_BATCH_SECTION_CONCURRENCY" default:"3" min:"1"` // sections of a batch submission processed at once
+	BatchSectionTimeout     int `key:"SCORE_BATCH_SECTION_TIMEOUT" default:"20" min:"1"`    // seconds allowed per section of a batch submission
+	OSMRetryBudget          int `key:"SCORE_OSM_RETRY_BUDGET" default:"60" min:"0"`         // failed OSM writes retried per minute across all users; 0 disables retries
+	IdempotencyKeyTTL       int `key:"SCORE_IDEMPOTENCY_KEY_TTL" default:"604800" min:"1"`  // seconds an Idempotency-Key is remembered (7 days default)
+}
+
+// SecurityConfig holds HTTP security hardening configuration
+type SecurityConfig struct {
+	HSTSMaxAge    int    `key:"HSTS_MAX_AGE" default:"31536000" min:"0"` // seconds; 0 disables the Strict-Transport-Security header
+	TLSMinVersion string `key:"TLS_MIN_VERSION" default:"1.2"`           // minimum TLS version when the server terminates TLS itself ("1.2" or "1.3")
+	TLSCertFile   string `key:"TLS_CERT_FILE"`                           // PEM certificate chain; with TLSKeyFile, the server terminates TLS itself
+	TLSKeyFile    string `key:"TLS_KEY_FILE"`                            // PEM private key for TLSCertFile
+	AnonymizeIPs  bool   `key:"ANONYMIZE_IPS" default:"false"`           // store only the network part of client IPs (IPv4 /24, IPv6 /48)
+	CORSOrigins   string `key:"CORS_ALLOWED_ORIGINS"`                    // Comma-separated origins of browser scoreboards allowed to call the device API
+}
+
+// GeoIPConfig locates the MaxMind GeoLite2 Country CSV files used to find the
+// country of clients when the proxy does not send CF-IPCountry. Leave
+// LocationsCSV empty to rely on the header alone.
+type GeoIPConfig struct {
+	LocationsCSV string `key:"GEOIP_LOCATIONS_CSV"` // GeoLite2-Country-Locations-en.csv
+	BlocksCSV    string `key:"GEOIP_BLOCKS_CSV"`    // Comma-separated GeoLite2-Country-Blocks-IPv4.csv and -IPv6.csv
 }
 
 // PathConfig holds configurable endpoint path prefixes
 // These can be changed to make endpoints less predictable to automated scanners
 type PathConfig struct {
-	OAuthPrefix  string `key:"OAUTH_PATH_PREFIX" default:"/oauth"`   // OAuth web flow path prefix
-	DevicePrefix string `key:"DEVICE_PATH_PREFIX" default:"/device"` // Device flow path prefix
-	APIPrefix    string `key:"API_PATH_PREFIX" default:"/api"`       // API endpoints path prefix
+	OAuthPrefix    string `key:"OAUTH_PATH_PREFIX" default:"/oauth"`         // OAuth web flow path prefix
+	DevicePrefix   string `key:"DEVICE_PATH_PREFIX" default:"/device"`       // Device flow path prefix
+	APIPrefix      string `key:"API_PATH_PREFIX" default:"/api"`             // API endpoints path prefix
+	AdminAPIPrefix string `key:"ADMIN_API_PATH_PREFIX" default:"/api/admin"` // Admin API endpoints path prefix
+}
+
+// AdminConfig holds admin UI configuration
+// The session cookie name can be changed so that multiple instances can share a domain
+type AdminConfig struct {
+	SessionCookieName      string `key:"ADMIN_SESSION_COOKIE_NAME" default:"osm_admin_session"` // Admin session cookie name
+	MaxAdhocPatrolsPerUser int    `key:"ADMIN_MAX_ADHOC_PATROLS" default:"20" min:"1"`          // ad-hoc teams each user may create
+}
+
+// FeatureConfig switches optional features on or off for this deployment
+type FeatureConfig struct {
+	List  string       `key:"FEATURES"` // comma-separated feature names to turn on, or "-name" to turn off
+	Flags FeatureFlags // parsed from List by Load
+}
+
+// Enabled reports whether feature is switched on.
+func (f *FeatureConfig) Enabled(feature Feature) bool {
+	return f.Flags.Enabled(feature)
 }
 
 // Config is the complete application configuration
@@ -78,7 +173,12 @@ type Config struct {
 	DeviceOAuth     DeviceOAuthConfig
 	RateLimit       RateLimitConfig
 	Cache           CacheConfig
+	ScoreUpdate     ScoreUpdateConfig
 	Paths           PathConfig
+	Admin           AdminConfig
+	Security        SecurityConfig
+	GeoIP           GeoIPConfig
+	Features        FeatureConfig
 }
 
 // MinimalConfig is the minimal configuration needed for database cleanup jobs
@@ -99,6 +199,44 @@ func Load() (*Config, error) {
 	cfg.Paths.OAuthPrefix = strings.TrimSuffix(cfg.Paths.OAuthPrefix, "/")
 	cfg.Paths.DevicePrefix = strings.TrimSuffix(cfg.Paths.DevicePrefix, "/")
 	cfg.Paths.APIPrefix = strings.TrimSuffix(cfg.Paths.APIPrefix, "/")
+	cfg.Paths.AdminAPIPrefix = strings.TrimSuffix(cfg.Paths.AdminAPIPrefix, "/")
+
+	if _, err := ParseTLSVersion(cfg.Security.TLSMinVersion); err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+	if (cfg.Security.TLSCertFile == "") != (cfg.Security.TLSKeyFile == "") {
+		return nil, fmt.Errorf("failed to load configuration: TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+
+	if err := cfg.Security.ValidateCORSOrigins(); err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	cfg.ExternalDomains.ExposedDomain = strings.TrimSuffix(cfg.ExternalDomains.ExposedDomain, "/")
+	if err := cfg.ExternalDomains.Validate(); err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	if secret := cfg.DeviceOAuth.TokenSigningSecret; secret != "" && len(secret) < minTokenSigningSecretLength {
+		return nil, fmt.Errorf("failed to load configuration: DEVICE_TOKEN_SIGNING_SECRET must be at least %d bytes", minTokenSigningSecretLength)
+	}
+
+	flags, err := ParseFeatures(cfg.Features.List)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+	cfg.Features.Flags = flags
+
+	tiers, err := ParseCacheTTLTiers(cfg.Cache.TTLTierList)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+	cfg.Cache.TTLTiers = tiers
+
+	// Demo mode must never run against production OSM
+	if cfg.Server.DemoMode && strings.Contains(strings.ToLower(cfg.ExternalDomains.OSMDomain), "onlinescoutmanager.co.uk") {
+		return nil, fmt.Errorf("failed to load configuration: DEMO_MODE cannot be used with the real OSM domain; set OSM_DOMAIN to %s/demo-osm", cfg.ExternalDomains.ExposedDomain)
+	}
 
 	// Set OSM redirect URI if not explicitly provided
 	if cfg.OAuth.OSMRedirectURI == "" {
@@ -108,6 +246,19 @@ func Load() (*Config, error) {
 	return cfg, nil
 }
 
+// ParseTLSVersion converts a TLS version string ("1.2" or "1.3") to its crypto/tls constant.
+// An empty string yields TLS 1.2.
+func ParseTLSVersion(version string) (uint16, error) {
+	switch version {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported TLS_MIN_VERSION %q (must be 1.2 or 1.3)", version)
+	}
+}
+
 // LoadMinimal loads only database and Redis configuration (for cleanup jobs)
 func LoadMinimal() (*MinimalConfig, error) {
 	cfg := &MinimalConfig{}
@@ -119,6 +270,91 @@ func LoadMinimal() (*MinimalConfig, error) {
 	return cfg, nil
 }
 
+// Validate checks that ExposedDomain is an http or https origin with no path,
+// and that each trusted forwarded host is a bare host name.
+func (e *ExternalDomainsConfig) Validate() error {
+	exposed, err := url.Parse(e.ExposedDomain)
+	if err != nil || (exposed.Scheme != "http" && exposed.Scheme != "https") || exposed.Host == "" {
+		return fmt.Errorf("EXPOSED_DOMAIN %q must be an http or https URL such as https://scores.example.com", e.ExposedDomain)
+	}
+	if exposed.Path != "" || exposed.RawQuery != "" || exposed.Fragment != "" {
+		return fmt.Errorf("EXPOSED_DOMAIN %q must not include a path", e.ExposedDomain)
+	}
+	for _, host := range e.ParseTrustedForwardedHosts() {
+		if strings.ContainsAny(host, "/?#@ ") {
+			return fmt.Errorf("TRUSTED_FORWARDED_HOSTS entry %q must be a host name, optionally with a port", host)
+		}
+	}
+	return nil
+}
+
+// ParseTrustedForwardedHosts parses the comma-separated list of trusted
+// forwarded hosts, lower-cased for comparison.
+func (e *ExternalDomainsConfig) ParseTrustedForwardedHosts() []string {
+	hosts := []string{}
+	for _, part := range strings.Split(e.TrustedForwardedHosts, ",") {
+		if trimmed := strings.ToLower(strings.TrimSpace(part)); trimmed != "" {
+			hosts = append(hosts, trimmed)
+		}
+	}
+	return hosts
+}
+
+// IsTrustedForwardedHost reports whether host is in TrustedForwardedHosts.
+func (e *ExternalDomainsConfig) IsTrustedForwardedHost(host string) bool {
+	host = strings.ToLower(host)
+	for _, trusted := range e.ParseTrustedForwardedHosts() {
+		if trusted == host {
+			return true
+		}
+	}
+	return false
+}
+
+// TLSEnabled reports whether the server terminates TLS itself rather than
+// relying on a tunnel or proxy in front of it.
+func (s *SecurityConfig) TLSEnabled() bool {
+	return s.TLSCertFile != "" && s.TLSKeyFile != ""
+}
+
+// ParseCORSOrigins parses the comma-separated list of allowed CORS origins.
+// Trailing slashes are dropped since browsers send origins without them.
+func (s *SecurityConfig) ParseCORSOrigins() []string {
+	origins := []string{}
+	for _, part := range strings.Split(s.CORSOrigins, ",") {
+		if trimmed := strings.TrimSuffix(strings.TrimSpace(part), "/"); trimmed != "" {
+			origins = append(origins, trimmed)
+		}
+	}
+	return origins
+}
+
+// ValidateCORSOrigins checks each allowed CORS origin is an explicit http or
+// https origin. A wildcard is refused so every scoreboard host is listed.
+func (s *SecurityConfig) ValidateCORSOrigins() error {
+	for _, origin := range s.ParseCORSOrigins() {
+		parsed, err := url.Parse(origin)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" || strings.Contains(parsed.Host, "*") {
+			return fmt.Errorf("CORS_ALLOWED_ORIGINS entry %q must be an http or https origin such as https://scoreboard.example.com", origin)
+		}
+		if parsed.Path != "" || parsed.RawQuery != "" || parsed.Fragment != "" || parsed.User != nil {
+			return fmt.Errorf("CORS_ALLOWED_ORIGINS entry %q must not include a path", origin)
+		}
+	}
+	return nil
+}
+
+// ParseBlocksCSV parses the comma-separated list of GeoIP blocks files
+func (g *GeoIPConfig) ParseBlocksCSV() []string {
+	paths := []string{}
+	for _, part := range strings.Split(g.BlocksCSV, ",") {
+		if trimmed := strings.TrimSpace(part); trimmed != "" {
+			paths = append(paths, trimmed)
+		}
+	}
+	return paths
+}
+
 // ParseClientIDs parses the comma-separated list of client IDs
 func (d *DeviceOAuthConfig) ParseClientIDs() []string {
 	if d.AllowedClientIDs == "" {
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Feature names an optional feature that a deployment can switch on or off
+// with FEATURES, rather than each feature adding its own setting.
+type Feature string
+
+const (
+	// FeatureAdhocTeams offers locally stored ad-hoc teams alongside OSM sections.
+	FeatureAdhocTeams Feature = "adhoc-teams"
+	// FeatureDeviceScoreWrites lets write-enabled devices change scores.
+	FeatureDeviceScoreWrites Feature = "device-score-writes"
+	// FeatureScoreBatches accepts score updates for several sections at once.
+	FeatureScoreBatches Feature = "score-batches"
+)
+
+// featureDefaults lists every known feature and whether it is on when FEATURES
+// does not mention it.
+var featureDefaults = map[Feature]bool{
+	FeatureAdhocTeams:        true,
+	FeatureDeviceScoreWrites: true,
+	FeatureScoreBatches:      true,
+}
+
+// FeatureFlags records the features FEATURES switched on (true) or off (false).
+// Features it does not mention keep their defaults, so a nil FeatureFlags has
+// every feature at its default.
+type FeatureFlags map[Feature]bool
+
+// Enabled reports whether feature is switched on.
+func (f FeatureFlags) Enabled(feature Feature) bool {
+	if enabled, ok := f[feature]; ok {
+		return enabled
+	}
+	return featureDefaults[feature]
+}
+
+// ParseFeatures parses a comma-separated list of feature names. A name turns
+// its feature on and a name prefixed with "-" turns it off, for example
+// "-adhoc-teams,score-batches". Unknown names are an error.
+func ParseFeatures(list string) (FeatureFlags, error) {
+	flags := FeatureFlags{}
+	for _, part := range strings.Split(list, ",") {
+		name := strings.ToLower(strings.TrimSpace(part))
+		if name == "" {
+			continue
+		}
+		enabled := !strings.HasPrefix(name, "-")
+		feature := Feature(strings.TrimPrefix(name, "-"))
+		if _, known := featureDefaults[feature]; !known {
+			return nil, fmt.Errorf("unknown feature %q in FEATURES (known features: %s)", feature, strings.Join(knownFeatures(), ", "))
+		}
+		flags[feature] = enabled
+	}
+	return flags, nil
+}
+
+// knownFeatures returns the names of every known feature in sorted order.
+func knownFeatures() []string {
+	names := make([]string, 0, len(featureDefaults))
+	for feature := range featureDefaults {
+		names = append(names, string(feature))
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,30 @@
+package config
+
+import "testing"
+
+func TestParseFeatures(t *testing.T) {
+	flags, err := ParseFeatures(" -adhoc-teams, Score-Batches ,")
+	if err != nil {
+		t.Fatalf("ParseFeatures failed: %v", err)
+	}
+	if flags.Enabled(FeatureAdhocTeams) {
+		t.Error("expected adhoc-teams to be turned off")
+	}
+	if !flags.Enabled(FeatureScoreBatches) {
+		t.Error("expected score-batches to be on")
+	}
+	if !flags.Enabled(FeatureDeviceScoreWrites) {
+		t.Error("expected an unmentioned feature to keep its default")
+	}
+
+	var unset FeatureFlags
+	if !unset.Enabled(FeatureAdhocTeams) {
+		t.Error("expected nil flags to use the defaults")
+	}
+}
+
+func TestParseFeatures_RejectsUnknownFeature(t *testing.T) {
+	if _, err := ParseFeatures("adhoc-teams,freeze-mode"); err == nil {
+		t.Error("expected an error for an unknown feature")
+	}
+}
@@ -2,17 +2,13 @@ package adhocpatrol
 
 import (
 	"errors"
-	"fmt"
 
 	"github.com/m0rjc/OsmDeviceAdapter/internal/db"
 	"gorm.io/gorm"
 )
 
-// MaxPatrolsPerUser is the maximum number of ad-hoc patrols a user can create.
-const MaxPatrolsPerUser = 20
-
-// ErrMaxPatrolsReached is returned when a user tries to create more than MaxPatrolsPerUser patrols.
-var ErrMaxPatrolsReached = fmt.Errorf("maximum of %d ad-hoc patrols reached", MaxPatrolsPerUser)
+// ErrMaxPatrolsReached is returned when a user already has the maximum number of patrols.
+var ErrMaxPatrolsReached = errors.New("maximum ad-hoc patrols reached")
 
 // ErrNotFound is returned when the requested patrol does not exist or does not belong to the user.
 var ErrNotFound = errors.New("ad-hoc patrol not found")
@@ -25,27 +21,47 @@ func ListByUser(conns *db.Connections, osmUserID int) ([]db.AdhocPatrol, error)
 }
 
 // Create creates a new ad-hoc patrol, assigning the next position.
-// Returns ErrMaxPatrolsReached if the user already has MaxPatrolsPerUser patrols.
-func Create(conns *db.Connections, patrol *db.AdhocPatrol) error {
-	// Count existing patrols for this user
-	var count int64
-	if err := conns.DB.Model(&db.AdhocPatrol{}).Where("osm_user_id = ?", patrol.OSMUserID).Count(&count).Error; err != nil {
-		return err
-	}
-	if count >= MaxPatrolsPerUser {
-		return ErrMaxPatrolsReached
-	}
+// Returns ErrMaxPatrolsReached, without writing anything, if the user already
+// has maxPatrols patrols.
+func Create(conns *db.Connections, patrol *db.AdhocPatrol, maxPatrols int) error {
+	return conns.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockUser(tx, patrol.OSMUserID); err != nil {
+			return err
+		}
 
-	// Assign next position
-	var maxPos *int
-	conns.DB.Model(&db.AdhocPatrol{}).Where("osm_user_id = ?", patrol.OSMUserID).Select("MAX(position)").Scan(&maxPos)
-	if maxPos != nil {
-		patrol.Position = *maxPos + 1
-	} else {
-		patrol.Position = 0
-	}
+		// Count existing patrols for this user
+		var count int64
+		if err := tx.Model(&db.AdhocPatrol{}).Where("osm_user_id = ?", patrol.OSMUserID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count >= int64(maxPatrols) {
+			return ErrMaxPatrolsReached
+		}
+
+		// Assign next position
+		var maxPos *int
+		if err := tx.Model(&db.AdhocPatrol{}).Where("osm_user_id = ?", patrol.OSMUserID).Select("MAX(position)").Scan(&maxPos).Error; err != nil {
+			return err
+		}
+		if maxPos != nil {
+			patrol.Position = *maxPos + 1
+		} else {
+			patrol.Position = 0
+		}
+
+		return tx.Create(patrol).Error
+	})
+}
 
-	return conns.DB.Create(patrol).Error
+// lockUser holds a lock on the user's ad-hoc patrols until tx ends, so that
+// concurrent creates cannot both pass the count check under READ COMMITTED.
+// Row locks would not do, as a user with no patrols has no rows to lock.
+// SQLite already serialises write transactions.
+func lockUser(tx *gorm.DB, osmUserID int) error {
+	if tx.Dialector.Name() != "postgres" {
+		return nil
+	}
+	return tx.Exec("SELECT pg_advisory_xact_lock(hashtext('adhoc_patrols'), ?::int)", osmUserID).Error
 }
 
 // Update updates the name and color of an ad-hoc patrol, with ownership check.
@@ -66,6 +82,23 @@ func Update(conns *db.Connections, id int64, osmUserID int, name string, color s
 	return nil
 }
 
+// UpdateColors sets the colors of several of a user's ad-hoc patrols in one
+// transaction, so either every color is applied or none is. Patrols that do not
+// exist or belong to another user are skipped.
+func UpdateColors(conns *db.Connections, osmUserID int, colors map[int64]string) error {
+	return conns.DB.Transaction(func(tx *gorm.DB) error {
+		for id, color := range colors {
+			err := tx.Model(&db.AdhocPatrol{}).
+				Where("id = ? AND osm_user_id = ?", id, osmUserID).
+				Update("color", color).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 // Delete deletes an ad-hoc patrol, with ownership check.
 // Returns ErrNotFound if the patrol does not exist or does not belong to the user.
 func Delete(conns *db.Connections, id int64, osmUserID int) error {
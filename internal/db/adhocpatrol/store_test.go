@@ -6,6 +6,9 @@ import (
 	"github.com/m0rjc/OsmDeviceAdapter/internal/db"
 )
 
+// testMaxPatrols matches the default ADMIN_MAX_ADHOC_PATROLS.
+const testMaxPatrols = 20
+
 func TestListByUser_Empty(t *testing.T) {
 	conns := db.SetupTestDB(t)
 
@@ -22,7 +25,7 @@ func TestCreate_AssignsPosition(t *testing.T) {
 	conns := db.SetupTestDB(t)
 
 	p1 := &db.AdhocPatrol{OSMUserID: 1, Name: "Team A", Color: "red"}
-	if err := Create(conns, p1); err != nil {
+	if err := Create(conns, p1, testMaxPatrols); err != nil {
 		t.Fatalf("create p1: %v", err)
 	}
 	if p1.Position != 0 {
@@ -30,7 +33,7 @@ func TestCreate_AssignsPosition(t *testing.T) {
 	}
 
 	p2 := &db.AdhocPatrol{OSMUserID: 1, Name: "Team B", Color: "blue"}
-	if err := Create(conns, p2); err != nil {
+	if err := Create(conns, p2, testMaxPatrols); err != nil {
 		t.Fatalf("create p2: %v", err)
 	}
 	if p2.Position != 1 {
@@ -41,31 +44,55 @@ func TestCreate_AssignsPosition(t *testing.T) {
 func TestCreate_MaxLimit(t *testing.T) {
 	conns := db.SetupTestDB(t)
 
-	for i := 0; i < MaxPatrolsPerUser; i++ {
+	for i := 0; i < testMaxPatrols; i++ {
 		p := &db.AdhocPatrol{OSMUserID: 1, Name: "Team"}
-		if err := Create(conns, p); err != nil {
+		if err := Create(conns, p, testMaxPatrols); err != nil {
 			t.Fatalf("create patrol %d: %v", i, err)
 		}
 	}
 
 	// 21st should fail
 	p := &db.AdhocPatrol{OSMUserID: 1, Name: "Too Many"}
-	err := Create(conns, p)
+	err := Create(conns, p, testMaxPatrols)
 	if err != ErrMaxPatrolsReached {
 		t.Errorf("expected ErrMaxPatrolsReached, got %v", err)
 	}
+	if p.ID != 0 {
+		t.Errorf("rejected patrol should not have been saved, got ID %d", p.ID)
+	}
+
+	patrols, err := ListByUser(conns, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(patrols) != testMaxPatrols {
+		t.Errorf("expected %d patrols after rejected create, got %d", testMaxPatrols, len(patrols))
+	}
+}
+
+func TestCreate_LimitIsConfigurable(t *testing.T) {
+	conns := db.SetupTestDB(t)
+
+	for i := 0; i < 2; i++ {
+		if err := Create(conns, &db.AdhocPatrol{OSMUserID: 1, Name: "Team"}, 2); err != nil {
+			t.Fatalf("create patrol %d: %v", i, err)
+		}
+	}
+	if err := Create(conns, &db.AdhocPatrol{OSMUserID: 1, Name: "Third"}, 2); err != ErrMaxPatrolsReached {
+		t.Errorf("expected ErrMaxPatrolsReached at a limit of 2, got %v", err)
+	}
 }
 
 func TestCreate_DifferentUsersIndependent(t *testing.T) {
 	conns := db.SetupTestDB(t)
 
 	p1 := &db.AdhocPatrol{OSMUserID: 1, Name: "User1 Team"}
-	if err := Create(conns, p1); err != nil {
+	if err := Create(conns, p1, testMaxPatrols); err != nil {
 		t.Fatalf("create: %v", err)
 	}
 
 	p2 := &db.AdhocPatrol{OSMUserID: 2, Name: "User2 Team"}
-	if err := Create(conns, p2); err != nil {
+	if err := Create(conns, p2, testMaxPatrols); err != nil {
 		t.Fatalf("create: %v", err)
 	}
 
@@ -87,7 +114,7 @@ func TestListByUser_OrderedByPosition(t *testing.T) {
 
 	for _, name := range []string{"Alpha", "Bravo", "Charlie"} {
 		p := &db.AdhocPatrol{OSMUserID: 1, Name: name}
-		if err := Create(conns, p); err != nil {
+		if err := Create(conns, p, testMaxPatrols); err != nil {
 			t.Fatalf("create %s: %v", name, err)
 		}
 	}
@@ -108,7 +135,7 @@ func TestUpdate_Success(t *testing.T) {
 	conns := db.SetupTestDB(t)
 
 	p := &db.AdhocPatrol{OSMUserID: 1, Name: "Old Name", Color: "red"}
-	Create(conns, p)
+	Create(conns, p, testMaxPatrols)
 
 	err := Update(conns, p.ID, 1, "New Name", "blue")
 	if err != nil {
@@ -125,7 +152,7 @@ func TestUpdate_WrongUser(t *testing.T) {
 	conns := db.SetupTestDB(t)
 
 	p := &db.AdhocPatrol{OSMUserID: 1, Name: "Team"}
-	Create(conns, p)
+	Create(conns, p, testMaxPatrols)
 
 	err := Update(conns, p.ID, 999, "Hacked", "red")
 	if err != ErrNotFound {
@@ -133,11 +160,33 @@ func TestUpdate_WrongUser(t *testing.T) {
 	}
 }
 
+func TestUpdateColors_SkipsOtherUsersPatrols(t *testing.T) {
+	conns := db.SetupTestDB(t)
+
+	mine := &db.AdhocPatrol{OSMUserID: 1, Name: "Mine", Color: "red"}
+	Create(conns, mine, testMaxPatrols)
+	theirs := &db.AdhocPatrol{OSMUserID: 2, Name: "Theirs", Color: "red"}
+	Create(conns, theirs, testMaxPatrols)
+
+	if err := UpdateColors(conns, 1, map[int64]string{mine.ID: "blue", theirs.ID: "green"}); err != nil {
+		t.Fatalf("update colors: %v", err)
+	}
+
+	found, _ := FindByIDAndUser(conns, mine.ID, 1)
+	if found.Name != "Mine" || found.Color != "blue" {
+		t.Errorf("own patrol after update: name=%q color=%q", found.Name, found.Color)
+	}
+	found, _ = FindByIDAndUser(conns, theirs.ID, 2)
+	if found.Color != "red" {
+		t.Errorf("expected another user's patrol to keep its color, got %q", found.Color)
+	}
+}
+
 func TestDelete_Success(t *testing.T) {
 	conns := db.SetupTestDB(t)
 
 	p := &db.AdhocPatrol{OSMUserID: 1, Name: "Team"}
-	Create(conns, p)
+	Create(conns, p, testMaxPatrols)
 
 	err := Delete(conns, p.ID, 1)
 	if err != nil {
@@ -154,7 +203,7 @@ func TestDelete_WrongUser(t *testing.T) {
 	conns := db.SetupTestDB(t)
 
 	p := &db.AdhocPatrol{OSMUserID: 1, Name: "Team"}
-	Create(conns, p)
+	Create(conns, p, testMaxPatrols)
 
 	err := Delete(conns, p.ID, 999)
 	if err != ErrNotFound {
@@ -172,7 +221,7 @@ func TestUpdateScore(t *testing.T) {
 	conns := db.SetupTestDB(t)
 
 	p := &db.AdhocPatrol{OSMUserID: 1, Name: "Team", Score: 10}
-	Create(conns, p)
+	Create(conns, p, testMaxPatrols)
 
 	err := UpdateScore(conns, p.ID, 1, 25)
 	if err != nil {
@@ -189,7 +238,7 @@ func TestUpdateScore_WrongUser(t *testing.T) {
 	conns := db.SetupTestDB(t)
 
 	p := &db.AdhocPatrol{OSMUserID: 1, Name: "Team", Score: 10}
-	Create(conns, p)
+	Create(conns, p, testMaxPatrols)
 
 	err := UpdateScore(conns, p.ID, 999, 100)
 	if err != ErrNotFound {
@@ -203,9 +252,9 @@ func TestResetAllScores(t *testing.T) {
 	p1 := &db.AdhocPatrol{OSMUserID: 1, Name: "Team A", Score: 10}
 	p2 := &db.AdhocPatrol{OSMUserID: 1, Name: "Team B", Score: 20}
 	p3 := &db.AdhocPatrol{OSMUserID: 2, Name: "Other User", Score: 30}
-	Create(conns, p1)
-	Create(conns, p2)
-	Create(conns, p3)
+	Create(conns, p1, testMaxPatrols)
+	Create(conns, p2, testMaxPatrols)
+	Create(conns, p3, testMaxPatrols)
 
 	err := ResetAllScores(conns, 1)
 	if err != nil {
@@ -2,11 +2,41 @@ package allowedclient
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/m0rjc/OsmDeviceAdapter/internal/db"
 	"gorm.io/gorm"
 )
 
+// ErrNotFound is returned when changing a client ID that does not exist.
+var ErrNotFound = errors.New("client ID not found")
+
+// Bounds on a client ID's device flow overrides. A code must last long enough
+// for someone to enter it, and devices must poll often enough to notice
+// authorization well before the code expires.
+const (
+	MinDeviceCodeExpirySeconds = 60
+	MaxDeviceCodeExpirySeconds = 1800
+	MinPollIntervalSeconds     = 1
+	MaxPollIntervalSeconds     = 60
+)
+
+// ValidateDeviceFlowOverrides checks that a client ID's device code expiry and
+// poll interval overrides are within bounds. Unset overrides are always valid.
+func ValidateDeviceFlowOverrides(client *db.AllowedClientID) error {
+	if expiry := client.DeviceCodeExpirySeconds; expiry != nil &&
+		(*expiry < MinDeviceCodeExpirySeconds || *expiry > MaxDeviceCodeExpirySeconds) {
+		return fmt.Errorf("device code expiry must be between %d and %d seconds, got %d",
+			MinDeviceCodeExpirySeconds, MaxDeviceCodeExpirySeconds, *expiry)
+	}
+	if interval := client.PollIntervalSeconds; interval != nil &&
+		(*interval < MinPollIntervalSeconds || *interval > MaxPollIntervalSeconds) {
+		return fmt.Errorf("poll interval must be between %d and %d seconds, got %d",
+			MinPollIntervalSeconds, MaxPollIntervalSeconds, *interval)
+	}
+	return nil
+}
+
 // IsAllowed checks if a client ID is in the database and enabled.
 // Returns (allowed bool, allowedClientID int, error).
 // If allowed is false, allowedClientID will be 0.
@@ -24,6 +54,9 @@ func IsAllowed(conns *db.Connections, clientID string) (bool, int, error) {
 
 // Create creates a new allowed client ID record
 func Create(conns *db.Connections, clientID *db.AllowedClientID) error {
+	if err := ValidateDeviceFlowOverrides(clientID); err != nil {
+		return err
+	}
 	return conns.DB.Create(clientID).Error
 }
 
@@ -40,11 +73,82 @@ func Find(conns *db.Connections, clientID string) (*db.AllowedClientID, error) {
 	return &record, nil
 }
 
-// UpdateEnabled updates the enabled status of a client ID
+// FindByID finds an allowed client ID by its surrogate primary key.
+// Returns nil if no record exists.
+func FindByID(conns *db.Connections, id int) (*db.AllowedClientID, error) {
+	var record db.AllowedClientID
+	err := conns.DB.Where("id = ?", id).First(&record).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &record, nil
+}
+
+// UpdateEnabled updates the enabled status of a client ID.
+// Returns ErrNotFound if the client ID does not exist.
 func UpdateEnabled(conns *db.Connections, clientID string, enabled bool) error {
+	result := conns.DB.Model(&db.AllowedClientID{}).
+		Where("client_id = ?", clientID).
+		Update("enabled", enabled)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Rotate replaces a client ID with a new one in place. The surrogate ID is
+// kept, so devices authorized through the old client ID keep working while new
+// authorizations must use the new one. Returns ErrNotFound if oldClientID does
+// not exist; a newClientID already in use fails the unique index.
+func Rotate(conns *db.Connections, oldClientID, newClientID string) error {
+	result := conns.DB.Model(&db.AllowedClientID{}).
+		Where("client_id = ?", oldClientID).
+		Update("client_id", newClientID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// UpdateDeviceFlowOverrides sets the device code expiry and poll interval for
+// devices using a client ID. Nil restores the service default. Returns
+// ErrNotFound if the client ID does not exist.
+func UpdateDeviceFlowOverrides(conns *db.Connections, clientID string, expirySeconds, pollIntervalSeconds *int) error {
+	if err := ValidateDeviceFlowOverrides(&db.AllowedClientID{
+		DeviceCodeExpirySeconds: expirySeconds,
+		PollIntervalSeconds:     pollIntervalSeconds,
+	}); err != nil {
+		return err
+	}
+	result := conns.DB.Model(&db.AllowedClientID{}).
+		Where("client_id = ?", clientID).
+		Updates(map[string]interface{}{
+			"device_code_expiry_seconds": expirySeconds,
+			"poll_interval_seconds":      pollIntervalSeconds,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// UpdateWriteEnabled updates whether devices authorized through a client ID may submit scores
+func UpdateWriteEnabled(conns *db.Connections, clientID string, writeEnabled bool) error {
 	return conns.DB.Model(&db.AllowedClientID{}).
 		Where("client_id = ?", clientID).
-		Update("enabled", enabled).Error
+		Update("write_enabled", writeEnabled).Error
 }
 
 // List returns all allowed client IDs (enabled and disabled)
@@ -0,0 +1,134 @@
+package allowedclient
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db"
+)
+
+func createClient(t *testing.T, conns *db.Connections, clientID string) *db.AllowedClientID {
+	t.Helper()
+	client := &db.AllowedClientID{ClientID: clientID, Comment: "Test client", ContactEmail: "test@example.com", Enabled: true}
+	if err := Create(conns, client); err != nil {
+		t.Fatalf("Create(%q) failed: %v", clientID, err)
+	}
+	return client
+}
+
+func TestCreate_RejectsDuplicateClientID(t *testing.T) {
+	conns := db.SetupTestDB(t)
+	createClient(t, conns, "scoreboard")
+
+	duplicate := &db.AllowedClientID{ClientID: "scoreboard", Comment: "Again", ContactEmail: "other@example.com"}
+	if err := Create(conns, duplicate); err == nil {
+		t.Error("expected an error creating a duplicate client ID")
+	}
+}
+
+func TestUpdateEnabled(t *testing.T) {
+	conns := db.SetupTestDB(t)
+	client := createClient(t, conns, "scoreboard")
+
+	if err := UpdateEnabled(conns, "scoreboard", false); err != nil {
+		t.Fatalf("UpdateEnabled failed: %v", err)
+	}
+	if allowed, _, err := IsAllowed(conns, "scoreboard"); err != nil || allowed {
+		t.Errorf("expected a disabled client to be refused, got %v (%v)", allowed, err)
+	}
+
+	if err := UpdateEnabled(conns, "scoreboard", true); err != nil {
+		t.Fatalf("UpdateEnabled failed: %v", err)
+	}
+	allowed, id, err := IsAllowed(conns, "scoreboard")
+	if err != nil || !allowed || id != client.ID {
+		t.Errorf("expected a re-enabled client to be allowed as ID %d, got %v, %d (%v)", client.ID, allowed, id, err)
+	}
+
+	if err := UpdateEnabled(conns, "missing", true); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an unknown client ID, got %v", err)
+	}
+}
+
+func TestRotate_KeepsSurrogateID(t *testing.T) {
+	conns := db.SetupTestDB(t)
+	client := createClient(t, conns, "old-id")
+	createClient(t, conns, "taken-id")
+
+	if err := Rotate(conns, "old-id", "new-id"); err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+	if old, err := Find(conns, "old-id"); err != nil || old != nil {
+		t.Errorf("expected the old client ID to be gone, got %+v (%v)", old, err)
+	}
+	rotated, err := FindByID(conns, client.ID)
+	if err != nil || rotated == nil || rotated.ClientID != "new-id" {
+		t.Fatalf("expected ID %d to carry the new client ID, got %+v (%v)", client.ID, rotated, err)
+	}
+	if rotated.Comment != "Test client" || !rotated.Enabled {
+		t.Errorf("expected the rest of the record to be kept, got %+v", rotated)
+	}
+
+	if err := Rotate(conns, "new-id", "taken-id"); err == nil {
+		t.Error("expected an error rotating onto a client ID already in use")
+	}
+	if err := Rotate(conns, "missing", "another-id"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound rotating an unknown client ID, got %v", err)
+	}
+}
+
+func TestList_IncludesDisabled(t *testing.T) {
+	conns := db.SetupTestDB(t)
+	createClient(t, conns, "first")
+	createClient(t, conns, "second")
+	if err := UpdateEnabled(conns, "second", false); err != nil {
+		t.Fatalf("UpdateEnabled failed: %v", err)
+	}
+
+	clients, err := List(conns)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(clients) != 2 {
+		t.Errorf("expected both clients, got %d", len(clients))
+	}
+}
+
+func TestUpdateDeviceFlowOverrides(t *testing.T) {
+	conns := db.SetupTestDB(t)
+	createClient(t, conns, "scoreboard")
+
+	expiry, interval := 900, 15
+	if err := UpdateDeviceFlowOverrides(conns, "scoreboard", &expiry, &interval); err != nil {
+		t.Fatalf("UpdateDeviceFlowOverrides failed: %v", err)
+	}
+	client, err := Find(conns, "scoreboard")
+	if err != nil || client == nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	if client.DeviceCodeExpirySeconds == nil || *client.DeviceCodeExpirySeconds != 900 ||
+		client.PollIntervalSeconds == nil || *client.PollIntervalSeconds != 15 {
+		t.Errorf("expected overrides 900s/15s, got %v/%v", client.DeviceCodeExpirySeconds, client.PollIntervalSeconds)
+	}
+
+	// Nil restores the defaults
+	if err := UpdateDeviceFlowOverrides(conns, "scoreboard", nil, nil); err != nil {
+		t.Fatalf("UpdateDeviceFlowOverrides failed: %v", err)
+	}
+	client, _ = Find(conns, "scoreboard")
+	if client.DeviceCodeExpirySeconds != nil || client.PollIntervalSeconds != nil {
+		t.Errorf("expected overrides cleared, got %v/%v", client.DeviceCodeExpirySeconds, client.PollIntervalSeconds)
+	}
+
+	tooFast := 0
+	if err := UpdateDeviceFlowOverrides(conns, "scoreboard", nil, &tooFast); err == nil {
+		t.Error("expected an error for a poll interval below the minimum")
+	}
+	tooLong := MaxDeviceCodeExpirySeconds + 1
+	if err := UpdateDeviceFlowOverrides(conns, "scoreboard", &tooLong, nil); err == nil {
+		t.Error("expected an error for a device code expiry above the maximum")
+	}
+	if err := UpdateDeviceFlowOverrides(conns, "missing", &expiry, nil); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an unknown client ID, got %v", err)
+	}
+}
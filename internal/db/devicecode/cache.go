@@ -0,0 +1,103 @@
+package devicecode
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db"
+)
+
+// authCacheKey is the Redis key holding an authorized device's record for
+// FindAuthorizedCached.
+func authCacheKey(deviceCode string) string {
+	return "device_auth:" + deviceCode
+}
+
+// authCacheEntry is what FindAuthorizedCached keeps in Redis. Credentials are
+// left out of the shared cache: the device access token is kept only as a hash
+// to check requests against, and OSM tokens stay in the database.
+type authCacheEntry struct {
+	Device          db.DeviceCode `json:"device"`
+	AccessTokenHash string        `json:"accessTokenHash"`
+}
+
+// FindAuthorizedCached returns the authorized device with deviceCode if it
+// holds accessToken, reading a copy cached in Redis for up to ttl before going
+// back to the database. fromCache reports whether the database was skipped;
+// a cached device carries no tokens, so callers needing its OSM tokens must
+// read them from the database. Returns nil if the device is not found, not
+// authorized or holds another token. Without Redis every call reads the
+// database.
+//
+// Every store function that changes a device drops its cached copy, so a
+// revoked or moved device is seen on its next request.
+func FindAuthorizedCached(ctx context.Context, conns *db.Connections, deviceCode, accessToken string, ttl time.Duration) (record *db.DeviceCode, fromCache bool, err error) {
+	tokenHash := hashAccessToken(accessToken)
+	if conns.Redis != nil {
+		if data, err := conns.Redis.Get(ctx, authCacheKey(deviceCode)).Bytes(); err == nil {
+			var cached authCacheEntry
+			if json.Unmarshal(data, &cached) == nil {
+				if subtle.ConstantTimeCompare([]byte(cached.AccessTokenHash), []byte(tokenHash)) != 1 {
+					return nil, false, nil
+				}
+				return &cached.Device, true, nil
+			}
+		}
+	}
+
+	record, err = FindByCode(conns, deviceCode)
+	if err != nil || record == nil || record.Status != "authorized" || record.DeviceAccessToken == nil {
+		return nil, false, err
+	}
+	if subtle.ConstantTimeCompare([]byte(*record.DeviceAccessToken), []byte(accessToken)) != 1 {
+		return nil, false, nil
+	}
+
+	if conns.Redis != nil {
+		entry := authCacheEntry{Device: *record, AccessTokenHash: tokenHash}
+		entry.Device.DeviceAccessToken = nil
+		entry.Device.OSMAccessToken = nil
+		entry.Device.OSMRefreshToken = nil
+		if data, err := json.Marshal(entry); err == nil {
+			if err := conns.Redis.Set(ctx, authCacheKey(deviceCode), data, ttl).Err(); err != nil {
+				slog.Warn("devicecode.auth_cache.set_failed",
+					"component", "devicecode",
+					"event", "auth_cache.set_error",
+					"device_code_hash", deviceCode[:min(8, len(deviceCode))],
+					"error", err,
+				)
+			}
+		}
+	}
+	return record, false, nil
+}
+
+func hashAccessToken(accessToken string) string {
+	sum := sha256.Sum256([]byte(accessToken))
+	return hex.EncodeToString(sum[:])
+}
+
+// Forget drops the cached copies of the given devices so their next request
+// reads the database.
+func Forget(conns *db.Connections, deviceCodes ...string) {
+	if conns.Redis == nil || len(deviceCodes) == 0 {
+		return
+	}
+	keys := make([]string, len(deviceCodes))
+	for i, deviceCode := range deviceCodes {
+		keys[i] = authCacheKey(deviceCode)
+	}
+	if err := conns.Redis.Del(context.Background(), keys...).Err(); err != nil {
+		slog.Error("devicecode.auth_cache.forget_failed",
+			"component", "devicecode",
+			"event", "auth_cache.forget_error",
+			"devices", len(deviceCodes),
+			"error", err,
+		)
+	}
+}
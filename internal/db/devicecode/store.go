@@ -2,6 +2,7 @@ package devicecode
 
 import (
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/m0rjc/OsmDeviceAdapter/internal/db"
@@ -42,9 +43,11 @@ func FindByUserCode(conns *db.Connections, userCode string) (*db.DeviceCode, err
 
 // UpdateStatus updates the status field of a device code
 func UpdateStatus(conns *db.Connections, deviceCode string, status string) error {
-	return conns.DB.Model(&db.DeviceCode{}).
+	err := conns.DB.Model(&db.DeviceCode{}).
 		Where("device_code = ?", deviceCode).
 		Update("status", status).Error
+	Forget(conns, deviceCode)
+	return err
 }
 
 // UpdateWithTokens updates a device code with OSM tokens, user ID, and expiry
@@ -55,10 +58,13 @@ func UpdateWithTokens(conns *db.Connections, deviceCode string, status string, a
 		"osm_refresh_token": refreshToken,
 		"osm_token_expiry":  tokenExpiry,
 		"osm_user_id":       userID,
+		"osm_authorized_at": time.Now(),
 	}
-	return conns.DB.Model(&db.DeviceCode{}).
+	err := conns.DB.Model(&db.DeviceCode{}).
 		Where("device_code = ?", deviceCode).
 		Updates(updates).Error
+	Forget(conns, deviceCode)
+	return err
 }
 
 // UpdateWithSection updates a device code with section ID, device access token, and status
@@ -68,9 +74,11 @@ func UpdateWithSection(conns *db.Connections, deviceCode string, status string,
 		"section_id":          sectionID,
 		"device_access_token": deviceAccessToken,
 	}
-	return conns.DB.Model(&db.DeviceCode{}).
+	err := conns.DB.Model(&db.DeviceCode{}).
 		Where("device_code = ?", deviceCode).
 		Updates(updates).Error
+	Forget(conns, deviceCode)
+	return err
 }
 
 // FindByAccessToken finds a device code by its OSM access token
@@ -110,16 +118,29 @@ func UpdateTokensOnly(conns *db.Connections, deviceCode string, accessToken stri
 		"osm_refresh_token": refreshToken,
 		"osm_token_expiry":  tokenExpiry,
 	}
-	return conns.DB.Model(&db.DeviceCode{}).
+	err := conns.DB.Model(&db.DeviceCode{}).
 		Where("device_code = ?", deviceCode).
 		Updates(updates).Error
+	Forget(conns, deviceCode)
+	return err
 }
 
 // DeleteExpired deletes expired device codes that were never fully authorized.
 // Authorized and revoked devices are not deleted here - they are handled by DeleteUnused
 // based on last_used_at timestamp instead.
 func DeleteExpired(conns *db.Connections) error {
-	return conns.DB.Where("expires_at < ? AND status NOT IN (?, ?)", time.Now(), "authorized", "revoked").Delete(&db.DeviceCode{}).Error
+	return whereExpired(conns.DB).Delete(&db.DeviceCode{}).Error
+}
+
+// CountExpired reports how many device codes DeleteExpired would delete.
+func CountExpired(conns *db.Connections) (int64, error) {
+	var count int64
+	err := whereExpired(conns.DB.Model(&db.DeviceCode{})).Count(&count).Error
+	return count, err
+}
+
+func whereExpired(tx *gorm.DB) *gorm.DB {
+	return tx.Where("expires_at < ? AND status NOT IN (?, ?)", time.Now(), "authorized", "revoked")
 }
 
 // UpdateTermInfo updates a device code with term information
@@ -130,9 +151,11 @@ func UpdateTermInfo(conns *db.Connections, deviceCode string, userID int, termID
 		"term_checked_at": termCheckedAt,
 		"term_end_date":   termEndDate,
 	}
-	return conns.DB.Model(&db.DeviceCode{}).
+	err := conns.DB.Model(&db.DeviceCode{}).
 		Where("device_code = ?", deviceCode).
 		Updates(updates).Error
+	Forget(conns, deviceCode)
+	return err
 }
 
 // UpdateLastUsed updates the last_used_at timestamp for a device
@@ -151,9 +174,11 @@ func Revoke(conns *db.Connections, deviceCode string) error {
 		"osm_refresh_token": nil,
 		"osm_token_expiry":  nil,
 	}
-	return conns.DB.Model(&db.DeviceCode{}).
+	err := conns.DB.Model(&db.DeviceCode{}).
 		Where("device_code = ?", deviceCode).
 		Updates(updates).Error
+	Forget(conns, deviceCode)
+	return err
 }
 
 // FindByUser returns all authorized device codes for a user, ordered by last used.
@@ -166,6 +191,7 @@ func FindByUser(conns *db.Connections, osmUserID int) ([]db.DeviceCode, error) {
 }
 
 // UpdateSectionID updates the section_id for a device code and clears term info.
+// Any further sections the device showed are dropped, leaving it primary-only.
 func UpdateSectionID(conns *db.Connections, deviceCodeStr string, sectionID int) error {
 	updates := map[string]interface{}{
 		"section_id":     sectionID,
@@ -173,9 +199,58 @@ func UpdateSectionID(conns *db.Connections, deviceCodeStr string, sectionID int)
 		"term_checked_at": nil,
 		"term_end_date":  nil,
 	}
-	return conns.DB.Model(&db.DeviceCode{}).
-		Where("device_code = ?", deviceCodeStr).
-		Updates(updates).Error
+	err := conns.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("device_code = ?", deviceCodeStr).Delete(&db.DeviceSection{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&db.DeviceCode{}).
+			Where("device_code = ?", deviceCodeStr).
+			Updates(updates).Error
+	})
+	Forget(conns, deviceCodeStr)
+	return err
+}
+
+// SetSections records the sections a device may show, in display order. The
+// first must be the device's section_id. A single section is stored as no rows,
+// the same as a device paired before devices could show several.
+func SetSections(conns *db.Connections, deviceCodeStr string, sectionIDs []int) error {
+	err := conns.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("device_code = ?", deviceCodeStr).Delete(&db.DeviceSection{}).Error; err != nil {
+			return err
+		}
+		if len(sectionIDs) < 2 {
+			return nil
+		}
+		rows := make([]db.DeviceSection, len(sectionIDs))
+		for i, sectionID := range sectionIDs {
+			rows[i] = db.DeviceSection{DeviceCode: deviceCodeStr, SectionID: sectionID, Position: i}
+		}
+		return tx.Create(&rows).Error
+	})
+	Forget(conns, deviceCodeStr)
+	return err
+}
+
+// ListSections returns the sections a device may show, primary first. A device
+// without further sections shows only its section_id; one with no section
+// shows none.
+func ListSections(conns *db.Connections, device *db.DeviceCode) ([]int, error) {
+	if device.SectionID == nil {
+		return nil, nil
+	}
+	var sectionIDs []int
+	err := conns.DB.Model(&db.DeviceSection{}).
+		Where("device_code = ?", device.DeviceCode).
+		Order("position").
+		Pluck("section_id", &sectionIDs).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(sectionIDs) == 0 {
+		return []int{*device.SectionID}, nil
+	}
+	return sectionIDs, nil
 }
 
 // ListBySectionID returns all authorized device codes for the given OSM section.
@@ -186,10 +261,41 @@ func ListBySectionID(conns *db.Connections, sectionID int) ([]db.DeviceCode, err
 	return records, err
 }
 
+// ListWithEndedTerm returns the authorized devices whose recorded term had
+// ended by now: devices that have not fetched scores since their term ended.
+func ListWithEndedTerm(conns *db.Connections, now time.Time) ([]db.DeviceCode, error) {
+	var records []db.DeviceCode
+	err := conns.DB.Where("status = ? AND section_id > 0 AND term_id IS NOT NULL AND term_end_date < ?", "authorized", now).
+		Find(&records).Error
+	return records, err
+}
+
 // DeleteUnused deletes device codes that haven't been used within the threshold duration
-// and are in authorized or revoked status (to avoid deleting pending authorization flows)
+// and are in authorized or revoked status (to avoid deleting pending authorization flows).
+// A non-positive threshold is rejected rather than deleting devices in active use.
 func DeleteUnused(conns *db.Connections, unusedThreshold time.Duration) error {
+	query, err := whereUnused(conns.DB, unusedThreshold)
+	if err != nil {
+		return err
+	}
+	return query.Delete(&db.DeviceCode{}).Error
+}
+
+// CountUnused reports how many device codes DeleteUnused would delete.
+func CountUnused(conns *db.Connections, unusedThreshold time.Duration) (int64, error) {
+	query, err := whereUnused(conns.DB.Model(&db.DeviceCode{}), unusedThreshold)
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	err = query.Count(&count).Error
+	return count, err
+}
+
+func whereUnused(tx *gorm.DB, unusedThreshold time.Duration) (*gorm.DB, error) {
+	if unusedThreshold <= 0 {
+		return nil, fmt.Errorf("unused threshold must be positive, got %v", unusedThreshold)
+	}
 	cutoffTime := time.Now().Add(-unusedThreshold)
-	return conns.DB.Where("status IN (?, ?) AND (last_used_at IS NULL OR last_used_at < ?)", "authorized", "revoked", cutoffTime).
-		Delete(&db.DeviceCode{}).Error
+	return tx.Where("status IN (?, ?) AND (last_used_at IS NULL OR last_used_at < ?)", "authorized", "revoked", cutoffTime), nil
 }
@@ -55,6 +55,15 @@ func TestDeleteExpired(t *testing.T) {
 		}
 	}
 
+	// The count matches what cleanup then deletes
+	count, err := CountExpired(conns)
+	if err != nil {
+		t.Fatalf("CountExpired failed: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected CountExpired to report 2, got %d", count)
+	}
+
 	// Run cleanup
 	if err := DeleteExpired(conns); err != nil {
 		t.Fatalf("DeleteExpired failed: %v", err)
@@ -96,6 +105,31 @@ func TestDeleteUnused(t *testing.T) {
 	conns := db.SetupTestDB(t)
 	now := time.Now()
 
+	t.Run("rejects a non-positive threshold", func(t *testing.T) {
+		active := &db.DeviceCode{
+			DeviceCode: "active-device",
+			UserCode:   "ACT1",
+			ClientID:   "test-client",
+			Status:     "authorized",
+			ExpiresAt:  now.Add(24 * time.Hour),
+			LastUsedAt: ptrTime(now),
+		}
+		if err := Create(conns, active); err != nil {
+			t.Fatalf("Failed to create active device: %v", err)
+		}
+		defer conns.DB.Delete(active)
+
+		if err := DeleteUnused(conns, -30*24*time.Hour); err == nil {
+			t.Error("expected a negative threshold to be rejected")
+		}
+		if _, err := CountUnused(conns, 0); err == nil {
+			t.Error("expected CountUnused to reject a zero threshold")
+		}
+		if found, _ := FindByCode(conns, "active-device"); found == nil {
+			t.Error("expected active device to be kept")
+		}
+	})
+
 	t.Run("deletes authorized devices not used for threshold period", func(t *testing.T) {
 		// Create an authorized device that hasn't been used in 60 days
 		oldDevice := &db.DeviceCode{
@@ -112,6 +146,9 @@ func TestDeleteUnused(t *testing.T) {
 
 		// Run cleanup with 30-day threshold
 		threshold := 30 * 24 * time.Hour
+		if count, err := CountUnused(conns, threshold); err != nil || count != 1 {
+			t.Errorf("Expected CountUnused to report 1, got %d (err %v)", count, err)
+		}
 		if err := DeleteUnused(conns, threshold); err != nil {
 			t.Fatalf("DeleteUnused failed: %v", err)
 		}
@@ -361,3 +398,42 @@ func TestUpdateLastUsed(t *testing.T) {
 func ptrTime(t time.Time) *time.Time {
 	return &t
 }
+
+func TestSections(t *testing.T) {
+	conns := db.SetupTestDB(t)
+	sectionID := 100
+	device := &db.DeviceCode{
+		DeviceCode: "multi-section",
+		UserCode:   "MULT",
+		ClientID:   "test-client",
+		Status:     "authorized",
+		ExpiresAt:  time.Now().Add(24 * time.Hour),
+		SectionID:  &sectionID,
+	}
+	if err := Create(conns, device); err != nil {
+		t.Fatalf("Failed to create device: %v", err)
+	}
+
+	sections, err := ListSections(conns, device)
+	if err != nil || len(sections) != 1 || sections[0] != 100 {
+		t.Fatalf("Expected a primary-only device to list [100], got %v (%v)", sections, err)
+	}
+
+	if err := SetSections(conns, device.DeviceCode, []int{100, 300, 200}); err != nil {
+		t.Fatalf("SetSections failed: %v", err)
+	}
+	sections, err = ListSections(conns, device)
+	if err != nil || len(sections) != 3 || sections[0] != 100 || sections[1] != 300 || sections[2] != 200 {
+		t.Fatalf("Expected [100 300 200] in order, got %v (%v)", sections, err)
+	}
+
+	// Moving the device to another section leaves it primary-only
+	if err := UpdateSectionID(conns, device.DeviceCode, 400); err != nil {
+		t.Fatalf("UpdateSectionID failed: %v", err)
+	}
+	moved, _ := FindByCode(conns, device.DeviceCode)
+	sections, err = ListSections(conns, moved)
+	if err != nil || len(sections) != 1 || sections[0] != 400 {
+		t.Errorf("Expected [400] after moving the device, got %v (%v)", sections, err)
+	}
+}
@@ -32,6 +32,13 @@ func DeleteExpired(conns *db.Connections) error {
 	return conns.DB.Where("expires_at < ?", time.Now()).Delete(&db.DeviceSession{}).Error
 }
 
+// CountExpired reports how many device sessions DeleteExpired would delete
+func CountExpired(conns *db.Connections) (int64, error) {
+	var count int64
+	err := conns.DB.Model(&db.DeviceSession{}).Where("expires_at < ?", time.Now()).Count(&count).Error
+	return count, err
+}
+
 // Delete deletes a device session by session ID
 func Delete(conns *db.Connections, sessionID string) error {
 	return conns.DB.Where("session_id = ?", sessionID).Delete(&db.DeviceSession{}).Error
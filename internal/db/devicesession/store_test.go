@@ -39,6 +39,15 @@ func TestDeleteExpired(t *testing.T) {
 		t.Fatalf("Failed to create valid session: %v", err)
 	}
 
+	// The count matches what cleanup then deletes
+	count, err := CountExpired(conns)
+	if err != nil {
+		t.Fatalf("CountExpired failed: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected CountExpired to report 2, got %d", count)
+	}
+
 	// Run cleanup
 	if err := DeleteExpired(conns); err != nil {
 		t.Fatalf("DeleteExpired failed: %v", err)
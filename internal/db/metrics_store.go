@@ -11,15 +11,51 @@ const (
 	osmUserBlockedPrefix = "osm:blocked:user:"
 )
 
-// MarkOsmServiceBlocked marks the OSM service as blocked.
+// SetOsmServiceBlockCooldown sets how long MarkOsmServiceBlocked pauses OSM calls.
+// Zero (the default) keeps the block until it is cleared manually.
+func (r *RedisClient) SetOsmServiceBlockCooldown(cooldown time.Duration) {
+	r.serviceBlockCooldown = cooldown
+}
+
+// MarkOsmServiceBlocked marks the OSM service as blocked, for the configured cooldown if one is set.
 func (r *RedisClient) MarkOsmServiceBlocked(ctx context.Context) {
+	if r.serviceBlockCooldown > 0 {
+		r.MarkOsmServiceBlockedUntil(ctx, time.Now().Add(r.serviceBlockCooldown))
+		return
+	}
 	r.Set(ctx, osmServiceBlockedKey, "1", 0)
 }
 
+// MarkOsmServiceBlockedUntil marks the OSM service as blocked until the specified time.
+// The shared flag clears itself when the block expires.
+func (r *RedisClient) MarkOsmServiceBlockedUntil(ctx context.Context, blockedUntil time.Time) {
+	ttl := time.Until(blockedUntil)
+	if ttl > 0 {
+		r.Set(ctx, osmServiceBlockedKey, blockedUntil.Format(time.RFC3339), ttl)
+	}
+}
+
 // IsOsmServiceBlocked returns true if the OSM service is marked as blocked.
 func (r *RedisClient) IsOsmServiceBlocked(ctx context.Context) bool {
+	blocked, _ := r.GetOsmServiceBlockEndTime(ctx)
+	return blocked
+}
+
+// GetOsmServiceBlockEndTime reports whether the OSM service is blocked and, for a
+// cooldown block, when it ends. The end time is zero for an indefinite block.
+func (r *RedisClient) GetOsmServiceBlockEndTime(ctx context.Context) (bool, time.Time) {
 	val, err := r.Get(ctx, osmServiceBlockedKey).Result()
-	return err == nil && val == "1"
+	if err != nil {
+		return false, time.Time{}
+	}
+	if val == "1" {
+		return true, time.Time{}
+	}
+	blockedUntil, err := time.Parse(time.RFC3339, val)
+	if err != nil {
+		return false, time.Time{}
+	}
+	return true, blockedUntil
 }
 
 // MarkUserTemporarilyBlocked marks a user as temporarily blocked until the specified time.
@@ -0,0 +1,117 @@
+package db
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration is a schema change applied once, in order, by RunMigrations.
+// Migrations must be additive and safe to repeat: a migration that fails part
+// way is retried on the next start.
+type Migration struct {
+	ID    string
+	Apply func(db *gorm.DB) error
+}
+
+// SchemaMigration records a migration that has been applied.
+type SchemaMigration struct {
+	ID        string    `gorm:"primaryKey;column:id;type:varchar(255)"`
+	AppliedAt time.Time `gorm:"column:applied_at;not null"`
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// migrations adds columns to existing tables ahead of AutoMigrate, which would
+// otherwise add them with locking DDL while the service is taking traffic.
+// Append new entries; never reorder or edit applied ones.
+var migrations = []Migration{
+	{ID: "0001_allowed_client_ids_write_enabled", Apply: addColumn("allowed_client_ids", "write_enabled", "BOOLEAN NOT NULL DEFAULT false")},
+	{ID: "0002_allowed_client_ids_max_points_per_update", Apply: addColumn("allowed_client_ids", "max_points_per_update", "INTEGER")},
+	{ID: "0003_score_audit_log_batch_id", Apply: addColumn("score_audit_log", "batch_id", "VARCHAR(36)")},
+	{ID: "0004_score_audit_log_batch_index", Apply: createIndex("idx_score_audit_batch", "score_audit_log", "batch_id")},
+	{ID: "0005_device_codes_osm_authorized_at", Apply: addColumn("device_codes", "osm_authorized_at", "TIMESTAMP")},
+	{ID: "0006_score_audit_log_source", Apply: addColumn("score_audit_log", "source", "VARCHAR(16) NOT NULL DEFAULT ''")},
+	{ID: "0007_score_audit_log_source_id", Apply: addColumn("score_audit_log", "source_id", "VARCHAR(8)")},
+	{ID: "0008_allowed_client_ids_require_proof_key", Apply: addColumn("allowed_client_ids", "require_proof_key", "BOOLEAN NOT NULL DEFAULT false")},
+	{ID: "0009_device_codes_code_challenge", Apply: addColumn("device_codes", "code_challenge", "VARCHAR(64)")},
+	{ID: "0010_score_audit_log_note", Apply: addColumn("score_audit_log", "note", "TEXT")},
+	{ID: "0011_score_audit_log_voided", Apply: addColumn("score_audit_log", "voided", "BOOLEAN NOT NULL DEFAULT false")},
+	{ID: "0012_allowed_client_ids_device_code_expiry", Apply: addColumn("allowed_client_ids", "device_code_expiry_seconds", "INTEGER")},
+	{ID: "0013_allowed_client_ids_poll_interval", Apply: addColumn("allowed_client_ids", "poll_interval_seconds", "INTEGER")},
+}
+
+// RunMigrations applies each migration not yet recorded in schema_migrations.
+// Run it before AutoMigrate, which then only creates missing tables.
+func RunMigrations(db *gorm.DB) error {
+	return runMigrations(db, migrations)
+}
+
+func runMigrations(db *gorm.DB, list []Migration) error {
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	var appliedIDs []string
+	if err := db.Model(&SchemaMigration{}).Pluck("id", &appliedIDs).Error; err != nil {
+		return fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	applied := make(map[string]bool, len(appliedIDs))
+	for _, id := range appliedIDs {
+		applied[id] = true
+	}
+
+	for _, m := range list {
+		if applied[m.ID] {
+			continue
+		}
+		if err := m.Apply(db); err != nil {
+			return fmt.Errorf("migration %s failed: %w", m.ID, err)
+		}
+		if err := db.Create(&SchemaMigration{ID: m.ID, AppliedAt: time.Now()}).Error; err != nil {
+			return fmt.Errorf("failed to record migration %s: %w", m.ID, err)
+		}
+		slog.Info("db.migration.applied",
+			"component", "db",
+			"event", "migration.applied",
+			"migration", m.ID,
+		)
+	}
+	return nil
+}
+
+// addColumn adds a column if it is missing. A table that does not exist yet is
+// left for AutoMigrate to create from the model.
+func addColumn(table, column, definition string) func(*gorm.DB) error {
+	return func(db *gorm.DB) error {
+		if !db.Migrator().HasTable(table) {
+			return nil
+		}
+		if db.Dialector.Name() == "postgres" {
+			return db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s", table, column, definition)).Error
+		}
+		if db.Migrator().HasColumn(table, column) {
+			return nil
+		}
+		return db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)).Error
+	}
+}
+
+// createIndex creates an index if it is missing. On Postgres the index is built
+// concurrently so writes to the table are not blocked meanwhile.
+func createIndex(name, table, columns string) func(*gorm.DB) error {
+	return func(db *gorm.DB) error {
+		if !db.Migrator().HasTable(table) {
+			return nil
+		}
+		concurrently := ""
+		if db.Dialector.Name() == "postgres" {
+			concurrently = "CONCURRENTLY "
+		}
+		return db.Exec(fmt.Sprintf("CREATE INDEX %sIF NOT EXISTS %s ON %s (%s)", concurrently, name, table, columns)).Error
+	}
+}
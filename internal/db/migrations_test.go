@@ -0,0 +1,89 @@
+package db
+
+import (
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupLegacyDB creates the tables touched by migrations as they were before
+// the migrated columns existed.
+func setupLegacyDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	database, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+	for _, stmt := range []string{
+		`CREATE TABLE allowed_client_ids (id INTEGER PRIMARY KEY AUTOINCREMENT, client_id VARCHAR(255) NOT NULL UNIQUE, comment TEXT NOT NULL, contact_email VARCHAR(255) NOT NULL, enabled BOOLEAN NOT NULL DEFAULT true)`,
+		`CREATE TABLE device_codes (device_code VARCHAR(255) PRIMARY KEY, user_code VARCHAR(255) NOT NULL, client_id VARCHAR(255) NOT NULL, expires_at TIMESTAMP NOT NULL)`,
+		`CREATE TABLE score_audit_log (id INTEGER PRIMARY KEY AUTOINCREMENT, osm_user_id INTEGER NOT NULL, section_id INTEGER NOT NULL, patrol_id VARCHAR(255) NOT NULL)`,
+	} {
+		if err := database.Exec(stmt).Error; err != nil {
+			t.Fatalf("Failed to create legacy table: %v", err)
+		}
+	}
+	return database
+}
+
+func TestRunMigrations_Idempotent(t *testing.T) {
+	database := setupLegacyDB(t)
+
+	for run := 1; run <= 2; run++ {
+		if err := RunMigrations(database); err != nil {
+			t.Fatalf("run %d: RunMigrations failed: %v", run, err)
+		}
+	}
+
+	var count int64
+	database.Model(&SchemaMigration{}).Count(&count)
+	if count != int64(len(migrations)) {
+		t.Errorf("Expected %d recorded migrations, got %d", len(migrations), count)
+	}
+
+	migrator := database.Migrator()
+	for table, columns := range map[string][]string{
+		"allowed_client_ids": {"write_enabled", "max_points_per_update", "require_proof_key"},
+		"device_codes":       {"osm_authorized_at", "code_challenge"},
+		"score_audit_log":    {"batch_id", "source", "source_id", "note", "voided"},
+	} {
+		for _, column := range columns {
+			if !migrator.HasColumn(table, column) {
+				t.Errorf("Expected column %s.%s to exist", table, column)
+			}
+		}
+	}
+	if !migrator.HasIndex("score_audit_log", "idx_score_audit_batch") {
+		t.Error("Expected index idx_score_audit_batch to exist")
+	}
+
+	// A migration whose record was lost is re-applied without error
+	database.Where("id = ?", migrations[0].ID).Delete(&SchemaMigration{})
+	if err := RunMigrations(database); err != nil {
+		t.Fatalf("re-applying a migration failed: %v", err)
+	}
+
+	// AutoMigrate still runs cleanly afterwards
+	if err := AutoMigrate(database); err != nil {
+		t.Fatalf("AutoMigrate after migrations failed: %v", err)
+	}
+}
+
+func TestRunMigrations_FreshDatabase(t *testing.T) {
+	database, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+
+	// With no tables yet, migrations are recorded and AutoMigrate creates everything
+	if err := RunMigrations(database); err != nil {
+		t.Fatalf("RunMigrations failed: %v", err)
+	}
+	if err := AutoMigrate(database); err != nil {
+		t.Fatalf("AutoMigrate failed: %v", err)
+	}
+	if !database.Migrator().HasColumn("device_codes", "code_challenge") {
+		t.Error("Expected AutoMigrate to create device_codes with code_challenge")
+	}
+}
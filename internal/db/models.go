@@ -39,6 +39,7 @@ type DeviceCode struct {
 	// - "awaiting_section": authorized but user needs to select a section
 	// - "authorized": fully authorized and ready for API access
 	// - "denied": user explicitly denied authorization
+	// - "abandoned": user signed in to OSM but never selected a section
 	// - "revoked": OSM access was revoked by user (token refresh failed with 401)
 	Status string `gorm:"column:status;type:varchar(50);default:'pending'"`
 
@@ -81,6 +82,14 @@ type DeviceCode struct {
 	// Extracted from OSM API response and used for cache invalidation.
 	TermEndDate *time.Time `gorm:"column:term_end_date;index:idx_device_codes_term_end_date"`
 
+	// CodeChallenge is the S256 challenge sent by the device at authorize time,
+	// if any. The token endpoint only issues a token with the matching verifier.
+	CodeChallenge *string `gorm:"column:code_challenge;type:varchar(64)"`
+
+	// OSMAuthorizedAt is when the user signed in to OSM for this code, moving
+	// it to "awaiting_section". The section selection timeout runs from here.
+	OSMAuthorizedAt *time.Time `gorm:"column:osm_authorized_at"`
+
 	// DeviceRequestIP is the client IP at device code generation time.
 	// Captured from CF-Connecting-IP for security auditing.
 	DeviceRequestIP *string `gorm:"column:device_request_ip;type:varchar(255)"`
@@ -99,12 +108,29 @@ type DeviceCode struct {
 	// DeviceSessions are temporary web sessions used during the OAuth flow.
 	// These are automatically deleted when the device code is deleted.
 	DeviceSessions []DeviceSession `gorm:"foreignKey:DeviceCode;constraint:OnDelete:CASCADE"`
+
+	// Sections lists every section a multi-section device may show. Devices
+	// with no rows show only SectionID, which is always the first listed.
+	Sections []DeviceSection `gorm:"foreignKey:DeviceCode;constraint:OnDelete:CASCADE"`
 }
 
 func (DeviceCode) TableName() string {
 	return "device_codes"
 }
 
+// DeviceSection is one of the sections a device may show, for scoreboards that
+// cycle between sections. The primary section, the device's SectionID, is at
+// position 0.
+type DeviceSection struct {
+	DeviceCode string `gorm:"primaryKey;column:device_code;type:varchar(255)"`
+	SectionID  int    `gorm:"primaryKey;column:section_id"`
+	Position   int    `gorm:"column:position;not null"`
+}
+
+func (DeviceSection) TableName() string {
+	return "device_sections"
+}
+
 // DeviceSession represents a temporary web session during the OAuth device flow.
 // These sessions connect the web-based OAuth callback to the device authorization
 // being processed, expiring after 15 minutes.
@@ -151,6 +177,27 @@ type AllowedClientID struct {
 	// Set to false to temporarily disable a client without deleting the record.
 	Enabled bool `gorm:"column:enabled;not null;default:true;index:idx_allowed_client_ids_enabled"`
 
+	// WriteEnabled allows devices authorized through this client to submit score
+	// changes (e.g. scoreboards with award buttons). Devices are read-only otherwise.
+	WriteEnabled bool `gorm:"column:write_enabled;not null;default:false"`
+
+	// MaxPointsPerUpdate caps the points a write-enabled device may award or remove
+	// for a patrol in one submission. Nil uses the service default.
+	MaxPointsPerUpdate *int `gorm:"column:max_points_per_update"`
+
+	// RequireProofKey makes devices prove at token retrieval that they started
+	// the flow, by sending the verifier for a code challenge given at authorize
+	// time. This stops a captured device code being used by another party.
+	RequireProofKey bool `gorm:"column:require_proof_key;not null;default:false"`
+
+	// DeviceCodeExpirySeconds overrides DEVICE_CODE_EXPIRY for devices using this
+	// client, e.g. longer for kiosks set up by hand. Nil uses the service default.
+	DeviceCodeExpirySeconds *int `gorm:"column:device_code_expiry_seconds"`
+
+	// PollIntervalSeconds overrides DEVICE_POLL_INTERVAL for devices using this
+	// client, e.g. longer for battery-powered devices. Nil uses the service default.
+	PollIntervalSeconds *int `gorm:"column:poll_interval_seconds"`
+
 	// CreatedAt is when this client ID was added to the system.
 	CreatedAt time.Time `gorm:"column:created_at;default:CURRENT_TIMESTAMP"`
 
@@ -228,14 +275,131 @@ type ScoreAuditLog struct {
 	// PointsAdded is the delta (can be negative)
 	PointsAdded int `gorm:"column:points_added;not null"`
 
+	// BatchID groups changes submitted together across sections (nil for single-section updates)
+	BatchID *string `gorm:"column:batch_id;type:varchar(36);index:idx_score_audit_batch"`
+
+	// Source is where the change was made: ScoreSourceAdmin or ScoreSourceDevice.
+	// Empty for changes recorded before sources were tracked.
+	Source string `gorm:"column:source;type:varchar(16);not null;default:''"`
+
+	// SourceID is the first 8 characters of the admin session ID or device code
+	// that made the change, enough to tell sessions and devices apart without
+	// storing a credential.
+	SourceID *string `gorm:"column:source_id;type:varchar(8)"`
+
+	// Note is a free-text annotation added by the user who made the change
+	Note *string `gorm:"column:note;type:text"`
+
+	// Voided flags a change as a mistake. The entry is kept as recorded but is
+	// left out of audit summaries.
+	Voided bool `gorm:"column:voided;not null;default:false"`
+
 	// CreatedAt is when the change was made
 	CreatedAt time.Time `gorm:"column:created_at;default:CURRENT_TIMESTAMP;index:idx_score_audit_created"`
 }
 
+// Values for ScoreAuditLog.Source.
+const (
+	ScoreSourceAdmin  = "admin"
+	ScoreSourceDevice = "device"
+)
+
 func (ScoreAuditLog) TableName() string {
 	return "score_audit_log"
 }
 
+// ScoreAuditSummary keeps the daily totals of audit log entries that the
+// cleanup job has compacted, so aggregate points can still be reconciled after
+// the individual entries are deleted.
+type ScoreAuditSummary struct {
+	// SectionID is the section containing the patrol
+	SectionID int `gorm:"primaryKey;column:section_id;not null"`
+
+	// PatrolID is the patrol whose score was changed
+	PatrolID string `gorm:"primaryKey;column:patrol_id;type:varchar(255);not null"`
+
+	// Day is the start of the UTC day the changes were made
+	Day time.Time `gorm:"primaryKey;column:day;not null"`
+
+	// PatrolName is the most recent patrol name among the compacted entries
+	PatrolName string `gorm:"column:patrol_name;type:varchar(255);not null"`
+
+	// PointsAdded is the sum of the compacted deltas
+	PointsAdded int `gorm:"column:points_added;not null"`
+
+	// Changes is the number of compacted entries
+	Changes int `gorm:"column:changes;not null"`
+
+	// UpdatedAt is when entries were last compacted into this row
+	UpdatedAt time.Time `gorm:"column:updated_at;default:CURRENT_TIMESTAMP"`
+}
+
+func (ScoreAuditSummary) TableName() string {
+	return "score_audit_summary"
+}
+
+// ScoreArchive is one patrol's final score for a section's term, kept after
+// the section moves on to its next term so past results can still be viewed.
+type ScoreArchive struct {
+	// SectionID is the section containing the patrol
+	SectionID int `gorm:"primaryKey;column:section_id;not null"`
+
+	// TermID is the OSM term the score was for
+	TermID int `gorm:"primaryKey;column:term_id;not null"`
+
+	// PatrolID is the patrol the score belongs to
+	PatrolID string `gorm:"primaryKey;column:patrol_id;type:varchar(255);not null"`
+
+	// PatrolName is the patrol's name when the score was read
+	PatrolName string `gorm:"column:patrol_name;type:varchar(255);not null"`
+
+	// Score is the patrol's last known score in the term
+	Score int `gorm:"column:score;not null"`
+
+	// Position keeps the patrols in the order OSM listed them
+	Position int `gorm:"column:position;not null"`
+
+	// ScoresAt is when the scores were read from OSM
+	ScoresAt time.Time `gorm:"column:scores_at;not null"`
+
+	// ArchivedAt is when the term's scores were archived
+	ArchivedAt time.Time `gorm:"column:archived_at;not null"`
+}
+
+func (ScoreArchive) TableName() string {
+	return "score_archives"
+}
+
+// ScoreBatch tracks the outcome of one batch of score updates, so a batch can
+// report its status while it runs and after patrols fail. Successful changes
+// are also recorded in the audit log under the same batch ID.
+type ScoreBatch struct {
+	// BatchID is the UUID handed to the client that submitted the batch
+	BatchID string `gorm:"primaryKey;column:batch_id;type:varchar(36)"`
+
+	// OSMUserID is the user who submitted the batch
+	OSMUserID int `gorm:"column:osm_user_id;not null;index:idx_score_batches_user"`
+
+	// Applied, Failed and Pending count the batch's patrol updates by outcome.
+	// Pending updates were sent to OSM but their result is unknown.
+	Applied int `gorm:"column:applied;not null;default:0"`
+	Failed  int `gorm:"column:failed;not null;default:0"`
+	Pending int `gorm:"column:pending;not null;default:0"`
+
+	// LastError is the most recent error reported for the batch
+	LastError *string `gorm:"column:last_error;type:text"`
+
+	// CreatedAt is when the batch was submitted
+	CreatedAt time.Time `gorm:"column:created_at;default:CURRENT_TIMESTAMP;index:idx_score_batches_created"`
+
+	// CompletedAt is when every section of the batch finished, nil while running
+	CompletedAt *time.Time `gorm:"column:completed_at"`
+}
+
+func (ScoreBatch) TableName() string {
+	return "score_batches"
+}
+
 // SectionSettings stores user-configurable settings for a section.
 // Settings are scoped per OSM user + section combination.
 type SectionSettings struct {
@@ -300,7 +464,7 @@ func (AdhocPatrol) TableName() string {
 }
 
 func AutoMigrate(db *gorm.DB) error {
-	return db.AutoMigrate(&DeviceCode{}, &DeviceSession{}, &AllowedClientID{}, &WebSession{}, &ScoreAuditLog{}, &SectionSettings{}, &AdhocPatrol{})
+	return db.AutoMigrate(&DeviceCode{}, &DeviceSession{}, &DeviceSection{}, &AllowedClientID{}, &WebSession{}, &ScoreAuditLog{}, &ScoreAuditSummary{}, &SectionSettings{}, &AdhocPatrol{}, &ScoreArchive{}, &ScoreBatch{})
 }
 
 // User returns the OSM user associated with this Device, or nil if this
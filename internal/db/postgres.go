@@ -28,6 +28,11 @@ func NewPostgresConnection(databaseURL string) (*gorm.DB, error) {
 	sqlDB.SetMaxOpenConns(25)
 	sqlDB.SetMaxIdleConns(5)
 
+	// Apply additive schema changes first so AutoMigrate finds nothing to alter
+	if err := RunMigrations(db); err != nil {
+		return nil, fmt.Errorf("migration failed: %w", err)
+	}
+
 	// Run auto-migrations
 	if err := AutoMigrate(db); err != nil {
 		return nil, fmt.Errorf("auto-migration failed: %w", err)
@@ -14,6 +14,8 @@ import (
 type RedisClient struct {
 	client    *redis.Client
 	keyPrefix string
+	// serviceBlockCooldown is how long an OSM service block lasts (0 = until cleared manually)
+	serviceBlockCooldown time.Duration
 }
 
 func NewRedisClient(redisURL string, keyPrefix string) (*RedisClient, error) {
@@ -161,6 +163,21 @@ func (r *RedisClient) SetNX(ctx context.Context, key string, value interface{},
 	return r.client.SetNX(ctx, r.prefixKey(key), value, expiration)
 }
 
+// SAdd adds members to a set with the configured key prefix
+func (r *RedisClient) SAdd(ctx context.Context, key string, members ...interface{}) *redis.IntCmd {
+	return r.client.SAdd(ctx, r.prefixKey(key), members...)
+}
+
+// SIsMember reports whether member is in a set with the configured key prefix
+func (r *RedisClient) SIsMember(ctx context.Context, key string, member interface{}) *redis.BoolCmd {
+	return r.client.SIsMember(ctx, r.prefixKey(key), member)
+}
+
+// Expire sets a key's time to live with the configured key prefix
+func (r *RedisClient) Expire(ctx context.Context, key string, expiration time.Duration) *redis.BoolCmd {
+	return r.client.Expire(ctx, r.prefixKey(key), expiration)
+}
+
 // Publish publishes a message to a Redis pub/sub channel.
 // Channel names are prefixed the same as keys so that Redis ACL rules apply consistently.
 func (r *RedisClient) Publish(ctx context.Context, channel string, msg any) error {
@@ -0,0 +1,95 @@
+// Package scorearchive keeps sections' final patrol scores for terms that
+// have ended.
+package scorearchive
+
+import (
+	"time"
+
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Term is one archived term's scores for a section.
+type Term struct {
+	TermID     int
+	ScoresAt   time.Time
+	ArchivedAt time.Time
+	Patrols    []types.PatrolScore
+}
+
+// Save archives a section's scores for a term, read from OSM at scoresAt.
+// Scores read later replace an earlier archive of the term, so the archive
+// ends up holding the last scores seen. Returns whether the scores were saved.
+func Save(conns *db.Connections, sectionID, termID int, scoresAt time.Time, patrols []types.PatrolScore) (bool, error) {
+	if len(patrols) == 0 {
+		return false, nil
+	}
+	now := time.Now()
+	rows := make([]db.ScoreArchive, len(patrols))
+	for i, patrol := range patrols {
+		rows[i] = db.ScoreArchive{
+			SectionID:  sectionID,
+			TermID:     termID,
+			PatrolID:   patrol.ID,
+			PatrolName: patrol.Name,
+			Score:      patrol.Score,
+			Position:   i,
+			ScoresAt:   scoresAt,
+			ArchivedAt: now,
+		}
+	}
+
+	var saved bool
+	err := conns.DB.Transaction(func(tx *gorm.DB) error {
+		term := tx.Where("section_id = ? AND term_id = ?", sectionID, termID)
+		var newer int64
+		if err := term.Session(&gorm.Session{}).Model(&db.ScoreArchive{}).
+			Where("scores_at >= ?", scoresAt).
+			Count(&newer).Error; err != nil {
+			return err
+		}
+		if newer > 0 {
+			return nil
+		}
+		if err := term.Session(&gorm.Session{}).Delete(&db.ScoreArchive{}).Error; err != nil {
+			return err
+		}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows)
+		saved = result.RowsAffected > 0
+		return result.Error
+	})
+	return saved, err
+}
+
+// Exists reports whether a section's term has been archived.
+func Exists(conns *db.Connections, sectionID, termID int) (bool, error) {
+	var count int64
+	err := conns.DB.Model(&db.ScoreArchive{}).
+		Where("section_id = ? AND term_id = ?", sectionID, termID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// ListBySection returns a section's archived terms, most recently archived
+// first, with patrols in the order OSM listed them.
+func ListBySection(conns *db.Connections, sectionID int) ([]Term, error) {
+	var rows []db.ScoreArchive
+	err := conns.DB.Where("section_id = ?", sectionID).
+		Order("archived_at DESC, term_id DESC, position").
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var terms []Term
+	for _, row := range rows {
+		if len(terms) == 0 || terms[len(terms)-1].TermID != row.TermID {
+			terms = append(terms, Term{TermID: row.TermID, ScoresAt: row.ScoresAt, ArchivedAt: row.ArchivedAt})
+		}
+		term := &terms[len(terms)-1]
+		term.Patrols = append(term.Patrols, types.PatrolScore{ID: row.PatrolID, Name: row.PatrolName, Score: row.Score})
+	}
+	return terms, nil
+}
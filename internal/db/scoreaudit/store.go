@@ -1,11 +1,18 @@
 package scoreaudit
 
 import (
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/m0rjc/OsmDeviceAdapter/internal/db"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ErrNotFound is returned when the requested audit entry does not exist or does not belong to the user.
+var ErrNotFound = errors.New("audit entry not found")
+
 // Create creates a new score audit log entry
 func Create(conns *db.Connections, log *db.ScoreAuditLog) error {
 	return conns.DB.Create(log).Error
@@ -19,8 +26,210 @@ func CreateBatch(conns *db.Connections, logs []db.ScoreAuditLog) error {
 	return conns.DB.Create(&logs).Error
 }
 
-// DeleteExpired deletes audit log entries older than the retention period
+// DeleteExpired deletes audit log entries older than the retention period.
+// A non-positive retention is rejected rather than deleting recent entries.
 func DeleteExpired(conns *db.Connections, retention time.Duration) error {
+	if retention <= 0 {
+		return fmt.Errorf("audit retention must be positive, got %v", retention)
+	}
 	cutoff := time.Now().Add(-retention)
 	return conns.DB.Where("created_at < ?", cutoff).Delete(&db.ScoreAuditLog{}).Error
 }
+
+// CountExpired reports how many audit log entries DeleteExpired or
+// CompactExpired would remove.
+func CountExpired(conns *db.Connections, retention time.Duration) (int64, error) {
+	if retention <= 0 {
+		return 0, fmt.Errorf("audit retention must be positive, got %v", retention)
+	}
+	var count int64
+	err := conns.DB.Model(&db.ScoreAuditLog{}).Where("created_at < ?", time.Now().Add(-retention)).Count(&count).Error
+	return count, err
+}
+
+// CompactExpired folds audit log entries older than the retention period into
+// per-patrol daily rows in score_audit_summary, then deletes them. Voided
+// entries are deleted without being counted. Rows for a day already partly
+// compacted are added to, so the job can run at any time of day.
+func CompactExpired(conns *db.Connections, retention time.Duration) error {
+	if retention <= 0 {
+		return fmt.Errorf("audit retention must be positive, got %v", retention)
+	}
+	cutoff := time.Now().Add(-retention)
+
+	return conns.DB.Transaction(func(tx *gorm.DB) error {
+		type summaryKey struct {
+			sectionID int
+			patrolID  string
+			day       time.Time
+		}
+		summaries := make(map[summaryKey]*db.ScoreAuditSummary)
+
+		var batch []db.ScoreAuditLog
+		result := tx.Where("created_at < ? AND voided = ?", cutoff, false).
+			Order("id").
+			FindInBatches(&batch, 1000, func(_ *gorm.DB, _ int) error {
+				for _, entry := range batch {
+					day := entry.CreatedAt.UTC().Truncate(24 * time.Hour)
+					key := summaryKey{entry.SectionID, entry.PatrolID, day}
+					summary, ok := summaries[key]
+					if !ok {
+						summary = &db.ScoreAuditSummary{SectionID: entry.SectionID, PatrolID: entry.PatrolID, Day: day}
+						summaries[key] = summary
+					}
+					summary.PatrolName = entry.PatrolName
+					summary.PointsAdded += entry.PointsAdded
+					summary.Changes++
+				}
+				return nil
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		for _, summary := range summaries {
+			summary.UpdatedAt = time.Now()
+			err := tx.Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "section_id"}, {Name: "patrol_id"}, {Name: "day"}},
+				DoUpdates: clause.Assignments(map[string]interface{}{
+					"patrol_name":  gorm.Expr("excluded.patrol_name"),
+					"points_added": gorm.Expr("score_audit_summary.points_added + excluded.points_added"),
+					"changes":      gorm.Expr("score_audit_summary.changes + excluded.changes"),
+					"updated_at":   gorm.Expr("excluded.updated_at"),
+				}),
+			}).Create(summary).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return tx.Where("created_at < ?", cutoff).Delete(&db.ScoreAuditLog{}).Error
+	})
+}
+
+// FindByBatchID returns the audit entries recorded for a batch, ordered by
+// section and then oldest first.
+func FindByBatchID(conns *db.Connections, batchID string) ([]db.ScoreAuditLog, error) {
+	var entries []db.ScoreAuditLog
+	err := conns.DB.Where("batch_id = ?", batchID).Order("section_id, id").Find(&entries).Error
+	return entries, err
+}
+
+// LatestPatrolNames returns the most recently audited name for each of the given
+// patrols in a section. Patrols with no audit history are omitted.
+func LatestPatrolNames(conns *db.Connections, sectionID int, patrolIDs []string) (map[string]string, error) {
+	names := make(map[string]string)
+	if len(patrolIDs) == 0 {
+		return names, nil
+	}
+
+	latestIDs := conns.DB.Model(&db.ScoreAuditLog{}).
+		Select("MAX(id)").
+		Where("section_id = ? AND patrol_id IN ?", sectionID, patrolIDs).
+		Group("patrol_id")
+
+	var logs []db.ScoreAuditLog
+	if err := conns.DB.Where("id IN (?)", latestIDs).Find(&logs).Error; err != nil {
+		return nil, err
+	}
+	for _, log := range logs {
+		names[log.PatrolID] = log.PatrolName
+	}
+	return names, nil
+}
+
+// Cursor marks a position in a section's audit history, newest first: the
+// entry at CreatedAt with ID. Entries are ordered by CreatedAt and then ID, so
+// entries sharing a timestamp are neither skipped nor repeated across pages.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        int64
+}
+
+// ListBySection returns up to limit audit entries for a section, newest first,
+// starting after before (nil for the newest). osmUserID limits the entries to
+// one user's changes; 0 returns every user's.
+func ListBySection(conns *db.Connections, sectionID, osmUserID, limit int, before *Cursor) ([]db.ScoreAuditLog, error) {
+	query := conns.DB.Where("section_id = ?", sectionID)
+	if osmUserID != 0 {
+		query = query.Where("osm_user_id = ?", osmUserID)
+	}
+	if before != nil {
+		query = query.Where("created_at < ? OR (created_at = ? AND id < ?)", before.CreatedAt, before.CreatedAt, before.ID)
+	}
+
+	var entries []db.ScoreAuditLog
+	err := query.Order("created_at DESC, id DESC").Limit(limit).Find(&entries).Error
+	return entries, err
+}
+
+// SummaryFilter selects the audit entries to summarize.
+type SummaryFilter struct {
+	SectionID int
+	OSMUserID int       // 0 for all users
+	From      time.Time // inclusive; zero for no lower bound
+	To        time.Time // exclusive; zero for no upper bound
+}
+
+// PatrolTotal is the net points one user added to one patrol.
+type PatrolTotal struct {
+	OSMUserID   int
+	PatrolID    string
+	PointsAdded int
+	Changes     int
+}
+
+// Summarize totals the points added per user and patrol for entries matching
+// filter, ordered by user then patrol. Voided entries are not counted.
+func Summarize(conns *db.Connections, filter SummaryFilter) ([]PatrolTotal, error) {
+	query := conns.DB.Model(&db.ScoreAuditLog{}).
+		Select("osm_user_id, patrol_id, SUM(points_added) AS points_added, COUNT(*) AS changes").
+		Where("section_id = ? AND voided = ?", filter.SectionID, false)
+	if filter.OSMUserID != 0 {
+		query = query.Where("osm_user_id = ?", filter.OSMUserID)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at < ?", filter.To)
+	}
+
+	var totals []PatrolTotal
+	err := query.Group("osm_user_id, patrol_id").
+		Order("osm_user_id, patrol_id").
+		Scan(&totals).Error
+	return totals, err
+}
+
+// Annotate sets the note and/or voided flag on an audit entry belonging to the
+// user, leaving the recorded change itself untouched. Nil arguments are left
+// unchanged. Returns ErrNotFound if the entry does not exist or belongs to
+// another user.
+func Annotate(conns *db.Connections, id int64, osmUserID int, note *string, voided *bool) (*db.ScoreAuditLog, error) {
+	updates := map[string]interface{}{}
+	if note != nil {
+		updates["note"] = *note
+	}
+	if voided != nil {
+		updates["voided"] = *voided
+	}
+
+	var entry db.ScoreAuditLog
+	err := conns.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND osm_user_id = ?", id, osmUserID).First(&entry).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrNotFound
+			}
+			return err
+		}
+		if len(updates) == 0 {
+			return nil
+		}
+		return tx.Model(&entry).Updates(updates).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
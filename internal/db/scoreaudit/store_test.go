@@ -0,0 +1,257 @@
+package scoreaudit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db"
+)
+
+func TestSummarize(t *testing.T) {
+	conns := db.SetupTestDB(t)
+
+	now := time.Now()
+	entry := func(userID, sectionID int, patrolID string, points int, at time.Time) db.ScoreAuditLog {
+		return db.ScoreAuditLog{
+			OSMUserID:   userID,
+			SectionID:   sectionID,
+			PatrolID:    patrolID,
+			PatrolName:  "Patrol " + patrolID,
+			PointsAdded: points,
+			CreatedAt:   at,
+		}
+	}
+	if err := CreateBatch(conns, []db.ScoreAuditLog{
+		entry(1, 100, "a", 5, now.Add(-3*time.Hour)),
+		entry(1, 100, "a", 10, now.Add(-2*time.Hour)),
+		entry(1, 100, "a", -3, now.Add(-1*time.Hour)),
+		entry(1, 100, "b", 4, now.Add(-1*time.Hour)),
+		entry(2, 100, "a", 7, now.Add(-1*time.Hour)),
+		entry(1, 100, "a", 50, now.Add(-48*time.Hour)), // before the range
+		entry(1, 200, "a", 99, now.Add(-1*time.Hour)),  // other section
+	}); err != nil {
+		t.Fatalf("CreateBatch failed: %v", err)
+	}
+
+	totals, err := Summarize(conns, SummaryFilter{SectionID: 100, From: now.Add(-24 * time.Hour)})
+	if err != nil {
+		t.Fatalf("Summarize failed: %v", err)
+	}
+
+	want := []PatrolTotal{
+		{OSMUserID: 1, PatrolID: "a", PointsAdded: 12, Changes: 3},
+		{OSMUserID: 1, PatrolID: "b", PointsAdded: 4, Changes: 1},
+		{OSMUserID: 2, PatrolID: "a", PointsAdded: 7, Changes: 1},
+	}
+	if len(totals) != len(want) {
+		t.Fatalf("expected %d totals, got %+v", len(want), totals)
+	}
+	for i := range want {
+		if totals[i] != want[i] {
+			t.Errorf("total %d: expected %+v, got %+v", i, want[i], totals[i])
+		}
+	}
+
+	// Restricting to one user
+	totals, err = Summarize(conns, SummaryFilter{SectionID: 100, OSMUserID: 2})
+	if err != nil {
+		t.Fatalf("Summarize failed: %v", err)
+	}
+	if len(totals) != 1 || totals[0].PointsAdded != 7 {
+		t.Errorf("expected only user 2's 7 points, got %+v", totals)
+	}
+}
+
+func TestAnnotate_VoidPreservesOriginalValues(t *testing.T) {
+	conns := db.SetupTestDB(t)
+
+	original := db.ScoreAuditLog{
+		OSMUserID:     1,
+		SectionID:     100,
+		PatrolID:      "a",
+		PatrolName:    "Eagles",
+		PreviousScore: 10,
+		NewScore:      60,
+		PointsAdded:   50,
+		Source:        db.ScoreSourceAdmin,
+	}
+	if err := Create(conns, &original); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	note := "Meant 5, not 50"
+	voided := true
+	if _, err := Annotate(conns, original.ID, 2, &note, &voided); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound for another user, got %v", err)
+	}
+
+	updated, err := Annotate(conns, original.ID, 1, &note, &voided)
+	if err != nil {
+		t.Fatalf("Annotate failed: %v", err)
+	}
+	if !updated.Voided || updated.Note == nil || *updated.Note != note {
+		t.Errorf("expected voided entry with note, got voided=%v note=%v", updated.Voided, updated.Note)
+	}
+
+	var stored db.ScoreAuditLog
+	if err := conns.DB.First(&stored, original.ID).Error; err != nil {
+		t.Fatalf("failed to reload entry: %v", err)
+	}
+	if !stored.Voided || stored.Note == nil || *stored.Note != note {
+		t.Errorf("expected stored entry to be voided with note, got voided=%v note=%v", stored.Voided, stored.Note)
+	}
+	if stored.PreviousScore != 10 || stored.NewScore != 60 || stored.PointsAdded != 50 ||
+		stored.PatrolName != "Eagles" || stored.Source != db.ScoreSourceAdmin {
+		t.Errorf("expected original values to be preserved, got %+v", stored)
+	}
+
+	totals, err := Summarize(conns, SummaryFilter{SectionID: 100})
+	if err != nil {
+		t.Fatalf("Summarize failed: %v", err)
+	}
+	if len(totals) != 0 {
+		t.Errorf("expected voided entry to be left out of the summary, got %+v", totals)
+	}
+}
+
+func TestDeleteExpired_RespectsRetention(t *testing.T) {
+	conns := db.SetupTestDB(t)
+
+	now := time.Now()
+	if err := CreateBatch(conns, []db.ScoreAuditLog{
+		{OSMUserID: 1, SectionID: 100, PatrolID: "old", PatrolName: "Old", CreatedAt: now.Add(-15 * 24 * time.Hour)},
+		{OSMUserID: 1, SectionID: 100, PatrolID: "recent", PatrolName: "Recent", CreatedAt: now.Add(-13 * 24 * time.Hour)},
+	}); err != nil {
+		t.Fatalf("CreateBatch failed: %v", err)
+	}
+
+	for _, retention := range []time.Duration{0, -14 * 24 * time.Hour} {
+		if err := DeleteExpired(conns, retention); err == nil {
+			t.Errorf("expected retention %v to be rejected", retention)
+		}
+		if _, err := CountExpired(conns, retention); err == nil {
+			t.Errorf("expected CountExpired to reject retention %v", retention)
+		}
+	}
+
+	if count, err := CountExpired(conns, 14*24*time.Hour); err != nil || count != 1 {
+		t.Errorf("expected CountExpired to report 1, got %d (err %v)", count, err)
+	}
+
+	if err := DeleteExpired(conns, 14*24*time.Hour); err != nil {
+		t.Fatalf("DeleteExpired failed: %v", err)
+	}
+
+	var remaining []db.ScoreAuditLog
+	conns.DB.Find(&remaining)
+	if len(remaining) != 1 || remaining[0].PatrolID != "recent" {
+		t.Errorf("expected only the entry inside the retention period to remain, got %+v", remaining)
+	}
+}
+
+func TestCompactExpired_PreservesTotalsPerPatrol(t *testing.T) {
+	conns := db.SetupTestDB(t)
+
+	day := time.Now().UTC().AddDate(0, 0, -20).Truncate(24 * time.Hour)
+	entry := func(patrolID string, points int, at time.Time, voided bool) db.ScoreAuditLog {
+		return db.ScoreAuditLog{OSMUserID: 1, SectionID: 100, PatrolID: patrolID, PatrolName: "Patrol " + patrolID, PointsAdded: points, CreatedAt: at, Voided: voided}
+	}
+
+	// A first run compacts part of a day; a later run adds the rest to the same row
+	if err := CreateBatch(conns, []db.ScoreAuditLog{
+		entry("a", 5, day.Add(9*time.Hour), false),
+		entry("a", -2, day.Add(10*time.Hour), false),
+		entry("b", 7, day.Add(10*time.Hour), false),
+		entry("a", 100, day.Add(11*time.Hour), true),
+	}); err != nil {
+		t.Fatalf("CreateBatch failed: %v", err)
+	}
+	if err := CompactExpired(conns, 14*24*time.Hour); err != nil {
+		t.Fatalf("CompactExpired failed: %v", err)
+	}
+	if err := CreateBatch(conns, []db.ScoreAuditLog{
+		entry("a", 4, day.Add(20*time.Hour), false),
+		entry("a", 9, time.Now(), false), // inside the retention period
+	}); err != nil {
+		t.Fatalf("CreateBatch failed: %v", err)
+	}
+	if err := CompactExpired(conns, 14*24*time.Hour); err != nil {
+		t.Fatalf("CompactExpired failed: %v", err)
+	}
+
+	var summaries []db.ScoreAuditSummary
+	conns.DB.Order("patrol_id").Find(&summaries)
+	if len(summaries) != 2 {
+		t.Fatalf("expected one summary row per patrol, got %+v", summaries)
+	}
+	if summaries[0].PatrolID != "a" || summaries[0].PointsAdded != 7 || summaries[0].Changes != 3 || !summaries[0].Day.Equal(day) {
+		t.Errorf("expected patrol a to total 7 points over 3 changes on %v, got %+v", day, summaries[0])
+	}
+	if summaries[1].PatrolID != "b" || summaries[1].PointsAdded != 7 || summaries[1].Changes != 1 {
+		t.Errorf("expected patrol b to total 7 points over 1 change, got %+v", summaries[1])
+	}
+
+	var remaining []db.ScoreAuditLog
+	conns.DB.Find(&remaining)
+	if len(remaining) != 1 || remaining[0].PointsAdded != 9 {
+		t.Errorf("expected only the recent entry to remain, got %+v", remaining)
+	}
+}
+
+func TestListBySection_PagesNewestFirst(t *testing.T) {
+	conns := db.SetupTestDB(t)
+
+	// Two entries share a timestamp, so the cursor must break the tie on ID
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+	at := []time.Time{base, base.Add(time.Minute), base.Add(time.Minute), base.Add(2 * time.Minute), base.Add(3 * time.Minute)}
+	logs := make([]db.ScoreAuditLog, 0, len(at)+1)
+	for i, createdAt := range at {
+		logs = append(logs, db.ScoreAuditLog{OSMUserID: 1 + i%2, SectionID: 100, PatrolID: "a", PatrolName: "Eagles", PointsAdded: i + 1, CreatedAt: createdAt})
+	}
+	logs = append(logs, db.ScoreAuditLog{OSMUserID: 1, SectionID: 200, PatrolID: "a", PatrolName: "Other", PointsAdded: 99, CreatedAt: base})
+	if err := CreateBatch(conns, logs); err != nil {
+		t.Fatalf("CreateBatch failed: %v", err)
+	}
+
+	// Page through two at a time; entries come out in reverse order of creation
+	var points []int
+	var before *Cursor
+	for page := 0; ; page++ {
+		entries, err := ListBySection(conns, 100, 0, 2, before)
+		if err != nil {
+			t.Fatalf("ListBySection failed: %v", err)
+		}
+		if len(entries) == 0 {
+			if page != 3 {
+				t.Errorf("expected the history to run out on page 3, got page %d", page)
+			}
+			break
+		}
+		for _, entry := range entries {
+			points = append(points, entry.PointsAdded)
+		}
+		last := entries[len(entries)-1]
+		before = &Cursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+	want := []int{5, 4, 3, 2, 1}
+	if len(points) != len(want) {
+		t.Fatalf("expected entries %v, got %v", want, points)
+	}
+	for i := range want {
+		if points[i] != want[i] {
+			t.Errorf("position %d: expected entry %d, got %d", i, want[i], points[i])
+		}
+	}
+
+	// Restricting to one user
+	entries, err := ListBySection(conns, 100, 2, 10, nil)
+	if err != nil || len(entries) != 2 || entries[0].PointsAdded != 4 || entries[1].PointsAdded != 2 {
+		t.Errorf("expected user 2's entries 4 and 2, got %+v (%v)", entries, err)
+	}
+
+	// A section with no history
+	entries, err = ListBySection(conns, 300, 0, 10, nil)
+	if err != nil || len(entries) != 0 {
+		t.Errorf("expected no entries, got %+v (%v)", entries, err)
+	}
+}
@@ -0,0 +1,77 @@
+// Package scorebatch tracks the outcome of batches of score updates, including
+// the patrols that failed or are still pending, which the audit log does not
+// record.
+package scorebatch
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db"
+	"gorm.io/gorm"
+)
+
+// Outcome counts the patrol updates from one part of a batch by result.
+type Outcome struct {
+	Applied int
+	Failed  int
+	Pending int
+	// LastError is the last error among the updates, empty if none failed
+	LastError string
+}
+
+// Create records a new, running batch submitted by osmUserID.
+func Create(conns *db.Connections, batchID string, osmUserID int) error {
+	return conns.DB.Create(&db.ScoreBatch{BatchID: batchID, OSMUserID: osmUserID, CreatedAt: time.Now()}).Error
+}
+
+// Record adds outcome to the batch's counts. Sections of a batch finish
+// concurrently, so the counts are incremented in place.
+func Record(conns *db.Connections, batchID string, outcome Outcome) error {
+	updates := map[string]interface{}{
+		"applied": gorm.Expr("applied + ?", outcome.Applied),
+		"failed":  gorm.Expr("failed + ?", outcome.Failed),
+		"pending": gorm.Expr("pending + ?", outcome.Pending),
+	}
+	if outcome.LastError != "" {
+		updates["last_error"] = outcome.LastError
+	}
+	return conns.DB.Model(&db.ScoreBatch{}).Where("batch_id = ?", batchID).Updates(updates).Error
+}
+
+// Complete marks the batch as finished.
+func Complete(conns *db.Connections, batchID string) error {
+	return conns.DB.Model(&db.ScoreBatch{}).Where("batch_id = ?", batchID).Update("completed_at", time.Now()).Error
+}
+
+// Find returns the batch, or nil if it does not exist.
+func Find(conns *db.Connections, batchID string) (*db.ScoreBatch, error) {
+	var batch db.ScoreBatch
+	err := conns.DB.Where("batch_id = ?", batchID).First(&batch).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// DeleteExpired deletes batches submitted before the retention period.
+func DeleteExpired(conns *db.Connections, retention time.Duration) error {
+	if retention <= 0 {
+		return fmt.Errorf("batch retention must be positive, got %v", retention)
+	}
+	return conns.DB.Where("created_at < ?", time.Now().Add(-retention)).Delete(&db.ScoreBatch{}).Error
+}
+
+// CountExpired reports how many batches DeleteExpired would remove.
+func CountExpired(conns *db.Connections, retention time.Duration) (int64, error) {
+	if retention <= 0 {
+		return 0, fmt.Errorf("batch retention must be positive, got %v", retention)
+	}
+	var count int64
+	err := conns.DB.Model(&db.ScoreBatch{}).Where("created_at < ?", time.Now().Add(-retention)).Count(&count).Error
+	return count, err
+}
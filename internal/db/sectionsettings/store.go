@@ -10,6 +10,16 @@ import (
 // SettingsJSON represents the JSON structure stored in the settings column
 type SettingsJSON struct {
 	PatrolColors map[string]string `json:"patrolColors,omitempty"`
+	PatrolIcons  map[string]string `json:"patrolIcons,omitempty"`
+	Layout       string            `json:"layout,omitempty"`
+	// PointsStep is the increment leaders usually award points in, e.g. 5.
+	// When EnforcePointsStep is set, score changes must be multiples of it.
+	PointsStep        int  `json:"pointsStep,omitempty"`
+	EnforcePointsStep bool `json:"enforcePointsStep,omitempty"`
+	// MinScore, when set, is the lowest score an update may leave a patrol with.
+	MinScore *int `json:"minScore,omitempty"`
+	// HideBelowScore, when set, hides patrols scoring less than it from devices.
+	HideBelowScore *int `json:"hideBelowScore,omitempty"`
 }
 
 // Get retrieves section settings for a user+section combination.
@@ -36,6 +46,7 @@ func GetParsed(conns *db.Connections, osmUserID, sectionID int) (*SettingsJSON,
 
 	parsed := &SettingsJSON{
 		PatrolColors: make(map[string]string),
+		PatrolIcons:  make(map[string]string),
 	}
 
 	if settings == nil || len(settings.Settings) == 0 {
@@ -46,10 +57,13 @@ func GetParsed(conns *db.Connections, osmUserID, sectionID int) (*SettingsJSON,
 		return nil, err
 	}
 
-	// Ensure map is initialized even if JSON had null
+	// Ensure maps are initialized even if JSON had null
 	if parsed.PatrolColors == nil {
 		parsed.PatrolColors = make(map[string]string)
 	}
+	if parsed.PatrolIcons == nil {
+		parsed.PatrolIcons = make(map[string]string)
+	}
 
 	return parsed, nil
 }
@@ -66,14 +80,68 @@ func Upsert(conns *db.Connections, settings *db.SectionSettings) error {
 // UpsertPatrolColors updates only the patrol colors portion of settings.
 // Creates the record if it doesn't exist.
 func UpsertPatrolColors(conns *db.Connections, osmUserID, sectionID int, patrolColors map[string]string) error {
-	// Get existing settings to preserve other fields
+	return upsertParsed(conns, osmUserID, sectionID, func(settings *SettingsJSON) {
+		settings.PatrolColors = patrolColors
+	})
+}
+
+// UpsertPatrolIcons updates only the patrol icons portion of settings.
+// Creates the record if it doesn't exist.
+func UpsertPatrolIcons(conns *db.Connections, osmUserID, sectionID int, patrolIcons map[string]string) error {
+	return upsertParsed(conns, osmUserID, sectionID, func(settings *SettingsJSON) {
+		settings.PatrolIcons = patrolIcons
+	})
+}
+
+// UpsertLayout updates only the display layout portion of settings.
+// Creates the record if it doesn't exist.
+func UpsertLayout(conns *db.Connections, osmUserID, sectionID int, layout string) error {
+	return upsertParsed(conns, osmUserID, sectionID, func(settings *SettingsJSON) {
+		settings.Layout = layout
+	})
+}
+
+// UpsertPointsStep updates only the points step portion of settings.
+// Creates the record if it doesn't exist.
+func UpsertPointsStep(conns *db.Connections, osmUserID, sectionID int, step int, enforce bool) error {
+	return upsertParsed(conns, osmUserID, sectionID, func(settings *SettingsJSON) {
+		settings.PointsStep = step
+		settings.EnforcePointsStep = enforce
+	})
+}
+
+// UpsertMinScore updates only the minimum score portion of settings. Nil
+// removes the floor. Creates the record if it doesn't exist.
+func UpsertMinScore(conns *db.Connections, osmUserID, sectionID int, minScore *int) error {
+	return upsertParsed(conns, osmUserID, sectionID, func(settings *SettingsJSON) {
+		settings.MinScore = minScore
+	})
+}
+
+// UpsertHideBelowScore updates only the device hiding threshold portion of
+// settings. Nil shows every patrol. Creates the record if it doesn't exist.
+func UpsertHideBelowScore(conns *db.Connections, osmUserID, sectionID int, threshold *int) error {
+	return upsertParsed(conns, osmUserID, sectionID, func(settings *SettingsJSON) {
+		settings.HideBelowScore = threshold
+	})
+}
+
+// Update applies update to the existing parsed settings and writes them back
+// in one statement, so several fields change together or not at all.
+// Creates the record if it doesn't exist.
+func Update(conns *db.Connections, osmUserID, sectionID int, update func(*SettingsJSON)) error {
+	return upsertParsed(conns, osmUserID, sectionID, update)
+}
+
+// upsertParsed applies update to the existing parsed settings, preserving other
+// fields, and writes the result back.
+func upsertParsed(conns *db.Connections, osmUserID, sectionID int, update func(*SettingsJSON)) error {
 	existing, err := GetParsed(conns, osmUserID, sectionID)
 	if err != nil {
 		return err
 	}
 
-	// Update patrol colors
-	existing.PatrolColors = patrolColors
+	update(existing)
 
 	// Serialize back to JSON
 	settingsBytes, err := json.Marshal(existing)
@@ -0,0 +1,44 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const userCodeFailuresPrefix = "user_code:failures:"
+
+// recordFailureScript increments a failure counter, starting its expiry on the
+// first failure so the count covers a fixed window.
+var recordFailureScript = redis.NewScript(`
+	local current = redis.call('INCR', KEYS[1])
+	if current == 1 then
+		redis.call('EXPIRE', KEYS[1], ARGV[1])
+	end
+	return current
+`)
+
+// RecordUserCodeFailure counts a failed attempt to use userCode and returns the
+// number of failures in the window starting at the first. Safe to call from
+// any number of replicas at once.
+func (r *RedisClient) RecordUserCodeFailure(ctx context.Context, userCode string, window time.Duration) (int64, error) {
+	key := r.prefixKey(userCodeFailuresPrefix + userCode)
+	count, err := recordFailureScript.Run(ctx, r.client, []string{key}, int64(window.Seconds())).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("failed to record user code failure: %w", err)
+	}
+	return count, nil
+}
+
+// UserCodeFailures returns the number of failed attempts recorded for userCode
+// in the current window.
+func (r *RedisClient) UserCodeFailures(ctx context.Context, userCode string) (int64, error) {
+	count, err := r.Get(ctx, userCodeFailuresPrefix+userCode).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return count, err
+}
@@ -0,0 +1,246 @@
+// Package userdata gathers and erases everything stored about one OSM user,
+// for answering data subject access and erasure requests.
+package userdata
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db/devicecode"
+	"gorm.io/gorm"
+)
+
+// Export is every record held for one OSM user. Credentials are never included;
+// device codes and session IDs are cut to their first 8 characters, enough to
+// match them against logs.
+type Export struct {
+	OSMUserID       int               `json:"osmUserId"`
+	ExportedAt      time.Time         `json:"exportedAt"`
+	Devices         []Device          `json:"devices"`
+	WebSessions     []WebSession      `json:"webSessions"`
+	SectionSettings []SectionSettings `json:"sectionSettings"`
+	AdhocPatrols    []AdhocPatrol     `json:"adhocPatrols"`
+	AuditLog        []AuditEntry      `json:"auditLog"`
+}
+
+// Device is a device code the user authorized.
+type Device struct {
+	DeviceCode           string     `json:"deviceCode"`
+	ClientID             string     `json:"clientId"`
+	Status               string     `json:"status"`
+	SectionID            *int       `json:"sectionId,omitempty"`
+	Sections             []int      `json:"sections,omitempty"`
+	HasOSMToken          bool       `json:"hasOsmToken"`
+	DeviceRequestIP      *string    `json:"deviceRequestIp,omitempty"`
+	DeviceRequestCountry *string    `json:"deviceRequestCountry,omitempty"`
+	CreatedAt            time.Time  `json:"createdAt"`
+	LastUsedAt           *time.Time `json:"lastUsedAt,omitempty"`
+}
+
+// WebSession is an admin UI session.
+type WebSession struct {
+	ID                string    `json:"id"`
+	SelectedSectionID *int      `json:"selectedSectionId,omitempty"`
+	CreatedAt         time.Time `json:"createdAt"`
+	LastActivity      time.Time `json:"lastActivity"`
+	ExpiresAt         time.Time `json:"expiresAt"`
+}
+
+// SectionSettings holds the user's display settings for one section.
+type SectionSettings struct {
+	SectionID int             `json:"sectionId"`
+	Settings  json.RawMessage `json:"settings"`
+	UpdatedAt time.Time       `json:"updatedAt"`
+}
+
+// AdhocPatrol is a team the user created for ad-hoc games.
+type AdhocPatrol struct {
+	Name      string    `json:"name"`
+	Color     string    `json:"color,omitempty"`
+	Score     int       `json:"score"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// AuditEntry is a score change the user made.
+type AuditEntry struct {
+	SectionID     int       `json:"sectionId"`
+	PatrolID      string    `json:"patrolId"`
+	PatrolName    string    `json:"patrolName"`
+	PreviousScore int       `json:"previousScore"`
+	NewScore      int       `json:"newScore"`
+	PointsAdded   int       `json:"pointsAdded"`
+	Source        string    `json:"source,omitempty"`
+	Note          *string   `json:"note,omitempty"`
+	Voided        bool      `json:"voided,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// DeleteCounts reports how many rows Delete removed from each table.
+type DeleteCounts struct {
+	DeviceSessions  int64 `json:"deviceSessions"`
+	DeviceSections  int64 `json:"deviceSections"`
+	Devices         int64 `json:"devices"`
+	WebSessions     int64 `json:"webSessions"`
+	SectionSettings int64 `json:"sectionSettings"`
+	AdhocPatrols    int64 `json:"adhocPatrols"`
+	AuditLog        int64 `json:"auditLog"`
+	ScoreBatches    int64 `json:"scoreBatches"`
+}
+
+// LogAttrs returns every count as slog key-value pairs, keyed by its JSON name,
+// so a table added to Delete is logged without further changes.
+func (c *DeleteCounts) LogAttrs() []any {
+	v := reflect.ValueOf(c).Elem()
+	attrs := make([]any, 0, 2*v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		name, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("json"), ",")
+		attrs = append(attrs, name, v.Field(i).Int())
+	}
+	return attrs
+}
+
+// ExportUser collects every record held for osmUserID.
+func ExportUser(conns *db.Connections, osmUserID int) (*Export, error) {
+	export := &Export{
+		OSMUserID:       osmUserID,
+		ExportedAt:      time.Now().UTC(),
+		Devices:         []Device{},
+		WebSessions:     []WebSession{},
+		SectionSettings: []SectionSettings{},
+		AdhocPatrols:    []AdhocPatrol{},
+		AuditLog:        []AuditEntry{},
+	}
+
+	var devices []db.DeviceCode
+	if err := conns.DB.Where("osm_user_id = ?", osmUserID).Order("created_at").Find(&devices).Error; err != nil {
+		return nil, err
+	}
+	for _, device := range devices {
+		var sections []int
+		if err := conns.DB.Model(&db.DeviceSection{}).Where("device_code = ?", device.DeviceCode).Order("position").Pluck("section_id", &sections).Error; err != nil {
+			return nil, err
+		}
+		export.Devices = append(export.Devices, Device{
+			DeviceCode:           truncateID(device.DeviceCode),
+			ClientID:             device.ClientID,
+			Status:               device.Status,
+			SectionID:            device.SectionID,
+			Sections:             sections,
+			HasOSMToken:          device.OSMAccessToken != nil,
+			DeviceRequestIP:      device.DeviceRequestIP,
+			DeviceRequestCountry: device.DeviceRequestCountry,
+			CreatedAt:            device.CreatedAt,
+			LastUsedAt:           device.LastUsedAt,
+		})
+	}
+
+	var sessions []db.WebSession
+	if err := conns.DB.Where("osm_user_id = ?", osmUserID).Order("created_at").Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		export.WebSessions = append(export.WebSessions, WebSession{
+			ID:                truncateID(session.ID),
+			SelectedSectionID: session.SelectedSectionID,
+			CreatedAt:         session.CreatedAt,
+			LastActivity:      session.LastActivity,
+			ExpiresAt:         session.ExpiresAt,
+		})
+	}
+
+	var settings []db.SectionSettings
+	if err := conns.DB.Where("osm_user_id = ?", osmUserID).Order("section_id").Find(&settings).Error; err != nil {
+		return nil, err
+	}
+	for _, s := range settings {
+		export.SectionSettings = append(export.SectionSettings, SectionSettings{
+			SectionID: s.SectionID,
+			Settings:  json.RawMessage(s.Settings),
+			UpdatedAt: s.UpdatedAt,
+		})
+	}
+
+	var patrols []db.AdhocPatrol
+	if err := conns.DB.Where("osm_user_id = ?", osmUserID).Order("position").Find(&patrols).Error; err != nil {
+		return nil, err
+	}
+	for _, patrol := range patrols {
+		export.AdhocPatrols = append(export.AdhocPatrols, AdhocPatrol{
+			Name:      patrol.Name,
+			Color:     patrol.Color,
+			Score:     patrol.Score,
+			CreatedAt: patrol.CreatedAt,
+		})
+	}
+
+	var entries []db.ScoreAuditLog
+	if err := conns.DB.Where("osm_user_id = ?", osmUserID).Order("id").Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		export.AuditLog = append(export.AuditLog, AuditEntry{
+			SectionID:     entry.SectionID,
+			PatrolID:      entry.PatrolID,
+			PatrolName:    entry.PatrolName,
+			PreviousScore: entry.PreviousScore,
+			NewScore:      entry.NewScore,
+			PointsAdded:   entry.PointsAdded,
+			Source:        entry.Source,
+			Note:          entry.Note,
+			Voided:        entry.Voided,
+			CreatedAt:     entry.CreatedAt,
+		})
+	}
+
+	return export, nil
+}
+
+// DeleteUser removes every record held for osmUserID in one transaction.
+// Devices the user authorized stop working immediately.
+func DeleteUser(conns *db.Connections, osmUserID int) (*DeleteCounts, error) {
+	counts := &DeleteCounts{}
+	var deleted []string
+	err := conns.DB.Transaction(func(tx *gorm.DB) error {
+		deviceCodes := tx.Model(&db.DeviceCode{}).Select("device_code").Where("osm_user_id = ?", osmUserID)
+		if err := tx.Model(&db.DeviceCode{}).Where("osm_user_id = ?", osmUserID).Pluck("device_code", &deleted).Error; err != nil {
+			return err
+		}
+		steps := []struct {
+			count *int64
+			query *gorm.DB
+			model interface{}
+		}{
+			{&counts.DeviceSessions, tx.Where("device_code IN (?)", deviceCodes), &db.DeviceSession{}},
+			{&counts.DeviceSections, tx.Where("device_code IN (?)", deviceCodes), &db.DeviceSection{}},
+			{&counts.Devices, tx.Where("osm_user_id = ?", osmUserID), &db.DeviceCode{}},
+			{&counts.WebSessions, tx.Where("osm_user_id = ?", osmUserID), &db.WebSession{}},
+			{&counts.SectionSettings, tx.Where("osm_user_id = ?", osmUserID), &db.SectionSettings{}},
+			{&counts.AdhocPatrols, tx.Where("osm_user_id = ?", osmUserID), &db.AdhocPatrol{}},
+			{&counts.AuditLog, tx.Where("osm_user_id = ?", osmUserID), &db.ScoreAuditLog{}},
+			{&counts.ScoreBatches, tx.Where("osm_user_id = ?", osmUserID), &db.ScoreBatch{}},
+		}
+		for _, step := range steps {
+			result := step.query.Delete(step.model)
+			if result.Error != nil {
+				return result.Error
+			}
+			*step.count = result.RowsAffected
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	devicecode.Forget(conns, deleted...)
+	return counts, nil
+}
+
+func truncateID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
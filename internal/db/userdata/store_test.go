@@ -0,0 +1,145 @@
+package userdata
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db"
+)
+
+const (
+	testUserID  = 4242
+	otherUserID = 5151
+)
+
+func seedUser(t *testing.T, conns *db.Connections, userID int, prefix string) {
+	t.Helper()
+	now := time.Now()
+	accessToken, refreshToken, deviceToken := prefix+"-osm-access-secret", prefix+"-osm-refresh-secret", prefix+"-device-token-secret"
+	sectionID := 100
+
+	records := []interface{}{
+		&db.DeviceCode{
+			DeviceCode:        prefix + "-device-code-0001",
+			UserCode:          prefix + "-USER",
+			ClientID:          "test-client",
+			Status:            "authorized",
+			ExpiresAt:         now.Add(time.Hour),
+			OsmUserID:         &userID,
+			SectionID:         &sectionID,
+			OSMAccessToken:    &accessToken,
+			OSMRefreshToken:   &refreshToken,
+			DeviceAccessToken: &deviceToken,
+		},
+		&db.DeviceSession{SessionID: prefix + "-device-session", DeviceCode: prefix + "-device-code-0001", ExpiresAt: now.Add(time.Hour)},
+		&db.WebSession{
+			ID:              prefix + "-web-session-0001",
+			OSMUserID:       userID,
+			OSMAccessToken:  accessToken,
+			OSMRefreshToken: refreshToken,
+			OSMTokenExpiry:  now.Add(time.Hour),
+			CSRFToken:       prefix + "-csrf-secret",
+			ExpiresAt:       now.Add(time.Hour),
+		},
+		&db.SectionSettings{OSMUserID: userID, SectionID: sectionID, Settings: []byte(`{"layout":"portrait"}`)},
+		&db.AdhocPatrol{OSMUserID: userID, Position: 0, Name: prefix + " Team"},
+		&db.ScoreAuditLog{OSMUserID: userID, SectionID: sectionID, PatrolID: "1", PatrolName: "Eagles", PreviousScore: 10, NewScore: 15, PointsAdded: 5},
+		&db.ScoreBatch{BatchID: prefix + "-batch", OSMUserID: userID},
+	}
+	for _, record := range records {
+		if err := conns.DB.Create(record).Error; err != nil {
+			t.Fatalf("Failed to seed %T: %v", record, err)
+		}
+	}
+}
+
+func TestExportUser_IncludesAllRecordsWithoutSecrets(t *testing.T) {
+	conns := db.SetupTestDB(t)
+	seedUser(t, conns, testUserID, "mine")
+	seedUser(t, conns, otherUserID, "theirs")
+
+	export, err := ExportUser(conns, testUserID)
+	if err != nil {
+		t.Fatalf("ExportUser failed: %v", err)
+	}
+
+	if len(export.Devices) != 1 || export.Devices[0].DeviceCode != "mine-dev" || !export.Devices[0].HasOSMToken {
+		t.Errorf("expected the user's device, got %+v", export.Devices)
+	}
+	if len(export.WebSessions) != 1 || export.WebSessions[0].ID != "mine-web" {
+		t.Errorf("expected the user's web session, got %+v", export.WebSessions)
+	}
+	if len(export.SectionSettings) != 1 || string(export.SectionSettings[0].Settings) != `{"layout":"portrait"}` {
+		t.Errorf("expected the user's section settings, got %+v", export.SectionSettings)
+	}
+	if len(export.AdhocPatrols) != 1 || export.AdhocPatrols[0].Name != "mine Team" {
+		t.Errorf("expected the user's ad-hoc patrol, got %+v", export.AdhocPatrols)
+	}
+	if len(export.AuditLog) != 1 || export.AuditLog[0].PointsAdded != 5 {
+		t.Errorf("expected the user's audit entry, got %+v", export.AuditLog)
+	}
+
+	document, err := json.Marshal(export)
+	if err != nil {
+		t.Fatalf("Failed to marshal export: %v", err)
+	}
+	for _, leaked := range []string{"secret", "theirs", "device-code-0001", "web-session-0001"} {
+		if strings.Contains(string(document), leaked) {
+			t.Errorf("export should not contain %q: %s", leaked, document)
+		}
+	}
+}
+
+func TestDeleteUser_RemovesOnlyThatUser(t *testing.T) {
+	conns := db.SetupTestDB(t)
+	seedUser(t, conns, testUserID, "mine")
+	seedUser(t, conns, otherUserID, "theirs")
+
+	counts, err := DeleteUser(conns, testUserID)
+	if err != nil {
+		t.Fatalf("DeleteUser failed: %v", err)
+	}
+	want := DeleteCounts{DeviceSessions: 1, Devices: 1, WebSessions: 1, SectionSettings: 1, AdhocPatrols: 1, AuditLog: 1, ScoreBatches: 1}
+	if *counts != want {
+		t.Errorf("expected counts %+v, got %+v", want, *counts)
+	}
+
+	export, err := ExportUser(conns, testUserID)
+	if err != nil {
+		t.Fatalf("ExportUser failed: %v", err)
+	}
+	if len(export.Devices)+len(export.WebSessions)+len(export.SectionSettings)+len(export.AdhocPatrols)+len(export.AuditLog) != 0 {
+		t.Errorf("expected nothing left for the user, got %+v", export)
+	}
+	var sessions int64
+	conns.DB.Model(&db.DeviceSession{}).Where("device_code = ?", "mine-device-code-0001").Count(&sessions)
+	if sessions != 0 {
+		t.Errorf("expected the user's device sessions to be deleted, found %d", sessions)
+	}
+
+	other, err := ExportUser(conns, otherUserID)
+	if err != nil {
+		t.Fatalf("ExportUser failed: %v", err)
+	}
+	if len(other.Devices) != 1 || len(other.WebSessions) != 1 || len(other.AuditLog) != 1 {
+		t.Errorf("expected the other user's data to be kept, got %+v", other)
+	}
+}
+
+func TestDeleteCounts_LogAttrsIncludesEveryCount(t *testing.T) {
+	counts := DeleteCounts{DeviceSessions: 1, DeviceSections: 2, Devices: 3, WebSessions: 4, SectionSettings: 5, AdhocPatrols: 6, AuditLog: 7, ScoreBatches: 8}
+	attrs := counts.LogAttrs()
+
+	logged := make(map[string]any, len(attrs)/2)
+	for i := 0; i+1 < len(attrs); i += 2 {
+		logged[attrs[i].(string)] = attrs[i+1]
+	}
+	if logged["deviceSections"] != int64(2) || logged["scoreBatches"] != int64(8) {
+		t.Errorf("expected every count to be logged, got %v", attrs)
+	}
+	if len(logged) != 8 {
+		t.Errorf("expected 8 counts, got %d: %v", len(logged), attrs)
+	}
+}
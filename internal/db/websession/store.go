@@ -63,6 +63,13 @@ func DeleteExpired(conns *db.Connections) error {
 	return conns.DB.Where("expires_at < ?", time.Now()).Delete(&db.WebSession{}).Error
 }
 
+// CountExpired reports how many web sessions DeleteExpired would delete
+func CountExpired(conns *db.Connections) (int64, error) {
+	var count int64
+	err := conns.DB.Model(&db.WebSession{}).Where("expires_at < ?", time.Now()).Count(&count).Error
+	return count, err
+}
+
 // DeleteByUserID deletes all sessions for a specific user (logout everywhere)
 func DeleteByUserID(conns *db.Connections, osmUserID int) error {
 	return conns.DB.Where("osm_user_id = ?", osmUserID).Delete(&db.WebSession{}).Error
@@ -0,0 +1,42 @@
+package websession
+
+import (
+	"testing"
+	"time"
+
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db"
+)
+
+func TestCountExpired_MatchesDeleteExpired(t *testing.T) {
+	conns := db.SetupTestDB(t)
+	now := time.Now()
+
+	for id, expiresAt := range map[string]time.Time{
+		"expired-1": now.Add(-time.Hour),
+		"expired-2": now.Add(-24 * time.Hour),
+		"valid":     now.Add(time.Hour),
+	} {
+		session := &db.WebSession{ID: id, OSMUserID: 1, OSMTokenExpiry: now, CSRFToken: "csrf-" + id, ExpiresAt: expiresAt}
+		if err := Create(conns, session); err != nil {
+			t.Fatalf("Failed to create session %s: %v", id, err)
+		}
+	}
+
+	count, err := CountExpired(conns)
+	if err != nil {
+		t.Fatalf("CountExpired failed: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected CountExpired to report 2, got %d", count)
+	}
+
+	if err := DeleteExpired(conns); err != nil {
+		t.Fatalf("DeleteExpired failed: %v", err)
+	}
+	if count, _ := CountExpired(conns); count != 0 {
+		t.Errorf("Expected nothing left to delete, got %d", count)
+	}
+	if found, _ := FindByID(conns, "valid"); found == nil {
+		t.Error("Expected the valid session to be kept")
+	}
+}
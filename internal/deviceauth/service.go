@@ -4,10 +4,13 @@ import (
 	"context"
 	"errors"
 	"log/slog"
+	"sync"
 	"time"
 
 	"github.com/m0rjc/OsmDeviceAdapter/internal/db"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/db/devicecode"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/devicetoken"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/notify"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/osm"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/tokenrefresh"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/types"
@@ -24,6 +27,12 @@ var (
 type Service struct {
 	conns          *db.Connections
 	tokenRefresher osm.TokenRefresher
+	notifier       notify.Notifier
+
+	// Signed device tokens; see SetTokenSigner
+	signer          *devicetoken.Signer
+	tokenMaxAge     time.Duration
+	recheckInterval time.Duration
 }
 
 // NewService creates a new device auth service
@@ -34,10 +43,41 @@ func NewService(conns *db.Connections, tokenRefresher osm.TokenRefresher) *Servi
 	}
 }
 
+// SetNotifier sets where revoked devices are reported. Without one, revocation
+// is only recorded on the device.
+func (s *Service) SetNotifier(notifier notify.Notifier) {
+	s.notifier = notifier
+}
+
+// SetTokenSigner makes new device tokens signed, so requests carrying them can
+// be authenticated from a copy of the device cached for recheckInterval
+// rather than a database lookup. Signed tokens older than maxAge are refused
+// (0 = no limit). Opaque tokens issued before signing was enabled keep
+// working.
+func (s *Service) SetTokenSigner(signer *devicetoken.Signer, maxAge, recheckInterval time.Duration) {
+	s.signer = signer
+	s.tokenMaxAge = maxAge
+	s.recheckInterval = recheckInterval
+}
+
+// SignAccessToken returns a signed device access token for deviceCode, or
+// false if no signer is set.
+func (s *Service) SignAccessToken(deviceCode string) (string, bool) {
+	if s == nil || s.signer == nil {
+		return "", false
+	}
+	return s.signer.Sign(deviceCode, time.Now()), true
+}
+
 // AuthContext holds the authentication context for an authenticated API request
 type AuthContext struct {
 	deviceCodeRecord *db.DeviceCode
 	osmAccessToken   string
+
+	// loadAccessToken, when set, reads the OSM access token on first use,
+	// for devices authenticated from the cache
+	loadAccessToken func() string
+	loadOnce        sync.Once
 }
 
 // UserID implements types.User interface
@@ -47,6 +87,9 @@ func (a *AuthContext) UserID() *int {
 
 // AccessToken implements types.User interface
 func (a *AuthContext) AccessToken() string {
+	if a.loadAccessToken != nil {
+		a.loadOnce.Do(func() { a.osmAccessToken = a.loadAccessToken() })
+	}
 	return a.osmAccessToken
 }
 
@@ -67,7 +110,14 @@ func (s *Service) Authenticate(ctx context.Context, authHeader string) (types.Us
 	}
 
 	// Verify the device access token belongs to a valid device
-	deviceCodeRecord, err := devicecode.FindByDeviceAccessToken(s.conns, accessToken)
+	var deviceCodeRecord *db.DeviceCode
+	var fromCache bool
+	var err error
+	if s.signer != nil && devicetoken.IsSigned(accessToken) {
+		deviceCodeRecord, fromCache, err = s.findBySignedToken(ctx, accessToken)
+	} else {
+		deviceCodeRecord, err = devicecode.FindByDeviceAccessToken(s.conns, accessToken)
+	}
 	if err != nil {
 		return nil, ErrInvalidToken
 	}
@@ -80,6 +130,13 @@ func (s *Service) Authenticate(ctx context.Context, authHeader string) (types.Us
 		osmAccessToken = *deviceCodeRecord.OSMAccessToken
 	}
 
+	// A device authenticated from the cache carries no OSM token; it is read
+	// from the database only if the request calls OSM
+	var loadAccessToken func() string
+	if fromCache {
+		loadAccessToken = func() string { return s.loadOSMAccessToken(deviceCodeRecord.DeviceCode) }
+	}
+
 	// Check if we need to refresh the OSM token
 	if deviceCodeRecord.OSMTokenExpiry != nil && time.Now().After(deviceCodeRecord.OSMTokenExpiry.Add(-5*time.Minute)) {
 		// Token is expired or about to expire, refresh it
@@ -89,9 +146,19 @@ func (s *Service) Authenticate(ctx context.Context, authHeader string) (types.Us
 		}
 
 		osmAccessToken = newAccessToken
+		loadAccessToken = nil
 	}
 
-	// Update last_used_at timestamp for this device
+	// Update last_used_at timestamp for this device. Devices served from the
+	// cache are recorded when the cache is next refilled, which is plenty for
+	// finding unused devices.
+	if fromCache {
+		return &AuthContext{
+			deviceCodeRecord: deviceCodeRecord,
+			osmAccessToken:   osmAccessToken,
+			loadAccessToken:  loadAccessToken,
+		}, nil
+	}
 	if err := devicecode.UpdateLastUsed(s.conns, deviceCodeRecord.DeviceCode); err != nil {
 		// Log the error but don't fail the authentication
 		slog.Error("deviceauth.last_used_update_failed",
@@ -108,11 +175,49 @@ func (s *Service) Authenticate(ctx context.Context, authHeader string) (types.Us
 	}, nil
 }
 
+// findBySignedToken checks a signed token's signature without touching the
+// database, then loads its device, from the cache when recently read. The
+// token must still be the one the device holds, so a re-paired device's old
+// token is refused.
+func (s *Service) findBySignedToken(ctx context.Context, accessToken string) (*db.DeviceCode, bool, error) {
+	deviceCode, err := s.signer.Verify(accessToken, s.tokenMaxAge, time.Now())
+	if err != nil {
+		return nil, false, err
+	}
+	return devicecode.FindAuthorizedCached(ctx, s.conns, deviceCode, accessToken, s.recheckInterval)
+}
+
+// loadOSMAccessToken reads a device's OSM access token from the database,
+// returning "" if it cannot, in which case OSM refuses the request and the
+// token is refreshed.
+func (s *Service) loadOSMAccessToken(deviceCode string) string {
+	record, err := devicecode.FindByCode(s.conns, deviceCode)
+	if err != nil || record == nil || record.OSMAccessToken == nil {
+		slog.Warn("deviceauth.osm_token_unavailable",
+			"component", "deviceauth",
+			"event", "osm_token.load_error",
+			"device_code_hash", deviceCode[:min(8, len(deviceCode))],
+			"error", err,
+		)
+		return ""
+	}
+	return *record.OSMAccessToken
+}
+
 // refreshDeviceToken refreshes the OSM token for a device using the central token refresh service.
+// The refresh token is read from the database rather than deviceCodeRecord,
+// which may be a cached copy without it or hold one already rotated.
 func (s *Service) refreshDeviceToken(ctx context.Context, deviceCodeRecord *db.DeviceCode) (string, error) {
+	current, err := devicecode.FindByCode(s.conns, deviceCodeRecord.DeviceCode)
+	if err != nil {
+		return "", ErrTokenRefreshFailed
+	}
+	if current == nil {
+		return "", ErrTokenRevoked
+	}
 	refreshToken := ""
-	if deviceCodeRecord.OSMRefreshToken != nil {
-		refreshToken = *deviceCodeRecord.OSMRefreshToken
+	if current.OSMRefreshToken != nil {
+		refreshToken = *current.OSMRefreshToken
 	}
 
 	identifier := deviceCodeRecord.DeviceCode[:8]
@@ -127,7 +232,18 @@ func (s *Service) refreshDeviceToken(ctx context.Context, deviceCodeRecord *db.D
 		},
 		// onRevoked: mark device as revoked
 		func() error {
-			return devicecode.Revoke(s.conns, deviceCodeRecord.DeviceCode)
+			if err := devicecode.Revoke(s.conns, deviceCodeRecord.DeviceCode); err != nil {
+				return err
+			}
+			if s.notifier != nil {
+				s.notifier.DeviceRevoked(notify.DeviceRevoked{
+					DeviceCode: identifier,
+					OSMUserID:  deviceCodeRecord.OsmUserID,
+					SectionID:  deviceCodeRecord.SectionID,
+					RevokedAt:  time.Now(),
+				})
+			}
+			return nil
 		},
 	)
 }
@@ -3,12 +3,16 @@ package deviceauth
 import (
 	"context"
 	"errors"
+	"fmt"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/db"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/db/devicecode"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/devicetoken"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/notify"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/tokenrefresh"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
@@ -327,6 +331,70 @@ func TestRefreshDeviceToken_Revocation(t *testing.T) {
 	}
 }
 
+// recordingNotifier captures notifications for assertions.
+type recordingNotifier struct {
+	revoked []notify.DeviceRevoked
+}
+
+func (n *recordingNotifier) DeviceRevoked(event notify.DeviceRevoked) {
+	n.revoked = append(n.revoked, event)
+}
+
+func TestRefreshDeviceToken_RevocationNotifies(t *testing.T) {
+	conns := setupTestDB(t)
+	now := time.Now()
+
+	osmRefresh := "osm-refresh-token"
+	userID := 123
+	sectionID := 456
+	device := &db.DeviceCode{
+		DeviceCode:      "notify-device-code",
+		UserCode:        "NTFY",
+		ClientID:        "test-client",
+		Status:          "authorized",
+		ExpiresAt:       now.Add(24 * time.Hour),
+		OSMRefreshToken: &osmRefresh,
+		OsmUserID:       &userID,
+		SectionID:       &sectionID,
+	}
+	if err := devicecode.Create(conns, device); err != nil {
+		t.Fatalf("Failed to create device: %v", err)
+	}
+
+	mockRefresher := &mockTokenRefresher{
+		refreshFunc: func(ctx context.Context, refreshToken, identifier string,
+			onSuccess func(string, string, time.Time) error,
+			onRevoked func() error) (string, error) {
+			if err := onRevoked(); err != nil {
+				t.Errorf("onRevoked failed: %v", err)
+			}
+			return "", tokenrefresh.ErrTokenRevoked
+		},
+	}
+
+	notifier := &recordingNotifier{}
+	service := NewService(conns, mockRefresher)
+	service.SetNotifier(notifier)
+
+	if _, err := service.CreateRefreshFunc(device)(context.Background()); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("Expected ErrTokenRevoked, got %v", err)
+	}
+
+	if len(notifier.revoked) != 1 {
+		t.Fatalf("Expected one revocation notice, got %d", len(notifier.revoked))
+	}
+	event := notifier.revoked[0]
+	if event.DeviceCode != "notify-d" {
+		t.Errorf("Expected the device code prefix, got %q", event.DeviceCode)
+	}
+	if event.OSMUserID == nil || *event.OSMUserID != userID || event.SectionID == nil || *event.SectionID != sectionID {
+		t.Errorf("Unexpected user or section in %+v", event)
+	}
+	if event.RevokedAt.IsZero() {
+		t.Error("Expected RevokedAt to be set")
+	}
+}
+
 // Test token refresh with success
 func TestRefreshDeviceToken_Success(t *testing.T) {
 	conns := setupTestDB(t)
@@ -401,6 +469,47 @@ func TestRefreshDeviceToken_Success(t *testing.T) {
 }
 
 // Test token refresh with network error
+func TestRefreshDeviceToken_UsesStoredRefreshToken(t *testing.T) {
+	conns := setupTestDB(t)
+
+	rotated := "rotated-refresh-token"
+	userID := 123
+	device := &db.DeviceCode{
+		DeviceCode:      "rotated-device",
+		UserCode:        "ROTA",
+		ClientID:        "test-client",
+		Status:          "authorized",
+		ExpiresAt:       time.Now().Add(24 * time.Hour),
+		OSMRefreshToken: &rotated,
+		OsmUserID:       &userID,
+	}
+	if err := devicecode.Create(conns, device); err != nil {
+		t.Fatalf("Failed to create device: %v", err)
+	}
+
+	var used string
+	service := NewService(conns, &mockTokenRefresher{
+		refreshFunc: func(ctx context.Context, refreshToken, identifier string,
+			onSuccess func(string, string, time.Time) error,
+			onRevoked func() error) (string, error) {
+			used = refreshToken
+			return "new-access-token", nil
+		},
+	})
+
+	// A record read before the token was rotated, or from the cache, must not
+	// be refreshed with the token it carries
+	stale := "stale-refresh-token"
+	copied := *device
+	copied.OSMRefreshToken = &stale
+	if _, err := service.CreateRefreshFunc(&copied)(context.Background()); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if used != rotated {
+		t.Errorf("Expected the stored refresh token %q, got %q", rotated, used)
+	}
+}
+
 func TestRefreshDeviceToken_NetworkError(t *testing.T) {
 	conns := setupTestDB(t)
 	now := time.Now()
@@ -558,6 +667,116 @@ func TestAuthenticate_LastUsedTracking(t *testing.T) {
 	}
 }
 
+// setupSignedTokenDevice creates an authorized device holding a signed token
+// and a service that accepts it, with Redis for the device cache.
+func setupSignedTokenDevice(t *testing.T) (*Service, *db.Connections, string) {
+	t.Helper()
+	conns := setupTestDB(t)
+	mr := miniredis.RunT(t)
+	rc, err := db.NewRedisClient(fmt.Sprintf("redis://%s", mr.Addr()), "test:")
+	if err != nil {
+		t.Fatalf("Failed to connect to miniredis: %v", err)
+	}
+	conns.Redis = rc
+
+	service := NewService(conns, &mockTokenRefresher{})
+	service.SetTokenSigner(devicetoken.NewSigner("a-test-secret-that-is-at-least-32-bytes"), 0, time.Minute)
+	token, ok := service.SignAccessToken("signed-device")
+	if !ok {
+		t.Fatal("Expected a signed token once a signer is set")
+	}
+
+	osmToken := "osm-access-token"
+	userID, sectionID := 123, 456
+	if err := devicecode.Create(conns, &db.DeviceCode{
+		DeviceCode:        "signed-device",
+		UserCode:          "SIGN",
+		ClientID:          "test-client",
+		Status:            "authorized",
+		ExpiresAt:         time.Now().Add(24 * time.Hour),
+		DeviceAccessToken: &token,
+		OSMAccessToken:    &osmToken,
+		OSMTokenExpiry:    ptrTime(time.Now().Add(2 * time.Hour)),
+		OsmUserID:         &userID,
+		SectionID:         &sectionID,
+	}); err != nil {
+		t.Fatalf("Failed to create device: %v", err)
+	}
+	return service, conns, token
+}
+
+func TestAuthenticate_SignedTokenServedFromCache(t *testing.T) {
+	service, conns, token := setupSignedTokenDevice(t)
+
+	if _, err := service.Authenticate(context.Background(), "Bearer "+token); err != nil {
+		t.Fatalf("Authenticate failed: %v", err)
+	}
+
+	// Change the row behind the store's back: a cached device does not see it
+	if err := conns.DB.Model(&db.DeviceCode{}).Where("device_code = ?", "signed-device").Update("section_id", 789).Error; err != nil {
+		t.Fatalf("Failed to update device: %v", err)
+	}
+	user, err := service.Authenticate(context.Background(), "Bearer "+token)
+	if err != nil {
+		t.Fatalf("Authenticate failed: %v", err)
+	}
+	if got := *user.(*AuthContext).DeviceCode().SectionID; got != 456 {
+		t.Errorf("Expected the cached section 456 without a database read, got %d", got)
+	}
+
+	// The shared cache holds no credentials; the OSM token is read when used
+	cached, err := conns.Redis.Get(context.Background(), "device_auth:signed-device").Result()
+	if err != nil {
+		t.Fatalf("Expected the device to be cached: %v", err)
+	}
+	if strings.Contains(cached, "osm-access-token") || strings.Contains(cached, token) {
+		t.Errorf("Expected no tokens in the cached device, got %s", cached)
+	}
+	if got := user.AccessToken(); got != "osm-access-token" {
+		t.Errorf("Expected the OSM token from the database, got %q", got)
+	}
+
+	// Revoking through the store drops the cached copy at once
+	if err := devicecode.Revoke(conns, "signed-device"); err != nil {
+		t.Fatalf("Revoke failed: %v", err)
+	}
+	if _, err := service.Authenticate(context.Background(), "Bearer "+token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected ErrInvalidToken after revocation, got %v", err)
+	}
+}
+
+func TestAuthenticate_SignedTokenRejections(t *testing.T) {
+	service, conns, token := setupSignedTokenDevice(t)
+
+	forged := devicetoken.NewSigner("some-other-secret-that-is-32-bytes-long").Sign("signed-device", time.Now())
+	if _, err := service.Authenticate(context.Background(), "Bearer "+forged); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected ErrInvalidToken for a token signed with another secret, got %v", err)
+	}
+	if _, err := service.Authenticate(context.Background(), "Bearer "+token+"x"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected ErrInvalidToken for a tampered token, got %v", err)
+	}
+
+	// A validly signed token the device no longer holds is refused
+	stale, _ := service.SignAccessToken("signed-device")
+	if err := devicecode.UpdateWithSection(conns, "signed-device", "authorized", 456, "replacement-token"); err != nil {
+		t.Fatalf("Failed to replace token: %v", err)
+	}
+	if _, err := service.Authenticate(context.Background(), "Bearer "+stale); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected ErrInvalidToken for a replaced token, got %v", err)
+	}
+}
+
+func TestAuthenticate_OpaqueTokenStillAcceptedWithSigner(t *testing.T) {
+	service, conns, _ := setupSignedTokenDevice(t)
+	if err := devicecode.UpdateWithSection(conns, "signed-device", "authorized", 456, "opaque-token-from-before-signing"); err != nil {
+		t.Fatalf("Failed to set opaque token: %v", err)
+	}
+
+	if _, err := service.Authenticate(context.Background(), "Bearer opaque-token-from-before-signing"); err != nil {
+		t.Errorf("Expected an opaque token to authenticate, got %v", err)
+	}
+}
+
 // Helper function for tests
 func setupTestDB(t *testing.T) *db.Connections {
 	database, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
@@ -0,0 +1,70 @@
+package devicetoken
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "a-test-secret-that-is-at-least-32-bytes"
+
+func TestSignAndVerify(t *testing.T) {
+	signer := NewSigner(testSecret)
+	issued := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	token := signer.Sign("device-code-1", issued)
+	if !IsSigned(token) {
+		t.Fatalf("expected %q to be recognised as signed", token)
+	}
+
+	deviceCode, err := signer.Verify(token, 0, issued.Add(365*24*time.Hour))
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if deviceCode != "device-code-1" {
+		t.Errorf("expected device code %q, got %q", "device-code-1", deviceCode)
+	}
+}
+
+func TestVerify_RejectsTampering(t *testing.T) {
+	signer := NewSigner(testSecret)
+	issued := time.Now()
+	token := signer.Sign("device-code-1", issued)
+	parts := strings.Split(token, ".")
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"other device code", "device-code-2." + parts[1] + "." + parts[2], ErrBadSignature},
+		{"later issue time", parts[0] + ".9999999999." + parts[2], ErrBadSignature},
+		{"altered signature", parts[0] + "." + parts[1] + "." + strings.Repeat("A", len(parts[2])), ErrBadSignature},
+		{"other secret", NewSigner(testSecret+"-rotated").Sign("device-code-1", issued), ErrBadSignature},
+		{"no issue time", parts[0] + "." + parts[2], ErrMalformed},
+		{"non-numeric issue time", parts[0] + ".soon." + parts[2], ErrMalformed},
+		{"signature not base64", parts[0] + "." + parts[1] + ".!!!", ErrMalformed},
+		{"opaque token", "opaque-random-token", ErrMalformed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := signer.Verify(tt.token, 0, issued); !errors.Is(err, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestVerify_Expiry(t *testing.T) {
+	signer := NewSigner(testSecret)
+	issued := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	token := signer.Sign("device-code-1", issued)
+
+	if _, err := signer.Verify(token, time.Hour, issued.Add(59*time.Minute)); err != nil {
+		t.Errorf("expected a token within its lifetime to verify, got %v", err)
+	}
+	if _, err := signer.Verify(token, time.Hour, issued.Add(61*time.Minute)); !errors.Is(err, ErrExpired) {
+		t.Errorf("expected ErrExpired past the lifetime, got %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+// Package geoip resolves client IP addresses to countries from the MaxMind
+// GeoLite2 Country database in its CSV edition, for deployments whose proxy
+// does not send a CF-IPCountry header.
+package geoip
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Resolver maps IP addresses to ISO 3166-1 alpha-2 country codes. It is safe
+// for concurrent use once loaded.
+type Resolver struct {
+	networks []network
+}
+
+type network struct {
+	prefix  netip.Prefix
+	country string
+}
+
+// LoadCSV reads GeoLite2-Country-Blocks-IPv4.csv (and optionally the IPv6 file)
+// together with GeoLite2-Country-Locations-en.csv. blocksPaths may name several
+// blocks files; networks without a country are skipped.
+func LoadCSV(locationsPath string, blocksPaths ...string) (*Resolver, error) {
+	countries, err := loadLocations(locationsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	resolver := &Resolver{}
+	for _, path := range blocksPaths {
+		if err := resolver.loadBlocks(path, countries); err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(resolver.networks, func(i, j int) bool {
+		return resolver.networks[i].prefix.Addr().Less(resolver.networks[j].prefix.Addr())
+	})
+	return resolver, nil
+}
+
+// Country returns the country code for ip, or "" if ip is not a valid address
+// or not in the database. IPv4-mapped IPv6 addresses are looked up as IPv4.
+func (r *Resolver) Country(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ""
+	}
+	addr = addr.Unmap()
+
+	// Networks do not overlap, so only the last one starting at or before addr
+	// can contain it
+	i := sort.Search(len(r.networks), func(i int) bool {
+		return addr.Less(r.networks[i].prefix.Addr())
+	})
+	if i == 0 || !r.networks[i-1].prefix.Contains(addr) {
+		return ""
+	}
+	return r.networks[i-1].country
+}
+
+// loadLocations maps geoname IDs to country codes.
+func loadLocations(path string) (map[string]string, error) {
+	countries := make(map[string]string)
+	err := readCSV(path, []string{"geoname_id", "country_iso_code"}, func(fields []string) error {
+		if fields[1] != "" {
+			countries[fields[0]] = fields[1]
+		}
+		return nil
+	})
+	return countries, err
+}
+
+// loadBlocks adds each network in a blocks file, using its registered country
+// when no geolocated country is given.
+func (r *Resolver) loadBlocks(path string, countries map[string]string) error {
+	return readCSV(path, []string{"network", "geoname_id", "registered_country_geoname_id"}, func(fields []string) error {
+		prefix, err := netip.ParsePrefix(fields[0])
+		if err != nil {
+			return fmt.Errorf("invalid network %q: %w", fields[0], err)
+		}
+		country := countries[fields[1]]
+		if country == "" {
+			country = countries[fields[2]]
+		}
+		if country != "" {
+			r.networks = append(r.networks, network{prefix: prefix.Masked(), country: country})
+		}
+		return nil
+	})
+}
+
+// readCSV calls row with the named columns of each record in a CSV file that
+// has a header line.
+func readCSV(path string, columns []string, row func(fields []string) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("%s: failed to read header: %w", path, err)
+	}
+	indexes := make([]int, len(columns))
+	for i, column := range columns {
+		indexes[i] = -1
+		for j, name := range header {
+			if strings.TrimSpace(name) == column {
+				indexes[i] = j
+				break
+			}
+		}
+		if indexes[i] < 0 {
+			return fmt.Errorf("%s: missing column %q", path, column)
+		}
+	}
+
+	fields := make([]string, len(columns))
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		for i, index := range indexes {
+			fields[i] = record[index]
+		}
+		if err := row(fields); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package geoip
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestResolver_Country(t *testing.T) {
+	dir := t.TempDir()
+	locations := writeFile(t, dir, "locations.csv", `geoname_id,locale_code,continent_code,continent_name,country_iso_code,country_name,is_in_european_union
+2635167,en,EU,Europe,GB,"United Kingdom",0
+2921044,en,EU,Europe,DE,Germany,1
+6255148,en,EU,Europe,,Europe,0
+`)
+	ipv4 := writeFile(t, dir, "blocks-ipv4.csv", `network,geoname_id,registered_country_geoname_id,represented_country_geoname_id,is_anonymous_proxy,is_satellite_provider
+81.2.69.0/24,2635167,2635167,,0,0
+198.51.100.0/24,,2921044,,0,0
+203.0.113.0/24,6255148,6255148,,0,0
+`)
+	ipv6 := writeFile(t, dir, "blocks-ipv6.csv", `network,geoname_id,registered_country_geoname_id,represented_country_geoname_id,is_anonymous_proxy,is_satellite_provider
+2a02:c7f::/32,2635167,2635167,,0,0
+`)
+
+	resolver, err := LoadCSV(locations, ipv4, ipv6)
+	if err != nil {
+		t.Fatalf("LoadCSV failed: %v", err)
+	}
+
+	tests := []struct {
+		ip       string
+		expected string
+	}{
+		{"81.2.69.160", "GB"},
+		{"81.2.70.1", ""},
+		{"198.51.100.7", "DE"}, // registered country when not geolocated
+		{"203.0.113.1", ""},    // continent only
+		{"::ffff:81.2.69.1", "GB"},
+		{"2a02:c7f:1234::1", "GB"},
+		{"10.0.0.1", ""},
+		{"not-an-ip", ""},
+	}
+	for _, tt := range tests {
+		if got := resolver.Country(tt.ip); got != tt.expected {
+			t.Errorf("Country(%q) = %q, expected %q", tt.ip, got, tt.expected)
+		}
+	}
+}
+
+func TestLoadCSV_RejectsMissingColumns(t *testing.T) {
+	dir := t.TempDir()
+	locations := writeFile(t, dir, "locations.csv", "geoname_id,country_name\n1,Nowhere\n")
+
+	if _, err := LoadCSV(locations); err == nil {
+		t.Error("Expected an error for a locations file without country_iso_code")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/m0rjc/OsmDeviceAdapter/internal/config"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/db"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/db/adhocpatrol"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/middleware"
@@ -36,6 +37,9 @@ func AdminAdhocPatrolsHandler(deps *Dependencies) http.HandlerFunc {
 			writeJSONError(w, http.StatusUnauthorized, "unauthorized", "Not authenticated")
 			return
 		}
+		if !requireFeature(w, deps, config.FeatureAdhocTeams) {
+			return
+		}
 
 		switch r.Method {
 		case http.MethodGet:
@@ -57,10 +61,13 @@ func AdminAdhocPatrolHandler(deps *Dependencies) http.HandlerFunc {
 			writeJSONError(w, http.StatusUnauthorized, "unauthorized", "Not authenticated")
 			return
 		}
+		if !requireFeature(w, deps, config.FeatureAdhocTeams) {
+			return
+		}
 
 		// Parse patrol ID from URL path: /api/admin/adhoc/patrols/{id}
 		path := r.URL.Path
-		prefix := "/api/admin/adhoc/patrols/"
+		prefix := deps.Config.Paths.AdminAPIPrefix + "/adhoc/patrols/"
 		if !strings.HasPrefix(path, prefix) {
 			writeJSONError(w, http.StatusNotFound, "not_found", "Invalid path")
 			return
@@ -128,8 +135,8 @@ func handleCreateAdhocPatrol(w http.ResponseWriter, r *http.Request, deps *Depen
 		return
 	}
 
-	if err := validateAdhocPatrolRequest(&req); err != nil {
-		writeJSONError(w, http.StatusBadRequest, "validation_error", err.Error())
+	if fieldErr := validateAdhocPatrolRequest(&req); fieldErr != nil {
+		writeValidationError(w, fieldErr)
 		return
 	}
 
@@ -139,9 +146,10 @@ func handleCreateAdhocPatrol(w http.ResponseWriter, r *http.Request, deps *Depen
 		Color:     req.Color,
 	}
 
-	if err := adhocpatrol.Create(deps.Conns, patrol); err != nil {
+	maxPatrols := deps.Config.Admin.MaxAdhocPatrolsPerUser
+	if err := adhocpatrol.Create(deps.Conns, patrol, maxPatrols); err != nil {
 		if err == adhocpatrol.ErrMaxPatrolsReached {
-			writeJSONError(w, http.StatusConflict, "max_patrols", err.Error())
+			writeJSONError(w, http.StatusConflict, "max_patrols_reached", fmt.Sprintf("Maximum of %d ad-hoc patrols reached", maxPatrols))
 			return
 		}
 		slog.Error("admin.adhoc.create.failed",
@@ -183,8 +191,8 @@ func handleUpdateAdhocPatrol(w http.ResponseWriter, r *http.Request, deps *Depen
 		return
 	}
 
-	if err := validateAdhocPatrolRequest(&req); err != nil {
-		writeJSONError(w, http.StatusBadRequest, "validation_error", err.Error())
+	if fieldErr := validateAdhocPatrolRequest(&req); fieldErr != nil {
+		writeValidationError(w, fieldErr)
 		return
 	}
 
@@ -291,16 +299,17 @@ func validateCSRFToken(r *http.Request, session *db.WebSession) error {
 	return nil
 }
 
-func validateAdhocPatrolRequest(req *AdhocPatrolRequest) error {
+func validateAdhocPatrolRequest(req *AdhocPatrolRequest) *FieldError {
 	req.Name = strings.TrimSpace(req.Name)
 	if req.Name == "" {
-		return fmt.Errorf("name is required")
+		return &FieldError{Field: "name", Constraint: ConstraintRequired, Message: "name is required"}
 	}
 	if len(req.Name) > 50 {
-		return fmt.Errorf("name must be 50 characters or less")
+		maxLength := 50
+		return &FieldError{Field: "name", Constraint: ConstraintMaxLength, Max: &maxLength, Message: "name must be 50 characters or less"}
 	}
 	if req.Color != "" && !validColorNames[req.Color] {
-		return fmt.Errorf("invalid color name")
+		return &FieldError{Field: "color", Constraint: ConstraintOneOf, Message: "invalid color name"}
 	}
 	return nil
 }
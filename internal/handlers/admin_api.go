@@ -1,24 +1,43 @@
 package handlers
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/m0rjc/OsmDeviceAdapter/internal/config"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/db"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/db/adhocpatrol"
-	"github.com/m0rjc/OsmDeviceAdapter/internal/db/devicecode"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/db/scoreaudit"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db/scorebatch"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/db/sectionsettings"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/metrics"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/middleware"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/osm"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/services"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/services/scoreupdateservice"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/term"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/types"
 	wsinternal "github.com/m0rjc/OsmDeviceAdapter/internal/websocket"
 )
 
+// duplicateSubmissionWindow is how long an identical score submission from the
+// same user and section is treated as an accidental repeat (e.g. a double-click).
+const duplicateSubmissionWindow = 5 * time.Second
+
+// maxIdempotencyKeyLength bounds the Idempotency-Key header on score updates.
+const maxIdempotencyKeyLength = 255
+
 // Response types for admin API endpoints
 
 // AdminSessionResponse is returned by GET /api/admin/session
@@ -42,9 +61,10 @@ type AdminSectionsResponse struct {
 
 // AdminSection represents a section the user has access to
 type AdminSection struct {
-	ID        int    `json:"id"`
-	Name      string `json:"name"`
-	GroupName string `json:"groupName"`
+	ID            int    `json:"id"`
+	Name          string `json:"name"`
+	GroupName     string `json:"groupName"`
+	CanEditScores bool   `json:"canEditScores"`
 }
 
 // AdminScoresResponse is returned by GET /api/admin/sections/{sectionId}/scores
@@ -76,6 +96,9 @@ type AdminScoreUpdate struct {
 type AdminUpdateResponse struct {
 	Success bool                `json:"success"`
 	Patrols []AdminPatrolResult `json:"patrols"`
+	// BatchID is set when the client asked not to wait; the changes can be
+	// awaited with GET /api/admin/batches/{batchId}?wait=N
+	BatchID string `json:"batchId,omitempty"`
 }
 
 // AdminPatrolResult contains the result of a single patrol score update
@@ -88,24 +111,96 @@ type AdminPatrolResult struct {
 	IsTemporaryError *bool      `json:"isTemporaryError,omitempty"`
 	RetryAfter       *time.Time `json:"retryAfter,omitempty"`
 	ErrorMessage     *string    `json:"errorMessage,omitempty"`
+	// Pending is true when OSM did not confirm the update before the request timed out
+	Pending bool `json:"pending,omitempty"`
 }
 
 // AdminErrorResponse is used for error responses
 type AdminErrorResponse struct {
 	Error   string `json:"error"`
 	Message string `json:"message"`
+	// Reauthenticate tells the client to send the user back through login
+	Reauthenticate bool `json:"reauthenticate,omitempty"`
+	// Fields lists the invalid inputs behind a validation_error
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+// FieldError describes one invalid field in a request so the admin UI can
+// highlight the input responsible.
+type FieldError struct {
+	// Field is the JSON path of the input, e.g. "updates[0].points" or "patrolColors.12"
+	Field string `json:"field"`
+	// Constraint is the rule the value broke, one of the Constraint constants
+	Constraint string `json:"constraint"`
+	// Min and Max are the bounds of a "range" constraint
+	Min     *int   `json:"min,omitempty"`
+	Max     *int   `json:"max,omitempty"`
+	Message string `json:"message"`
+}
+
+// Constraints reported in FieldError.Constraint
+const (
+	ConstraintRange      = "range"
+	ConstraintRequired   = "required"
+	ConstraintMaxLength  = "max_length"
+	ConstraintOneOf      = "one_of"
+	ConstraintMultipleOf = "multiple_of"
+	ConstraintConflict   = "conflict"
+)
+
+// Bounds on the points a single score update may add or remove
+const (
+	minPointsDelta = -1000
+	maxPointsDelta = 1000
+)
+
+// updatePointsField is the path of an update's points in a single-section request.
+func updatePointsField(index int) string {
+	return fmt.Sprintf("updates[%d].points", index)
+}
+
+// pointsRangeError reports points outside minPointsDelta..maxPointsDelta at field.
+func pointsRangeError(field string) *FieldError {
+	low, high := minPointsDelta, maxPointsDelta
+	return &FieldError{
+		Field:      field,
+		Constraint: ConstraintRange,
+		Min:        &low,
+		Max:        &high,
+		Message:    fmt.Sprintf("Points must be between %d and %d", minPointsDelta, maxPointsDelta),
+	}
 }
 
 // AdminSettingsResponse is returned by GET /api/admin/sections/{sectionId}/settings
 type AdminSettingsResponse struct {
-	SectionID    int                 `json:"sectionId"`
-	PatrolColors map[string]string   `json:"patrolColors"`
-	Patrols      []types.PatrolInfo  `json:"patrols"` // Canonical list for UI
+	SectionID         int                `json:"sectionId"`
+	PatrolColors      map[string]string  `json:"patrolColors"`
+	PatrolIcons       map[string]string  `json:"patrolIcons,omitempty"`
+	Layout            string             `json:"layout,omitempty"`
+	PointsStep        int                `json:"pointsStep,omitempty"`
+	EnforcePointsStep bool               `json:"enforcePointsStep,omitempty"`
+	MinScore          *int               `json:"minScore,omitempty"`
+	HideBelowScore    *int               `json:"hideBelowScore,omitempty"`
+	Patrols           []types.PatrolInfo `json:"patrols"` // Canonical list for UI
 }
 
-// AdminSettingsUpdateRequest is the request body for PUT /api/admin/sections/{sectionId}/settings
+// AdminSettingsUpdateRequest is the request body for PUT /api/admin/sections/{sectionId}/settings.
+// Fields that are omitted are left unchanged.
 type AdminSettingsUpdateRequest struct {
 	PatrolColors map[string]string `json:"patrolColors"`
+	PatrolIcons  map[string]string `json:"patrolIcons,omitempty"`
+	Layout       *string           `json:"layout,omitempty"` // "" clears the layout
+	// PointsStep and EnforcePointsStep are updated together; 0 clears the step
+	PointsStep        *int  `json:"pointsStep,omitempty"`
+	EnforcePointsStep *bool `json:"enforcePointsStep,omitempty"`
+	// MinScore sets a floor that score updates will not take a patrol below;
+	// ClearMinScore removes it
+	MinScore      *int `json:"minScore,omitempty"`
+	ClearMinScore bool `json:"clearMinScore,omitempty"`
+	// HideBelowScore hides lower-scoring patrols from devices (the admin UI
+	// still shows them); ClearHideBelowScore shows every patrol again
+	HideBelowScore      *int `json:"hideBelowScore,omitempty"`
+	ClearHideBelowScore bool `json:"clearHideBelowScore,omitempty"`
 }
 
 // writeJSONError writes a JSON error response
@@ -118,6 +213,44 @@ func writeJSONError(w http.ResponseWriter, statusCode int, errorCode, message st
 	})
 }
 
+// writeValidationError writes a 400 validation_error naming the invalid field.
+func writeValidationError(w http.ResponseWriter, fieldErr *FieldError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(AdminErrorResponse{
+		Error:   "validation_error",
+		Message: fieldErr.Message,
+		Fields:  []FieldError{*fieldErr},
+	})
+}
+
+// requireFeature writes a 404 and returns false when feature is switched off
+// for this deployment, so disabled endpoints look as though they do not exist.
+func requireFeature(w http.ResponseWriter, deps *Dependencies, feature config.Feature) bool {
+	if deps.Config.Features.Enabled(feature) {
+		return true
+	}
+	writeJSONError(w, http.StatusNotFound, "feature_disabled", "This feature is not enabled")
+	return false
+}
+
+// writeProfileFetchError reports a failed OSM profile fetch. A 401 from OSM means
+// the user's OSM access has been revoked, which no retry will fix, so the client
+// is told to log in again rather than shown a generic OSM error.
+func writeProfileFetchError(w http.ResponseWriter, err error, message string) {
+	if errors.Is(err, osm.ErrUnauthorized) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(AdminErrorResponse{
+			Error:          "access_revoked",
+			Message:        "Your OSM access has been revoked or has expired. Please log in again.",
+			Reauthenticate: true,
+		})
+		return
+	}
+	writeJSONError(w, http.StatusBadGateway, "osm_error", message)
+}
+
 // writeJSON writes a JSON response
 func writeJSON(w http.ResponseWriter, data any) {
 	w.Header().Set("Content-Type", "application/json")
@@ -154,6 +287,10 @@ func AdminSessionHandler(deps *Dependencies) http.HandlerFunc {
 				"event", "session.error",
 				"error", err,
 			)
+			if errors.Is(err, osm.ErrUnauthorized) {
+				writeProfileFetchError(w, err, "")
+				return
+			}
 			// Return session info without name if profile fetch fails
 			writeJSON(w, AdminSessionResponse{
 				Authenticated:     true,
@@ -208,7 +345,7 @@ func AdminSectionsHandler(deps *Dependencies) http.HandlerFunc {
 				"event", "sections.error",
 				"error", err,
 			)
-			writeJSONError(w, http.StatusBadGateway, "osm_error", "Failed to fetch sections from OSM")
+			writeProfileFetchError(w, err, "Failed to fetch sections from OSM")
 			return
 		}
 
@@ -217,18 +354,22 @@ func AdminSectionsHandler(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
-		// Convert OSM sections to admin sections, with ad-hoc section first
+		// Convert OSM sections to admin sections, with the ad-hoc section first when enabled
 		sections := make([]AdminSection, 0, len(profile.Data.Sections)+1)
-		sections = append(sections, AdminSection{
-			ID:        0,
-			Name:      "Ad-hoc Teams",
-			GroupName: "Local",
-		})
+		if deps.Config.Features.Enabled(config.FeatureAdhocTeams) {
+			sections = append(sections, AdminSection{
+				ID:            0,
+				Name:          "Ad-hoc Teams",
+				GroupName:     "Local",
+				CanEditScores: true,
+			})
+		}
 		for _, s := range profile.Data.Sections {
 			sections = append(sections, AdminSection{
-				ID:        s.SectionID,
-				Name:      s.SectionName,
-				GroupName: s.GroupName,
+				ID:            s.SectionID,
+				Name:          s.SectionName,
+				GroupName:     s.GroupName,
+				CanEditScores: s.CanEditScores(),
 			})
 		}
 
@@ -256,7 +397,7 @@ func AdminScoresHandler(deps *Dependencies) http.HandlerFunc {
 		// Parse section ID from URL path
 		// Expected format: /api/admin/sections/{sectionId}/scores
 		path := r.URL.Path
-		prefix := "/api/admin/sections/"
+		prefix := deps.Config.Paths.AdminAPIPrefix + "/sections/"
 		suffix := "/scores"
 
 		if !strings.HasPrefix(path, prefix) || !strings.HasSuffix(path, suffix) {
@@ -273,6 +414,9 @@ func AdminScoresHandler(deps *Dependencies) http.HandlerFunc {
 
 		// Ad-hoc section: bypass OSM validation, serve from local DB
 		if sectionID == 0 {
+			if !requireFeature(w, deps, config.FeatureAdhocTeams) {
+				return
+			}
 			switch r.Method {
 			case http.MethodGet:
 				handleGetAdhocScores(w, deps, session)
@@ -293,7 +437,7 @@ func AdminScoresHandler(deps *Dependencies) http.HandlerFunc {
 				"event", "scores.error",
 				"error", err,
 			)
-			writeJSONError(w, http.StatusBadGateway, "osm_error", "Failed to validate section access")
+			writeProfileFetchError(w, err, "Failed to validate section access")
 			return
 		}
 
@@ -332,7 +476,7 @@ func handleGetScores(w http.ResponseWriter, r *http.Request, deps *Dependencies,
 	ctx := r.Context()
 
 	// Get the current term for the section
-	termInfo, err := deps.OSM.FetchActiveTermForSection(ctx, user, sectionID)
+	termInfo, err := term.NewService(deps.OSM, deps.Conns).GetActiveTermForSection(ctx, user, sectionID)
 	if err != nil {
 		slog.Error("admin.api.scores.term_fetch_failed",
 			"component", "admin_api",
@@ -409,24 +553,234 @@ func handleUpdateScores(w http.ResponseWriter, r *http.Request, deps *Dependenci
 		return
 	}
 
+	idempotencyKey := r.Header.Get("Idempotency-Key")
+	if len(idempotencyKey) > maxIdempotencyKeyLength {
+		writeJSONError(w, http.StatusBadRequest, "bad_request", "Idempotency-Key is too long")
+		return
+	}
+
 	// Validate points range
-	for _, update := range req.Updates {
-		if update.Points < -1000 || update.Points > 1000 {
-			writeJSONError(w, http.StatusBadRequest, "validation_error", "Points must be between -1000 and 1000")
+	for i, update := range req.Updates {
+		if update.Points < minPointsDelta || update.Points > maxPointsDelta {
+			writeValidationError(w, pointsRangeError(updatePointsField(i)))
 			return
 		}
 	}
+	if fieldErr := checkPointsStep(deps, session.OSMUserID, sectionID, req.Updates, updatePointsField); fieldErr != nil {
+		writeValidationError(w, fieldErr)
+		return
+	}
 
 	// Convert to service request format
+	minScore := sectionMinScore(deps, session.OSMUserID, sectionID)
 	serviceRequests := make([]scoreupdateservice.UpdateRequest, len(req.Updates))
 	for i, update := range req.Updates {
 		serviceRequests[i] = scoreupdateservice.UpdateRequest{
 			PatrolID: update.PatrolID,
 			Delta:    update.Points,
+			MinScore: minScore,
+		}
+	}
+
+	// Drop double-clicks before they count against the rate limit. A
+	// submission with an Idempotency-Key is recognised by its key instead, so
+	// a retry gets the first response.
+	dedupeKey := ""
+	if idempotencyKey == "" {
+		dedupeKey = scoreSubmissionDedupeKey(session.OSMUserID, sectionID, req.Updates)
+		if isDuplicateScoreSubmission(ctx, deps, dedupeKey, session.OSMUserID, sectionID) {
+			writeJSONError(w, http.StatusConflict, "duplicate_submission",
+				"An identical score update was just submitted. Wait a few seconds before sending it again if it was intended, "+
+					"or send an Idempotency-Key header so that retries are recognised.")
+			return
+		}
+	}
+
+	if allowed, retryAfter := checkSectionScoreRateLimit(ctx, deps, session.OSMUserID, sectionID); !allowed {
+		forgetScoreSubmission(ctx, deps, dedupeKey)
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+		writeJSONError(w, http.StatusTooManyRequests, "rate_limited", "Too many score updates for this section. Please try again later.")
+		return
+	}
+
+	// A retry of a submission that was already applied must not add the points again,
+	// however long after the first attempt it arrives. It gets the first response.
+	if idempotencyKey != "" {
+		if claimed, previous := claimIdempotencyKey(ctx, deps, session.OSMUserID, idempotencyKey); !claimed {
+			if previous == nil {
+				writeJSONError(w, http.StatusConflict, "duplicate_submission",
+					"These updates were already submitted with this Idempotency-Key and are still being sent to OSM.")
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.Write(previous)
+			return
+		}
+	}
+
+	// A client with a latency budget may ask us not to wait for OSM beyond it
+	if wait, ok := parsePreferWait(r.Header.Get("Prefer")); ok {
+		handleUpdateScoresWithin(ctx, w, deps, session, user, sectionID, serviceRequests, idempotencyKey, dedupeKey, wait)
+		return
+	}
+
+	accepted := time.Now()
+	results, err := runScoreUpdate(ctx, deps, session, user, sectionID, nil, serviceRequests)
+	if err != nil {
+		forgetIdempotencyKey(ctx, deps, session.OSMUserID, idempotencyKey)
+		forgetScoreSubmission(ctx, deps, dedupeKey)
+		writeJSONError(w, http.StatusBadGateway, "osm_error", "Failed to update scores")
+		return
+	}
+	if !anyPatrolApplied(results) {
+		forgetScoreSubmission(ctx, deps, dedupeKey)
+	}
+	metrics.ScoreUpdateSyncDuration.WithLabelValues("interactive").Observe(time.Since(accepted).Seconds())
+
+	response := AdminUpdateResponse{
+		Success: true,
+		Patrols: results,
+	}
+	rememberIdempotentResponse(ctx, deps, session.OSMUserID, idempotencyKey, &response)
+	writeJSON(w, response)
+}
+
+// handleUpdateScoresWithin runs the update detached from the request and waits at
+// most wait for it. If the budget runs out the update carries on in the background,
+// still recording its results, and the client gets 202 with optimistic results.
+// The update's audit entries share a batch ID so the client can await them.
+// idempotencyKey and dedupeKey are released if the update fails.
+func handleUpdateScoresWithin(ctx context.Context, w http.ResponseWriter, deps *Dependencies, session *db.WebSession, user types.User, sectionID int, serviceRequests []scoreupdateservice.UpdateRequest, idempotencyKey, dedupeKey string, wait time.Duration) {
+	batchID, err := generateUUID()
+	if err != nil {
+		slog.Error("admin.api.scores.batch_id_failed",
+			"component", "admin_api",
+			"event", "scores.error",
+			"error", err,
+		)
+		forgetIdempotencyKey(ctx, deps, session.OSMUserID, idempotencyKey)
+		forgetScoreSubmission(ctx, deps, dedupeKey)
+		writeJSONError(w, http.StatusInternalServerError, "internal_error", "Failed to create batch")
+		return
+	}
+	if err := scorebatch.Create(deps.Conns, batchID, session.OSMUserID); err != nil {
+		slog.Error("admin.api.scores.batch_create_failed",
+			"component", "admin_api",
+			"event", "scores.error",
+			"error", err,
+		)
+		forgetIdempotencyKey(ctx, deps, session.OSMUserID, idempotencyKey)
+		forgetScoreSubmission(ctx, deps, dedupeKey)
+		writeJSONError(w, http.StatusInternalServerError, "internal_error", "Failed to create batch")
+		return
+	}
+
+	type outcome struct {
+		results []AdminPatrolResult
+		err     error
+	}
+	done := make(chan outcome, 1)
+	// backgrounded is set once the client has been sent 202, so the sync is
+	// measured as background work from then on
+	var backgrounded atomic.Bool
+	accepted := time.Now()
+	go func() {
+		detached := context.WithoutCancel(ctx)
+		results, err := runScoreUpdate(detached, deps, session, user, sectionID, &batchID, serviceRequests)
+		if err != nil {
+			forgetIdempotencyKey(detached, deps, session.OSMUserID, idempotencyKey)
+			forgetScoreSubmission(detached, deps, dedupeKey)
+			recordBatchFailure(deps, batchID, len(serviceRequests), "Failed to update scores")
+		} else {
+			if !anyPatrolApplied(results) {
+				forgetScoreSubmission(detached, deps, dedupeKey)
+			}
+			mode := "interactive"
+			if backgrounded.Load() {
+				mode = "background"
+			}
+			metrics.ScoreUpdateSyncDuration.WithLabelValues(mode).Observe(time.Since(accepted).Seconds())
+			rememberIdempotentResponse(detached, deps, session.OSMUserID, idempotencyKey, &AdminUpdateResponse{
+				Success: true,
+				Patrols: results,
+				BatchID: batchID,
+			})
 		}
+		publishBatchDone(detached, deps, session.OSMUserID, batchID)
+		done <- outcome{results, err}
+	}()
+
+	w.Header().Set("Preference-Applied", fmt.Sprintf("wait=%d", int(wait.Seconds())))
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case o := <-done:
+		if o.err != nil {
+			writeJSONError(w, http.StatusBadGateway, "osm_error", "Failed to update scores")
+			return
+		}
+		writeJSON(w, AdminUpdateResponse{
+			Success: true,
+			Patrols: o.results,
+			BatchID: batchID,
+		})
+	case <-timer.C:
+		backgrounded.Store(true)
+		slog.Info("admin.api.scores.accepted",
+			"component", "admin_api",
+			"event", "scores.accepted",
+			"user_id", session.OSMUserID,
+			"section_id", sectionID,
+			"wait_seconds", wait.Seconds(),
+		)
+		// Report what the update is expected to do from the cached scores; the
+		// batch gives the outcome once OSM has confirmed it.
+		var currentScores []types.PatrolScore
+		if cached, err := services.GetCachedPatrolScores(ctx, deps.Conns, sectionID); err == nil {
+			currentScores = cached.Patrols
+		}
+		serviceResults := scoreupdateservice.AcceptedResponses(serviceRequests, currentScores)
+		results := make([]AdminPatrolResult, len(serviceResults))
+		for i, serviceResult := range serviceResults {
+			results[i] = adminPatrolResult(serviceResult)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(AdminUpdateResponse{
+			Success: true,
+			Patrols: results,
+			BatchID: batchID,
+		})
+	}
+}
+
+// adminPatrolResult converts a score update service result for the API. Unknown
+// scores are reported as zero.
+func adminPatrolResult(serviceResult scoreupdateservice.UpdateResponse) AdminPatrolResult {
+	result := AdminPatrolResult{
+		ID:               serviceResult.PatrolID,
+		Name:             serviceResult.PatrolName,
+		Success:          serviceResult.Success,
+		IsTemporaryError: serviceResult.IsTemporaryError,
+		RetryAfter:       serviceResult.RetryAfter,
+		ErrorMessage:     serviceResult.ErrorMessage,
+		Pending:          serviceResult.Pending,
+	}
+	if serviceResult.PreviousScore != nil {
+		result.PreviousScore = *serviceResult.PreviousScore
 	}
+	if serviceResult.NewScore != nil {
+		result.NewScore = *serviceResult.NewScore
+	}
+	return result
+}
 
-	// Call the score update service
+// runScoreUpdate sends the updates to OSM through the score update service and
+// records the results, tagged with batchID when non-nil.
+func runScoreUpdate(ctx context.Context, deps *Dependencies, session *db.WebSession, user types.User, sectionID int, batchID *string, serviceRequests []scoreupdateservice.UpdateRequest) ([]AdminPatrolResult, error) {
 	serviceResults, err := deps.ScoreUpdateService.UpdateScores(ctx, user, sectionID, serviceRequests)
 	if err != nil {
 		slog.Error("admin.api.scores.service_error",
@@ -435,50 +789,268 @@ func handleUpdateScores(w http.ResponseWriter, r *http.Request, deps *Dependenci
 			"section_id", sectionID,
 			"error", err,
 		)
-		writeJSONError(w, http.StatusBadGateway, "osm_error", "Failed to update scores")
-		return
+		return nil, err
 	}
 
-	// Convert service results to API response format and prepare audit logs
-	results := make([]AdminPatrolResult, 0, len(serviceResults))
-	auditLogs := make([]db.ScoreAuditLog, 0, len(serviceResults))
+	results := recordScoreResults(ctx, deps, session.OSMUserID, sectionID, batchID, adminAuditSource(session), serviceResults)
 
-	for _, serviceResult := range serviceResults {
-		result := AdminPatrolResult{
-			ID:               serviceResult.PatrolID,
-			Name:             serviceResult.PatrolName,
-			Success:          serviceResult.Success,
-			IsTemporaryError: serviceResult.IsTemporaryError,
-			RetryAfter:       serviceResult.RetryAfter,
-			ErrorMessage:     serviceResult.ErrorMessage,
-		}
+	slog.Info("admin.api.scores.updated",
+		"component", "admin_api",
+		"event", "scores.update_success",
+		"user_id", session.OSMUserID,
+		"section_id", sectionID,
+		"update_count", len(results),
+	)
+	return results, nil
+}
 
-		if serviceResult.PreviousScore != nil {
-			result.PreviousScore = *serviceResult.PreviousScore
+// parsePreferWait reads the RFC 7240 "wait" preference, e.g. "Prefer: wait=5".
+// The wait is capped at the service's own update timeout; a missing, malformed
+// or non-positive value means the client has no budget.
+func parsePreferWait(header string) (time.Duration, bool) {
+	for _, pref := range strings.Split(header, ",") {
+		name, value, found := strings.Cut(strings.TrimSpace(pref), "=")
+		if !found || !strings.EqualFold(strings.TrimSpace(name), "wait") {
+			continue
+		}
+		seconds, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || seconds <= 0 {
+			return 0, false
 		}
-		if serviceResult.NewScore != nil {
-			result.NewScore = *serviceResult.NewScore
+		wait := time.Duration(seconds) * time.Second
+		if wait > scoreupdateservice.DefaultUpdateTimeout {
+			wait = scoreupdateservice.DefaultUpdateTimeout
 		}
+		return wait, true
+	}
+	return 0, false
+}
+
+// checkSectionScoreRateLimit applies the per-user, per-section limit on score
+// submissions. Limiter errors allow the request rather than blocking leaders.
+func checkSectionScoreRateLimit(ctx context.Context, deps *Dependencies, osmUserID, sectionID int) (bool, time.Duration) {
+	result, err := deps.Conns.GetRateLimiter().CheckRateLimit(
+		ctx,
+		"admin_scores",
+		fmt.Sprintf("%d:%d", osmUserID, sectionID),
+		int64(deps.Config.RateLimit.AdminScoreRateLimit),
+		time.Minute,
+	)
+	if err != nil {
+		slog.Error("admin.api.scores.rate_limit_error",
+			"component", "admin_api",
+			"event", "scores.rate_limit_error",
+			"section_id", sectionID,
+			"error", err,
+		)
+		return true, 0
+	}
+	if !result.Allowed {
+		slog.Warn("admin.api.scores.rate_limited",
+			"component", "admin_api",
+			"event", "scores.rate_limited",
+			"user_id", osmUserID,
+			"section_id", sectionID,
+			"retry_after", result.RetryAfter.Seconds(),
+		)
+		return false, result.RetryAfter
+	}
+	return true, 0
+}
+
+// scoreSubmissionDedupeKey is the Redis key fingerprinting a user's set of
+// updates for a section, the same whatever order the updates are in.
+func scoreSubmissionDedupeKey(osmUserID, sectionID int, updates []AdminScoreUpdate) string {
+	parts := make([]string, len(updates))
+	for i, update := range updates {
+		parts[i] = fmt.Sprintf("%s:%d", update.PatrolID, update.Points)
+	}
+	sort.Strings(parts)
+	sum := sha256.Sum256([]byte(strings.Join(parts, ",")))
+	return fmt.Sprintf("score_dedupe:%d:%d:%s", osmUserID, sectionID, hex.EncodeToString(sum[:8]))
+}
+
+// isDuplicateScoreSubmission reports whether the submission fingerprinted by
+// dedupeKey was already made within duplicateSubmissionWindow. The first
+// submission records the fingerprint in Redis; Redis errors allow the request.
+func isDuplicateScoreSubmission(ctx context.Context, deps *Dependencies, dedupeKey string, osmUserID, sectionID int) bool {
+	if deps.Conns.Redis == nil {
+		return false
+	}
+
+	first, err := deps.Conns.Redis.SetNX(ctx, dedupeKey, "1", duplicateSubmissionWindow).Result()
+	if err != nil {
+		slog.Error("admin.api.scores.dedupe_error",
+			"component", "admin_api",
+			"event", "scores.dedupe_error",
+			"section_id", sectionID,
+			"error", err,
+		)
+		return false
+	}
+	if !first {
+		slog.Warn("admin.api.scores.duplicate",
+			"component", "admin_api",
+			"event", "scores.duplicate",
+			"user_id", osmUserID,
+			"section_id", sectionID,
+		)
+	}
+	return !first
+}
 
-		results = append(results, result)
+// forgetScoreSubmission releases the fingerprint of a submission that was
+// refused or failed, so the user can send it again straight away.
+func forgetScoreSubmission(ctx context.Context, deps *Dependencies, dedupeKey string) {
+	if dedupeKey == "" || deps.Conns.Redis == nil {
+		return
+	}
+	deps.Conns.Redis.Del(ctx, dedupeKey)
+}
+
+// idempotencyPending is stored under an Idempotency-Key while its submission
+// is still being sent to OSM.
+const idempotencyPending = "pending"
+
+// claimIdempotencyKey records that the user is submitting score updates with
+// this Idempotency-Key. If the key was already used within
+// SCORE_IDEMPOTENCY_KEY_TTL it is not claimed, and the response stored for the
+// earlier submission is returned, or nil while that submission is still
+// running. Redis errors allow the request.
+func claimIdempotencyKey(ctx context.Context, deps *Dependencies, osmUserID int, idempotencyKey string) (bool, []byte) {
+	if deps.Conns.Redis == nil {
+		return true, nil
+	}
+
+	key := idempotencyRedisKey(osmUserID, idempotencyKey)
+	ttl := time.Duration(deps.Config.ScoreUpdate.IdempotencyKeyTTL) * time.Second
+	first, err := deps.Conns.Redis.SetNX(ctx, key, idempotencyPending, ttl).Result()
+	if err != nil {
+		slog.Error("admin.api.scores.idempotency_error",
+			"component", "admin_api",
+			"event", "scores.idempotency_error",
+			"error", err,
+		)
+		return true, nil
+	}
+	if first {
+		return true, nil
+	}
+
+	slog.Warn("admin.api.scores.idempotency_key_reused",
+		"component", "admin_api",
+		"event", "scores.duplicate",
+		"user_id", osmUserID,
+	)
+	previous, err := deps.Conns.Redis.Get(ctx, key).Bytes()
+	if err != nil || string(previous) == idempotencyPending {
+		return false, nil
+	}
+	return false, previous
+}
+
+// rememberIdempotentResponse stores the response to a submission under its
+// Idempotency-Key, to be replayed to retries. If no patrol was updated, or
+// might have been, the key is released instead so the client can retry.
+func rememberIdempotentResponse(ctx context.Context, deps *Dependencies, osmUserID int, idempotencyKey string, response *AdminUpdateResponse) {
+	if idempotencyKey == "" || deps.Conns.Redis == nil {
+		return
+	}
+	if !anyPatrolApplied(response.Patrols) {
+		forgetIdempotencyKey(ctx, deps, osmUserID, idempotencyKey)
+		return
+	}
+
+	body, err := json.Marshal(response)
+	if err == nil {
+		ttl := time.Duration(deps.Config.ScoreUpdate.IdempotencyKeyTTL) * time.Second
+		err = deps.Conns.Redis.Set(ctx, idempotencyRedisKey(osmUserID, idempotencyKey), body, ttl).Err()
+	}
+	if err != nil {
+		slog.Error("admin.api.scores.idempotency_error",
+			"component", "admin_api",
+			"event", "scores.idempotency_error",
+			"error", err,
+		)
+	}
+}
+
+// anyPatrolApplied reports whether any of the results updated, or may yet
+// update, a patrol's score.
+func anyPatrolApplied(results []AdminPatrolResult) bool {
+	for _, patrol := range results {
+		if patrol.Success || patrol.Pending {
+			return true
+		}
+	}
+	return false
+}
+
+// forgetIdempotencyKey releases a key whose submission failed, so the client
+// can retry it.
+func forgetIdempotencyKey(ctx context.Context, deps *Dependencies, osmUserID int, idempotencyKey string) {
+	if idempotencyKey == "" || deps.Conns.Redis == nil {
+		return
+	}
+	deps.Conns.Redis.Del(ctx, idempotencyRedisKey(osmUserID, idempotencyKey))
+}
+
+func idempotencyRedisKey(osmUserID int, idempotencyKey string) string {
+	sum := sha256.Sum256([]byte(idempotencyKey))
+	return fmt.Sprintf("score_idempotency:%d:%s", osmUserID, hex.EncodeToString(sum[:16]))
+}
+
+// auditSource identifies the admin session or device behind a score change.
+type auditSource struct {
+	kind string // db.ScoreSourceAdmin or db.ScoreSourceDevice
+	id   string // truncated session ID or device code
+}
+
+func adminAuditSource(session *db.WebSession) auditSource {
+	return auditSource{kind: db.ScoreSourceAdmin, id: session.ID[:min(8, len(session.ID))]}
+}
+
+func deviceAuditSource(device *db.DeviceCode) auditSource {
+	return auditSource{kind: db.ScoreSourceDevice, id: device.DeviceCode[:min(8, len(device.DeviceCode))]}
+}
+
+// recordScoreResults converts service results to the API format, writes audit log
+// entries for successful updates (tagged with batchID when non-nil, which also
+// counts every outcome against the batch), and tells the section's devices to
+// refresh, sending the new scores along.
+func recordScoreResults(ctx context.Context, deps *Dependencies, osmUserID, sectionID int, batchID *string, source auditSource, serviceResults []scoreupdateservice.UpdateResponse) []AdminPatrolResult {
+	results := make([]AdminPatrolResult, 0, len(serviceResults))
+	auditLogs := make([]db.ScoreAuditLog, 0, len(serviceResults))
+
+	for _, serviceResult := range serviceResults {
+		results = append(results, adminPatrolResult(serviceResult))
 
 		// Only create audit log for successful updates
 		if serviceResult.Success && serviceResult.PreviousScore != nil && serviceResult.NewScore != nil {
 			pointsAdded := *serviceResult.NewScore - *serviceResult.PreviousScore
 			auditLogs = append(auditLogs, db.ScoreAuditLog{
-				OSMUserID:     session.OSMUserID,
+				OSMUserID:     osmUserID,
 				SectionID:     sectionID,
 				PatrolID:      serviceResult.PatrolID,
 				PatrolName:    serviceResult.PatrolName,
 				PreviousScore: *serviceResult.PreviousScore,
 				NewScore:      *serviceResult.NewScore,
 				PointsAdded:   pointsAdded,
+				BatchID:       batchID,
+				Source:        source.kind,
+				SourceID:      &source.id,
 			})
 		}
 	}
 
-	// Create audit log entries
+	if batchID != nil {
+		recordBatchOutcome(deps, *batchID, patrolResultsOutcome(results))
+	}
+
+	// Create audit log entries. Names come from the fresh OSM fetch, so a patrol
+	// renamed in OSM is audited under its current name.
 	if len(auditLogs) > 0 {
+		logPatrolRenames(deps, sectionID, auditLogs)
 		if err := scoreaudit.CreateBatch(deps.Conns, auditLogs); err != nil {
 			slog.Error("admin.api.scores.audit_log_failed",
 				"component", "admin_api",
@@ -489,21 +1061,9 @@ func handleUpdateScores(w http.ResponseWriter, r *http.Request, deps *Dependenci
 		}
 	}
 
-	slog.Info("admin.api.scores.updated",
-		"component", "admin_api",
-		"event", "scores.update_success",
-		"user_id", session.OSMUserID,
-		"section_id", sectionID,
-		"update_count", len(results),
-	)
-
-	// Invalidate per-device score cache for all devices in this section so that
-	// the WebSocket refresh prompt causes devices to fetch the updated scores.
-	if devices, err := devicecode.ListBySectionID(deps.Conns, sectionID); err == nil {
-		for _, d := range devices {
-			deps.Conns.Redis.Del(ctx, "patrol_scores:"+d.DeviceCode)
-		}
-	} else {
+	// Invalidate the section's score cache so that the WebSocket refresh
+	// prompt causes devices to fetch the updated scores.
+	if err := deps.Conns.Redis.Del(ctx, services.PatrolScoresCacheKey(sectionID)).Err(); err != nil {
 		slog.Warn("admin.api.scores.cache_invalidation_failed",
 			"component", "admin_api",
 			"event", "scores.cache_error",
@@ -513,13 +1073,69 @@ func handleUpdateScores(w http.ResponseWriter, r *http.Request, deps *Dependenci
 	}
 
 	if deps.WebSocketHub != nil {
-		deps.WebSocketHub.BroadcastToSection(strconv.Itoa(sectionID), wsinternal.RefreshScoresMessage())
+		deps.WebSocketHub.BroadcastToSection(strconv.Itoa(sectionID), wsinternal.RefreshScoresMessage(updatedPatrolScores(results)))
 	}
 
-	writeJSON(w, AdminUpdateResponse{
-		Success: true,
-		Patrols: results,
-	})
+	return results
+}
+
+// updatedPatrolScores returns the new score of each patrol that was
+// successfully updated, for the device refresh message.
+func updatedPatrolScores(results []AdminPatrolResult) []types.PatrolScore {
+	var patrols []types.PatrolScore
+	for _, result := range results {
+		if result.Success {
+			patrols = append(patrols, types.PatrolScore{ID: result.ID, Name: result.Name, Score: result.NewScore})
+		}
+	}
+	return patrols
+}
+
+// logPatrolRenames logs a rename event for each patrol whose current OSM name
+// differs from the name recorded in its latest audit entry.
+func logPatrolRenames(deps *Dependencies, sectionID int, auditLogs []db.ScoreAuditLog) {
+	renames := findPatrolRenames(deps.Conns, sectionID, auditLogs)
+	for _, log := range auditLogs {
+		if previousName, ok := renames[log.PatrolID]; ok {
+			slog.Info("admin.api.scores.patrol_renamed",
+				"component", "admin_api",
+				"event", "scores.patrol_renamed",
+				"section_id", sectionID,
+				"patrol_id", log.PatrolID,
+				"previous_name", previousName,
+				"patrol_name", log.PatrolName,
+			)
+		}
+	}
+}
+
+// findPatrolRenames returns the previously audited name of each patrol in auditLogs
+// that has since been renamed, keyed by patrol ID. Lookup failures are logged and
+// treated as no renames; they must not fail the update.
+func findPatrolRenames(conns *db.Connections, sectionID int, auditLogs []db.ScoreAuditLog) map[string]string {
+	patrolIDs := make([]string, len(auditLogs))
+	for i, log := range auditLogs {
+		patrolIDs[i] = log.PatrolID
+	}
+
+	previousNames, err := scoreaudit.LatestPatrolNames(conns, sectionID, patrolIDs)
+	if err != nil {
+		slog.Warn("admin.api.scores.rename_check_failed",
+			"component", "admin_api",
+			"event", "scores.audit_error",
+			"section_id", sectionID,
+			"error", err,
+		)
+		return nil
+	}
+
+	renames := make(map[string]string)
+	for _, log := range auditLogs {
+		if previous, ok := previousNames[log.PatrolID]; ok && previous != log.PatrolName {
+			renames[log.PatrolID] = previous
+		}
+	}
+	return renames
 }
 
 // handleGetAdhocScores handles GET /api/admin/sections/0/scores
@@ -575,10 +1191,11 @@ func handleUpdateAdhocScores(w http.ResponseWriter, r *http.Request, deps *Depen
 
 	results := make([]AdminPatrolResult, 0, len(req.Updates))
 	auditLogs := make([]db.ScoreAuditLog, 0, len(req.Updates))
+	source := adminAuditSource(session)
 
-	for _, update := range req.Updates {
-		if update.Points < -1000 || update.Points > 1000 {
-			writeJSONError(w, http.StatusBadRequest, "validation_error", "Points must be between -1000 and 1000")
+	for i, update := range req.Updates {
+		if update.Points < minPointsDelta || update.Points > maxPointsDelta {
+			writeValidationError(w, pointsRangeError(updatePointsField(i)))
 			return
 		}
 
@@ -635,6 +1252,8 @@ func handleUpdateAdhocScores(w http.ResponseWriter, r *http.Request, deps *Depen
 			PreviousScore: previousScore,
 			NewScore:      newScore,
 			PointsAdded:   update.Points,
+			Source:        source.kind,
+			SourceID:      &source.id,
 		})
 	}
 
@@ -661,7 +1280,7 @@ func handleUpdateAdhocScores(w http.ResponseWriter, r *http.Request, deps *Depen
 	)
 
 	if deps.WebSocketHub != nil {
-		deps.WebSocketHub.BroadcastToAdhocUser(strconv.Itoa(session.OSMUserID), wsinternal.RefreshScoresMessage())
+		deps.WebSocketHub.BroadcastToAdhocUser(strconv.Itoa(session.OSMUserID), wsinternal.RefreshScoresMessage(updatedPatrolScores(results)))
 	}
 
 	writeJSON(w, AdminUpdateResponse{
@@ -683,6 +1302,135 @@ var validColorNames = map[string]bool{
 	"white":   true,
 }
 
+// validIconNames is the set of allowed icon names for patrol icons.
+// Devices map these names to their own glyphs.
+var validIconNames = map[string]bool{
+	"eagle":   true,
+	"hawk":    true,
+	"falcon":  true,
+	"kestrel": true,
+	"owl":     true,
+	"fox":     true,
+	"wolf":    true,
+	"bear":    true,
+	"lion":    true,
+	"tiger":   true,
+	"otter":   true,
+	"badger":  true,
+	"beaver":  true,
+	"stag":    true,
+}
+
+// maxPointsStep bounds the points step setting to the largest allowed update.
+const maxPointsStep = 1000
+
+// validateSettingsUpdateRequest checks patrol colors, icons, layout and the
+// score settings, returning the first invalid field.
+func validateSettingsUpdateRequest(req *AdminSettingsUpdateRequest) *FieldError {
+	for patrolID, color := range req.PatrolColors {
+		if color != "" && !validColorNames[color] {
+			return &FieldError{
+				Field:      "patrolColors." + patrolID,
+				Constraint: ConstraintOneOf,
+				Message:    "Invalid color for patrol " + patrolID + ": must be a valid color name",
+			}
+		}
+	}
+	for patrolID, icon := range req.PatrolIcons {
+		if icon != "" && !validIconNames[icon] {
+			return &FieldError{
+				Field:      "patrolIcons." + patrolID,
+				Constraint: ConstraintOneOf,
+				Message:    "Invalid icon for patrol " + patrolID + ": must be a valid icon name",
+			}
+		}
+	}
+	if req.Layout != nil && !validLayouts[*req.Layout] {
+		return &FieldError{
+			Field:      "layout",
+			Constraint: ConstraintOneOf,
+			Message:    "Invalid layout: must be one of landscape, portrait",
+		}
+	}
+	if req.PointsStep != nil && (*req.PointsStep < 0 || *req.PointsStep > maxPointsStep) {
+		low, high := 0, maxPointsStep
+		return &FieldError{
+			Field:      "pointsStep",
+			Constraint: ConstraintRange,
+			Min:        &low,
+			Max:        &high,
+			Message:    fmt.Sprintf("Invalid points step: must be between 0 and %d", maxPointsStep),
+		}
+	}
+	if req.MinScore != nil && req.ClearMinScore {
+		return &FieldError{
+			Field:      "minScore",
+			Constraint: ConstraintConflict,
+			Message:    "Cannot set and clear the minimum score together",
+		}
+	}
+	if req.HideBelowScore != nil && req.ClearHideBelowScore {
+		return &FieldError{
+			Field:      "hideBelowScore",
+			Constraint: ConstraintConflict,
+			Message:    "Cannot set and clear the hiding threshold together",
+		}
+	}
+	return nil
+}
+
+// checkPointsStep rejects updates that are not multiples of the section's points
+// step when the user has chosen to enforce it. Settings that cannot be read are
+// not allowed to block scoring.
+func checkPointsStep(deps *Dependencies, osmUserID, sectionID int, updates []AdminScoreUpdate, pointsField func(int) string) *FieldError {
+	settings, err := sectionsettings.GetParsed(deps.Conns, osmUserID, sectionID)
+	if err != nil {
+		slog.Warn("admin.api.scores.points_step_unavailable",
+			"component", "admin_api",
+			"event", "scores.settings_error",
+			"section_id", sectionID,
+			"error", err,
+		)
+		return nil
+	}
+	if !settings.EnforcePointsStep || settings.PointsStep <= 1 {
+		return nil
+	}
+	for i, update := range updates {
+		if update.Points%settings.PointsStep != 0 {
+			return &FieldError{
+				Field:      pointsField(i),
+				Constraint: ConstraintMultipleOf,
+				Message:    fmt.Sprintf("points must be a multiple of %d", settings.PointsStep),
+			}
+		}
+	}
+	return nil
+}
+
+// sectionMinScore returns the section's minimum score setting, or nil if there
+// is none or it cannot be read.
+func sectionMinScore(deps *Dependencies, osmUserID, sectionID int) *int {
+	settings, err := sectionsettings.GetParsed(deps.Conns, osmUserID, sectionID)
+	if err != nil {
+		slog.Warn("admin.api.scores.min_score_unavailable",
+			"component", "admin_api",
+			"event", "scores.settings_error",
+			"section_id", sectionID,
+			"error", err,
+		)
+		return nil
+	}
+	return settings.MinScore
+}
+
+// validLayouts is the set of allowed scoreboard layouts. Empty clears the setting.
+var validLayouts = map[string]bool{
+	"":                    true,
+	types.LayoutLandscape: true,
+	types.LayoutPortrait:  true,
+}
+
 // AdminSettingsHandler handles both GET and PUT for /api/admin/sections/{sectionId}/settings
 func AdminSettingsHandler(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -696,7 +1444,7 @@ func AdminSettingsHandler(deps *Dependencies) http.HandlerFunc {
 		// Parse section ID from URL path
 		// Expected format: /api/admin/sections/{sectionId}/settings
 		path := r.URL.Path
-		prefix := "/api/admin/sections/"
+		prefix := deps.Config.Paths.AdminAPIPrefix + "/sections/"
 		suffix := "/settings"
 
 		if !strings.HasPrefix(path, prefix) || !strings.HasSuffix(path, suffix) {
@@ -713,6 +1461,9 @@ func AdminSettingsHandler(deps *Dependencies) http.HandlerFunc {
 
 		// Ad-hoc section: settings are the patrol list with colors
 		if sectionID == 0 {
+			if !requireFeature(w, deps, config.FeatureAdhocTeams) {
+				return
+			}
 			switch r.Method {
 			case http.MethodGet:
 				handleGetAdhocSettings(w, deps, session)
@@ -733,7 +1484,7 @@ func AdminSettingsHandler(deps *Dependencies) http.HandlerFunc {
 				"event", "settings.error",
 				"error", err,
 			)
-			writeJSONError(w, http.StatusBadGateway, "osm_error", "Failed to validate section access")
+			writeProfileFetchError(w, err, "Failed to validate section access")
 			return
 		}
 
@@ -817,11 +1568,15 @@ func handleUpdateAdhocSettings(w http.ResponseWriter, r *http.Request, deps *Dep
 		req.PatrolColors = make(map[string]string)
 	}
 
-	// Validate and apply colors to ad-hoc patrols
+	// Validate every color before applying any
+	colors := make(map[int64]string, len(req.PatrolColors))
 	for patrolIDStr, color := range req.PatrolColors {
 		if color != "" && !validColorNames[color] {
-			writeJSONError(w, http.StatusBadRequest, "validation_error",
-				"Invalid color for patrol "+patrolIDStr+": must be a valid color name")
+			writeValidationError(w, &FieldError{
+				Field:      "patrolColors." + patrolIDStr,
+				Constraint: ConstraintOneOf,
+				Message:    "Invalid color for patrol " + patrolIDStr + ": must be a valid color name",
+			})
 			return
 		}
 
@@ -829,14 +1584,18 @@ func handleUpdateAdhocSettings(w http.ResponseWriter, r *http.Request, deps *Dep
 		if err != nil {
 			continue
 		}
+		colors[patrolID] = color
+	}
 
-		// Fetch the patrol to preserve its name
-		patrol, err := adhocpatrol.FindByIDAndUser(deps.Conns, patrolID, session.OSMUserID)
-		if err != nil {
-			continue
-		}
-
-		adhocpatrol.Update(deps.Conns, patrolID, session.OSMUserID, patrol.Name, color)
+	if err := adhocpatrol.UpdateColors(deps.Conns, session.OSMUserID, colors); err != nil {
+		slog.Error("admin.api.adhoc_settings.db_update_failed",
+			"component", "admin_api",
+			"event", "adhoc_settings.error",
+			"user_id", session.OSMUserID,
+			"error", err,
+		)
+		writeJSONError(w, http.StatusInternalServerError, "internal_error", "Failed to save settings")
+		return
 	}
 
 	slog.Info("admin.api.adhoc_settings.updated",
@@ -857,7 +1616,7 @@ func handleGetSettings(w http.ResponseWriter, r *http.Request, deps *Dependencie
 	ctx := r.Context()
 
 	// Get the current term for the section to fetch patrols
-	termInfo, err := deps.OSM.FetchActiveTermForSection(ctx, user, sectionID)
+	termInfo, err := term.NewService(deps.OSM, deps.Conns).GetActiveTermForSection(ctx, user, sectionID)
 	if err != nil {
 		slog.Error("admin.api.settings.term_fetch_failed",
 			"component", "admin_api",
@@ -913,9 +1672,15 @@ func handleGetSettings(w http.ResponseWriter, r *http.Request, deps *Dependencie
 	)
 
 	writeJSON(w, AdminSettingsResponse{
-		SectionID:    sectionID,
-		PatrolColors: settings.PatrolColors,
-		Patrols:      patrolInfos,
+		SectionID:         sectionID,
+		PatrolColors:      settings.PatrolColors,
+		PatrolIcons:       settings.PatrolIcons,
+		Layout:            settings.Layout,
+		PointsStep:        settings.PointsStep,
+		EnforcePointsStep: settings.EnforcePointsStep,
+		MinScore:          settings.MinScore,
+		HideBelowScore:    settings.HideBelowScore,
+		Patrols:           patrolInfos,
 	})
 }
 
@@ -944,21 +1709,38 @@ func handleUpdateSettings(w http.ResponseWriter, r *http.Request, deps *Dependen
 		return
 	}
 
-	// Validate patrol colors
-	if req.PatrolColors == nil {
-		req.PatrolColors = make(map[string]string)
+	// Validate patrol colors, icons and layout
+	if fieldErr := validateSettingsUpdateRequest(&req); fieldErr != nil {
+		writeValidationError(w, fieldErr)
+		return
 	}
 
-	for patrolID, color := range req.PatrolColors {
-		if color != "" && !validColorNames[color] {
-			writeJSONError(w, http.StatusBadRequest, "validation_error",
-				"Invalid color for patrol "+patrolID+": must be a valid color name")
-			return
+	// Update settings in database. Every change is written together so a
+	// failure leaves the previous settings untouched.
+	err := sectionsettings.Update(deps.Conns, session.OSMUserID, sectionID, func(settings *sectionsettings.SettingsJSON) {
+		if req.PatrolColors != nil {
+			settings.PatrolColors = req.PatrolColors
 		}
-	}
-
-	// Update settings in database
-	if err := sectionsettings.UpsertPatrolColors(deps.Conns, session.OSMUserID, sectionID, req.PatrolColors); err != nil {
+		if req.PatrolIcons != nil {
+			settings.PatrolIcons = req.PatrolIcons
+		}
+		if req.Layout != nil {
+			settings.Layout = *req.Layout
+		}
+		if req.PointsStep != nil {
+			settings.PointsStep = *req.PointsStep
+		}
+		if req.EnforcePointsStep != nil {
+			settings.EnforcePointsStep = *req.EnforcePointsStep
+		}
+		if req.MinScore != nil || req.ClearMinScore {
+			settings.MinScore = req.MinScore
+		}
+		if req.HideBelowScore != nil || req.ClearHideBelowScore {
+			settings.HideBelowScore = req.HideBelowScore
+		}
+	})
+	if err != nil {
 		slog.Error("admin.api.settings.db_update_failed",
 			"component", "admin_api",
 			"event", "settings.error",
@@ -969,18 +1751,39 @@ func handleUpdateSettings(w http.ResponseWriter, r *http.Request, deps *Dependen
 		return
 	}
 
+	settings, err := sectionsettings.GetParsed(deps.Conns, session.OSMUserID, sectionID)
+	if err != nil {
+		slog.Error("admin.api.settings.db_fetch_failed",
+			"component", "admin_api",
+			"event", "settings.error",
+			"section_id", sectionID,
+			"error", err,
+		)
+		writeJSONError(w, http.StatusInternalServerError, "internal_error", "Failed to fetch settings")
+		return
+	}
+
 	slog.Info("admin.api.settings.updated",
 		"component", "admin_api",
 		"event", "settings.update_success",
 		"user_id", session.OSMUserID,
 		"section_id", sectionID,
-		"color_count", len(req.PatrolColors),
+		"color_count", len(settings.PatrolColors),
+		"icon_count", len(settings.PatrolIcons),
+		"layout", settings.Layout,
+		"points_step", settings.PointsStep,
 	)
 
 	// Return the updated settings
 	writeJSON(w, AdminSettingsResponse{
-		SectionID:    sectionID,
-		PatrolColors: req.PatrolColors,
-		Patrols:      nil, // Don't need to fetch patrols again for PUT response
+		SectionID:         sectionID,
+		PatrolColors:      settings.PatrolColors,
+		PatrolIcons:       settings.PatrolIcons,
+		Layout:            settings.Layout,
+		PointsStep:        settings.PointsStep,
+		EnforcePointsStep: settings.EnforcePointsStep,
+		MinScore:          settings.MinScore,
+		HideBelowScore:    settings.HideBelowScore,
+		Patrols:           nil, // Don't need to fetch patrols again for PUT response
 	})
 }
@@ -0,0 +1,1114 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/m0rjc/OsmDeviceAdapter/internal/config"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db/scoreaudit"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db/sectionsettings"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db/websession"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/middleware"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/osm"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/osm/demo"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/services"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/services/scoreupdateservice"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/types"
+)
+
+const (
+	settingsTestSectionID = 100
+	settingsTestSessionID = "settings-test-session"
+	settingsTestCSRF      = "settings-test-csrf"
+)
+
+// setupSettingsTestDeps creates admin test dependencies with a logged-in session
+// and a mock OSM server that grants access to one section with two patrols.
+func setupSettingsTestDeps(t *testing.T) *Dependencies {
+	t.Helper()
+	return setupAdminAPITestDeps(t, map[string]osm.PatrolData{
+		"1": {PatrolID: "1", Name: "Eagles", Points: "10", Members: []any{"a"}},
+		"2": {PatrolID: "2", Name: "Hawks", Points: "20", Members: []any{"b"}},
+	})
+}
+
+// setupAdminAPITestDeps creates admin test dependencies with a logged-in session
+// and a mock OSM server that grants access to the test section, plus any extra
+// sections, each with the given patrols. Patrol score updates are accepted
+// without changing the patrols.
+func setupAdminAPITestDeps(t *testing.T, patrols map[string]osm.PatrolData, extraSectionIDs ...int) *Dependencies {
+	t.Helper()
+
+	deps, mr := setupAdminTestDeps(t)
+	t.Cleanup(mr.Close)
+
+	now := time.Now()
+	useOSMHandler(t, deps, adminAPIOSMHandler(patrols, append([]int{settingsTestSectionID}, extraSectionIDs...)...))
+	deps.Config.RateLimit.AdminScoreRateLimit = 30
+
+	session := &db.WebSession{
+		ID:              settingsTestSessionID,
+		OSMUserID:       12345,
+		OSMAccessToken:  "test-token",
+		OSMRefreshToken: "test-refresh",
+		OSMTokenExpiry:  now.Add(time.Hour),
+		CSRFToken:       settingsTestCSRF,
+		CreatedAt:       now,
+		LastActivity:    now,
+		ExpiresAt:       now.Add(24 * time.Hour),
+	}
+	if err := websession.Create(deps.Conns, session); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	return deps
+}
+
+// adminAPIOSMHandler mocks OSM for a user with access to the given sections, each
+// reporting the given patrols. Patrol score updates are accepted without changing the patrols.
+func adminAPIOSMHandler(patrols map[string]osm.PatrolData, sectionIDs ...int) http.HandlerFunc {
+	now := time.Now()
+	terms := []types.OSMTerm{{
+		TermID:    1,
+		StartDate: now.AddDate(0, -1, 0).Format("2006-01-02"),
+		EndDate:   now.AddDate(0, 1, 0).Format("2006-01-02"),
+	}}
+	sections := make([]types.OSMSection, len(sectionIDs))
+	for i, id := range sectionIDs {
+		sections[i] = types.OSMSection{SectionID: id, Terms: terms}
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/oauth/resource":
+			json.NewEncoder(w).Encode(types.OSMProfileResponse{
+				Status: true,
+				Data: &types.OSMProfileData{
+					UserID:   12345,
+					Sections: sections,
+				},
+			})
+		case "/ext/members/patrols/":
+			if r.Method == http.MethodPost {
+				w.Write([]byte("[]"))
+				return
+			}
+			json.NewEncoder(w).Encode(patrols)
+		default:
+			http.NotFound(w, r)
+		}
+	}
+}
+
+// useOSMHandler points deps' OSM client and score update service at a mock OSM server.
+func useOSMHandler(t *testing.T, deps *Dependencies, handler http.HandlerFunc) {
+	t.Helper()
+	osmServer := httptest.NewServer(handler)
+	t.Cleanup(osmServer.Close)
+	deps.OSM = newMockOSMClient(osmServer.URL)
+	deps.ScoreUpdateService = scoreupdateservice.New(deps.OSM, deps.Conns, 4, 0)
+}
+
+// doSettingsRequest sends a settings request for the test section through the session middleware.
+func doSettingsRequest(t *testing.T, deps *Dependencies, method string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	return doSettingsRequestFor(t, deps, method, fmt.Sprintf("/api/admin/sections/%d/settings", settingsTestSectionID), settingsTestCSRF, body)
+}
+
+// doSettingsRequestFor sends a settings request to path with the given CSRF token.
+func doSettingsRequestFor(t *testing.T, deps *Dependencies, method, path, csrfToken string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	return doAdminRequest(t, deps, AdminSettingsHandler(deps), method, path, csrfToken, body)
+}
+
+// doAdminRequest sends a JSON request for the test session to handler through the session middleware.
+func doAdminRequest(t *testing.T, deps *Dependencies, handler http.Handler, method, path, csrfToken string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("Failed to marshal request body: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.AddCookie(&http.Cookie{Name: AdminSessionCookieName, Value: settingsTestSessionID})
+	if csrfToken != "" {
+		req.Header.Set("X-CSRF-Token", csrfToken)
+	}
+	w := httptest.NewRecorder()
+
+	middleware.SessionMiddleware(deps.Conns, AdminSessionCookieName)(handler).ServeHTTP(w, req)
+	return w
+}
+
+func TestAdminSettingsHandler_PatrolIconsRoundTrip(t *testing.T) {
+	deps := setupSettingsTestDeps(t)
+
+	w := doSettingsRequest(t, deps, http.MethodPut, AdminSettingsUpdateRequest{
+		PatrolColors: map[string]string{"1": "red"},
+		PatrolIcons:  map[string]string{"1": "eagle", "2": "hawk"},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("PUT: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = doSettingsRequest(t, deps, http.MethodGet, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp AdminSettingsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.PatrolIcons["1"] != "eagle" || resp.PatrolIcons["2"] != "hawk" {
+		t.Errorf("expected icons eagle/hawk, got %v", resp.PatrolIcons)
+	}
+	if resp.PatrolColors["1"] != "red" {
+		t.Errorf("expected color red for patrol 1, got %v", resp.PatrolColors)
+	}
+	if len(resp.Patrols) != 2 {
+		t.Errorf("expected 2 patrols, got %d", len(resp.Patrols))
+	}
+
+	// Updating colors alone must leave icons untouched
+	w = doSettingsRequest(t, deps, http.MethodPut, AdminSettingsUpdateRequest{
+		PatrolColors: map[string]string{"1": "blue"},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("second PUT: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.PatrolIcons["1"] != "eagle" {
+		t.Errorf("expected icons to be preserved, got %v", resp.PatrolIcons)
+	}
+}
+
+func TestAdminSettingsHandler_RejectsUnknownIcon(t *testing.T) {
+	deps := setupSettingsTestDeps(t)
+
+	w := doSettingsRequest(t, deps, http.MethodPut, AdminSettingsUpdateRequest{
+		PatrolIcons: map[string]string{"1": "dragon"},
+	})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestAdminSettingsHandler_GetReturnsPatrolsAndStoredColors(t *testing.T) {
+	deps := setupSettingsTestDeps(t)
+
+	// Nothing stored yet: patrol list from OSM, no colors
+	w := doSettingsRequest(t, deps, http.MethodGet, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp AdminSettingsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.SectionID != settingsTestSectionID {
+		t.Errorf("expected section %d, got %d", settingsTestSectionID, resp.SectionID)
+	}
+	if len(resp.Patrols) != 2 {
+		t.Fatalf("expected 2 patrols, got %d", len(resp.Patrols))
+	}
+	names := map[string]string{}
+	for _, p := range resp.Patrols {
+		names[p.ID] = p.Name
+	}
+	if names["1"] != "Eagles" || names["2"] != "Hawks" {
+		t.Errorf("unexpected patrol names: %v", names)
+	}
+	if len(resp.PatrolColors) != 0 {
+		t.Errorf("expected no colors, got %v", resp.PatrolColors)
+	}
+
+	// PUT replaces the colors and echoes them back
+	w = doSettingsRequest(t, deps, http.MethodPut, AdminSettingsUpdateRequest{
+		PatrolColors: map[string]string{"1": "green", "2": "yellow"},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("PUT: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.PatrolColors["1"] != "green" || resp.PatrolColors["2"] != "yellow" {
+		t.Errorf("unexpected colors in PUT response: %v", resp.PatrolColors)
+	}
+
+	w = doSettingsRequest(t, deps, http.MethodGet, nil)
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.PatrolColors["1"] != "green" || resp.PatrolColors["2"] != "yellow" {
+		t.Errorf("expected stored colors on GET, got %v", resp.PatrolColors)
+	}
+}
+
+func TestAdminSettingsHandler_PutValidation(t *testing.T) {
+	validBody := AdminSettingsUpdateRequest{PatrolColors: map[string]string{"1": "red"}}
+	path := fmt.Sprintf("/api/admin/sections/%d/settings", settingsTestSectionID)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		csrfToken  string
+		body       any
+		wantStatus int
+	}{
+		{"missing CSRF token", http.MethodPut, path, "", validBody, http.StatusForbidden},
+		{"wrong CSRF token", http.MethodPut, path, "wrong-token", validBody, http.StatusForbidden},
+		{"invalid color", http.MethodPut, path, settingsTestCSRF, AdminSettingsUpdateRequest{PatrolColors: map[string]string{"1": "puce"}}, http.StatusBadRequest},
+		{"invalid layout", http.MethodPut, path, settingsTestCSRF, map[string]any{"layout": "diagonal"}, http.StatusBadRequest},
+		{"section without access", http.MethodPut, "/api/admin/sections/999/settings", settingsTestCSRF, validBody, http.StatusForbidden},
+		{"invalid section ID", http.MethodGet, "/api/admin/sections/abc/settings", settingsTestCSRF, nil, http.StatusBadRequest},
+		{"method not allowed", http.MethodPost, path, settingsTestCSRF, validBody, http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := setupSettingsTestDeps(t)
+
+			w := doSettingsRequestFor(t, deps, tt.method, tt.path, tt.csrfToken, tt.body)
+			if w.Code != tt.wantStatus {
+				t.Errorf("expected %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestAdminSettingsHandler_InvalidColorPersistsNothing(t *testing.T) {
+	deps := setupSettingsTestDeps(t)
+
+	layout := "portrait"
+	w := doSettingsRequest(t, deps, http.MethodPut, AdminSettingsUpdateRequest{
+		PatrolColors: map[string]string{"1": "red", "2": "puce", "3": "blue"},
+		Layout:       &layout,
+	})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp AdminErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(resp.Fields) != 1 || resp.Fields[0].Field != "patrolColors.2" || resp.Fields[0].Constraint != ConstraintOneOf {
+		t.Errorf("expected a field error on patrolColors.2, got %+v", resp.Fields)
+	}
+
+	settings, err := sectionsettings.GetParsed(deps.Conns, 12345, settingsTestSectionID)
+	if err != nil {
+		t.Fatalf("GetParsed failed: %v", err)
+	}
+	if len(settings.PatrolColors) != 0 || settings.Layout != "" {
+		t.Errorf("expected nothing persisted, got colors %v and layout %q", settings.PatrolColors, settings.Layout)
+	}
+}
+
+func TestAdminScoresHandler_AuditRecordsRenamedPatrol(t *testing.T) {
+	// OSM now calls patrol 1 "Golden Eagles"
+	deps := setupAdminAPITestDeps(t, map[string]osm.PatrolData{
+		"1": {PatrolID: "1", Name: "Golden Eagles", Points: "10", Members: []any{"a"}},
+		"2": {PatrolID: "2", Name: "Hawks", Points: "20", Members: []any{"b"}},
+	})
+
+	// Earlier updates were audited under the old names
+	if err := scoreaudit.CreateBatch(deps.Conns, []db.ScoreAuditLog{
+		{OSMUserID: 12345, SectionID: settingsTestSectionID, PatrolID: "1", PatrolName: "Eagles", PreviousScore: 5, NewScore: 10, PointsAdded: 5},
+		{OSMUserID: 12345, SectionID: settingsTestSectionID, PatrolID: "2", PatrolName: "Hawks", PreviousScore: 15, NewScore: 20, PointsAdded: 5},
+	}); err != nil {
+		t.Fatalf("Failed to create audit logs: %v", err)
+	}
+
+	body, _ := json.Marshal(AdminUpdateRequest{Updates: []AdminScoreUpdate{
+		{PatrolID: "1", Points: 3},
+		{PatrolID: "2", Points: 2},
+	}})
+	req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/admin/sections/%d/scores", settingsTestSectionID), bytes.NewReader(body))
+	req.AddCookie(&http.Cookie{Name: AdminSessionCookieName, Value: settingsTestSessionID})
+	req.Header.Set("X-CSRF-Token", settingsTestCSRF)
+	w := httptest.NewRecorder()
+	middleware.SessionMiddleware(deps.Conns, AdminSessionCookieName)(AdminScoresHandler(deps)).ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	names, err := scoreaudit.LatestPatrolNames(deps.Conns, settingsTestSectionID, []string{"1", "2"})
+	if err != nil {
+		t.Fatalf("LatestPatrolNames failed: %v", err)
+	}
+	if names["1"] != "Golden Eagles" {
+		t.Errorf("expected audit to record the current name, got %q", names["1"])
+	}
+	if names["2"] != "Hawks" {
+		t.Errorf("expected unchanged name Hawks, got %q", names["2"])
+	}
+}
+
+func TestAdminScoresHandler_AuditsCoalescedUpdateOnce(t *testing.T) {
+	deps := setupAdminAPITestDeps(t, map[string]osm.PatrolData{
+		"1": {PatrolID: "1", Name: "Eagles", Points: "10", Members: []any{"a"}},
+	})
+
+	// Two entries for the same patrol are sent to OSM as one write
+	body, _ := json.Marshal(AdminUpdateRequest{Updates: []AdminScoreUpdate{
+		{PatrolID: "1", Points: 3},
+		{PatrolID: "1", Points: 4},
+	}})
+	req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/admin/sections/%d/scores", settingsTestSectionID), bytes.NewReader(body))
+	req.AddCookie(&http.Cookie{Name: AdminSessionCookieName, Value: settingsTestSessionID})
+	req.Header.Set("X-CSRF-Token", settingsTestCSRF)
+	w := httptest.NewRecorder()
+	middleware.SessionMiddleware(deps.Conns, AdminSessionCookieName)(AdminScoresHandler(deps)).ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	entries, err := scoreaudit.ListBySection(deps.Conns, settingsTestSectionID, 0, 10, nil)
+	if err != nil {
+		t.Fatalf("ListBySection failed: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected one audit entry for the coalesced write, got %d", len(entries))
+	}
+	entry := entries[0]
+	if entry.PreviousScore != 10 || entry.NewScore != 17 || entry.PointsAdded != 7 {
+		t.Errorf("expected 10 -> 17 (+7), got %d -> %d (%+d)", entry.PreviousScore, entry.NewScore, entry.PointsAdded)
+	}
+	if entry.OSMUserID != 12345 {
+		t.Errorf("expected acting user 12345, got %d", entry.OSMUserID)
+	}
+}
+
+func TestFindPatrolRenames(t *testing.T) {
+	deps := setupSettingsTestDeps(t)
+
+	if err := scoreaudit.CreateBatch(deps.Conns, []db.ScoreAuditLog{
+		{OSMUserID: 1, SectionID: settingsTestSectionID, PatrolID: "1", PatrolName: "Eagles"},
+		{OSMUserID: 1, SectionID: settingsTestSectionID, PatrolID: "2", PatrolName: "Hawks"},
+	}); err != nil {
+		t.Fatalf("Failed to create audit logs: %v", err)
+	}
+
+	renames := findPatrolRenames(deps.Conns, settingsTestSectionID, []db.ScoreAuditLog{
+		{PatrolID: "1", PatrolName: "Golden Eagles"},
+		{PatrolID: "2", PatrolName: "Hawks"},
+		{PatrolID: "3", PatrolName: "Owls"}, // no history
+	})
+
+	if len(renames) != 1 || renames["1"] != "Eagles" {
+		t.Errorf("expected only patrol 1 renamed from Eagles, got %v", renames)
+	}
+}
+
+func TestAdminSectionsHandler_ProfileUnauthorizedReturnsAccessRevoked(t *testing.T) {
+	deps := setupSettingsTestDeps(t)
+
+	revokedServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	t.Cleanup(revokedServer.Close)
+	deps.OSM = newMockOSMClient(revokedServer.URL)
+
+	w := doAdminRequest(t, deps, AdminSectionsHandler(deps), http.MethodGet, "/api/admin/sections", "", nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp AdminErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Error != "access_revoked" {
+		t.Errorf("expected error access_revoked, got %q", resp.Error)
+	}
+	if !resp.Reauthenticate {
+		t.Error("expected reauthenticate flag to be set")
+	}
+}
+
+func TestParsePreferWait(t *testing.T) {
+	tests := []struct {
+		header string
+		want   time.Duration
+		ok     bool
+	}{
+		{"", 0, false},
+		{"wait=5", 5 * time.Second, true},
+		{"respond-async, wait=2", 2 * time.Second, true},
+		{"wait=0", 0, false},
+		{"wait=abc", 0, false},
+		{"wait=3600", scoreupdateservice.DefaultUpdateTimeout, true},
+	}
+	for _, tt := range tests {
+		got, ok := parsePreferWait(tt.header)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parsePreferWait(%q) = %v, %v; want %v, %v", tt.header, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestAdminScoresHandler_PreferWaitReturnsAcceptedWhenBudgetExceeded(t *testing.T) {
+	deps := setupSettingsTestDeps(t)
+
+	// Hold OSM's score write until the test has seen the response
+	release := make(chan struct{})
+	osmHandler := adminAPIOSMHandler(map[string]osm.PatrolData{
+		"1": {PatrolID: "1", Name: "Eagles", Points: "10", Members: []any{"a"}},
+	}, settingsTestSectionID)
+	useOSMHandler(t, deps, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ext/members/patrols/" && r.Method == http.MethodPost {
+			<-release
+		}
+		osmHandler(w, r)
+	})
+
+	// The expected scores are reported from the section's cached scores
+	cached, _ := json.Marshal(services.CachedPatrolScores{
+		Patrols: []types.PatrolScore{{ID: "1", Name: "Eagles", Score: 10}},
+	})
+	deps.Conns.Redis.Set(context.Background(), services.PatrolScoresCacheKey(settingsTestSectionID), cached, time.Minute)
+
+	body, _ := json.Marshal(AdminUpdateRequest{Updates: []AdminScoreUpdate{
+		{PatrolID: "1", Points: 5},
+		{PatrolID: "2", Points: 3},
+		{PatrolID: "1", Points: 2},
+	}})
+	req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/admin/sections/%d/scores", settingsTestSectionID), bytes.NewReader(body))
+	req.AddCookie(&http.Cookie{Name: AdminSessionCookieName, Value: settingsTestSessionID})
+	req.Header.Set("X-CSRF-Token", settingsTestCSRF)
+	req.Header.Set("Prefer", "wait=1")
+	w := httptest.NewRecorder()
+
+	start := time.Now()
+	middleware.SessionMiddleware(deps.Conns, AdminSessionCookieName)(AdminScoresHandler(deps)).ServeHTTP(w, req)
+	elapsed := time.Since(start)
+	close(release)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+	if elapsed > 3*time.Second {
+		t.Errorf("expected the response within the client's budget, took %v", elapsed)
+	}
+
+	var resp AdminUpdateResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(resp.Patrols) != 2 {
+		t.Fatalf("expected one result per patrol, got %+v", resp.Patrols)
+	}
+	eagles := resp.Patrols[0]
+	if eagles.ID != "1" || eagles.Name != "Eagles" || eagles.PreviousScore != 10 || eagles.NewScore != 17 {
+		t.Errorf("expected patrol 1 to be reported going from 10 to 17, got %+v", eagles)
+	}
+	uncached := resp.Patrols[1]
+	if uncached.ID != "2" || uncached.Name != "" || uncached.PreviousScore != 0 || uncached.NewScore != 0 {
+		t.Errorf("expected no scores for patrol 2, which is not cached, got %+v", uncached)
+	}
+	for _, patrol := range resp.Patrols {
+		if patrol.Success || !patrol.Pending || patrol.ErrorMessage == nil {
+			t.Errorf("expected patrol %s to be reported pending, not successful, got %+v", patrol.ID, patrol)
+		}
+	}
+	if resp.BatchID == "" {
+		t.Fatal("expected a batch ID to await the update with")
+	}
+
+	// The update finishes in the background and is still audited
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var count int64
+		deps.Conns.DB.Model(&db.ScoreAuditLog{}).Where("patrol_id = ?", "1").Count(&count)
+		if count == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the background update to be audited")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	w = doAdminRequest(t, deps, AdminBatchHandler(deps), http.MethodGet, "/api/admin/batches/"+resp.BatchID+"?wait=5", "", nil)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected the background update under its batch ID, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestAdminScoresHandler_EnforcesPointsStep(t *testing.T) {
+	deps := setupSettingsTestDeps(t)
+	path := fmt.Sprintf("/api/admin/sections/%d/scores", settingsTestSectionID)
+
+	step, enforce := 5, true
+	w := doSettingsRequest(t, deps, http.MethodPut, AdminSettingsUpdateRequest{
+		PointsStep:        &step,
+		EnforcePointsStep: &enforce,
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("PUT settings: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var settings AdminSettingsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &settings); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if settings.PointsStep != 5 || !settings.EnforcePointsStep {
+		t.Fatalf("expected an enforced step of 5, got %+v", settings)
+	}
+
+	w = doAdminRequest(t, deps, AdminScoresHandler(deps), http.MethodPost, path, settingsTestCSRF, AdminUpdateRequest{
+		Updates: []AdminScoreUpdate{{PatrolID: "1", Points: 7}},
+	})
+	if w.Code != http.StatusBadRequest || !bytes.Contains(w.Body.Bytes(), []byte("validation_error")) {
+		t.Fatalf("expected 400 validation_error for 7 points, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = doAdminRequest(t, deps, AdminScoresHandler(deps), http.MethodPost, path, settingsTestCSRF, AdminUpdateRequest{
+		Updates: []AdminScoreUpdate{{PatrolID: "1", Points: 10}},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 for 10 points, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestAdminScoresHandler_OutOfRangePointsNameField(t *testing.T) {
+	deps := setupSettingsTestDeps(t)
+	path := fmt.Sprintf("/api/admin/sections/%d/scores", settingsTestSectionID)
+
+	w := doAdminRequest(t, deps, AdminScoresHandler(deps), http.MethodPost, path, settingsTestCSRF, AdminUpdateRequest{
+		Updates: []AdminScoreUpdate{{PatrolID: "1", Points: 5}, {PatrolID: "2", Points: 1001}},
+	})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp AdminErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Error != "validation_error" || len(resp.Fields) != 1 {
+		t.Fatalf("expected one field-level validation error, got %+v", resp)
+	}
+	field := resp.Fields[0]
+	if field.Field != "updates[1].points" || field.Constraint != ConstraintRange {
+		t.Errorf("expected a range error on updates[1].points, got %+v", field)
+	}
+	if field.Min == nil || *field.Min != -1000 || field.Max == nil || *field.Max != 1000 {
+		t.Errorf("expected bounds -1000..1000, got min %v max %v", field.Min, field.Max)
+	}
+}
+
+func TestAdminScoresHandler_ClampsToMinScore(t *testing.T) {
+	deps := setupSettingsTestDeps(t)
+	path := fmt.Sprintf("/api/admin/sections/%d/scores", settingsTestSectionID)
+
+	floor := 0
+	w := doSettingsRequest(t, deps, http.MethodPut, AdminSettingsUpdateRequest{MinScore: &floor})
+	if w.Code != http.StatusOK {
+		t.Fatalf("PUT settings: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var settings AdminSettingsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &settings); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if settings.MinScore == nil || *settings.MinScore != 0 {
+		t.Fatalf("expected a minimum score of 0, got %+v", settings)
+	}
+
+	// Eagles have 10 points; taking 15 would leave them on -5
+	w = doAdminRequest(t, deps, AdminScoresHandler(deps), http.MethodPost, path, settingsTestCSRF, AdminUpdateRequest{
+		Updates: []AdminScoreUpdate{{PatrolID: "1", Points: -15}},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp AdminUpdateResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(resp.Patrols) != 1 || resp.Patrols[0].NewScore != 0 {
+		t.Fatalf("expected Eagles to be clamped to 0, got %+v", resp.Patrols)
+	}
+
+	var logs []db.ScoreAuditLog
+	deps.Conns.DB.Find(&logs)
+	if len(logs) != 1 || logs[0].PointsAdded != -10 {
+		t.Errorf("expected the audit to record the clamped -10, got %+v", logs)
+	}
+
+	w = doSettingsRequest(t, deps, http.MethodPut, AdminSettingsUpdateRequest{ClearMinScore: true})
+	if w.Code != http.StatusOK {
+		t.Fatalf("PUT settings: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	settings = AdminSettingsResponse{}
+	json.Unmarshal(w.Body.Bytes(), &settings)
+	if settings.MinScore != nil {
+		t.Errorf("expected the minimum score to be cleared, got %d", *settings.MinScore)
+	}
+}
+
+func TestAdminScoresHandler_RecordsAdminSource(t *testing.T) {
+	deps := setupSettingsTestDeps(t)
+	path := fmt.Sprintf("/api/admin/sections/%d/scores", settingsTestSectionID)
+
+	w := doAdminRequest(t, deps, AdminScoresHandler(deps), http.MethodPost, path, settingsTestCSRF, AdminUpdateRequest{
+		Updates: []AdminScoreUpdate{{PatrolID: "1", Points: 5}},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var logs []db.ScoreAuditLog
+	deps.Conns.DB.Find(&logs)
+	if len(logs) != 1 {
+		t.Fatalf("expected one audit entry, got %+v", logs)
+	}
+	if logs[0].Source != db.ScoreSourceAdmin {
+		t.Errorf("expected source %q, got %q", db.ScoreSourceAdmin, logs[0].Source)
+	}
+	if logs[0].SourceID == nil || *logs[0].SourceID != settingsTestSessionID[:8] {
+		t.Errorf("expected source ID %q, got %v", settingsTestSessionID[:8], logs[0].SourceID)
+	}
+}
+
+func TestAdminScoresHandler_RejectsRapidDuplicateSubmission(t *testing.T) {
+	deps := setupSettingsTestDeps(t)
+	path := fmt.Sprintf("/api/admin/sections/%d/scores", settingsTestSectionID)
+
+	// Same updates in a different order are still the same submission
+	first := doAdminRequest(t, deps, AdminScoresHandler(deps), http.MethodPost, path, settingsTestCSRF, AdminUpdateRequest{
+		Updates: []AdminScoreUpdate{{PatrolID: "1", Points: 5}, {PatrolID: "2", Points: 3}},
+	})
+	if first.Code != http.StatusOK {
+		t.Fatalf("expected 200 for first submission, got %d: %s", first.Code, first.Body.String())
+	}
+
+	second := doAdminRequest(t, deps, AdminScoresHandler(deps), http.MethodPost, path, settingsTestCSRF, AdminUpdateRequest{
+		Updates: []AdminScoreUpdate{{PatrolID: "2", Points: 3}, {PatrolID: "1", Points: 5}},
+	})
+	if second.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for duplicate submission, got %d: %s", second.Code, second.Body.String())
+	}
+
+	var count int64
+	deps.Conns.DB.Model(&db.ScoreAuditLog{}).Count(&count)
+	if count != 2 {
+		t.Errorf("expected one batch of 2 audit entries, got %d", count)
+	}
+}
+
+func TestAdminScoresHandler_ReplaysKeyedRetryInsideDedupeWindow(t *testing.T) {
+	deps := setupSettingsTestDeps(t)
+	path := fmt.Sprintf("/api/admin/sections/%d/scores", settingsTestSectionID)
+
+	submit := func() *httptest.ResponseRecorder {
+		body, _ := json.Marshal(AdminUpdateRequest{Updates: []AdminScoreUpdate{{PatrolID: "1", Points: 5}}})
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+		req.AddCookie(&http.Cookie{Name: AdminSessionCookieName, Value: settingsTestSessionID})
+		req.Header.Set("X-CSRF-Token", settingsTestCSRF)
+		req.Header.Set("Idempotency-Key", "quick-retry")
+		w := httptest.NewRecorder()
+		middleware.SessionMiddleware(deps.Conns, AdminSessionCookieName)(AdminScoresHandler(deps)).ServeHTTP(w, req)
+		return w
+	}
+
+	first := submit()
+	if first.Code != http.StatusOK {
+		t.Fatalf("expected 200 for first submission, got %d: %s", first.Code, first.Body.String())
+	}
+	retry := submit()
+	if retry.Code != http.StatusOK || retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("expected the retry to be replayed, got %d: %s", retry.Code, retry.Body.String())
+	}
+	if !bytes.Equal(bytes.TrimSpace(retry.Body.Bytes()), bytes.TrimSpace(first.Body.Bytes())) {
+		t.Errorf("expected the first response to be replayed, got %s, want %s", retry.Body.String(), first.Body.String())
+	}
+}
+
+func TestAdminScoresHandler_RetryAfterRefusedSubmissionIsNotDuplicate(t *testing.T) {
+	deps := setupSettingsTestDeps(t)
+	path := fmt.Sprintf("/api/admin/sections/%d/scores", settingsTestSectionID)
+	updates := AdminUpdateRequest{Updates: []AdminScoreUpdate{{PatrolID: "1", Points: 5}}}
+
+	// Rate limited
+	deps.Config.RateLimit.AdminScoreRateLimit = 1
+	doAdminRequest(t, deps, AdminScoresHandler(deps), http.MethodPost, path, settingsTestCSRF, AdminUpdateRequest{
+		Updates: []AdminScoreUpdate{{PatrolID: "2", Points: 1}},
+	})
+	w := doAdminRequest(t, deps, AdminScoresHandler(deps), http.MethodPost, path, settingsTestCSRF, updates)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d: %s", w.Code, w.Body.String())
+	}
+	deps.Config.RateLimit.AdminScoreRateLimit = 30
+
+	// OSM fails
+	healthy := adminAPIOSMHandler(map[string]osm.PatrolData{
+		"1": {PatrolID: "1", Name: "Eagles", Points: "10", Members: []any{"a"}},
+	}, settingsTestSectionID)
+	useOSMHandler(t, deps, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ext/members/patrols/" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		healthy(w, r)
+	})
+	if w := doAdminRequest(t, deps, AdminScoresHandler(deps), http.MethodPost, path, settingsTestCSRF, updates); w.Code != http.StatusBadGateway {
+		t.Fatalf("expected 502, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// The manual retry straight afterwards is applied
+	useOSMHandler(t, deps, healthy)
+	if w := doAdminRequest(t, deps, AdminScoresHandler(deps), http.MethodPost, path, settingsTestCSRF, updates); w.Code != http.StatusOK {
+		t.Errorf("expected the retry to be applied, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestAdminScoresHandler_ReplaysReusedIdempotencyKeyAfterDedupeWindow(t *testing.T) {
+	deps := setupSettingsTestDeps(t)
+	deps.Config.ScoreUpdate.IdempotencyKeyTTL = 7 * 24 * 60 * 60
+	path := fmt.Sprintf("/api/admin/sections/%d/scores", settingsTestSectionID)
+	ctx := context.Background()
+
+	submit := func(idempotencyKey string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(AdminUpdateRequest{Updates: []AdminScoreUpdate{{PatrolID: "1", Points: 5}}})
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+		req.AddCookie(&http.Cookie{Name: AdminSessionCookieName, Value: settingsTestSessionID})
+		req.Header.Set("X-CSRF-Token", settingsTestCSRF)
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+		w := httptest.NewRecorder()
+		middleware.SessionMiddleware(deps.Conns, AdminSessionCookieName)(AdminScoresHandler(deps)).ServeHTTP(w, req)
+		return w
+	}
+
+	first := submit("retry-me")
+	if first.Code != http.StatusOK {
+		t.Fatalf("expected 200 for first submission, got %d: %s", first.Code, first.Body.String())
+	}
+
+	redis := deps.Conns.Redis.Client()
+	forgetDoubleClicks := func() {
+		dedupeKeys, _ := redis.Keys(ctx, "*score_dedupe:*").Result()
+		redis.Del(ctx, dedupeKeys...)
+	}
+
+	// Long after the double-click window, and after the audit log was cleaned up
+	forgetDoubleClicks()
+	deps.Conns.DB.Where("1 = 1").Delete(&db.ScoreAuditLog{})
+
+	keys, _ := redis.Keys(ctx, "*score_idempotency:*").Result()
+	if len(keys) != 1 {
+		t.Fatalf("expected one idempotency key in Redis, got %v", keys)
+	}
+	if ttl := redis.TTL(ctx, keys[0]).Val(); ttl < 6*24*time.Hour {
+		t.Errorf("expected the key to be kept for about 7 days, TTL is %v", ttl)
+	}
+
+	// The retry gets the first submission's response instead of being applied
+	w := submit("retry-me")
+	if w.Code != http.StatusOK || w.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("expected a replayed 200 for a reused idempotency key, got %d: %s", w.Code, w.Body.String())
+	}
+	if !bytes.Equal(bytes.TrimSpace(w.Body.Bytes()), bytes.TrimSpace(first.Body.Bytes())) {
+		t.Errorf("expected the first response to be replayed, got %s, want %s", w.Body.String(), first.Body.String())
+	}
+	var count int64
+	deps.Conns.DB.Model(&db.ScoreAuditLog{}).Count(&count)
+	if count != 0 {
+		t.Errorf("expected the retry not to be applied, found %d audit entries", count)
+	}
+
+	forgetDoubleClicks()
+	if w := submit("a-new-key"); w.Code != http.StatusOK {
+		t.Errorf("expected 200 for a new idempotency key, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// A retry while the first submission is still being sent has nothing to replay yet
+	forgetDoubleClicks()
+	redis.Set(ctx, keys[0], idempotencyPending, time.Hour)
+	if w := submit("retry-me"); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 while the first submission is running, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestAdminSectionsHandler_FlagsViewOnlySections(t *testing.T) {
+	deps := setupSettingsTestDeps(t)
+	useOSMHandler(t, deps, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(types.OSMProfileResponse{
+			Status: true,
+			Data: &types.OSMProfileData{
+				UserID: 12345,
+				Sections: []types.OSMSection{
+					{SectionID: 100, SectionName: "Scouts", Permissions: map[string]int{"member": 20, "badge": 20}},
+					{SectionID: 200, SectionName: "Cubs", Permissions: map[string]int{"member": 10, "badge": 20}},
+					{SectionID: 300, SectionName: "Beavers"},
+				},
+			},
+		})
+	})
+
+	w := doAdminRequest(t, deps, AdminSectionsHandler(deps), http.MethodGet, "/api/admin/sections", "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp AdminSectionsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	canEdit := make(map[int]bool)
+	for _, section := range resp.Sections {
+		canEdit[section.ID] = section.CanEditScores
+	}
+	expected := map[int]bool{0: true, 100: true, 200: false, 300: true}
+	for id, want := range expected {
+		if got, ok := canEdit[id]; !ok || got != want {
+			t.Errorf("section %d: expected canEditScores %v, got %v (present %v)", id, want, got, ok)
+		}
+	}
+}
+
+func TestAdminAdhocPatrolsHandler_FeatureFlag(t *testing.T) {
+	deps := setupSettingsTestDeps(t)
+
+	deps.Config.Features.Flags = config.FeatureFlags{config.FeatureAdhocTeams: false}
+	w := doAdminRequest(t, deps, AdminAdhocPatrolsHandler(deps), http.MethodGet, "/api/admin/adhoc/patrols", "", nil)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 with ad-hoc teams disabled, got %d: %s", w.Code, w.Body.String())
+	}
+	w = doAdminRequest(t, deps, AdminSectionsHandler(deps), http.MethodGet, "/api/admin/sections", "", nil)
+	var resp AdminSectionsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	for _, section := range resp.Sections {
+		if section.ID == 0 {
+			t.Error("expected the ad-hoc section to be hidden with ad-hoc teams disabled")
+		}
+	}
+
+	deps.Config.Features.Flags = config.FeatureFlags{config.FeatureAdhocTeams: true}
+	w = doAdminRequest(t, deps, AdminAdhocPatrolsHandler(deps), http.MethodGet, "/api/admin/adhoc/patrols", "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 with ad-hoc teams enabled, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestAdminSectionsHandler_DemoModeServesSampleSections(t *testing.T) {
+	deps := setupSettingsTestDeps(t)
+	demoOSM := demo.NewServer()
+	deps.OSM = newMockOSMClient("http://demo.invalid" + demo.PathPrefix)
+	deps.OSM.SetTransport(demoOSM.Transport())
+
+	w := doAdminRequest(t, deps, AdminSectionsHandler(deps), http.MethodGet, "/api/admin/sections", "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp AdminSectionsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	names := make(map[int]string)
+	for _, section := range resp.Sections {
+		names[section.ID] = section.Name
+	}
+	if names[1001] != "1st Anytown Scouts" || names[1002] != "2nd Anytown Scouts" {
+		t.Errorf("expected the demo sections, got %+v", resp.Sections)
+	}
+}
+
+func TestAdminAuditEntryHandler_VoidsOwnEntryOnly(t *testing.T) {
+	deps := setupSettingsTestDeps(t)
+
+	own := db.ScoreAuditLog{OSMUserID: 12345, SectionID: settingsTestSectionID, PatrolID: "1", PatrolName: "Eagles", PreviousScore: 10, NewScore: 60, PointsAdded: 50}
+	other := db.ScoreAuditLog{OSMUserID: 999, SectionID: settingsTestSectionID, PatrolID: "1", PatrolName: "Eagles", PreviousScore: 60, NewScore: 65, PointsAdded: 5}
+	deps.Conns.DB.Create(&own)
+	deps.Conns.DB.Create(&other)
+
+	voided := true
+	note := "Typed 50 instead of 5"
+	body := AdminAuditAnnotateRequest{Note: &note, Voided: &voided}
+
+	w := doAdminRequest(t, deps, AdminAuditEntryHandler(deps), http.MethodPatch, fmt.Sprintf("/api/admin/audit/%d", other.ID), settingsTestCSRF, body)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for another user's entry, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = doAdminRequest(t, deps, AdminAuditEntryHandler(deps), http.MethodPatch, fmt.Sprintf("/api/admin/audit/%d", own.ID), "", body)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 without CSRF token, got %d", w.Code)
+	}
+
+	w = doAdminRequest(t, deps, AdminAuditEntryHandler(deps), http.MethodPatch, fmt.Sprintf("/api/admin/audit/%d", own.ID), settingsTestCSRF, body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp AdminAuditEntryResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if !resp.Voided || resp.Note != note || resp.PointsAdded != 50 {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+
+	var stored db.ScoreAuditLog
+	deps.Conns.DB.First(&stored, other.ID)
+	if stored.Voided || stored.Note != nil {
+		t.Errorf("expected another user's entry to be untouched, got %+v", stored)
+	}
+}
+
+func TestAdminAuditListHandler_PagesSectionHistory(t *testing.T) {
+	deps := setupSettingsTestDeps(t)
+	path := fmt.Sprintf("/api/admin/sections/%d/audit", settingsTestSectionID)
+
+	list := func(query string) AdminAuditListResponse {
+		t.Helper()
+		w := doAdminRequest(t, deps, AdminAuditListHandler(deps), http.MethodGet, path+query, "", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %q: expected 200, got %d: %s", query, w.Code, w.Body.String())
+		}
+		var resp AdminAuditListResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return resp
+	}
+
+	if resp := list(""); len(resp.Entries) != 0 || resp.NextBefore != "" {
+		t.Errorf("expected an empty history, got %+v", resp)
+	}
+
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for i := 0; i < 3; i++ {
+		deps.Conns.DB.Create(&db.ScoreAuditLog{OSMUserID: 12345, SectionID: settingsTestSectionID, PatrolID: "1", PatrolName: "Eagles", PointsAdded: i + 1, CreatedAt: base.Add(time.Duration(i) * time.Minute)})
+	}
+	deps.Conns.DB.Create(&db.ScoreAuditLog{OSMUserID: 12345, SectionID: 999, PatrolID: "1", PatrolName: "Other", PointsAdded: 99, CreatedAt: base})
+
+	first := list("?limit=2")
+	if len(first.Entries) != 2 || first.Entries[0].PointsAdded != 3 || first.Entries[1].PointsAdded != 2 || first.NextBefore == "" {
+		t.Fatalf("expected the newest two entries and a cursor, got %+v", first)
+	}
+	if first.Entries[0].UserID != 12345 || first.Entries[0].PatrolName != "Eagles" {
+		t.Errorf("expected the acting user and patrol name, got %+v", first.Entries[0])
+	}
+
+	// A page ending exactly on the last entry has no further cursor
+	second := list("?limit=1&before=" + first.NextBefore)
+	if len(second.Entries) != 1 || second.Entries[0].PointsAdded != 1 || second.NextBefore != "" {
+		t.Errorf("expected the oldest entry and no cursor, got %+v", second)
+	}
+
+	for _, query := range []string{"?limit=0", "?limit=201", "?before=yesterday"} {
+		w := doAdminRequest(t, deps, AdminAuditListHandler(deps), http.MethodGet, path+query, "", nil)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("GET %q: expected 400, got %d", query, w.Code)
+		}
+	}
+
+	w := doAdminRequest(t, deps, AdminAuditListHandler(deps), http.MethodGet, "/api/admin/sections/999/audit", "", nil)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a section outside the profile, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestAdminScoresHandler_FailedUpdateReleasesIdempotencyKey(t *testing.T) {
+	tests := []struct {
+		name string
+		// fails reports whether the OSM request should fail
+		fails func(r *http.Request) bool
+	}{
+		// The scores cannot be read, so the update fails outright
+		{"read fails", func(r *http.Request) bool {
+			return r.URL.Path == "/ext/members/patrols/"
+		}},
+		// The scores are read but every write is rejected, so the update
+		// completes with no patrol applied
+		{"write fails", func(r *http.Request) bool {
+			return r.URL.Path == "/ext/members/patrols/" && r.Method == http.MethodPost
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := setupAdminAPITestDeps(t, map[string]osm.PatrolData{
+				"1": {PatrolID: "1", Name: "Eagles", Points: "10", Members: []any{"a"}},
+			})
+			path := fmt.Sprintf("/api/admin/sections/%d/scores", settingsTestSectionID)
+
+			submit := func() *httptest.ResponseRecorder {
+				body, _ := json.Marshal(AdminUpdateRequest{Updates: []AdminScoreUpdate{{PatrolID: "1", Points: 5}}})
+				req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+				req.AddCookie(&http.Cookie{Name: AdminSessionCookieName, Value: settingsTestSessionID})
+				req.Header.Set("X-CSRF-Token", settingsTestCSRF)
+				req.Header.Set("Idempotency-Key", "claimed-then-failed")
+				w := httptest.NewRecorder()
+				middleware.SessionMiddleware(deps.Conns, AdminSessionCookieName)(AdminScoresHandler(deps)).ServeHTTP(w, req)
+				return w
+			}
+
+			// OSM fails after the key has been claimed
+			healthy := adminAPIOSMHandler(map[string]osm.PatrolData{
+				"1": {PatrolID: "1", Name: "Eagles", Points: "10", Members: []any{"a"}},
+			}, settingsTestSectionID)
+			useOSMHandler(t, deps, func(w http.ResponseWriter, r *http.Request) {
+				if tt.fails(r) {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				healthy(w, r)
+			})
+			w := submit()
+			if w.Code == http.StatusConflict {
+				t.Fatalf("expected the first submission to be attempted, got %d: %s", w.Code, w.Body.String())
+			}
+			var resp AdminUpdateResponse
+			if w.Code == http.StatusOK {
+				if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+					t.Fatalf("Failed to decode response: %v", err)
+				}
+			}
+			for _, patrol := range resp.Patrols {
+				if patrol.Success {
+					t.Fatalf("expected no patrol to be updated, got %+v", resp.Patrols)
+				}
+			}
+
+			// Once the double-click window has passed the key is not left
+			// claimed, so the client's retry goes through
+			ctx := context.Background()
+			redis := deps.Conns.Redis.Client()
+			dedupeKeys, _ := redis.Keys(ctx, "*score_dedupe:*").Result()
+			redis.Del(ctx, dedupeKeys...)
+			useOSMHandler(t, deps, healthy)
+			w = submit()
+			if w.Code != http.StatusOK || w.Header().Get("Idempotent-Replayed") != "" {
+				t.Fatalf("expected the retry to be applied, got %d: %s", w.Code, w.Body.String())
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if len(resp.Patrols) != 1 || !resp.Patrols[0].Success {
+				t.Errorf("expected the retry to update the patrol, got %+v", resp.Patrols)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db/scorearchive"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/middleware"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/types"
+)
+
+// AdminArchivesResponse is returned by GET /api/admin/sections/{sectionId}/archives
+type AdminArchivesResponse struct {
+	SectionID int                 `json:"sectionId"`
+	Terms     []AdminArchivedTerm `json:"terms"`
+}
+
+// AdminArchivedTerm is a section's final patrol scores for one past term.
+type AdminArchivedTerm struct {
+	TermID int `json:"termId"`
+	// ScoresAt is when the scores were last read from OSM during the term
+	ScoresAt   time.Time           `json:"scoresAt"`
+	ArchivedAt time.Time           `json:"archivedAt"`
+	Patrols    []types.PatrolScore `json:"patrols"`
+}
+
+// AdminArchivesHandler handles GET /api/admin/sections/{sectionId}/archives,
+// returning the section's final scores for past terms, most recent first. A
+// term is archived when the section's devices first fetch scores after it
+// ends, from the scores they last showed.
+func AdminArchivesHandler(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session, ok := middleware.WebSessionFromContext(r.Context())
+		if !ok {
+			writeJSONError(w, http.StatusUnauthorized, "unauthorized", "Not authenticated")
+			return
+		}
+
+		if r.Method != http.MethodGet {
+			writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed")
+			return
+		}
+
+		path := r.URL.Path
+		prefix := deps.Config.Paths.AdminAPIPrefix + "/sections/"
+		suffix := "/archives"
+		if !strings.HasPrefix(path, prefix) || !strings.HasSuffix(path, suffix) {
+			writeJSONError(w, http.StatusNotFound, "not_found", "Invalid path")
+			return
+		}
+		sectionID, err := strconv.Atoi(path[len(prefix) : len(path)-len(suffix)])
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "bad_request", "Invalid section ID")
+			return
+		}
+
+		// Ad-hoc patrols have no terms, so have nothing archived
+		if sectionID == 0 {
+			writeJSON(w, AdminArchivesResponse{SectionID: 0, Terms: []AdminArchivedTerm{}})
+			return
+		}
+		if !checkSectionAccess(w, deps, session, sectionID) {
+			return
+		}
+
+		terms, err := scorearchive.ListBySection(deps.Conns, sectionID)
+		if err != nil {
+			slog.Error("admin.api.archives.list_failed",
+				"component", "admin_api",
+				"event", "archives.error",
+				"section_id", sectionID,
+				"error", err,
+			)
+			writeJSONError(w, http.StatusInternalServerError, "internal_error", "Failed to list archived scores")
+			return
+		}
+
+		response := AdminArchivesResponse{SectionID: sectionID, Terms: make([]AdminArchivedTerm, 0, len(terms))}
+		for _, term := range terms {
+			response.Terms = append(response.Terms, AdminArchivedTerm{
+				TermID:     term.TermID,
+				ScoresAt:   term.ScoresAt,
+				ArchivedAt: term.ArchivedAt,
+				Patrols:    term.Patrols,
+			})
+		}
+
+		writeJSON(w, response)
+	}
+}
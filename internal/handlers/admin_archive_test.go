@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db/scorearchive"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/types"
+)
+
+func TestAdminArchivesHandler_ReturnsPastTerms(t *testing.T) {
+	deps := setupSettingsTestDeps(t)
+	path := fmt.Sprintf("/api/admin/sections/%d/archives", settingsTestSectionID)
+
+	list := func() AdminArchivesResponse {
+		t.Helper()
+		w := doAdminRequest(t, deps, AdminArchivesHandler(deps), http.MethodGet, path, "", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var resp AdminArchivesResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return resp
+	}
+
+	if resp := list(); resp.Terms == nil || len(resp.Terms) != 0 {
+		t.Errorf("expected an empty list before any term ends, got %+v", resp)
+	}
+
+	scoresAt := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	final := []types.PatrolScore{{ID: "2", Name: "Hawks", Score: 80}, {ID: "1", Name: "Eagles", Score: 75}}
+	if _, err := scorearchive.Save(deps.Conns, settingsTestSectionID, 41, scoresAt, final); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	// Another section's archive is not listed
+	if _, err := scorearchive.Save(deps.Conns, 999, 41, scoresAt, final[:1]); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	resp := list()
+	if resp.SectionID != settingsTestSectionID || len(resp.Terms) != 1 {
+		t.Fatalf("expected one archived term, got %+v", resp)
+	}
+	term := resp.Terms[0]
+	if term.TermID != 41 || !term.ScoresAt.Equal(scoresAt) {
+		t.Errorf("expected term 41 scored at %v, got %+v", scoresAt, term)
+	}
+	if len(term.Patrols) != 2 || term.Patrols[0] != final[0] || term.Patrols[1] != final[1] {
+		t.Errorf("expected the patrols in their archived order %+v, got %+v", final, term.Patrols)
+	}
+
+	w := doAdminRequest(t, deps, AdminArchivesHandler(deps), http.MethodGet, "/api/admin/sections/999/archives", "", nil)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a section outside the profile, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,395 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db/scoreaudit"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/middleware"
+)
+
+// AdminAuditSummaryResponse is returned by GET /api/admin/sections/{sectionId}/audit/summary
+type AdminAuditSummaryResponse struct {
+	SectionID int               `json:"sectionId"`
+	From      *time.Time        `json:"from,omitempty"`
+	To        *time.Time        `json:"to,omitempty"`
+	Totals    []AdminAuditTotal `json:"totals"`
+}
+
+// AdminAuditTotal is the net points one user added to one patrol.
+type AdminAuditTotal struct {
+	UserID     int    `json:"userId"`
+	PatrolID   string `json:"patrolId"`
+	PatrolName string `json:"patrolName"`
+	Points     int    `json:"points"`
+	Changes    int    `json:"changes"`
+}
+
+// AdminAuditListResponse is returned by GET /api/admin/sections/{sectionId}/audit
+type AdminAuditListResponse struct {
+	SectionID int                       `json:"sectionId"`
+	Entries   []AdminAuditEntryResponse `json:"entries"`
+	// NextBefore fetches the next page when passed as before; absent on the last page
+	NextBefore string `json:"nextBefore,omitempty"`
+}
+
+// Page sizes for the audit list.
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 200
+)
+
+// maxAuditNoteLength bounds the note a user can attach to an audit entry.
+const maxAuditNoteLength = 500
+
+// AdminAuditAnnotateRequest is the body of PATCH /api/admin/audit/{id}.
+// Omitted fields are left unchanged.
+type AdminAuditAnnotateRequest struct {
+	Note   *string `json:"note"`
+	Voided *bool   `json:"voided"`
+}
+
+// AdminAuditEntryResponse is an audit entry as returned by the admin API.
+type AdminAuditEntryResponse struct {
+	ID            string    `json:"id"`
+	SectionID     int       `json:"sectionId"`
+	UserID        int       `json:"userId"`
+	Source        string    `json:"source,omitempty"`
+	PatrolID      string    `json:"patrolId"`
+	PatrolName    string    `json:"patrolName"`
+	PreviousScore int       `json:"previousScore"`
+	NewScore      int       `json:"newScore"`
+	PointsAdded   int       `json:"pointsAdded"`
+	Note          string    `json:"note,omitempty"`
+	Voided        bool      `json:"voided"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// AdminAuditSummaryHandler handles GET /api/admin/sections/{sectionId}/audit/summary.
+// Optional from and to query parameters (YYYY-MM-DD, both inclusive) limit the
+// date range; otherwise every retained audit entry is counted. The ad-hoc
+// section only includes the current user's own changes.
+func AdminAuditSummaryHandler(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session, ok := middleware.WebSessionFromContext(r.Context())
+		if !ok {
+			writeJSONError(w, http.StatusUnauthorized, "unauthorized", "Not authenticated")
+			return
+		}
+
+		if r.Method != http.MethodGet {
+			writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed")
+			return
+		}
+
+		path := r.URL.Path
+		prefix := deps.Config.Paths.AdminAPIPrefix + "/sections/"
+		suffix := "/audit/summary"
+		if !strings.HasPrefix(path, prefix) || !strings.HasSuffix(path, suffix) {
+			writeJSONError(w, http.StatusNotFound, "not_found", "Invalid path")
+			return
+		}
+		sectionID, err := strconv.Atoi(path[len(prefix) : len(path)-len(suffix)])
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "bad_request", "Invalid section ID")
+			return
+		}
+
+		filter := scoreaudit.SummaryFilter{SectionID: sectionID}
+		response := AdminAuditSummaryResponse{SectionID: sectionID, Totals: []AdminAuditTotal{}}
+		if from := r.URL.Query().Get("from"); from != "" {
+			day, err := time.Parse(time.DateOnly, from)
+			if err != nil {
+				writeJSONError(w, http.StatusBadRequest, "bad_request", "from must be a date (YYYY-MM-DD)")
+				return
+			}
+			filter.From = day
+			response.From = &day
+		}
+		if to := r.URL.Query().Get("to"); to != "" {
+			day, err := time.Parse(time.DateOnly, to)
+			if err != nil {
+				writeJSONError(w, http.StatusBadRequest, "bad_request", "to must be a date (YYYY-MM-DD)")
+				return
+			}
+			filter.To = day.AddDate(0, 0, 1)
+			response.To = &day
+		}
+
+		if sectionID == 0 {
+			filter.OSMUserID = session.OSMUserID
+		} else if !checkSectionAccess(w, deps, session, sectionID) {
+			return
+		}
+
+		totals, err := scoreaudit.Summarize(deps.Conns, filter)
+		if err != nil {
+			slog.Error("admin.api.audit.summary_failed",
+				"component", "admin_api",
+				"event", "audit.error",
+				"section_id", sectionID,
+				"error", err,
+			)
+			writeJSONError(w, http.StatusInternalServerError, "internal_error", "Failed to summarize audit log")
+			return
+		}
+
+		patrolIDs := make([]string, 0, len(totals))
+		for _, total := range totals {
+			patrolIDs = append(patrolIDs, total.PatrolID)
+		}
+		names, err := scoreaudit.LatestPatrolNames(deps.Conns, sectionID, patrolIDs)
+		if err != nil {
+			// Names are cosmetic; the totals are still worth returning
+			slog.Warn("admin.api.audit.names_failed",
+				"component", "admin_api",
+				"event", "audit.names_error",
+				"section_id", sectionID,
+				"error", err,
+			)
+		}
+
+		for _, total := range totals {
+			response.Totals = append(response.Totals, AdminAuditTotal{
+				UserID:     total.OSMUserID,
+				PatrolID:   total.PatrolID,
+				PatrolName: names[total.PatrolID],
+				Points:     total.PointsAdded,
+				Changes:    total.Changes,
+			})
+		}
+
+		writeJSON(w, response)
+	}
+}
+
+// AdminAuditListHandler handles GET /api/admin/sections/{sectionId}/audit,
+// returning the section's score changes newest first. Pages hold limit entries
+// (default 50, at most 200); pass a response's nextBefore as before to fetch
+// the next page. The ad-hoc section only lists the current user's own changes.
+func AdminAuditListHandler(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session, ok := middleware.WebSessionFromContext(r.Context())
+		if !ok {
+			writeJSONError(w, http.StatusUnauthorized, "unauthorized", "Not authenticated")
+			return
+		}
+
+		if r.Method != http.MethodGet {
+			writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed")
+			return
+		}
+
+		path := r.URL.Path
+		prefix := deps.Config.Paths.AdminAPIPrefix + "/sections/"
+		suffix := "/audit"
+		if !strings.HasPrefix(path, prefix) || !strings.HasSuffix(path, suffix) {
+			writeJSONError(w, http.StatusNotFound, "not_found", "Invalid path")
+			return
+		}
+		sectionID, err := strconv.Atoi(path[len(prefix) : len(path)-len(suffix)])
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "bad_request", "Invalid section ID")
+			return
+		}
+
+		limit := defaultAuditPageSize
+		if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+			limit, err = strconv.Atoi(limitStr)
+			if err != nil || limit < 1 || limit > maxAuditPageSize {
+				writeJSONError(w, http.StatusBadRequest, "bad_request", fmt.Sprintf("limit must be between 1 and %d", maxAuditPageSize))
+				return
+			}
+		}
+		var before *scoreaudit.Cursor
+		if beforeStr := r.URL.Query().Get("before"); beforeStr != "" {
+			before, err = parseAuditCursor(beforeStr)
+			if err != nil {
+				writeJSONError(w, http.StatusBadRequest, "bad_request", "Invalid before cursor")
+				return
+			}
+		}
+
+		osmUserID := 0
+		if sectionID == 0 {
+			osmUserID = session.OSMUserID
+		} else if !checkSectionAccess(w, deps, session, sectionID) {
+			return
+		}
+
+		// Fetch one extra entry to tell whether there is another page
+		entries, err := scoreaudit.ListBySection(deps.Conns, sectionID, osmUserID, limit+1, before)
+		if err != nil {
+			slog.Error("admin.api.audit.list_failed",
+				"component", "admin_api",
+				"event", "audit.error",
+				"section_id", sectionID,
+				"error", err,
+			)
+			writeJSONError(w, http.StatusInternalServerError, "internal_error", "Failed to list audit log")
+			return
+		}
+
+		response := AdminAuditListResponse{SectionID: sectionID, Entries: []AdminAuditEntryResponse{}}
+		if len(entries) > limit {
+			entries = entries[:limit]
+			last := entries[limit-1]
+			response.NextBefore = formatAuditCursor(scoreaudit.Cursor{CreatedAt: last.CreatedAt, ID: last.ID})
+		}
+		for i := range entries {
+			response.Entries = append(response.Entries, newAdminAuditEntryResponse(&entries[i]))
+		}
+
+		writeJSON(w, response)
+	}
+}
+
+// formatAuditCursor encodes a cursor for the before query parameter as the
+// entry's timestamp in Unix nanoseconds and its ID.
+func formatAuditCursor(cursor scoreaudit.Cursor) string {
+	return fmt.Sprintf("%d_%d", cursor.CreatedAt.UnixNano(), cursor.ID)
+}
+
+// parseAuditCursor decodes a cursor written by formatAuditCursor.
+func parseAuditCursor(s string) (*scoreaudit.Cursor, error) {
+	nanosStr, idStr, ok := strings.Cut(s, "_")
+	if !ok {
+		return nil, fmt.Errorf("malformed cursor %q", s)
+	}
+	nanos, err := strconv.ParseInt(nanosStr, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &scoreaudit.Cursor{CreatedAt: time.Unix(0, nanos), ID: id}, nil
+}
+
+// checkSectionAccess checks the user's OSM profile includes sectionID,
+// writing an error response and returning false if it does not.
+func checkSectionAccess(w http.ResponseWriter, deps *Dependencies, session *db.WebSession, sectionID int) bool {
+	profile, err := deps.OSM.FetchOSMProfile(session.User())
+	if err != nil {
+		slog.Error("admin.api.section_access.profile_fetch_failed",
+			"component", "admin_api",
+			"event", "section_access.error",
+			"error", err,
+		)
+		writeProfileFetchError(w, err, "Failed to validate section access")
+		return false
+	}
+	if profile.Data == nil {
+		writeJSONError(w, http.StatusBadGateway, "osm_error", "Invalid response from OSM")
+		return false
+	}
+	for _, section := range profile.Data.Sections {
+		if section.SectionID == sectionID {
+			return true
+		}
+	}
+	writeJSONError(w, http.StatusForbidden, "forbidden", "You do not have access to this section")
+	return false
+}
+
+// AdminAuditEntryHandler handles PATCH /api/admin/audit/{id}, which lets the
+// user who made a change annotate it or mark it voided. The recorded change
+// itself is never altered.
+func AdminAuditEntryHandler(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session, ok := middleware.WebSessionFromContext(r.Context())
+		if !ok {
+			writeJSONError(w, http.StatusUnauthorized, "unauthorized", "Not authenticated")
+			return
+		}
+
+		if r.Method != http.MethodPatch {
+			writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed")
+			return
+		}
+
+		// Parse entry ID from URL path: /api/admin/audit/{id}
+		path := r.URL.Path
+		prefix := deps.Config.Paths.AdminAPIPrefix + "/audit/"
+		if !strings.HasPrefix(path, prefix) {
+			writeJSONError(w, http.StatusNotFound, "not_found", "Invalid path")
+			return
+		}
+		id, err := strconv.ParseInt(path[len(prefix):], 10, 64)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "bad_request", "Invalid audit entry ID")
+			return
+		}
+
+		if err := validateCSRFToken(r, session); err != nil {
+			writeJSONError(w, http.StatusForbidden, "csrf_invalid", err.Error())
+			return
+		}
+
+		var req AdminAuditAnnotateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "bad_request", "Invalid request body")
+			return
+		}
+		if req.Note != nil {
+			trimmed := strings.TrimSpace(*req.Note)
+			if len(trimmed) > maxAuditNoteLength {
+				writeJSONError(w, http.StatusBadRequest, "validation_error", "Note is too long")
+				return
+			}
+			req.Note = &trimmed
+		}
+
+		entry, err := scoreaudit.Annotate(deps.Conns, id, session.OSMUserID, req.Note, req.Voided)
+		if err != nil {
+			if err == scoreaudit.ErrNotFound {
+				writeJSONError(w, http.StatusNotFound, "not_found", "Audit entry not found")
+				return
+			}
+			slog.Error("admin.api.audit.annotate_failed",
+				"component", "admin_api",
+				"event", "audit.error",
+				"error", err,
+			)
+			writeJSONError(w, http.StatusInternalServerError, "internal_error", "Failed to update audit entry")
+			return
+		}
+
+		slog.Info("admin.api.audit.annotated",
+			"component", "admin_api",
+			"event", "audit.annotated",
+			"user_id", session.OSMUserID,
+			"audit_id", id,
+			"voided", entry.Voided,
+		)
+
+		writeJSON(w, newAdminAuditEntryResponse(entry))
+	}
+}
+
+func newAdminAuditEntryResponse(entry *db.ScoreAuditLog) AdminAuditEntryResponse {
+	response := AdminAuditEntryResponse{
+		ID:            strconv.FormatInt(entry.ID, 10),
+		SectionID:     entry.SectionID,
+		UserID:        entry.OSMUserID,
+		Source:        entry.Source,
+		PatrolID:      entry.PatrolID,
+		PatrolName:    entry.PatrolName,
+		PreviousScore: entry.PreviousScore,
+		NewScore:      entry.NewScore,
+		PointsAdded:   entry.PointsAdded,
+		Voided:        entry.Voided,
+		CreatedAt:     entry.CreatedAt,
+	}
+	if entry.Note != nil {
+		response.Note = *entry.Note
+	}
+	return response
+}
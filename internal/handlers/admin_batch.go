@@ -0,0 +1,570 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/m0rjc/OsmDeviceAdapter/internal/config"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db/scoreaudit"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db/scorebatch"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/metrics"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/middleware"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/services/scoreupdateservice"
+)
+
+// maxBatchSections bounds the number of sections in a single batch submission.
+const maxBatchSections = 20
+
+// AdminBatchUpdateRequest is the request body for POST /api/admin/scores/batch
+type AdminBatchUpdateRequest struct {
+	Sections []AdminSectionUpdates `json:"sections"`
+}
+
+// AdminSectionUpdates holds the score updates for one section of a batch
+type AdminSectionUpdates struct {
+	SectionID int                `json:"sectionId"`
+	Updates   []AdminScoreUpdate `json:"updates"`
+}
+
+// AdminBatchUpdateResponse is returned by POST /api/admin/scores/batch
+type AdminBatchUpdateResponse struct {
+	BatchID  string                     `json:"batchId"`
+	Sections []AdminSectionUpdateResult `json:"sections"`
+}
+
+// AdminSectionUpdateResult contains the outcome for one section of a batch.
+// Sections that were rate limited, failed or timed out carry an error code;
+// a timed-out section may still include the patrol results known so far.
+type AdminSectionUpdateResult struct {
+	SectionID    int                 `json:"sectionId"`
+	Success      bool                `json:"success"`
+	Patrols      []AdminPatrolResult `json:"patrols"`
+	ErrorCode    string              `json:"errorCode,omitempty"`
+	ErrorMessage string              `json:"errorMessage,omitempty"`
+	RetryAfter   *time.Time          `json:"retryAfter,omitempty"`
+}
+
+// Values for AdminBatchStatusResponse.Status.
+const (
+	batchStatusRunning   = "running"
+	batchStatusCompleted = "completed"
+)
+
+// AdminBatchStatusResponse is returned by GET /api/admin/batches/{batchId}
+type AdminBatchStatusResponse struct {
+	BatchID string `json:"batchId"`
+	// Status is "running" until every section of the batch has finished
+	Status string `json:"status"`
+	// Applied, Failed and Pending count the batch's patrol updates by outcome
+	Applied int `json:"applied"`
+	Failed  int `json:"failed"`
+	Pending int `json:"pending"`
+	// LastError is the most recent error reported for the batch
+	LastError *string `json:"lastError,omitempty"`
+	// ChangeCount is the number of patrol changes recorded for the batch
+	ChangeCount int `json:"changeCount"`
+	// VoidedCount is how many of those changes have since been voided
+	VoidedCount int                       `json:"voidedCount"`
+	Entries     []AdminAuditEntryResponse `json:"entries"`
+}
+
+// AdminBatchScoresHandler handles POST /api/admin/scores/batch.
+// Updates are grouped by section; access is checked for every section before
+// any update is made, and all resulting audit entries share one batch ID.
+func AdminBatchScoresHandler(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		session, ok := middleware.WebSessionFromContext(ctx)
+		if !ok {
+			writeJSONError(w, http.StatusUnauthorized, "unauthorized", "Not authenticated")
+			return
+		}
+		if !requireFeature(w, deps, config.FeatureScoreBatches) {
+			return
+		}
+
+		if r.Method != http.MethodPost {
+			writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed")
+			return
+		}
+
+		if err := validateCSRFToken(r, session); err != nil {
+			writeJSONError(w, http.StatusForbidden, "csrf_invalid", err.Error())
+			return
+		}
+
+		var req AdminBatchUpdateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "bad_request", "Invalid request body")
+			return
+		}
+
+		if err := validateBatchUpdateRequest(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "validation_error", err.Error())
+			return
+		}
+		for i, section := range req.Sections {
+			for j, update := range section.Updates {
+				if update.Points < minPointsDelta || update.Points > maxPointsDelta {
+					writeValidationError(w, pointsRangeError(batchPointsField(i)(j)))
+					return
+				}
+			}
+		}
+
+		// Validate access to every section before touching any scores
+		user := session.User()
+		profile, err := deps.OSM.FetchOSMProfile(user)
+		if err != nil {
+			slog.Error("admin.api.batch.profile_fetch_failed",
+				"component", "admin_api",
+				"event", "batch.error",
+				"error", err,
+			)
+			writeProfileFetchError(w, err, "Failed to validate section access")
+			return
+		}
+		if profile.Data == nil {
+			writeJSONError(w, http.StatusBadGateway, "osm_error", "Invalid response from OSM")
+			return
+		}
+
+		allowedSections := make(map[int]bool, len(profile.Data.Sections))
+		for _, section := range profile.Data.Sections {
+			allowedSections[section.SectionID] = true
+		}
+		for i, section := range req.Sections {
+			if !allowedSections[section.SectionID] {
+				writeJSONError(w, http.StatusForbidden, "forbidden", fmt.Sprintf("You do not have access to section %d", section.SectionID))
+				return
+			}
+			if fieldErr := checkPointsStep(deps, session.OSMUserID, section.SectionID, section.Updates, batchPointsField(i)); fieldErr != nil {
+				writeValidationError(w, fieldErr)
+				return
+			}
+		}
+
+		batchID, err := generateUUID()
+		if err != nil {
+			slog.Error("admin.api.batch.id_failed",
+				"component", "admin_api",
+				"event", "batch.error",
+				"error", err,
+			)
+			writeJSONError(w, http.StatusInternalServerError, "internal_error", "Failed to create batch")
+			return
+		}
+		if err := scorebatch.Create(deps.Conns, batchID, session.OSMUserID); err != nil {
+			slog.Error("admin.api.batch.create_failed",
+				"component", "admin_api",
+				"event", "batch.error",
+				"error", err,
+			)
+			writeJSONError(w, http.StatusInternalServerError, "internal_error", "Failed to create batch")
+			return
+		}
+
+		response := AdminBatchUpdateResponse{
+			BatchID:  batchID,
+			Sections: make([]AdminSectionUpdateResult, len(req.Sections)),
+		}
+
+		// Rate limits are checked up front; only allowed sections are fanned out
+		var pending []int
+		for i, section := range req.Sections {
+			if allowed, retryAfter := checkSectionScoreRateLimit(ctx, deps, session.OSMUserID, section.SectionID); !allowed {
+				retryAt := time.Now().Add(retryAfter)
+				response.Sections[i] = AdminSectionUpdateResult{
+					SectionID:    section.SectionID,
+					Patrols:      []AdminPatrolResult{},
+					ErrorCode:    "rate_limited",
+					ErrorMessage: "Too many score updates for this section. Please try again later.",
+					RetryAfter:   &retryAt,
+				}
+				recordBatchFailure(deps, batchID, len(section.Updates), response.Sections[i].ErrorMessage)
+				continue
+			}
+			pending = append(pending, i)
+		}
+
+		// Sections are submitted through a bounded pool, each with its own deadline,
+		// so one slow section cannot hold up the rest of the batch.
+		sectionTimeout := time.Duration(deps.Config.ScoreUpdate.BatchSectionTimeout) * time.Second
+		if sectionTimeout <= 0 {
+			sectionTimeout = scoreupdateservice.DefaultUpdateTimeout
+		}
+		slots := make(chan struct{}, max(deps.Config.ScoreUpdate.BatchSectionConcurrency, 1))
+		var wg sync.WaitGroup
+		for _, i := range pending {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				slots <- struct{}{}
+				defer func() { <-slots }()
+				response.Sections[i] = updateBatchSection(ctx, deps, session, batchID, req.Sections[i], sectionTimeout)
+			}()
+		}
+		wg.Wait()
+
+		slog.Info("admin.api.batch.updated",
+			"component", "admin_api",
+			"event", "batch.update_success",
+			"user_id", session.OSMUserID,
+			"batch_id", batchID,
+			"section_count", len(req.Sections),
+		)
+		publishBatchDone(ctx, deps, session.OSMUserID, batchID)
+
+		writeJSON(w, response)
+	}
+}
+
+// updateBatchSection submits one section of a batch, giving up after timeout.
+// A section that times out is marked with the "timeout" error code; any patrol
+// results already known are still returned and recorded.
+func updateBatchSection(ctx context.Context, deps *Dependencies, session *db.WebSession, batchID string, section AdminSectionUpdates, timeout time.Duration) AdminSectionUpdateResult {
+	result := AdminSectionUpdateResult{SectionID: section.SectionID, Patrols: []AdminPatrolResult{}}
+
+	minScore := sectionMinScore(deps, session.OSMUserID, section.SectionID)
+	serviceRequests := make([]scoreupdateservice.UpdateRequest, len(section.Updates))
+	for i, update := range section.Updates {
+		serviceRequests[i] = scoreupdateservice.UpdateRequest{
+			PatrolID: update.PatrolID,
+			Delta:    update.Points,
+			MinScore: minScore,
+		}
+	}
+
+	sectionCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	accepted := time.Now()
+	serviceResults, err := deps.ScoreUpdateService.UpdateScores(sectionCtx, session.User(), section.SectionID, serviceRequests)
+	timedOut := sectionCtx.Err() == context.DeadlineExceeded
+	if err != nil {
+		slog.Error("admin.api.batch.service_error",
+			"component", "admin_api",
+			"event", "batch.update_error",
+			"batch_id", batchID,
+			"section_id", section.SectionID,
+			"timed_out", timedOut,
+			"error", err,
+		)
+		if timedOut {
+			result.ErrorCode = "timeout"
+			result.ErrorMessage = "Timed out waiting for OSM"
+		} else {
+			result.ErrorCode = "osm_error"
+			result.ErrorMessage = "Failed to update scores"
+		}
+		recordBatchFailure(deps, batchID, len(section.Updates), result.ErrorMessage)
+		return result
+	}
+
+	result.Patrols = recordScoreResults(ctx, deps, session.OSMUserID, section.SectionID, &batchID, adminAuditSource(session), serviceResults)
+	if timedOut {
+		result.ErrorCode = "timeout"
+		result.ErrorMessage = "Timed out waiting for OSM"
+		return result
+	}
+	metrics.ScoreUpdateSyncDuration.WithLabelValues("interactive").Observe(time.Since(accepted).Seconds())
+	result.Success = true
+	return result
+}
+
+// validateBatchUpdateRequest checks the shape of a batch request; points are
+// checked separately so the invalid field can be reported. Ad-hoc
+// sections are not supported because they have no OSM access to validate.
+func validateBatchUpdateRequest(req *AdminBatchUpdateRequest) error {
+	if len(req.Sections) == 0 {
+		return fmt.Errorf("no sections provided")
+	}
+	if len(req.Sections) > maxBatchSections {
+		return fmt.Errorf("at most %d sections may be updated in one batch", maxBatchSections)
+	}
+
+	seen := make(map[int]bool, len(req.Sections))
+	for _, section := range req.Sections {
+		if section.SectionID <= 0 {
+			return fmt.Errorf("invalid section ID %d", section.SectionID)
+		}
+		if seen[section.SectionID] {
+			return fmt.Errorf("section %d appears more than once", section.SectionID)
+		}
+		seen[section.SectionID] = true
+
+		if len(section.Updates) == 0 {
+			return fmt.Errorf("no updates provided for section %d", section.SectionID)
+		}
+	}
+	return nil
+}
+
+// batchPointsField returns the path of an update's points within the section
+// at index sectionIndex of a batch request.
+func batchPointsField(sectionIndex int) func(int) string {
+	return func(index int) string {
+		return fmt.Sprintf("sections.%d.updates.%d.points", sectionIndex, index)
+	}
+}
+
+// AdminBatchHandler handles GET /api/admin/batches/{batchId}, reporting the
+// status and recorded changes of a batch the caller submitted. A batch
+// belonging to another user is reported as not found so its existence is not
+// revealed. Batches cannot be cancelled: their updates are sent to OSM as soon
+// as they are submitted, so DELETE is declined.
+// With ?wait=N a GET waits up to N seconds for a batch that is still being
+// submitted to OSM, returning its final state as soon as it completes, or its
+// current state when the wait runs out.
+func AdminBatchHandler(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		session, ok := middleware.WebSessionFromContext(ctx)
+		if !ok {
+			writeJSONError(w, http.StatusUnauthorized, "unauthorized", "Not authenticated")
+			return
+		}
+		if !requireFeature(w, deps, config.FeatureScoreBatches) {
+			return
+		}
+
+		if r.Method == http.MethodDelete {
+			w.Header().Set("Allow", http.MethodGet)
+			writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed",
+				"Batches cannot be cancelled because their updates have already been sent to OSM. Void individual changes through the audit log instead.")
+			return
+		}
+		if r.Method != http.MethodGet {
+			writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed")
+			return
+		}
+
+		// Parse batch ID from URL path: /api/admin/batches/{batchId}
+		path := r.URL.Path
+		prefix := deps.Config.Paths.AdminAPIPrefix + "/batches/"
+		if !strings.HasPrefix(path, prefix) {
+			writeJSONError(w, http.StatusNotFound, "not_found", "Invalid path")
+			return
+		}
+		batchID := path[len(prefix):]
+		if batchID == "" || len(batchID) > 36 {
+			writeJSONError(w, http.StatusNotFound, "not_found", "Batch not found")
+			return
+		}
+
+		var wait time.Duration
+		if value := r.URL.Query().Get("wait"); value != "" {
+			seconds, err := strconv.Atoi(value)
+			if err != nil || seconds < 0 {
+				writeJSONError(w, http.StatusBadRequest, "bad_request", "wait must be a number of seconds")
+				return
+			}
+			wait = min(time.Duration(seconds)*time.Second, scoreupdateservice.DefaultUpdateTimeout)
+		}
+
+		var completions *batchCompletions
+		if wait > 0 {
+			// Subscribe before looking the batch up so a completion published
+			// in between is not missed
+			completions = subscribeBatchCompletions(ctx, deps, session.OSMUserID, wait)
+			defer completions.Close()
+		}
+
+		// A running batch is waited on until it finishes, whether its patrols
+		// were applied or failed
+		response, err := loadBatchStatus(deps, batchID, session.OSMUserID)
+		if err == nil && (response == nil || response.Status == batchStatusRunning) && completions != nil && completions.Await(ctx, batchID) {
+			response, err = loadBatchStatus(deps, batchID, session.OSMUserID)
+		}
+		if err != nil {
+			slog.Error("admin.api.batch.lookup_failed",
+				"component", "admin_api",
+				"event", "batch.error",
+				"batch_id", batchID,
+				"error", err,
+			)
+			writeJSONError(w, http.StatusInternalServerError, "internal_error", "Failed to fetch batch")
+			return
+		}
+		if response == nil {
+			writeJSONError(w, http.StatusNotFound, "not_found", "Batch not found")
+			return
+		}
+
+		writeJSON(w, response)
+	}
+}
+
+// loadBatchStatus reads a batch's outcome and its recorded changes, returning
+// nil if the batch does not exist or belongs to another user. Batches submitted
+// before outcomes were tracked are reported from their audit entries alone.
+func loadBatchStatus(deps *Dependencies, batchID string, osmUserID int) (*AdminBatchStatusResponse, error) {
+	batch, err := scorebatch.Find(deps.Conns, batchID)
+	if err != nil {
+		return nil, err
+	}
+	if batch != nil && batch.OSMUserID != osmUserID {
+		return nil, nil
+	}
+	entries, err := scoreaudit.FindByBatchID(deps.Conns, batchID)
+	if err != nil {
+		return nil, err
+	}
+	if batch == nil && len(entries) == 0 {
+		return nil, nil
+	}
+
+	response := &AdminBatchStatusResponse{
+		BatchID:     batchID,
+		Status:      batchStatusCompleted,
+		Applied:     len(entries),
+		ChangeCount: len(entries),
+		Entries:     make([]AdminAuditEntryResponse, 0, len(entries)),
+	}
+	if batch != nil {
+		if batch.CompletedAt == nil {
+			response.Status = batchStatusRunning
+		}
+		response.Applied = batch.Applied
+		response.Failed = batch.Failed
+		response.Pending = batch.Pending
+		response.LastError = batch.LastError
+	}
+	for i := range entries {
+		if entries[i].OSMUserID != osmUserID {
+			return nil, nil
+		}
+		if entries[i].Voided {
+			response.VoidedCount++
+		}
+		response.Entries = append(response.Entries, newAdminAuditEntryResponse(&entries[i]))
+	}
+	return response, nil
+}
+
+// batchChannel is the Redis pub/sub channel announcing completed batches for
+// one user. Messages carry the batch ID.
+func batchChannel(osmUserID int) string {
+	return fmt.Sprintf("batches:%d", osmUserID)
+}
+
+// recordBatchOutcome adds the outcome of part of a batch to its record. Errors
+// are logged; the updates themselves have already been made.
+func recordBatchOutcome(deps *Dependencies, batchID string, outcome scorebatch.Outcome) {
+	if err := scorebatch.Record(deps.Conns, batchID, outcome); err != nil {
+		slog.Error("admin.api.batch.record_failed",
+			"component", "admin_api",
+			"event", "batch.error",
+			"batch_id", batchID,
+			"error", err,
+		)
+	}
+}
+
+// recordBatchFailure records count patrol updates of a batch that failed
+// without reaching OSM.
+func recordBatchFailure(deps *Dependencies, batchID string, count int, message string) {
+	recordBatchOutcome(deps, batchID, scorebatch.Outcome{Failed: count, LastError: message})
+}
+
+// patrolResultsOutcome counts patrol results by outcome for the batch record.
+func patrolResultsOutcome(results []AdminPatrolResult) scorebatch.Outcome {
+	var outcome scorebatch.Outcome
+	for _, result := range results {
+		switch {
+		case result.Pending:
+			outcome.Pending++
+		case result.Success:
+			outcome.Applied++
+		default:
+			outcome.Failed++
+		}
+		if !result.Success && result.ErrorMessage != nil {
+			outcome.LastError = *result.ErrorMessage
+		}
+	}
+	return outcome
+}
+
+// publishBatchDone marks batchID as finished, with all its changes recorded,
+// and announces it, waking any request waiting on it. Best effort.
+func publishBatchDone(ctx context.Context, deps *Dependencies, osmUserID int, batchID string) {
+	if err := scorebatch.Complete(deps.Conns, batchID); err != nil {
+		slog.Error("admin.api.batch.complete_failed",
+			"component", "admin_api",
+			"event", "batch.error",
+			"batch_id", batchID,
+			"error", err,
+		)
+	}
+	if err := deps.Conns.Redis.Publish(ctx, batchChannel(osmUserID), batchID); err != nil {
+		slog.Warn("admin.api.batch.publish_failed",
+			"component", "admin_api",
+			"event", "batch.publish_error",
+			"batch_id", batchID,
+			"error", err,
+		)
+	}
+}
+
+// batchCompletions is a subscription to a user's batch completion events that
+// gives up at a fixed deadline.
+type batchCompletions struct {
+	pubSub     *db.PubSub
+	events     <-chan *db.PubSubEvent
+	deadline   *time.Timer
+	subscribed bool
+}
+
+func subscribeBatchCompletions(ctx context.Context, deps *Dependencies, osmUserID int, wait time.Duration) *batchCompletions {
+	pubSub := deps.Conns.Redis.Subscribe(ctx, batchChannel(osmUserID))
+	c := &batchCompletions{pubSub: pubSub, events: pubSub.Events(), deadline: time.NewTimer(wait)}
+	c.subscribed = c.next(ctx, func(event *db.PubSubEvent) bool {
+		return event.Kind == db.PubSubSubscribed
+	})
+	return c
+}
+
+// Await blocks until batchID is announced, returning false if the deadline
+// passes, the request ends or the subscription could not be made.
+func (c *batchCompletions) Await(ctx context.Context, batchID string) bool {
+	if !c.subscribed {
+		return false
+	}
+	return c.next(ctx, func(event *db.PubSubEvent) bool {
+		var id string
+		return event.Kind == db.PubSubMessage && json.Unmarshal([]byte(event.Payload), &id) == nil && id == batchID
+	})
+}
+
+func (c *batchCompletions) next(ctx context.Context, match func(*db.PubSubEvent) bool) bool {
+	for {
+		select {
+		case event, ok := <-c.events:
+			if !ok {
+				return false
+			}
+			if match(event) {
+				return true
+			}
+		case <-c.deadline.C:
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// Close releases the subscription.
+func (c *batchCompletions) Close() {
+	c.deadline.Stop()
+	c.pubSub.Close()
+}
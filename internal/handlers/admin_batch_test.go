@@ -0,0 +1,379 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db/scorebatch"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/osm"
+)
+
+const batchTestSecondSectionID = 200
+
+func TestAdminBatchScoresHandler_SharedBatchIDAcrossSections(t *testing.T) {
+	deps := setupAdminAPITestDeps(t, map[string]osm.PatrolData{
+		"1": {PatrolID: "1", Name: "Eagles", Points: "10", Members: []any{"a"}},
+		"2": {PatrolID: "2", Name: "Hawks", Points: "20", Members: []any{"b"}},
+	}, batchTestSecondSectionID)
+
+	w := doAdminRequest(t, deps, AdminBatchScoresHandler(deps), http.MethodPost, "/api/admin/scores/batch", settingsTestCSRF, AdminBatchUpdateRequest{
+		Sections: []AdminSectionUpdates{
+			{SectionID: settingsTestSectionID, Updates: []AdminScoreUpdate{{PatrolID: "1", Points: 5}}},
+			{SectionID: batchTestSecondSectionID, Updates: []AdminScoreUpdate{{PatrolID: "2", Points: 3}}},
+		},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp AdminBatchUpdateResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.BatchID == "" {
+		t.Fatal("expected a batch ID in the response")
+	}
+	if len(resp.Sections) != 2 || !resp.Sections[0].Success || !resp.Sections[1].Success {
+		t.Fatalf("expected both sections to succeed, got %+v", resp.Sections)
+	}
+
+	var logs []db.ScoreAuditLog
+	if err := deps.Conns.DB.Where("batch_id = ?", resp.BatchID).Order("section_id").Find(&logs).Error; err != nil {
+		t.Fatalf("Failed to load audit logs: %v", err)
+	}
+	if len(logs) != 2 {
+		t.Fatalf("expected 2 audit entries for the batch, got %d", len(logs))
+	}
+	if logs[0].SectionID != settingsTestSectionID || logs[1].SectionID != batchTestSecondSectionID {
+		t.Errorf("expected entries for sections %d and %d, got %d and %d",
+			settingsTestSectionID, batchTestSecondSectionID, logs[0].SectionID, logs[1].SectionID)
+	}
+}
+
+func TestAdminBatchScoresHandler_RejectsSectionWithoutAccess(t *testing.T) {
+	deps := setupSettingsTestDeps(t)
+
+	w := doAdminRequest(t, deps, AdminBatchScoresHandler(deps), http.MethodPost, "/api/admin/scores/batch", settingsTestCSRF, AdminBatchUpdateRequest{
+		Sections: []AdminSectionUpdates{
+			{SectionID: settingsTestSectionID, Updates: []AdminScoreUpdate{{PatrolID: "1", Points: 5}}},
+			{SectionID: 999, Updates: []AdminScoreUpdate{{PatrolID: "2", Points: 3}}},
+		},
+	})
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var count int64
+	deps.Conns.DB.Model(&db.ScoreAuditLog{}).Count(&count)
+	if count != 0 {
+		t.Errorf("expected no audit entries after a rejected batch, got %d", count)
+	}
+}
+
+func TestAdminBatchScoresHandler_ReportsInvalidPointsField(t *testing.T) {
+	deps := setupSettingsTestDeps(t)
+
+	w := doAdminRequest(t, deps, AdminBatchScoresHandler(deps), http.MethodPost, "/api/admin/scores/batch", settingsTestCSRF, AdminBatchUpdateRequest{
+		Sections: []AdminSectionUpdates{
+			{SectionID: settingsTestSectionID, Updates: []AdminScoreUpdate{{PatrolID: "1", Points: 5}}},
+			{SectionID: batchTestSecondSectionID, Updates: []AdminScoreUpdate{{PatrolID: "2", Points: 3}, {PatrolID: "3", Points: -1001}}},
+		},
+	})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp AdminErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Error != "validation_error" || len(resp.Fields) != 1 {
+		t.Fatalf("expected one field-level validation error, got %+v", resp)
+	}
+	field := resp.Fields[0]
+	if field.Field != "sections.1.updates.1.points" || field.Constraint != ConstraintRange {
+		t.Errorf("expected a range error on sections.1.updates.1.points, got %+v", field)
+	}
+	if field.Min == nil || *field.Min != minPointsDelta || field.Max == nil || *field.Max != maxPointsDelta {
+		t.Errorf("expected bounds %d..%d, got min %v max %v", minPointsDelta, maxPointsDelta, field.Min, field.Max)
+	}
+}
+
+func TestAdminBatchScoresHandler_SlowSectionYieldsPartialResult(t *testing.T) {
+	deps := setupSettingsTestDeps(t)
+	deps.Config.ScoreUpdate.BatchSectionTimeout = 1
+
+	// Reads for the second section hang until the handler gives up on it
+	osmHandler := adminAPIOSMHandler(map[string]osm.PatrolData{
+		"1": {PatrolID: "1", Name: "Eagles", Points: "10", Members: []any{"a"}},
+	}, settingsTestSectionID, batchTestSecondSectionID)
+	useOSMHandler(t, deps, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("sectionid") == strconv.Itoa(batchTestSecondSectionID) {
+			<-r.Context().Done()
+			return
+		}
+		osmHandler(w, r)
+	})
+
+	start := time.Now()
+	w := doAdminRequest(t, deps, AdminBatchScoresHandler(deps), http.MethodPost, "/api/admin/scores/batch", settingsTestCSRF, AdminBatchUpdateRequest{
+		Sections: []AdminSectionUpdates{
+			{SectionID: settingsTestSectionID, Updates: []AdminScoreUpdate{{PatrolID: "1", Points: 5}}},
+			{SectionID: batchTestSecondSectionID, Updates: []AdminScoreUpdate{{PatrolID: "1", Points: 3}}},
+		},
+	})
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the slow section to be abandoned after its timeout, took %v", elapsed)
+	}
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp AdminBatchUpdateResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(resp.Sections) != 2 {
+		t.Fatalf("expected results for 2 sections, got %d", len(resp.Sections))
+	}
+
+	fast, slow := resp.Sections[0], resp.Sections[1]
+	if !fast.Success || len(fast.Patrols) != 1 || fast.Patrols[0].NewScore != 15 {
+		t.Errorf("expected the fast section to be populated, got %+v", fast)
+	}
+	if slow.Success || slow.ErrorCode != "timeout" {
+		t.Errorf("expected the slow section to be marked as timed out, got %+v", slow)
+	}
+}
+
+func TestAdminBatchHandler_ReturnsOwnBatchOnly(t *testing.T) {
+	deps := setupAdminAPITestDeps(t, map[string]osm.PatrolData{
+		"1": {PatrolID: "1", Name: "Eagles", Points: "10", Members: []any{"a"}},
+		"2": {PatrolID: "2", Name: "Hawks", Points: "20", Members: []any{"b"}},
+	}, batchTestSecondSectionID)
+
+	w := doAdminRequest(t, deps, AdminBatchScoresHandler(deps), http.MethodPost, "/api/admin/scores/batch", settingsTestCSRF, AdminBatchUpdateRequest{
+		Sections: []AdminSectionUpdates{
+			{SectionID: settingsTestSectionID, Updates: []AdminScoreUpdate{{PatrolID: "1", Points: 5}}},
+			{SectionID: batchTestSecondSectionID, Updates: []AdminScoreUpdate{{PatrolID: "2", Points: 3}}},
+		},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var submitted AdminBatchUpdateResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &submitted); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	w = doAdminRequest(t, deps, AdminBatchHandler(deps), http.MethodGet, "/api/admin/batches/"+submitted.BatchID, "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var status AdminBatchStatusResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if status.BatchID != submitted.BatchID || status.ChangeCount != 2 || status.VoidedCount != 0 || len(status.Entries) != 2 {
+		t.Fatalf("unexpected batch status: %+v", status)
+	}
+	if status.Entries[0].PatrolID != "1" || status.Entries[0].NewScore != 15 ||
+		status.Entries[1].PatrolID != "2" || status.Entries[1].NewScore != 23 {
+		t.Errorf("unexpected batch entries: %+v", status.Entries)
+	}
+
+	w = doAdminRequest(t, deps, AdminBatchHandler(deps), http.MethodGet, "/api/admin/batches/unknown-batch", "", nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown batch, got %d", w.Code)
+	}
+
+	// Another user's batch is indistinguishable from one that does not exist
+	othersBatch := "11111111-2222-3333-4444-555555555555"
+	deps.Conns.DB.Create(&db.ScoreAuditLog{OSMUserID: 999, SectionID: settingsTestSectionID, PatrolID: "1", PatrolName: "Eagles", BatchID: &othersBatch})
+	w = doAdminRequest(t, deps, AdminBatchHandler(deps), http.MethodGet, "/api/admin/batches/"+othersBatch, "", nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for another user's batch, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestAdminBatchHandler_ReportsFailedPatrols(t *testing.T) {
+	deps := setupSettingsTestDeps(t)
+
+	// Scores are read, but every write to OSM fails
+	healthy := adminAPIOSMHandler(map[string]osm.PatrolData{
+		"1": {PatrolID: "1", Name: "Eagles", Points: "10", Members: []any{"a"}},
+	}, settingsTestSectionID)
+	useOSMHandler(t, deps, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ext/members/patrols/" && r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		healthy(w, r)
+	})
+
+	w := doAdminRequest(t, deps, AdminBatchScoresHandler(deps), http.MethodPost, "/api/admin/scores/batch", settingsTestCSRF, AdminBatchUpdateRequest{
+		Sections: []AdminSectionUpdates{
+			{SectionID: settingsTestSectionID, Updates: []AdminScoreUpdate{{PatrolID: "1", Points: 5}}},
+		},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var submitted AdminBatchUpdateResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &submitted); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	// No change reached the audit log, but the batch still reports its outcome
+	w = doAdminRequest(t, deps, AdminBatchHandler(deps), http.MethodGet, "/api/admin/batches/"+submitted.BatchID, "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 for a batch whose patrols all failed, got %d: %s", w.Code, w.Body.String())
+	}
+	var status AdminBatchStatusResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if status.Status != batchStatusCompleted || status.Applied != 0 || status.Failed != 1 || status.Pending != 0 {
+		t.Errorf("expected a completed batch with one failure, got %+v", status)
+	}
+	if status.LastError == nil || *status.LastError == "" {
+		t.Error("expected the batch to report its last error")
+	}
+	if status.ChangeCount != 0 || len(status.Entries) != 0 {
+		t.Errorf("expected no recorded changes, got %+v", status.Entries)
+	}
+}
+
+func TestAdminBatchHandler_ReportsRunningBatch(t *testing.T) {
+	deps := setupSettingsTestDeps(t)
+	batchID := "aaaaaaaa-bbbb-cccc-dddd-eeeeeeeeeeee"
+	if err := scorebatch.Create(deps.Conns, batchID, 12345); err != nil {
+		t.Fatalf("Failed to create batch: %v", err)
+	}
+
+	w := doAdminRequest(t, deps, AdminBatchHandler(deps), http.MethodGet, "/api/admin/batches/"+batchID, "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 for a running batch, got %d: %s", w.Code, w.Body.String())
+	}
+	var status AdminBatchStatusResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if status.Status != batchStatusRunning {
+		t.Errorf("expected a running batch, got %+v", status)
+	}
+
+	othersBatch := "11111111-2222-3333-4444-555555555555"
+	if err := scorebatch.Create(deps.Conns, othersBatch, 999); err != nil {
+		t.Fatalf("Failed to create batch: %v", err)
+	}
+	w = doAdminRequest(t, deps, AdminBatchHandler(deps), http.MethodGet, "/api/admin/batches/"+othersBatch, "", nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for another user's batch, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestAdminBatchHandler_WaitReturnsWhenBatchCompletes(t *testing.T) {
+	deps := setupSettingsTestDeps(t)
+	batchID := "aaaaaaaa-bbbb-cccc-dddd-eeeeeeeeeeee"
+
+	done := make(chan *httptest.ResponseRecorder, 1)
+	go func() {
+		done <- doAdminRequest(t, deps, AdminBatchHandler(deps), http.MethodGet, "/api/admin/batches/"+batchID+"?wait=10", "", nil)
+	}()
+
+	// The update finishes in the background while the request waits
+	time.Sleep(100 * time.Millisecond)
+	deps.Conns.DB.Create(&db.ScoreAuditLog{OSMUserID: 12345, SectionID: settingsTestSectionID, PatrolID: "1", PatrolName: "Eagles", NewScore: 15, PointsAdded: 5, BatchID: &batchID})
+	completedAt := time.Now()
+	publishBatchDone(context.Background(), deps, 12345, batchID)
+
+	select {
+	case w := <-done:
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		if elapsed := time.Since(completedAt); elapsed > 2*time.Second {
+			t.Errorf("expected the wait to end promptly after completion, took %v", elapsed)
+		}
+		var status AdminBatchStatusResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if status.ChangeCount != 1 || status.Entries[0].NewScore != 15 {
+			t.Errorf("unexpected batch status: %+v", status)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the batch request to return")
+	}
+
+	w := doAdminRequest(t, deps, AdminBatchHandler(deps), http.MethodGet, "/api/admin/batches/"+batchID+"?wait=soon", "", nil)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a malformed wait, got %d", w.Code)
+	}
+}
+
+func TestAdminBatchHandler_WaitReturnsWhenFailedBatchCompletes(t *testing.T) {
+	deps := setupSettingsTestDeps(t)
+	batchID := "aaaaaaaa-bbbb-cccc-dddd-eeeeeeeeeeee"
+	if err := scorebatch.Create(deps.Conns, batchID, 12345); err != nil {
+		t.Fatalf("Failed to create batch: %v", err)
+	}
+
+	done := make(chan *httptest.ResponseRecorder, 1)
+	go func() {
+		done <- doAdminRequest(t, deps, AdminBatchHandler(deps), http.MethodGet, "/api/admin/batches/"+batchID+"?wait=10", "", nil)
+	}()
+
+	// Every patrol fails, so nothing reaches the audit log
+	time.Sleep(100 * time.Millisecond)
+	recordBatchFailure(deps, batchID, 2, "Failed to update scores")
+	completedAt := time.Now()
+	publishBatchDone(context.Background(), deps, 12345, batchID)
+
+	select {
+	case w := <-done:
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		if elapsed := time.Since(completedAt); elapsed > 2*time.Second {
+			t.Errorf("expected the wait to end promptly after completion, took %v", elapsed)
+		}
+		var status AdminBatchStatusResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if status.Status != batchStatusCompleted || status.Failed != 2 || status.LastError == nil {
+			t.Errorf("expected the failed batch's final state, got %+v", status)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the batch request to return")
+	}
+}
+
+func TestAdminBatchHandler_DeleteIsDeclined(t *testing.T) {
+	deps := setupSettingsTestDeps(t)
+	batchID := "aaaaaaaa-bbbb-cccc-dddd-eeeeeeeeeeee"
+	deps.Conns.DB.Create(&db.ScoreAuditLog{OSMUserID: 12345, SectionID: settingsTestSectionID, PatrolID: "1", PatrolName: "Eagles", PointsAdded: 500, BatchID: &batchID})
+
+	// The points are already in OSM, so there is nothing left to cancel
+	w := doAdminRequest(t, deps, AdminBatchHandler(deps), http.MethodDelete, "/api/admin/batches/"+batchID, settingsTestCSRF, nil)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Allow"); got != http.MethodGet {
+		t.Errorf("expected Allow: GET, got %q", got)
+	}
+
+	var voided int64
+	deps.Conns.DB.Model(&db.ScoreAuditLog{}).Where("voided = ?", true).Count(&voided)
+	if voided != 0 {
+		t.Errorf("expected no entries to be voided, got %d", voided)
+	}
+}
@@ -19,7 +19,8 @@ import (
 )
 
 const (
-	// AdminSessionCookieName is the name of the session cookie for admin UI
+	// AdminSessionCookieName is the default name of the session cookie for admin UI.
+	// Deployments can override it with config.AdminConfig.SessionCookieName.
 	AdminSessionCookieName = "osm_admin_session"
 	// AdminOAuthStateTTL is how long OAuth state tokens are valid
 	AdminOAuthStateTTL = 15 * time.Minute
@@ -204,7 +205,7 @@ func AdminCallbackHandler(deps *Dependencies) http.HandlerFunc {
 		}
 
 		// Set secure session cookie
-		setSessionCookie(w, sessionID, sessionExpiry)
+		setSessionCookie(w, deps.Config.Admin.SessionCookieName, sessionID, sessionExpiry)
 
 		slog.Info("admin.callback.success",
 			"component", "admin_oauth",
@@ -222,7 +223,7 @@ func AdminCallbackHandler(deps *Dependencies) http.HandlerFunc {
 func AdminLogoutHandler(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Get session ID from cookie
-		cookie, err := r.Cookie(AdminSessionCookieName)
+		cookie, err := r.Cookie(deps.Config.Admin.SessionCookieName)
 		if err == nil && cookie.Value != "" {
 			// Delete session from database
 			if err := websession.Delete(deps.Conns, cookie.Value); err != nil {
@@ -236,7 +237,7 @@ func AdminLogoutHandler(deps *Dependencies) http.HandlerFunc {
 		}
 
 		// Clear the session cookie
-		clearSessionCookie(w)
+		clearSessionCookie(w, deps.Config.Admin.SessionCookieName)
 
 		slog.Info("admin.logout.success",
 			"component", "admin_oauth",
@@ -358,9 +359,9 @@ func exchangeAdminCode(ctx context.Context, deps *Dependencies, code, callbackUR
 }
 
 // setSessionCookie sets the secure session cookie
-func setSessionCookie(w http.ResponseWriter, sessionID string, expiry time.Time) {
+func setSessionCookie(w http.ResponseWriter, name, sessionID string, expiry time.Time) {
 	http.SetCookie(w, &http.Cookie{
-		Name:     AdminSessionCookieName,
+		Name:     name,
 		Value:    sessionID,
 		Path:     "/",
 		Expires:  expiry,
@@ -371,9 +372,9 @@ func setSessionCookie(w http.ResponseWriter, sessionID string, expiry time.Time)
 }
 
 // clearSessionCookie clears the session cookie
-func clearSessionCookie(w http.ResponseWriter) {
+func clearSessionCookie(w http.ResponseWriter, name string) {
 	http.SetCookie(w, &http.Cookie{
-		Name:     AdminSessionCookieName,
+		Name:     name,
 		Value:    "",
 		Path:     "/",
 		Expires:  time.Unix(0, 0),
@@ -31,6 +31,13 @@ func setupAdminTestDeps(t *testing.T) (*Dependencies, *miniredis.Miniredis) {
 	if err != nil {
 		t.Fatalf("Failed to connect to test database: %v", err)
 	}
+	// Each connection to :memory: is a separate, empty database, so keep to one:
+	// the session middleware updates activity alongside the handler
+	sqlDB, err := database.DB()
+	if err != nil {
+		t.Fatalf("Failed to get test database: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
 
 	// Auto-migrate tables
 	if err := db.AutoMigrate(database); err != nil {
@@ -61,6 +68,12 @@ func setupAdminTestDeps(t *testing.T) (*Dependencies, *miniredis.Miniredis) {
 			OSMClientID:     "test-client-id",
 			OSMClientSecret: "test-client-secret",
 		},
+		Paths: config.PathConfig{
+			AdminAPIPrefix: "/api/admin",
+		},
+		Admin: config.AdminConfig{
+			SessionCookieName: AdminSessionCookieName,
+		},
 	}
 
 	return &Dependencies{
@@ -69,6 +82,36 @@ func setupAdminTestDeps(t *testing.T) (*Dependencies, *miniredis.Miniredis) {
 	}, mr
 }
 
+// newAdminOSMServer creates a mock OSM server for token exchange and profile fetch
+func newAdminOSMServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/oauth/token":
+			// Token exchange
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(types.OSMTokenResponse{
+				AccessToken:  "test-access-token",
+				RefreshToken: "test-refresh-token",
+				ExpiresIn:    3600,
+				TokenType:    "Bearer",
+			})
+		case "/oauth/resource":
+			// Profile fetch (uses /oauth/resource endpoint)
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(types.OSMProfileResponse{
+				Status: true,
+				Data: &types.OSMProfileData{
+					UserID:   12345,
+					FullName: "Test User",
+					Email:    "test@example.com",
+				},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
 func TestAdminLoginHandler_RedirectsToOSM(t *testing.T) {
 	deps, mr := setupAdminTestDeps(t)
 	defer mr.Close()
@@ -219,33 +262,7 @@ func TestAdminCallbackHandler_SuccessfulLogin(t *testing.T) {
 	deps, mr := setupAdminTestDeps(t)
 	defer mr.Close()
 
-	// Create a mock OSM server for token exchange and profile fetch
-	osmServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		switch r.URL.Path {
-		case "/oauth/token":
-			// Token exchange
-			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(types.OSMTokenResponse{
-				AccessToken:  "test-access-token",
-				RefreshToken: "test-refresh-token",
-				ExpiresIn:    3600,
-				TokenType:    "Bearer",
-			})
-		case "/oauth/resource":
-			// Profile fetch (uses /oauth/resource endpoint)
-			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(types.OSMProfileResponse{
-				Status: true,
-				Data: &types.OSMProfileData{
-					UserID:   12345,
-					FullName: "Test User",
-					Email:    "test@example.com",
-				},
-			})
-		default:
-			http.NotFound(w, r)
-		}
-	}))
+	osmServer := newAdminOSMServer()
 	defer osmServer.Close()
 
 	// Update config to use mock server
@@ -393,6 +410,82 @@ func TestAdminLogoutHandler_WithSession(t *testing.T) {
 	}
 }
 
+func TestAdminSessionCookie_ConfiguredName(t *testing.T) {
+	deps, mr := setupAdminTestDeps(t)
+	defer mr.Close()
+
+	const cookieName = "osm_admin_session_staging"
+	deps.Config.Admin.SessionCookieName = cookieName
+
+	osmServer := newAdminOSMServer()
+	defer osmServer.Close()
+	deps.Config.ExternalDomains.OSMDomain = osmServer.URL
+	deps.OSM = newMockOSMClient(osmServer.URL)
+
+	// Log in: the session cookie should be set under the configured name
+	state := "configured-cookie-state"
+	mr.Set("test:admin_oauth_state:"+state, "1")
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/callback?code=test-code&state="+state, nil)
+	w := httptest.NewRecorder()
+	AdminCallbackHandler(deps)(w, req)
+
+	if w.Code != http.StatusFound {
+		t.Fatalf("Expected status %d, got %d. Body: %s", http.StatusFound, w.Code, w.Body.String())
+	}
+
+	var sessionCookie *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == AdminSessionCookieName {
+			t.Errorf("Expected default cookie name %s not to be used", AdminSessionCookieName)
+		}
+		if c.Name == cookieName {
+			sessionCookie = c
+		}
+	}
+	if sessionCookie == nil {
+		t.Fatalf("Expected session cookie %s to be set", cookieName)
+	}
+
+	// A cookie under the default name must not be read
+	req = httptest.NewRequest(http.MethodGet, "/admin/logout", nil)
+	req.AddCookie(&http.Cookie{Name: AdminSessionCookieName, Value: sessionCookie.Value})
+	w = httptest.NewRecorder()
+	AdminLogoutHandler(deps)(w, req)
+
+	session, err := websession.FindByID(deps.Conns, sessionCookie.Value)
+	if err != nil {
+		t.Fatalf("Failed to get session from database: %v", err)
+	}
+	if session == nil {
+		t.Fatal("Expected session to survive logout with the default cookie name")
+	}
+
+	// Logging out with the configured cookie name reads and deletes the session
+	req = httptest.NewRequest(http.MethodGet, "/admin/logout", nil)
+	req.AddCookie(&http.Cookie{Name: cookieName, Value: sessionCookie.Value})
+	w = httptest.NewRecorder()
+	AdminLogoutHandler(deps)(w, req)
+
+	session, err = websession.FindByID(deps.Conns, sessionCookie.Value)
+	if err != nil {
+		t.Fatalf("Error checking for deleted session: %v", err)
+	}
+	if session != nil {
+		t.Error("Expected session to be deleted from database")
+	}
+
+	var cleared bool
+	for _, c := range w.Result().Cookies() {
+		if c.Name == cookieName && c.MaxAge == -1 {
+			cleared = true
+		}
+	}
+	if !cleared {
+		t.Errorf("Expected cookie %s to be cleared", cookieName)
+	}
+}
+
 func TestAdminLogoutHandler_WithoutSession(t *testing.T) {
 	deps, mr := setupAdminTestDeps(t)
 	defer mr.Close()
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/m0rjc/OsmDeviceAdapter/internal/config"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/middleware"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/services"
+	wsinternal "github.com/m0rjc/OsmDeviceAdapter/internal/websocket"
+)
+
+// sectionRefreshInterval is how often a section's scoreboards may be told to
+// refresh. Devices all reload from OSM at once, so repeated pushes are costly.
+const sectionRefreshInterval = 5 * time.Second
+
+// AdminSectionRefreshHandler handles POST /api/admin/sections/{id}/refresh,
+// telling the section's connected scoreboards to reload scores now rather than
+// at their next poll. Section 0 refreshes the user's ad-hoc scoreboards.
+func AdminSectionRefreshHandler(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed")
+			return
+		}
+
+		ctx := r.Context()
+		session, ok := middleware.WebSessionFromContext(ctx)
+		if !ok {
+			writeJSONError(w, http.StatusUnauthorized, "unauthorized", "Not authenticated")
+			return
+		}
+
+		if err := validateCSRFToken(r, session); err != nil {
+			writeJSONError(w, http.StatusForbidden, "csrf_invalid", err.Error())
+			return
+		}
+
+		// Parse section ID from URL path: /api/admin/sections/{id}/refresh
+		path := r.URL.Path
+		prefix := deps.Config.Paths.AdminAPIPrefix + "/sections/"
+		suffix := "/refresh"
+		if !strings.HasPrefix(path, prefix) || !strings.HasSuffix(path, suffix) {
+			writeJSONError(w, http.StatusNotFound, "not_found", "Invalid path")
+			return
+		}
+		sectionID, err := strconv.Atoi(path[len(prefix) : len(path)-len(suffix)])
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "bad_request", "Invalid section ID")
+			return
+		}
+
+		if sectionID == 0 {
+			if !requireFeature(w, deps, config.FeatureAdhocTeams) {
+				return
+			}
+		} else if !checkSectionAccess(w, deps, session, sectionID) {
+			return
+		}
+
+		// Ad-hoc scoreboards belong to one user, so limit those per user.
+		limitKey := strconv.Itoa(sectionID)
+		if sectionID == 0 {
+			limitKey = "adhoc:" + strconv.Itoa(session.OSMUserID)
+		}
+		result, err := deps.Conns.GetRateLimiter().CheckRateLimit(ctx, "admin_refresh", limitKey, 1, sectionRefreshInterval)
+		if err != nil {
+			// Allow the refresh rather than blocking leaders
+			slog.Error("admin.api.refresh.rate_limit_error",
+				"component", "admin_api",
+				"event", "refresh.rate_limit_error",
+				"section_id", sectionID,
+				"error", err,
+			)
+		} else if !result.Allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(max(1, int(result.RetryAfter.Seconds()))))
+			writeJSONError(w, http.StatusTooManyRequests, "rate_limited", "Scoreboards for this section were just refreshed. Please try again in a few seconds.")
+			return
+		}
+
+		// Drop cached scores so devices fetch what OSM holds now.
+		if sectionID != 0 {
+			if err := deps.Conns.Redis.Del(ctx, services.PatrolScoresCacheKey(sectionID)).Err(); err != nil {
+				slog.Warn("admin.api.refresh.cache_invalidation_failed",
+					"component", "admin_api",
+					"event", "refresh.cache_error",
+					"section_id", sectionID,
+					"error", err,
+				)
+			}
+		}
+
+		if deps.WebSocketHub != nil {
+			if sectionID == 0 {
+				deps.WebSocketHub.BroadcastToAdhocUser(strconv.Itoa(session.OSMUserID), wsinternal.RefreshMessage())
+			} else {
+				deps.WebSocketHub.BroadcastToSection(strconv.Itoa(sectionID), wsinternal.RefreshMessage())
+			}
+		}
+
+		slog.Info("admin.api.refresh.sent",
+			"component", "admin_api",
+			"event", "refresh.sent",
+			"user_id", session.OSMUserID,
+			"section_id", sectionID,
+		)
+
+		writeJSON(w, map[string]bool{"success": true})
+	}
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	wslib "github.com/gorilla/websocket"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/deviceauth"
+	wsinternal "github.com/m0rjc/OsmDeviceAdapter/internal/websocket"
+)
+
+func TestAdminSectionRefreshHandler_ReachesSectionDevice(t *testing.T) {
+	deps := setupSettingsTestDeps(t)
+	deps.DeviceAuth = deviceauth.NewService(deps.Conns, nil)
+	deps.WebSocketHub = wsinternal.NewHub(deps.Conns.Redis)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go deps.WebSocketHub.Run(ctx)
+	time.Sleep(20 * time.Millisecond)
+
+	createTestDevice(t, deps, "refreshed", false)
+	srv := httptest.NewServer(wsinternal.DeviceWebSocketHandler(deps.WebSocketHub, deps.DeviceAuth, "http://localhost"))
+	t.Cleanup(srv.Close)
+	conn, _, err := wslib.DefaultDialer.Dial(strings.Replace(srv.URL, "http://", "ws://", 1)+"/ws/device?token=refreshed-device-token", nil)
+	if err != nil {
+		t.Fatalf("Failed to connect device: %v", err)
+	}
+	defer conn.Close()
+
+	// Wait for the device to register.
+	time.Sleep(100 * time.Millisecond)
+
+	path := fmt.Sprintf("/api/admin/sections/%d/refresh", settingsTestSectionID)
+	w := doAdminRequest(t, deps, AdminSectionRefreshHandler(deps), http.MethodPost, path, settingsTestCSRF, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second)) //nolint:errcheck
+	var msg wsinternal.Message
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("Failed to read message: %v", err)
+	}
+	if msg.Type != "refresh-scores" {
+		t.Errorf("expected a refresh-scores message, got %+v", msg)
+	}
+
+	// A second push straight away is refused
+	w = doAdminRequest(t, deps, AdminSectionRefreshHandler(deps), http.MethodPost, path, settingsTestCSRF, nil)
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("expected 429 for a repeated refresh, got %d", w.Code)
+	}
+}
+
+func TestAdminSectionRefreshHandler_Validation(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		csrfToken  string
+		wantStatus int
+	}{
+		{"missing CSRF token", fmt.Sprintf("/api/admin/sections/%d/refresh", settingsTestSectionID), "", http.StatusForbidden},
+		{"section without access", "/api/admin/sections/999/refresh", settingsTestCSRF, http.StatusForbidden},
+		{"invalid section ID", "/api/admin/sections/abc/refresh", settingsTestCSRF, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := setupSettingsTestDeps(t)
+			w := doAdminRequest(t, deps, AdminSectionRefreshHandler(deps), http.MethodPost, tt.path, tt.csrfToken, nil)
+			if w.Code != tt.wantStatus {
+				t.Errorf("expected %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
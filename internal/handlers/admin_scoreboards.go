@@ -19,6 +19,8 @@ type ScoreboardResponse struct {
 	SectionName      string  `json:"sectionName"`
 	ClientID         string  `json:"clientId"`
 	LastUsedAt       *string `json:"lastUsedAt,omitempty"`
+	// Status is the device's last health report, if it has sent one recently.
+	Status *wsinternal.DeviceStatus `json:"status,omitempty"`
 }
 
 // ScoreboardSectionUpdateRequest is the request body for changing a device's section.
@@ -82,12 +84,26 @@ func AdminScoreboardsHandler(deps *Dependencies) http.HandlerFunc {
 				lastUsed = &s
 			}
 
+			var status *wsinternal.DeviceStatus
+			if deps.WebSocketHub != nil {
+				status, err = deps.WebSocketHub.DeviceStatus(r.Context(), d.DeviceCode)
+				if err != nil {
+					slog.Warn("admin.scoreboards.status_failed",
+						"component", "admin_scoreboards",
+						"event", "status.error",
+						"device_code_prefix", prefix,
+						"error", err,
+					)
+				}
+			}
+
 			resp[i] = ScoreboardResponse{
 				DeviceCodePrefix: prefix,
 				SectionID:        d.SectionID,
 				SectionName:      sectionName,
 				ClientID:         d.ClientID,
 				LastUsedAt:       lastUsed,
+				Status:           status,
 			}
 		}
 
@@ -116,7 +132,7 @@ func AdminScoreboardSectionHandler(deps *Dependencies) http.HandlerFunc {
 
 		// Parse device code from URL: /api/admin/scoreboards/{deviceCode}/section
 		path := r.URL.Path
-		prefix := "/api/admin/scoreboards/"
+		prefix := deps.Config.Paths.AdminAPIPrefix + "/scoreboards/"
 		suffix := "/section"
 		if !strings.HasPrefix(path, prefix) || !strings.HasSuffix(path, suffix) {
 			writeJSONError(w, http.StatusNotFound, "not_found", "Invalid path")
@@ -160,7 +176,7 @@ func AdminScoreboardSectionHandler(deps *Dependencies) http.HandlerFunc {
 			user := session.User()
 			profile, err := deps.OSM.FetchOSMProfile(user)
 			if err != nil {
-				writeJSONError(w, http.StatusBadGateway, "osm_error", "Failed to validate section access")
+				writeProfileFetchError(w, err, "Failed to validate section access")
 				return
 			}
 			if profile.Data == nil {
@@ -191,10 +207,6 @@ func AdminScoreboardSectionHandler(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
-		// Invalidate device's patrol scores cache
-		cacheKey := "patrol_scores:" + *targetDevice
-		deps.Conns.Redis.Del(r.Context(), cacheKey)
-
 		slog.Info("admin.scoreboards.section_updated",
 			"component", "admin_scoreboards",
 			"event", "section.updated",
@@ -210,3 +222,77 @@ func AdminScoreboardSectionHandler(deps *Dependencies) http.HandlerFunc {
 		writeJSON(w, map[string]bool{"success": true})
 	}
 }
+
+// AdminScoreboardStatusHandler handles GET /api/admin/scoreboards/{deviceCode}/status.
+// Returns the last status report the device sent over its WebSocket, or 404 if
+// it has not reported recently.
+func AdminScoreboardStatusHandler(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed")
+			return
+		}
+
+		session, ok := middleware.WebSessionFromContext(r.Context())
+		if !ok {
+			writeJSONError(w, http.StatusUnauthorized, "unauthorized", "Not authenticated")
+			return
+		}
+
+		// Parse device code prefix from URL: /api/admin/scoreboards/{deviceCode}/status
+		path := r.URL.Path
+		prefix := deps.Config.Paths.AdminAPIPrefix + "/scoreboards/"
+		suffix := "/status"
+		if !strings.HasPrefix(path, prefix) || !strings.HasSuffix(path, suffix) {
+			writeJSONError(w, http.StatusNotFound, "not_found", "Invalid path")
+			return
+		}
+		deviceCodePrefix := path[len(prefix) : len(path)-len(suffix)]
+
+		// Find the device and validate ownership
+		devices, err := devicecode.FindByUser(deps.Conns, session.OSMUserID)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "internal_error", "Failed to look up devices")
+			return
+		}
+
+		var targetDevice *string
+		for _, d := range devices {
+			dp := d.DeviceCode
+			if len(dp) > 8 {
+				dp = dp[:8]
+			}
+			if dp == deviceCodePrefix {
+				targetDevice = &d.DeviceCode
+				break
+			}
+		}
+
+		if targetDevice == nil {
+			writeJSONError(w, http.StatusNotFound, "not_found", "Device not found")
+			return
+		}
+
+		if deps.WebSocketHub == nil {
+			writeJSONError(w, http.StatusNotFound, "status_unavailable", "Device has not reported its status")
+			return
+		}
+		status, err := deps.WebSocketHub.DeviceStatus(r.Context(), *targetDevice)
+		if err != nil {
+			slog.Error("admin.scoreboards.status_failed",
+				"component", "admin_scoreboards",
+				"event", "status.error",
+				"device_code_prefix", deviceCodePrefix,
+				"error", err,
+			)
+			writeJSONError(w, http.StatusInternalServerError, "internal_error", "Failed to read device status")
+			return
+		}
+		if status == nil {
+			writeJSONError(w, http.StatusNotFound, "status_unavailable", "Device has not reported its status")
+			return
+		}
+
+		writeJSON(w, status)
+	}
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/types"
+	wsinternal "github.com/m0rjc/OsmDeviceAdapter/internal/websocket"
+)
+
+// createScoreboardDevice adds an authorized device owned by the test session's user.
+func createScoreboardDevice(t *testing.T, deps *Dependencies, deviceCode string) {
+	t.Helper()
+	sectionID := settingsTestSectionID
+	osmUserID := 12345
+	device := &db.DeviceCode{
+		DeviceCode: deviceCode,
+		UserCode:   deviceCode[:8],
+		ClientID:   "test-client",
+		Status:     "authorized",
+		ExpiresAt:  time.Now().Add(time.Hour),
+		SectionID:  &sectionID,
+		OsmUserID:  &osmUserID,
+	}
+	if err := deps.Conns.DB.Create(device).Error; err != nil {
+		t.Fatalf("Failed to create device: %v", err)
+	}
+}
+
+func TestAdminScoreboardStatusHandler_ReturnsLastStatus(t *testing.T) {
+	deps := setupSettingsTestDeps(t)
+	deps.WebSocketHub = wsinternal.NewHub(deps.Conns.Redis)
+	createScoreboardDevice(t, deps, "statusdev-0001")
+
+	reportedAt := time.Now().UTC().Truncate(time.Second)
+	err := deps.WebSocketHub.RecordDeviceStatus(context.Background(), "statusdev-0001", wsinternal.DeviceStatus{
+		Uptime:            120,
+		FirmwareVersion:   "2.0.1",
+		ConnectionQuality: 55,
+		ReportedAt:        reportedAt,
+	})
+	if err != nil {
+		t.Fatalf("Failed to seed status: %v", err)
+	}
+
+	w := doAdminRequest(t, deps, AdminScoreboardStatusHandler(deps), http.MethodGet, "/api/admin/scoreboards/statusde/status", "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var status wsinternal.DeviceStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if status.Uptime != 120 || status.FirmwareVersion != "2.0.1" || status.ConnectionQuality != 55 {
+		t.Errorf("unexpected status %+v", status)
+	}
+	if !status.ReportedAt.Equal(reportedAt) {
+		t.Errorf("expected reportedAt %v, got %v", reportedAt, status.ReportedAt)
+	}
+}
+
+func TestAdminScoreboardStatusHandler_NotFound(t *testing.T) {
+	deps := setupSettingsTestDeps(t)
+	deps.WebSocketHub = wsinternal.NewHub(deps.Conns.Redis)
+	createScoreboardDevice(t, deps, "quietdev-0001")
+
+	// Owned device that has never reported
+	w := doAdminRequest(t, deps, AdminScoreboardStatusHandler(deps), http.MethodGet, "/api/admin/scoreboards/quietdev/status", "", nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 without a status, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Device that doesn't belong to the user
+	w = doAdminRequest(t, deps, AdminScoreboardStatusHandler(deps), http.MethodGet, "/api/admin/scoreboards/otherdev/status", "", nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown device, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestAdminScoreboardsHandler_ShowsRenamedSection(t *testing.T) {
+	deps := setupSettingsTestDeps(t)
+	createScoreboardDevice(t, deps, "renamedev-0001")
+
+	sectionName := "1st Anytown Cubs"
+	useOSMHandler(t, deps, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(types.OSMProfileResponse{
+			Status: true,
+			Data: &types.OSMProfileData{
+				UserID:   12345,
+				Sections: []types.OSMSection{{SectionID: settingsTestSectionID, SectionName: sectionName}},
+			},
+		})
+	})
+
+	listedName := func() string {
+		t.Helper()
+		w := doAdminRequest(t, deps, AdminScoreboardsHandler(deps), http.MethodGet, "/api/admin/scoreboards", "", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var boards []ScoreboardResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &boards); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if len(boards) != 1 {
+			t.Fatalf("expected one scoreboard, got %d", len(boards))
+		}
+		return boards[0].SectionName
+	}
+
+	if name := listedName(); name != "1st Anytown Cubs" {
+		t.Errorf("expected the OSM section name, got %q", name)
+	}
+
+	// The section is renamed in OSM
+	sectionName = "1st Anytown Beavers"
+	if name := listedName(); name != "1st Anytown Beavers" {
+		t.Errorf("expected the renamed section, got %q", name)
+	}
+}
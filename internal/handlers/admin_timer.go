@@ -38,7 +38,7 @@ func AdminScoreboardTimerHandler(deps *Dependencies) http.HandlerFunc {
 
 		// Parse device code prefix from URL: /api/admin/scoreboards/{deviceCode}/timer
 		path := r.URL.Path
-		prefix := "/api/admin/scoreboards/"
+		prefix := deps.Config.Paths.AdminAPIPrefix + "/scoreboards/"
 		suffix := "/timer"
 		if !strings.HasPrefix(path, prefix) || !strings.HasSuffix(path, suffix) {
 			writeJSONError(w, http.StatusNotFound, "not_found", "Invalid path")
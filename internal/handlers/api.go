@@ -5,18 +5,26 @@ import (
 	"errors"
 	"log/slog"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/m0rjc/OsmDeviceAdapter/internal/db"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db/devicecode"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/middleware"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/osm"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/services"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/types"
 )
 
 // GetPatrolScoresHandler handles GET /api/v1/patrols requests.
 // Expects authentication middleware to have already run and added User to context.
 // Returns patrol scores with intelligent caching and rate limiting.
+// An optional patrols query parameter (comma-separated patrol IDs) limits the
+// response to those patrols; the full section is still fetched and cached.
+// A device authorized for several sections chooses one with the section query
+// parameter, defaulting to its primary section.
 func GetPatrolScoresHandler(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
@@ -59,8 +67,43 @@ func GetPatrolScoresHandler(deps *Dependencies) http.HandlerFunc {
 			deps.Config,
 		)
 
+		// A multi-section device picks a section with ?section=, defaulting
+		// to its primary section
+		sections, err := devicecode.ListSections(deps.Conns, device)
+		if err != nil {
+			slog.Error("api.patrol_scores.sections_error",
+				"component", "api",
+				"event", "patrol.error",
+				"device_code_hash", device.DeviceCode[:8],
+				"error", err,
+			)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		sectionID := 0
+		if len(sections) > 0 {
+			sectionID = sections[0]
+		}
+		if requested := r.URL.Query().Get("section"); requested != "" {
+			id, err := strconv.Atoi(requested)
+			if err != nil {
+				writeJSONError(w, http.StatusBadRequest, "invalid_section", "section must be a section ID")
+				return
+			}
+			if !slices.Contains(sections, id) {
+				writeJSONError(w, http.StatusForbidden, "section_not_authorized", "This device is not authorized for that section")
+				return
+			}
+			sectionID = id
+		}
+
 		// Get patrol scores with caching and term management
-		response, err := patrolService.GetPatrolScores(ctx, user, device)
+		var response *services.PatrolScoreResponse
+		if len(sections) == 0 {
+			response, err = patrolService.GetPatrolScores(ctx, user, device)
+		} else {
+			response, err = patrolService.GetSectionPatrolScores(ctx, user, device, sectionID)
+		}
 		if err != nil {
 			slog.Error("api.patrol_scores.fetch_error",
 				"component", "api",
@@ -135,6 +178,19 @@ func GetPatrolScoresHandler(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
+		if len(sections) > 1 {
+			withSections := *response
+			withSections.Sections = sections
+			response = &withSections
+		}
+
+		// Filter after caching so devices with different filters share one cache entry
+		if ids := r.URL.Query().Get("patrols"); ids != "" {
+			filtered := *response
+			filtered.Patrols = filterPatrols(response.Patrols, ids)
+			response = &filtered
+		}
+
 		// Success - return patrol scores
 		w.Header().Set("Content-Type", "application/json")
 		if response.FromCache {
@@ -145,3 +201,25 @@ func GetPatrolScoresHandler(deps *Dependencies) http.HandlerFunc {
 		json.NewEncoder(w).Encode(response)
 	}
 }
+
+// filterPatrols returns the patrols whose IDs appear in the comma-separated
+// ids list, in their original order. Unknown IDs are ignored.
+func filterPatrols(patrols []types.PatrolScore, ids string) []types.PatrolScore {
+	wanted := make(map[string]bool)
+	for _, id := range strings.Split(ids, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			wanted[id] = true
+		}
+	}
+	if len(wanted) == 0 {
+		return patrols
+	}
+
+	filtered := make([]types.PatrolScore, 0, len(wanted))
+	for _, patrol := range patrols {
+		if wanted[patrol.ID] {
+			filtered = append(filtered, patrol)
+		}
+	}
+	return filtered
+}
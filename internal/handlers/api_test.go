@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db/devicecode"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/deviceauth"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/middleware"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/osm"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/services"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/types"
+)
+
+func TestFilterPatrols(t *testing.T) {
+	patrols := []types.PatrolScore{
+		{ID: "1", Name: "Eagles", Score: 45},
+		{ID: "2", Name: "Hawks", Score: 30},
+		{ID: "3", Name: "Owls", Score: 12},
+	}
+
+	tests := []struct {
+		name string
+		ids  string
+		want []string
+	}{
+		{"keeps section order", "3,1", []string{"1", "3"}},
+		{"ignores unknown IDs", "2,99", []string{"2"}},
+		{"trims whitespace", " 1 , 2 ", []string{"1", "2"}},
+		{"no matches gives an empty list", "99", []string{}},
+		{"only separators leaves the list alone", ",,", []string{"1", "2", "3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := filterPatrols(patrols, tt.ids)
+			if got == nil {
+				t.Fatal("expected a non-nil slice")
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %v, got %+v", tt.want, got)
+			}
+			for i, id := range tt.want {
+				if got[i].ID != id {
+					t.Errorf("position %d: expected patrol %s, got %s", i, id, got[i].ID)
+				}
+			}
+		})
+	}
+}
+
+func TestGetPatrolScoresHandler_HidesLowScoresFromDevicesOnly(t *testing.T) {
+	deps := setupSettingsTestDeps(t)
+	deviceToken := createTestDevice(t, deps, "display-client", false)
+
+	// Eagles have 10 points and Hawks 20
+	threshold := 15
+	w := doSettingsRequest(t, deps, http.MethodPut, AdminSettingsUpdateRequest{HideBelowScore: &threshold})
+	if w.Code != http.StatusOK {
+		t.Fatalf("PUT settings: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/patrols", nil)
+	req.Header.Set("Authorization", "Bearer "+deviceToken)
+	w = httptest.NewRecorder()
+	middleware.DeviceAuthMiddleware(deviceauth.NewService(deps.Conns, nil))(GetPatrolScoresHandler(deps)).ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("device GET: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var device services.PatrolScoreResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &device); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(device.Patrols) != 1 || device.Patrols[0].ID != "2" || device.HiddenCount != 1 {
+		t.Errorf("expected only Hawks with one patrol hidden, got %+v (hidden %d)", device.Patrols, device.HiddenCount)
+	}
+
+	path := fmt.Sprintf("/api/admin/sections/%d/scores", settingsTestSectionID)
+	w = doAdminRequest(t, deps, AdminScoresHandler(deps), http.MethodGet, path, "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("admin GET: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var admin AdminScoresResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &admin); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(admin.Patrols) != 2 {
+		t.Errorf("expected the admin to see both patrols, got %+v", admin.Patrols)
+	}
+}
+
+func TestGetPatrolScoresHandler_SectionQueryPicksAuthorizedSection(t *testing.T) {
+	otherSectionID := settingsTestSectionID + 100
+	deps := setupAdminAPITestDeps(t, map[string]osm.PatrolData{
+		"1": {PatrolID: "1", Name: "Eagles", Points: "10", Members: []any{"a"}},
+	}, otherSectionID)
+	deviceToken := createTestDevice(t, deps, "cycling-client", false)
+	if err := devicecode.SetSections(deps.Conns, "cycling-client-device-code", []int{settingsTestSectionID, otherSectionID}); err != nil {
+		t.Fatalf("Failed to set device sections: %v", err)
+	}
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/patrols"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+deviceToken)
+		w := httptest.NewRecorder()
+		middleware.DeviceAuthMiddleware(deviceauth.NewService(deps.Conns, nil))(GetPatrolScoresHandler(deps)).ServeHTTP(w, req)
+		return w
+	}
+
+	for _, query := range []string{"", fmt.Sprintf("?section=%d", otherSectionID)} {
+		w := get(query)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %q: expected 200, got %d: %s", query, w.Code, w.Body.String())
+		}
+		var response services.PatrolScoreResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if len(response.Sections) != 2 || response.Sections[0] != settingsTestSectionID || response.Sections[1] != otherSectionID {
+			t.Errorf("GET %q: expected sections [%d %d], got %v", query, settingsTestSectionID, otherSectionID, response.Sections)
+		}
+	}
+
+	if w := get("?section=999"); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a section the device may not show, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := get("?section=abc"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a malformed section, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// The term stored on the device stays the primary section's
+	device, err := devicecode.FindByCode(deps.Conns, "cycling-client-device-code")
+	if err != nil || device == nil || device.SectionID == nil || *device.SectionID != settingsTestSectionID {
+		t.Fatalf("expected the device to keep its primary section, got %+v (%v)", device, err)
+	}
+}
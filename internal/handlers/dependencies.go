@@ -1,9 +1,12 @@
 package handlers
 
 import (
+	"net/http"
+
 	"github.com/m0rjc/OsmDeviceAdapter/internal/config"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/db"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/deviceauth"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/middleware"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/osm"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/osm/oauthclient"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/services/scoreupdateservice"
@@ -20,4 +23,9 @@ type Dependencies struct {
 	WebAuth            *webauth.Service
 	ScoreUpdateService *scoreupdateservice.ScoreUpdateService
 	WebSocketHub       *wsinternal.Hub
+	// DemoOSM serves the in-process fake OSM in demo mode; nil otherwise.
+	DemoOSM http.Handler
+	// Countries resolves client IPs to countries when the proxy does not send
+	// CF-IPCountry; nil when no GeoIP database is configured.
+	Countries middleware.CountryResolver
 }
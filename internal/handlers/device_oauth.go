@@ -2,12 +2,15 @@ package handlers
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"log/slog"
 	"math/big"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
@@ -18,9 +21,23 @@ import (
 	"github.com/m0rjc/OsmDeviceAdapter/internal/middleware"
 )
 
+// osmBlockedRetryAfter is the Retry-After sent while OSM has blocked the service
+// with no known end time.
+const osmBlockedRetryAfter = 5 * time.Minute
+
+// pollIntervalJitter is the fraction of DEVICE_POLL_INTERVAL that may be added
+// to the interval advertised to each device, so a fleet powered on together
+// does not poll in lockstep. Only ever added: slow_down is enforced at the
+// configured interval, which stays the minimum.
+const pollIntervalJitter = 0.2
+
 type DeviceAuthorizationRequest struct {
 	ClientID string `json:"client_id"`
 	Scope    string `json:"scope,omitempty"`
+	// CodeChallenge and CodeChallengeMethod bind the device code to a secret
+	// held by the device, as in PKCE (RFC 7636). Only S256 is supported.
+	CodeChallenge       string `json:"code_challenge,omitempty"`
+	CodeChallengeMethod string `json:"code_challenge_method,omitempty"`
 }
 
 type DeviceAuthorizationResponse struct {
@@ -37,6 +54,8 @@ type DeviceTokenRequest struct {
 	GrantType  string `json:"grant_type"`
 	DeviceCode string `json:"device_code"`
 	ClientID   string `json:"client_id"`
+	// CodeVerifier is required when a code challenge was given at authorize time.
+	CodeVerifier string `json:"code_verifier,omitempty"`
 }
 
 type DeviceTokenResponse struct {
@@ -94,6 +113,25 @@ func DeviceAuthorizeHandler(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
+		// A device paired now could do nothing until OSM lifts the block
+		if deps.Config.DeviceOAuth.RejectWhileOSMBlocked {
+			if blocked, blockedUntil := deps.Conns.Redis.GetOsmServiceBlockEndTime(r.Context()); blocked {
+				retryAfter := osmBlockedRetryAfter
+				if !blockedUntil.IsZero() {
+					retryAfter = max(time.Until(blockedUntil), time.Second)
+				}
+				slog.Warn("device.authorize.osm_blocked",
+					"component", "device_oauth",
+					"event", "authorize.osm_blocked",
+					"client_ip", clientIP,
+					"retry_after", retryAfter.Seconds(),
+				)
+				w.Header().Set("Retry-After", fmt.Sprintf("%d", int(retryAfter.Seconds())))
+				http.Error(w, "Service degraded. Please try again later.", http.StatusServiceUnavailable)
+				return
+			}
+		}
+
 		var req DeviceAuthorizationRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			http.Error(w, "Invalid request body", http.StatusBadRequest)
@@ -130,6 +168,39 @@ func DeviceAuthorizeHandler(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
+		client, err := allowedclient.FindByID(deps.Conns, allowedClientID)
+		if err != nil {
+			slog.Error("device.authorize.db_error",
+				"component", "device_oauth",
+				"event", "authorize.error",
+				"client_id", req.ClientID,
+				"error", err,
+			)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		var codeChallenge *string
+		if req.CodeChallenge != "" {
+			if req.CodeChallengeMethod != "S256" || !isValidCodeChallenge(req.CodeChallenge) {
+				http.Error(w, "code_challenge must be an S256 challenge", http.StatusBadRequest)
+				return
+			}
+			codeChallenge = &req.CodeChallenge
+		} else {
+			if client != nil && client.RequireProofKey {
+				slog.Warn("device.authorize.denied",
+					"component", "device_oauth",
+					"event", "authorize.denied",
+					"client_id", req.ClientID,
+					"reason", "missing_code_challenge",
+				)
+				metrics.DeviceAuthRequests.WithLabelValues(req.ClientID, "denied").Inc()
+				http.Error(w, "code_challenge is required for this client", http.StatusBadRequest)
+				return
+			}
+		}
+
 		// Generate device code and user code
 		deviceCode, err := generateRandomString(32)
 		if err != nil {
@@ -156,7 +227,8 @@ func DeviceAuthorizeHandler(deps *Dependencies) http.HandlerFunc {
 		}
 
 		// Store in database
-		expiresAt := time.Now().Add(time.Duration(deps.Config.DeviceOAuth.DeviceCodeExpiry) * time.Second)
+		expirySeconds, pollIntervalSeconds := deviceFlowTiming(deps, client)
+		expiresAt := time.Now().Add(time.Duration(expirySeconds) * time.Second)
 		now := time.Now()
 		deviceCodeRecord := &db.DeviceCode{
 			DeviceCode:           deviceCode,
@@ -166,9 +238,10 @@ func DeviceAuthorizeHandler(deps *Dependencies) http.HandlerFunc {
 			ExpiresAt:            expiresAt,
 			Status:               "pending",
 			CreatedAt:            now,
-			DeviceRequestIP:      &remoteMetadata.IP,
+			DeviceRequestIP:      storedIP(deps, remoteMetadata.IP),
 			DeviceRequestCountry: &remoteMetadata.Country,
 			DeviceRequestTime:    &now,
+			CodeChallenge:        codeChallenge,
 		}
 		if err := devicecode.Create(deps.Conns, deviceCodeRecord); err != nil {
 			slog.Error("device.authorize.db_store_failed",
@@ -183,11 +256,12 @@ func DeviceAuthorizeHandler(deps *Dependencies) http.HandlerFunc {
 		}
 
 		// Build verification URLs using configurable path prefix
-		verificationURI := fmt.Sprintf("%s%s", deps.Config.ExternalDomains.ExposedDomain, deps.Config.Paths.DevicePrefix)
-		verificationURIComplete := fmt.Sprintf("%s%s?user_code=%s", deps.Config.ExternalDomains.ExposedDomain, deps.Config.Paths.DevicePrefix, userCode)
+		exposedDomain := exposedDomainFor(r, deps)
+		verificationURI := fmt.Sprintf("%s%s", exposedDomain, deps.Config.Paths.DevicePrefix)
+		verificationURIComplete := fmt.Sprintf("%s%s?user_code=%s", exposedDomain, deps.Config.Paths.DevicePrefix, userCode)
 		// Short URL for QR codes (no hyphen in user code)
 		userCodeNoHyphen := strings.ReplaceAll(userCode, "-", "")
-		verificationURIShort := fmt.Sprintf("%s/d/%s", deps.Config.ExternalDomains.ExposedDomain, userCodeNoHyphen)
+		verificationURIShort := fmt.Sprintf("%s/d/%s", exposedDomain, userCodeNoHyphen)
 
 		slog.Info("device.authorize.success",
 			"component", "device_oauth",
@@ -195,7 +269,7 @@ func DeviceAuthorizeHandler(deps *Dependencies) http.HandlerFunc {
 			"client_id", req.ClientID,
 			"user_code", userCode,
 			"device_code_hash", fmt.Sprintf("%s...", deviceCode[:8]), // Log truncated for security
-			"expires_in", deps.Config.DeviceOAuth.DeviceCodeExpiry,
+			"expires_in", expirySeconds,
 		)
 		metrics.DeviceAuthRequests.WithLabelValues(req.ClientID, "success").Inc()
 
@@ -205,8 +279,8 @@ func DeviceAuthorizeHandler(deps *Dependencies) http.HandlerFunc {
 			VerificationURI:         verificationURI,
 			VerificationURIComplete: verificationURIComplete,
 			VerificationURIShort:    verificationURIShort,
-			ExpiresIn:               deps.Config.DeviceOAuth.DeviceCodeExpiry,
-			Interval:                deps.Config.DeviceOAuth.DevicePollInterval,
+			ExpiresIn:               expirySeconds,
+			Interval:                jitteredPollInterval(pollIntervalSeconds),
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -270,8 +344,15 @@ func DeviceTokenHandler(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
-		// Enforce slow_down - client must not poll faster than the configured interval
-		pollInterval := time.Duration(deps.Config.DeviceOAuth.DevicePollInterval) * time.Second
+		// Enforce slow_down - client must not poll faster than the configured
+		// interval, or its client ID's own interval if it names one
+		pollIntervalSeconds := deps.Config.DeviceOAuth.DevicePollInterval
+		if req.ClientID != "" {
+			if client, err := allowedclient.Find(deps.Conns, req.ClientID); err == nil && client != nil {
+				_, pollIntervalSeconds = deviceFlowTiming(deps, client)
+			}
+		}
+		pollInterval := time.Duration(pollIntervalSeconds) * time.Second
 		pollKey := fmt.Sprintf("device_token_poll:%s", req.DeviceCode)
 
 		// Check if polling too fast using rate limiter (1 request per poll interval)
@@ -299,7 +380,7 @@ func DeviceTokenHandler(deps *Dependencies) http.HandlerFunc {
 				"device_code_hash", fmt.Sprintf("%s...", req.DeviceCode[:8]),
 				"retry_after", pollResult.RetryAfter.Seconds(),
 			)
-			sendTokenError(w, "slow_down", fmt.Sprintf("Polling too fast. Please wait at least %d seconds between requests.", deps.Config.DeviceOAuth.DevicePollInterval))
+			sendTokenError(w, "slow_down", fmt.Sprintf("Polling too fast. Please wait at least %d seconds between requests.", pollIntervalSeconds))
 			return
 		}
 
@@ -338,9 +419,35 @@ func DeviceTokenHandler(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
+		// A device that gave a code challenge must prove it is the one polling
+		if deviceCodeRecord.CodeChallenge != nil && !verifyCodeChallenge(*deviceCodeRecord.CodeChallenge, req.CodeVerifier) {
+			slog.Warn("device.token.bad_code_verifier",
+				"component", "device_oauth",
+				"event", "token.error",
+				"client_id", deviceCodeRecord.ClientID,
+				"user_code", deviceCodeRecord.UserCode,
+				"error", "code_verifier_mismatch",
+			)
+			sendTokenError(w, "invalid_grant", "code_verifier does not match the code challenge")
+			return
+		}
+
 		// Check status
 		switch deviceCodeRecord.Status {
 		case "pending", "awaiting_section":
+			if deviceCodeRecord.Status == "awaiting_section" && sectionSelectionTimedOut(deps, deviceCodeRecord, time.Now()) {
+				if err := devicecode.UpdateStatus(deps.Conns, deviceCodeRecord.DeviceCode, "abandoned"); err != nil {
+					slog.Error("device.token.abandon_failed",
+						"component", "device_oauth",
+						"event", "token.error",
+						"client_id", deviceCodeRecord.ClientID,
+						"user_code", deviceCodeRecord.UserCode,
+						"error", err,
+					)
+				}
+				sendAbandonedTokenError(w, deviceCodeRecord)
+				return
+			}
 			slog.Debug("device.token.pending",
 				"component", "device_oauth",
 				"event", "token.pending",
@@ -350,6 +457,9 @@ func DeviceTokenHandler(deps *Dependencies) http.HandlerFunc {
 			)
 			sendTokenError(w, "authorization_pending", "User has not yet authorized")
 			return
+		case "abandoned":
+			sendAbandonedTokenError(w, deviceCodeRecord)
+			return
 		case "denied":
 			slog.Info("device.token.denied",
 				"component", "device_oauth",
@@ -374,12 +484,10 @@ func DeviceTokenHandler(deps *Dependencies) http.HandlerFunc {
 				return
 			}
 
-			// Device tokens don't expire, but we still track OSM token expiry server-side
-			// For client compatibility, we can return a long expiration time
-			expiresIn := 0
-			if deviceCodeRecord.OSMTokenExpiry != nil {
-				expiresIn = int(time.Until(*deviceCodeRecord.OSMTokenExpiry).Seconds())
-			}
+			// Device tokens don't expire; the OSM token behind them is refreshed
+			// server-side, so its expiry must not leak to the device. Report the
+			// configured lifetime instead (0 omits expires_in).
+			expiresIn := deps.Config.DeviceOAuth.DeviceTokenExpiresIn
 
 			slog.Info("device.token.issued",
 				"component", "device_oauth",
@@ -413,6 +521,45 @@ func DeviceTokenHandler(deps *Dependencies) http.HandlerFunc {
 	}
 }
 
+// isValidCodeChallenge checks for an unpadded base64url SHA-256 digest.
+func isValidCodeChallenge(challenge string) bool {
+	decoded, err := base64.RawURLEncoding.DecodeString(challenge)
+	return err == nil && len(decoded) == sha256.Size
+}
+
+// verifyCodeChallenge reports whether verifier hashes to challenge under S256.
+func verifyCodeChallenge(challenge, verifier string) bool {
+	if verifier == "" {
+		return false
+	}
+	digest := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(digest[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+// sectionSelectionTimedOut reports whether a code in "awaiting_section" has
+// waited longer than the configured section selection timeout.
+func sectionSelectionTimedOut(deps *Dependencies, record *db.DeviceCode, now time.Time) bool {
+	timeout := time.Duration(deps.Config.DeviceOAuth.SectionSelectionTimeout) * time.Second
+	if timeout <= 0 || record.OSMAuthorizedAt == nil {
+		return false
+	}
+	return now.After(record.OSMAuthorizedAt.Add(timeout))
+}
+
+// sendAbandonedTokenError tells the device to stop polling and start again.
+// expired_token is used so that standard clients restart the flow.
+func sendAbandonedTokenError(w http.ResponseWriter, record *db.DeviceCode) {
+	slog.Info("device.token.abandoned",
+		"component", "device_oauth",
+		"event", "token.abandoned",
+		"client_id", record.ClientID,
+		"user_code", record.UserCode,
+	)
+	metrics.DeviceAuthRequests.WithLabelValues(record.ClientID, "abandoned").Inc()
+	sendTokenError(w, "expired_token", "No section was selected in time")
+}
+
 func sendTokenError(w http.ResponseWriter, errorCode, description string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusBadRequest)
@@ -443,6 +590,15 @@ func generateDeviceAccessToken() (string, error) {
 	return base64.RawURLEncoding.EncodeToString(bytes), nil
 }
 
+// newDeviceAccessToken returns a signed access token for deviceCode when token
+// signing is configured, and a random opaque token otherwise.
+func newDeviceAccessToken(deps *Dependencies, deviceCode string) (string, error) {
+	if token, ok := deps.DeviceAuth.SignAccessToken(deviceCode); ok {
+		return token, nil
+	}
+	return generateDeviceAccessToken()
+}
+
 func generateUserCode() (string, error) {
 	// Base20: No vowels (prevents accidental words), no ambiguous chars. RFC-8628
 	const charset = "BCDFGHJKLMNPQRSTVWXZ"
@@ -511,3 +667,70 @@ func extractBearerToken(authHeader string) string {
 	}
 	return ""
 }
+
+// storedIP returns a client IP as it should be persisted: the network only when
+// ANONYMIZE_IPS is set. Nil means there is nothing worth storing.
+func storedIP(deps *Dependencies, ip string) *string {
+	if deps.Config.Security.AnonymizeIPs {
+		ip = middleware.AnonymizeIP(ip)
+	}
+	if ip == "" {
+		return nil
+	}
+	return &ip
+}
+
+// exposedDomainFor returns the base URL to give the client in links: the
+// configured exposed domain, or the same scheme on the proxy-reported host when
+// that host is trusted. Untrusted forwarded hosts are ignored.
+func exposedDomainFor(r *http.Request, deps *Dependencies) string {
+	domains := deps.Config.ExternalDomains
+	host := middleware.RemoteFromContext(r.Context()).ForwardedHost
+	if host == "" || !domains.IsTrustedForwardedHost(host) {
+		return domains.ExposedDomain
+	}
+	exposed, err := url.Parse(domains.ExposedDomain)
+	if err != nil {
+		return domains.ExposedDomain
+	}
+	return (&url.URL{Scheme: exposed.Scheme, Host: host}).String()
+}
+
+// deviceFlowTiming returns the device code lifetime and poll interval, in
+// seconds, for devices using client: its overrides where set, the service
+// defaults otherwise. Overrides out of bounds, which can only be stored by
+// editing the database directly, are ignored.
+func deviceFlowTiming(deps *Dependencies, client *db.AllowedClientID) (expirySeconds, pollIntervalSeconds int) {
+	expirySeconds = deps.Config.DeviceOAuth.DeviceCodeExpiry
+	pollIntervalSeconds = deps.Config.DeviceOAuth.DevicePollInterval
+	if client == nil {
+		return expirySeconds, pollIntervalSeconds
+	}
+	if err := allowedclient.ValidateDeviceFlowOverrides(client); err != nil {
+		slog.Warn("device.authorize.invalid_client_timing",
+			"component", "device_oauth",
+			"event", "authorize.config_error",
+			"client_id", client.ClientID,
+			"error", err,
+		)
+		return expirySeconds, pollIntervalSeconds
+	}
+	if client.DeviceCodeExpirySeconds != nil {
+		expirySeconds = *client.DeviceCodeExpirySeconds
+	}
+	if client.PollIntervalSeconds != nil {
+		pollIntervalSeconds = *client.PollIntervalSeconds
+	}
+	return expirySeconds, pollIntervalSeconds
+}
+
+// jitteredPollInterval returns base plus a random number of seconds up to
+// pollIntervalJitter of it (at least one).
+func jitteredPollInterval(base int) int {
+	spread := max(int64(float64(base)*pollIntervalJitter), 1)
+	n, err := rand.Int(rand.Reader, big.NewInt(spread+1))
+	if err != nil {
+		return base
+	}
+	return base + int(n.Int64())
+}
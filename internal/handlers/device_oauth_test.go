@@ -2,12 +2,17 @@ package handlers
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/config"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/db"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/db/allowedclient"
@@ -116,6 +121,209 @@ func TestDeviceAuthorizeHandler_ValidClientID(t *testing.T) {
 	}
 }
 
+func TestDeviceAuthorizeHandler_JittersAdvertisedInterval(t *testing.T) {
+	deps := setupTestDeps(t, []string{"test-client-1"})
+	deps.Config.DeviceOAuth.DevicePollInterval = 10
+	handler := DeviceAuthorizeHandler(deps)
+
+	seen := make(map[int]bool)
+	for i := 0; i < 40; i++ {
+		body, _ := json.Marshal(DeviceAuthorizationRequest{ClientID: "test-client-1"})
+		req := httptest.NewRequest(http.MethodPost, "/device/authorize", bytes.NewReader(body))
+		req = req.WithContext(middleware.ContextWithRemote(req.Context(), middleware.RemoteMetadata{IP: "192.168.1.1"}))
+		w := httptest.NewRecorder()
+		handler(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+		}
+
+		var resp DeviceAuthorizationResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		// Never below the enforced interval, at most 20% above it
+		if resp.Interval < 10 || resp.Interval > 12 {
+			t.Fatalf("Expected interval between 10 and 12, got %d", resp.Interval)
+		}
+		seen[resp.Interval] = true
+	}
+	if len(seen) < 2 {
+		t.Errorf("Expected the interval to vary across authorizations, always got %v", seen)
+	}
+}
+
+func TestDeviceAuthorizeHandler_UsesClientTimingOverrides(t *testing.T) {
+	deps := setupTestDeps(t, []string{"default-client"})
+	expiry, interval := 900, 30
+	if err := allowedclient.Create(deps.Conns, &db.AllowedClientID{
+		ClientID:                "slow-client",
+		Comment:                 "Battery powered",
+		ContactEmail:            "test@example.com",
+		Enabled:                 true,
+		DeviceCodeExpirySeconds: &expiry,
+		PollIntervalSeconds:     &interval,
+	}); err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	authorize := func(clientID string) DeviceAuthorizationResponse {
+		body, _ := json.Marshal(DeviceAuthorizationRequest{ClientID: clientID})
+		req := httptest.NewRequest(http.MethodPost, "/device/authorize", bytes.NewReader(body))
+		req = req.WithContext(middleware.ContextWithRemote(req.Context(), middleware.RemoteMetadata{IP: "192.168.1.1"}))
+		w := httptest.NewRecorder()
+		DeviceAuthorizeHandler(deps)(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+		}
+		var resp DeviceAuthorizationResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return resp
+	}
+
+	resp := authorize("slow-client")
+	if resp.ExpiresIn != 900 {
+		t.Errorf("Expected expires_in 900 from the client override, got %d", resp.ExpiresIn)
+	}
+	if resp.Interval < 30 || resp.Interval > 36 {
+		t.Errorf("Expected interval between 30 and 36 from the client override, got %d", resp.Interval)
+	}
+	stored, err := devicecode.FindByCode(deps.Conns, resp.DeviceCode)
+	if err != nil || stored == nil {
+		t.Fatalf("Failed to load device code: %v", err)
+	}
+	if remaining := time.Until(stored.ExpiresAt); remaining < 890*time.Second || remaining > 900*time.Second {
+		t.Errorf("Expected the stored code to expire in about 900s, got %s", remaining)
+	}
+
+	resp = authorize("default-client")
+	if resp.ExpiresIn != 300 || resp.Interval < 5 || resp.Interval > 6 {
+		t.Errorf("Expected the global 300s expiry and 5s interval, got %d and %d", resp.ExpiresIn, resp.Interval)
+	}
+}
+
+func TestDeviceAuthorizeHandler_UsesTrustedForwardedHost(t *testing.T) {
+	deps := setupTestDeps(t, []string{"test-client-1"})
+	deps.Config.ExternalDomains.TrustedForwardedHosts = "scores.example.org, Other.example.net"
+	handler := DeviceAuthorizeHandler(deps)
+
+	tests := []struct {
+		name          string
+		forwardedHost string
+		expected      string
+	}{
+		{"trusted host", "scores.example.org", "https://scores.example.org/device"},
+		{"trusted host in other case", "OTHER.example.net", "https://OTHER.example.net/device"},
+		{"untrusted host", "evil.example.com", "https://example.com/device"},
+		{"no forwarded host", "", "https://example.com/device"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(DeviceAuthorizationRequest{ClientID: "test-client-1"})
+			req := httptest.NewRequest(http.MethodPost, "/device/authorize", bytes.NewReader(body))
+			req = req.WithContext(middleware.ContextWithRemote(req.Context(), middleware.RemoteMetadata{IP: "192.168.1.1", ForwardedHost: tt.forwardedHost}))
+			w := httptest.NewRecorder()
+			handler(w, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+			}
+
+			var resp DeviceAuthorizationResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if resp.VerificationURI != tt.expected {
+				t.Errorf("Expected verification URI %q, got %q", tt.expected, resp.VerificationURI)
+			}
+			if !strings.HasPrefix(resp.VerificationURIComplete, tt.expected+"?user_code=") {
+				t.Errorf("Expected complete verification URI on %q, got %q", tt.expected, resp.VerificationURIComplete)
+			}
+		})
+	}
+}
+
+func TestDeviceAuthorizeHandler_AnonymizesStoredIP(t *testing.T) {
+	deps := setupTestDeps(t, []string{"test-client-1"})
+	deps.Config.Security.AnonymizeIPs = true
+	handler := DeviceAuthorizeHandler(deps)
+
+	body, _ := json.Marshal(DeviceAuthorizationRequest{ClientID: "test-client-1"})
+	req := httptest.NewRequest(http.MethodPost, "/device/authorize", bytes.NewReader(body))
+	req = req.WithContext(middleware.ContextWithRemote(req.Context(), middleware.RemoteMetadata{IP: "203.0.113.77", Country: "GB"}))
+	w := httptest.NewRecorder()
+	handler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	var resp DeviceAuthorizationResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	record, err := devicecode.FindByCode(deps.Conns, resp.DeviceCode)
+	if err != nil || record == nil {
+		t.Fatalf("Failed to load device code: %v", err)
+	}
+	if record.DeviceRequestIP == nil || *record.DeviceRequestIP != "203.0.113.0" {
+		t.Errorf("Expected stored IP 203.0.113.0, got %v", record.DeviceRequestIP)
+	}
+	if record.DeviceRequestCountry == nil || *record.DeviceRequestCountry != "GB" {
+		t.Errorf("Expected stored country GB, got %v", record.DeviceRequestCountry)
+	}
+
+	// The country comparison on the confirmation page is unaffected
+	page := httptest.NewRecorder()
+	showDeviceConfirmationPage(page, resp.UserCode, record, middleware.RemoteMetadata{IP: "198.51.100.0", Country: "FR"}, "session")
+	if !strings.Contains(page.Body.String(), "Country Mismatch Detected") {
+		t.Error("Expected the country mismatch warning with an anonymized IP")
+	}
+	if !strings.Contains(page.Body.String(), "203.0.113.0") || strings.Contains(page.Body.String(), "203.0.113.77") {
+		t.Error("Expected the confirmation page to show only the anonymized device IP")
+	}
+}
+
+func TestDeviceAuthorizeHandler_RejectsWhileOSMBlocked(t *testing.T) {
+	deps := setupTestDeps(t, []string{"test-client-1"})
+	mr := miniredis.RunT(t)
+	rc, err := db.NewRedisClient("redis://"+mr.Addr(), "test:")
+	if err != nil {
+		t.Fatalf("Failed to create Redis client: %v", err)
+	}
+	deps.Conns.Redis = rc
+	handler := DeviceAuthorizeHandler(deps)
+
+	authorize := func() *httptest.ResponseRecorder {
+		body, _ := json.Marshal(DeviceAuthorizationRequest{ClientID: "test-client-1"})
+		req := httptest.NewRequest(http.MethodPost, "/device/authorize", bytes.NewReader(body))
+		req = req.WithContext(middleware.ContextWithRemote(req.Context(), middleware.RemoteMetadata{IP: "192.168.1.1"}))
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+
+	rc.MarkOsmServiceBlockedUntil(context.Background(), time.Now().Add(10*time.Minute))
+
+	// The guard is off by default
+	if w := authorize(); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 with the guard off, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	deps.Config.DeviceOAuth.RejectWhileOSMBlocked = true
+	w := authorize()
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status 503 while OSM is blocked, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	if retryAfter := w.Header().Get("Retry-After"); retryAfter == "" || retryAfter == "0" {
+		t.Errorf("Expected a Retry-After header, got %q", retryAfter)
+	}
+
+	mr.FastForward(11 * time.Minute)
+	if w := authorize(); w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 once the block is lifted, got %d. Body: %s", w.Code, w.Body.String())
+	}
+}
+
 func TestDeviceAuthorizeHandler_InvalidClientID(t *testing.T) {
 	deps := setupTestDeps(t, []string{"test-client-1", "test-client-2"})
 	handler := DeviceAuthorizeHandler(deps)
@@ -344,3 +552,193 @@ func TestDeviceAccessTokenUniqueness(t *testing.T) {
 		t.Error("Expected error when creating device code with duplicate device access token")
 	}
 }
+
+func TestDeviceTokenHandler_ExpiresInFollowsConfig(t *testing.T) {
+	tests := []struct {
+		name           string
+		configured     int
+		wantExpiresIn  int
+		wantFieldFound bool
+	}{
+		{"non-expiring omits expires_in", 0, 0, false},
+		{"configured lifetime", 86400, 86400, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := setupTestDeps(t, []string{"test-client"})
+			deps.Config.DeviceOAuth.DeviceTokenExpiresIn = tt.configured
+
+			// The OSM token is about to expire; that must not leak to the device
+			deviceAccessToken := "device-token-expiry-test"
+			osmExpiry := time.Now().Add(10 * time.Second)
+			if err := devicecode.Create(deps.Conns, &db.DeviceCode{
+				DeviceCode:        "expiry-test-device-code",
+				UserCode:          "EXPI-RYTS",
+				ClientID:          "test-client",
+				Status:            "authorized",
+				DeviceAccessToken: &deviceAccessToken,
+				OSMTokenExpiry:    &osmExpiry,
+				ExpiresAt:         time.Now().Add(5 * time.Minute),
+			}); err != nil {
+				t.Fatalf("Failed to create device code: %v", err)
+			}
+
+			body, _ := json.Marshal(DeviceTokenRequest{
+				GrantType:  "urn:ietf:params:oauth:grant-type:device_code",
+				DeviceCode: "expiry-test-device-code",
+				ClientID:   "test-client",
+			})
+			req := httptest.NewRequest(http.MethodPost, "/device/token", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			DeviceTokenHandler(deps)(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+
+			var raw map[string]any
+			if err := json.Unmarshal(w.Body.Bytes(), &raw); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			expiresIn, found := raw["expires_in"]
+			if found != tt.wantFieldFound {
+				t.Fatalf("expires_in present = %v, want %v (body %s)", found, tt.wantFieldFound, w.Body.String())
+			}
+			if found && int(expiresIn.(float64)) != tt.wantExpiresIn {
+				t.Errorf("Expected expires_in %d, got %v", tt.wantExpiresIn, expiresIn)
+			}
+		})
+	}
+}
+
+func TestDeviceTokenHandler_AwaitingSectionTimesOut(t *testing.T) {
+	tests := []struct {
+		name         string
+		authorizedAt time.Duration // before now
+		wantError    string
+		wantStatus   string
+	}{
+		{"within timeout keeps polling", 30 * time.Second, "authorization_pending", "awaiting_section"},
+		{"past timeout is abandoned", 3 * time.Minute, "expired_token", "abandoned"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := setupTestDeps(t, []string{"test-client"})
+			deps.Config.DeviceOAuth.SectionSelectionTimeout = 120
+
+			authorizedAt := time.Now().Add(-tt.authorizedAt)
+			if err := devicecode.Create(deps.Conns, &db.DeviceCode{
+				DeviceCode:      "awaiting-section-device-code",
+				UserCode:        "AWAI-TSEC",
+				ClientID:        "test-client",
+				Status:          "awaiting_section",
+				OSMAuthorizedAt: &authorizedAt,
+				ExpiresAt:       time.Now().Add(5 * time.Minute),
+			}); err != nil {
+				t.Fatalf("Failed to create device code: %v", err)
+			}
+
+			body, _ := json.Marshal(DeviceTokenRequest{
+				GrantType:  "urn:ietf:params:oauth:grant-type:device_code",
+				DeviceCode: "awaiting-section-device-code",
+				ClientID:   "test-client",
+			})
+			req := httptest.NewRequest(http.MethodPost, "/device/token", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			DeviceTokenHandler(deps)(w, req)
+
+			var resp DeviceTokenErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if resp.Error != tt.wantError {
+				t.Errorf("Expected error %q, got %q", tt.wantError, resp.Error)
+			}
+
+			record, err := devicecode.FindByCode(deps.Conns, "awaiting-section-device-code")
+			if err != nil || record == nil {
+				t.Fatalf("Failed to reload device code: %v", err)
+			}
+			if record.Status != tt.wantStatus {
+				t.Errorf("Expected status %q, got %q", tt.wantStatus, record.Status)
+			}
+		})
+	}
+}
+
+func TestDeviceFlow_ProofKeyRequiredByClient(t *testing.T) {
+	deps := setupTestDeps(t, []string{"pop-client"})
+	if err := deps.Conns.DB.Model(&db.AllowedClientID{}).Where("client_id = ?", "pop-client").Update("require_proof_key", true).Error; err != nil {
+		t.Fatalf("Failed to require proof key: %v", err)
+	}
+
+	authorize := func(req DeviceAuthorizationRequest) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(req)
+		r := httptest.NewRequest(http.MethodPost, "/device/authorize", bytes.NewReader(body))
+		r = r.WithContext(middleware.ContextWithRemote(r.Context(), middleware.RemoteMetadata{IP: "192.168.1.1"}))
+		w := httptest.NewRecorder()
+		DeviceAuthorizeHandler(deps)(w, r)
+		return w
+	}
+	poll := func(deviceCode, verifier string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(DeviceTokenRequest{
+			GrantType:    "urn:ietf:params:oauth:grant-type:device_code",
+			DeviceCode:   deviceCode,
+			ClientID:     "pop-client",
+			CodeVerifier: verifier,
+		})
+		w := httptest.NewRecorder()
+		DeviceTokenHandler(deps)(w, httptest.NewRequest(http.MethodPost, "/device/token", bytes.NewReader(body)))
+		return w
+	}
+
+	if w := authorize(DeviceAuthorizationRequest{ClientID: "pop-client"}); w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected 400 without a code challenge, got %d: %s", w.Code, w.Body.String())
+	}
+
+	verifier := "a-device-held-secret-verifier-of-reasonable-length"
+	digest := sha256.Sum256([]byte(verifier))
+	w := authorize(DeviceAuthorizationRequest{
+		ClientID:            "pop-client",
+		CodeChallenge:       base64.RawURLEncoding.EncodeToString(digest[:]),
+		CodeChallengeMethod: "S256",
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 with a code challenge, got %d: %s", w.Code, w.Body.String())
+	}
+	var authResp DeviceAuthorizationResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &authResp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	// The user completes the flow in the browser
+	deviceAccessToken := "pop-device-access-token"
+	if err := devicecode.UpdateWithSection(deps.Conns, authResp.DeviceCode, "authorized", 1, deviceAccessToken); err != nil {
+		t.Fatalf("Failed to authorize device code: %v", err)
+	}
+
+	for name, v := range map[string]string{"missing verifier": "", "wrong verifier": "someone-elses-guess"} {
+		w := poll(authResp.DeviceCode, v)
+		var resp DeviceTokenErrorResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if resp.Error != "invalid_grant" {
+			t.Errorf("%s: expected invalid_grant, got %d %s", name, w.Code, w.Body.String())
+		}
+	}
+
+	w = poll(authResp.DeviceCode, verifier)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 with the matching verifier, got %d: %s", w.Code, w.Body.String())
+	}
+	var tokenResp DeviceTokenResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &tokenResp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if tokenResp.AccessToken != deviceAccessToken {
+		t.Errorf("Expected access token %q, got %q", deviceAccessToken, tokenResp.AccessToken)
+	}
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/m0rjc/OsmDeviceAdapter/internal/config"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db/allowedclient"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/metrics"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/middleware"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/services/scoreupdateservice"
+)
+
+const (
+	// maxDeviceScoreUpdates bounds a single device submission; award buttons
+	// change a handful of patrols at a time.
+	maxDeviceScoreUpdates = 20
+	// defaultDevicePointsPerUpdate is deliberately much tighter than the admin
+	// limit. A client ID may set its own cap with max_points_per_update.
+	defaultDevicePointsPerUpdate = 100
+)
+
+// PostDeviceScoresHandler handles POST /api/device/sections/scores.
+// Expects authentication middleware to have already run and added User to context.
+// Only devices authorized through a write-enabled client may submit scores, and
+// only for the section configured on the device. The request and response bodies
+// have the same shape as the admin scores endpoint.
+func PostDeviceScoresHandler(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		ctx := r.Context()
+
+		user, ok := middleware.UserFromContext(ctx)
+		if !ok {
+			slog.Error("api.device_scores.no_user_in_context",
+				"component", "api",
+				"event", "auth.error",
+				"error", "user not found in context - middleware not configured?",
+			)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		authCtx, ok := user.(interface{ DeviceCode() *db.DeviceCode })
+		if !ok {
+			slog.Error("api.device_scores.auth_context_error",
+				"component", "api",
+				"event", "auth.error",
+				"error", "user does not implement DeviceCode() method",
+			)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		device := authCtx.DeviceCode()
+
+		if !deps.Config.Features.Enabled(config.FeatureDeviceScoreWrites) {
+			writeJSONError(w, http.StatusForbidden, "read_only_device", "Score changes from devices are not enabled")
+			return
+		}
+
+		client, err := deviceWriteClient(deps, device)
+		if err != nil {
+			slog.Error("api.device_scores.client_lookup_failed",
+				"component", "api",
+				"event", "device_scores.error",
+				"device_code_hash", device.DeviceCode[:8],
+				"error", err,
+			)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if client == nil {
+			slog.Warn("api.device_scores.read_only",
+				"component", "api",
+				"event", "device_scores.forbidden",
+				"client_id", device.ClientID,
+				"device_code_hash", device.DeviceCode[:8],
+			)
+			writeJSONError(w, http.StatusForbidden, "read_only_device", "This device is not permitted to change scores")
+			return
+		}
+
+		if device.SectionID == nil || *device.SectionID <= 0 || device.OsmUserID == nil {
+			writeJSONError(w, http.StatusBadRequest, "section_not_configured", "Device has not selected an OSM section")
+			return
+		}
+		sectionID := *device.SectionID
+
+		var req AdminUpdateRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 16*1024)).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "bad_request", "Invalid request body")
+			return
+		}
+		maxPoints := defaultDevicePointsPerUpdate
+		if client.MaxPointsPerUpdate != nil {
+			maxPoints = *client.MaxPointsPerUpdate
+		}
+		if err := validateDeviceScoreUpdates(req.Updates, maxPoints); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "validation_error", err.Error())
+			return
+		}
+
+		rateLimitResult, err := deps.Conns.GetRateLimiter().CheckRateLimit(
+			ctx,
+			"device_scores",
+			device.DeviceCode,
+			int64(deps.Config.RateLimit.DeviceScoreRateLimit),
+			time.Minute,
+		)
+		if err != nil {
+			slog.Error("api.device_scores.rate_limit_error",
+				"component", "api",
+				"event", "device_scores.rate_limit_error",
+				"device_code_hash", device.DeviceCode[:8],
+				"error", err,
+			)
+		} else if !rateLimitResult.Allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(rateLimitResult.RetryAfter.Seconds())))
+			writeJSONError(w, http.StatusTooManyRequests, "rate_limited", "Too many score updates from this device. Please try again later.")
+			return
+		}
+
+		minScore := sectionMinScore(deps, *device.OsmUserID, sectionID)
+		serviceRequests := make([]scoreupdateservice.UpdateRequest, len(req.Updates))
+		for i, update := range req.Updates {
+			serviceRequests[i] = scoreupdateservice.UpdateRequest{
+				PatrolID: update.PatrolID,
+				Delta:    update.Points,
+				MinScore: minScore,
+			}
+		}
+
+		accepted := time.Now()
+		serviceResults, err := deps.ScoreUpdateService.UpdateScores(ctx, user, sectionID, serviceRequests)
+		if err != nil {
+			slog.Error("api.device_scores.service_error",
+				"component", "api",
+				"event", "device_scores.update_error",
+				"device_code_hash", device.DeviceCode[:8],
+				"section_id", sectionID,
+				"error", err,
+			)
+			writeJSONError(w, http.StatusBadGateway, "osm_error", "Failed to update scores")
+			return
+		}
+		metrics.ScoreUpdateSyncDuration.WithLabelValues("interactive").Observe(time.Since(accepted).Seconds())
+
+		results := recordScoreResults(ctx, deps, *device.OsmUserID, sectionID, nil, deviceAuditSource(device), serviceResults)
+
+		slog.Info("api.device_scores.updated",
+			"component", "api",
+			"event", "device_scores.update_success",
+			"client_id", device.ClientID,
+			"device_code_hash", device.DeviceCode[:8],
+			"section_id", sectionID,
+			"update_count", len(results),
+		)
+
+		writeJSON(w, AdminUpdateResponse{
+			Success: true,
+			Patrols: results,
+		})
+	}
+}
+
+// deviceWriteClient returns the client that authorized the device if it is flagged
+// write-capable, or nil if the device is read-only. Devices from before client
+// tracking are read-only.
+func deviceWriteClient(deps *Dependencies, device *db.DeviceCode) (*db.AllowedClientID, error) {
+	if device.CreatedByID == nil {
+		return nil, nil
+	}
+	client, err := allowedclient.FindByID(deps.Conns, *device.CreatedByID)
+	if err != nil || client == nil {
+		return nil, err
+	}
+	if !client.Enabled || !client.WriteEnabled {
+		return nil, nil
+	}
+	return client, nil
+}
+
+func validateDeviceScoreUpdates(updates []AdminScoreUpdate, maxPoints int) error {
+	if len(updates) == 0 {
+		return fmt.Errorf("no updates provided")
+	}
+	if len(updates) > maxDeviceScoreUpdates {
+		return fmt.Errorf("at most %d updates may be submitted at once", maxDeviceScoreUpdates)
+	}
+	for _, update := range updates {
+		if update.PatrolID == "" {
+			return fmt.Errorf("patrolId is required")
+		}
+		if update.Points == 0 || update.Points < -maxPoints || update.Points > maxPoints {
+			return fmt.Errorf("points must be non-zero and between -%d and %d", maxPoints, maxPoints)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/m0rjc/OsmDeviceAdapter/internal/config"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db/allowedclient"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db/devicecode"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/deviceauth"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/middleware"
+)
+
+// createTestDevice registers an authorized device for the test section, created
+// through a client with the given write permission, and returns its access token.
+func createTestDevice(t *testing.T, deps *Dependencies, clientID string, writeEnabled bool) string {
+	t.Helper()
+
+	client := &db.AllowedClientID{
+		ClientID:     clientID,
+		Comment:      "Test client",
+		ContactEmail: "test@example.com",
+		Enabled:      true,
+		WriteEnabled: writeEnabled,
+	}
+	if err := allowedclient.Create(deps.Conns, client); err != nil {
+		t.Fatalf("Failed to create allowed client: %v", err)
+	}
+
+	deviceToken := clientID + "-device-token"
+	osmToken := "osm-token"
+	userID := 12345
+	sectionID := settingsTestSectionID
+	if err := devicecode.Create(deps.Conns, &db.DeviceCode{
+		DeviceCode:        clientID + "-device-code",
+		UserCode:          clientID,
+		ClientID:          clientID,
+		CreatedByID:       &client.ID,
+		Status:            "authorized",
+		DeviceAccessToken: &deviceToken,
+		OSMAccessToken:    &osmToken,
+		OsmUserID:         &userID,
+		SectionID:         &sectionID,
+		ExpiresAt:         time.Now().Add(5 * time.Minute),
+	}); err != nil {
+		t.Fatalf("Failed to create device: %v", err)
+	}
+	return deviceToken
+}
+
+func postDeviceScores(t *testing.T, deps *Dependencies, deviceToken string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+
+	data, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, "/api/device/sections/scores", bytes.NewReader(data))
+	req.Header.Set("Authorization", "Bearer "+deviceToken)
+	w := httptest.NewRecorder()
+
+	handler := middleware.DeviceAuthMiddleware(deviceauth.NewService(deps.Conns, nil))(PostDeviceScoresHandler(deps))
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func TestPostDeviceScoresHandler_WriteEnabledDeviceCanSubmit(t *testing.T) {
+	deps := setupSettingsTestDeps(t)
+	deps.Config.RateLimit.DeviceScoreRateLimit = 10
+	deviceToken := createTestDevice(t, deps, "button-client", true)
+
+	w := postDeviceScores(t, deps, deviceToken, AdminUpdateRequest{
+		Updates: []AdminScoreUpdate{{PatrolID: "1", Points: 2}},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp AdminUpdateResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(resp.Patrols) != 1 || !resp.Patrols[0].Success || resp.Patrols[0].NewScore != 12 {
+		t.Errorf("expected patrol 1 to go from 10 to 12, got %+v", resp.Patrols)
+	}
+
+	var logs []db.ScoreAuditLog
+	deps.Conns.DB.Find(&logs)
+	if len(logs) != 1 || logs[0].OSMUserID != 12345 || logs[0].SectionID != settingsTestSectionID {
+		t.Fatalf("expected one audit entry for the device's user and section, got %+v", logs)
+	}
+	if logs[0].Source != db.ScoreSourceDevice {
+		t.Errorf("expected source %q, got %q", db.ScoreSourceDevice, logs[0].Source)
+	}
+}
+
+func TestPostDeviceScoresHandler_ReadOnlyDeviceForbidden(t *testing.T) {
+	deps := setupSettingsTestDeps(t)
+	deps.Config.RateLimit.DeviceScoreRateLimit = 10
+	deviceToken := createTestDevice(t, deps, "display-client", false)
+
+	w := postDeviceScores(t, deps, deviceToken, AdminUpdateRequest{
+		Updates: []AdminScoreUpdate{{PatrolID: "1", Points: 2}},
+	})
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var count int64
+	deps.Conns.DB.Model(&db.ScoreAuditLog{}).Count(&count)
+	if count != 0 {
+		t.Errorf("expected no score changes from a read-only device, got %d audit entries", count)
+	}
+}
+
+func TestPostDeviceScoresHandler_WriteEnabledFlagGatesWrites(t *testing.T) {
+	deps := setupSettingsTestDeps(t)
+	deps.Config.RateLimit.DeviceScoreRateLimit = 10
+	deviceToken := createTestDevice(t, deps, "toggle-client", false)
+
+	submit := func(points int) int {
+		return postDeviceScores(t, deps, deviceToken, AdminUpdateRequest{
+			Updates: []AdminScoreUpdate{{PatrolID: "1", Points: points}},
+		}).Code
+	}
+
+	if code := submit(1); code != http.StatusForbidden {
+		t.Fatalf("expected 403 before enabling writes, got %d", code)
+	}
+
+	if err := allowedclient.UpdateWriteEnabled(deps.Conns, "toggle-client", true); err != nil {
+		t.Fatalf("Failed to enable writes: %v", err)
+	}
+	if code := submit(2); code != http.StatusOK {
+		t.Fatalf("expected 200 once writes are enabled, got %d", code)
+	}
+
+	if err := allowedclient.UpdateWriteEnabled(deps.Conns, "toggle-client", false); err != nil {
+		t.Fatalf("Failed to disable writes: %v", err)
+	}
+	if code := submit(3); code != http.StatusForbidden {
+		t.Fatalf("expected 403 after disabling writes, got %d", code)
+	}
+}
+
+func TestPostDeviceScoresHandler_FeatureFlagGatesWrites(t *testing.T) {
+	deps := setupSettingsTestDeps(t)
+	deps.Config.RateLimit.DeviceScoreRateLimit = 10
+	deviceToken := createTestDevice(t, deps, "flagged-client", true)
+
+	submit := func(points int) int {
+		return postDeviceScores(t, deps, deviceToken, AdminUpdateRequest{
+			Updates: []AdminScoreUpdate{{PatrolID: "1", Points: points}},
+		}).Code
+	}
+
+	deps.Config.Features.Flags = config.FeatureFlags{config.FeatureDeviceScoreWrites: false}
+	if code := submit(1); code != http.StatusForbidden {
+		t.Fatalf("expected 403 with device score writes disabled, got %d", code)
+	}
+
+	deps.Config.Features.Flags = config.FeatureFlags{config.FeatureDeviceScoreWrites: true}
+	if code := submit(2); code != http.StatusOK {
+		t.Fatalf("expected 200 with device score writes enabled, got %d", code)
+	}
+}
+
+func TestPostDeviceScoresHandler_ClientPointsCap(t *testing.T) {
+	deps := setupSettingsTestDeps(t)
+	deps.Config.RateLimit.DeviceScoreRateLimit = 10
+	deviceToken := createTestDevice(t, deps, "capped-client", true)
+	if err := deps.Conns.DB.Model(&db.AllowedClientID{}).Where("client_id = ?", "capped-client").
+		Update("max_points_per_update", 5).Error; err != nil {
+		t.Fatalf("Failed to set points cap: %v", err)
+	}
+
+	w := postDeviceScores(t, deps, deviceToken, AdminUpdateRequest{
+		Updates: []AdminScoreUpdate{{PatrolID: "1", Points: 6}},
+	})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 above the client's cap, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestValidateDeviceScoreUpdates(t *testing.T) {
+	tests := []struct {
+		name    string
+		updates []AdminScoreUpdate
+		wantErr bool
+	}{
+		{"valid", []AdminScoreUpdate{{PatrolID: "1", Points: 5}}, false},
+		{"empty", nil, true},
+		{"missing patrol", []AdminScoreUpdate{{Points: 5}}, true},
+		{"zero points", []AdminScoreUpdate{{PatrolID: "1"}}, true},
+		{"too many points", []AdminScoreUpdate{{PatrolID: "1", Points: 101}}, true},
+		{"too many updates", make([]AdminScoreUpdate, maxDeviceScoreUpdates+1), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateDeviceScoreUpdates(tt.updates, defaultDevicePointsPerUpdate); (err != nil) != tt.wantErr {
+				t.Errorf("validateDeviceScoreUpdates() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
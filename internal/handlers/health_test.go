@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db"
+)
+
+func setupReadyTestDeps(t *testing.T) (*Dependencies, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rc, err := db.NewRedisClient("redis://"+mr.Addr(), "test:")
+	if err != nil {
+		t.Fatalf("Failed to create Redis client: %v", err)
+	}
+	t.Cleanup(func() { rc.Close() })
+	conns := db.SetupTestDB(t)
+	conns.Redis = rc
+	return &Dependencies{Conns: conns}, mr
+}
+
+func getReady(t *testing.T, deps *Dependencies) (int, ReadyResponse) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	ReadyHandler(deps)(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var resp ReadyResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return w.Code, resp
+}
+
+func TestReadyHandler(t *testing.T) {
+	t.Run("healthy", func(t *testing.T) {
+		deps, _ := setupReadyTestDeps(t)
+		code, resp := getReady(t, deps)
+		if code != http.StatusOK || resp != (ReadyResponse{Status: "ready", Database: "ok", Redis: "ok"}) {
+			t.Errorf("Expected 200 with everything ok, got %d %+v", code, resp)
+		}
+	})
+
+	t.Run("redis unreachable", func(t *testing.T) {
+		deps, mr := setupReadyTestDeps(t)
+		mr.Close()
+		code, resp := getReady(t, deps)
+		if code != http.StatusServiceUnavailable || resp.Redis != "error" || resp.Database != "ok" {
+			t.Errorf("Expected 503 naming Redis, got %d %+v", code, resp)
+		}
+	})
+
+	t.Run("database unreachable", func(t *testing.T) {
+		deps, _ := setupReadyTestDeps(t)
+		sqlDB, err := deps.Conns.DB.DB()
+		if err != nil {
+			t.Fatalf("Failed to get database handle: %v", err)
+		}
+		sqlDB.Close()
+		code, resp := getReady(t, deps)
+		if code != http.StatusServiceUnavailable || resp.Database != "error" || resp.Redis != "ok" {
+			t.Errorf("Expected 503 naming the database, got %d %+v", code, resp)
+		}
+	})
+}
+
+func TestHealthHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	HealthHandler(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected 200, got %d", w.Code)
+	}
+}
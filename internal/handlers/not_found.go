@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/m0rjc/OsmDeviceAdapter/internal/templates"
+)
+
+// ServiceInfoResponse is the response for GET / when the client asks for JSON
+type ServiceInfoResponse struct {
+	Service   string `json:"service"`
+	Status    string `json:"status"`
+	DeviceURL string `json:"device_url"` // where users enter their device code
+}
+
+// NotFoundHandler answers requests for paths with no route. API paths and
+// clients asking for JSON get a JSON error like the rest of the API; browsers
+// get an HTML page pointing them at the device code entry form.
+func NotFoundHandler(deps *Dependencies) http.HandlerFunc {
+	apiPrefix := deps.Config.Paths.APIPrefix + "/"
+	return func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, apiPrefix) || prefersJSON(r) {
+			writeJSONError(w, http.StatusNotFound, "not_found", "No such endpoint")
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusNotFound)
+		if err := templates.RenderNotFound(w, deps.Config.Paths.DevicePrefix); err != nil {
+			slog.Error("template render failed", "error", err)
+		}
+	}
+}
+
+// prefersJSON reports whether the Accept header asks for JSON rather than HTML.
+// Browsers always list text/html, so anything that does not is treated as a
+// program.
+func prefersJSON(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, "application/json") && !strings.Contains(accept, "text/html")
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	wsinternal "github.com/m0rjc/OsmDeviceAdapter/internal/websocket"
+)
+
+// maxNoticeLength keeps notices short enough to fit a scoreboard banner.
+const maxNoticeLength = 200
+
+// NoticeRequest is the request body for POST /internal/notice
+type NoticeRequest struct {
+	Text string `json:"text"`
+}
+
+// InternalNoticeHandler handles POST /internal/notice on the internal metrics server.
+// It broadcasts a service announcement to every connected scoreboard.
+func InternalNoticeHandler(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed")
+			return
+		}
+
+		var req NoticeRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4*1024)).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "bad_request", "Invalid request body")
+			return
+		}
+		req.Text = strings.TrimSpace(req.Text)
+		if req.Text == "" || len(req.Text) > maxNoticeLength {
+			writeJSONError(w, http.StatusBadRequest, "validation_error", "text must be between 1 and 200 characters")
+			return
+		}
+
+		if deps.WebSocketHub == nil {
+			writeJSONError(w, http.StatusServiceUnavailable, "unavailable", "WebSocket hub is not running")
+			return
+		}
+		deps.WebSocketHub.BroadcastToAll(wsinternal.NoticeMessage(req.Text))
+
+		slog.Info("internal.notice.broadcast",
+			"component", "internal",
+			"event", "notice.broadcast",
+			"text", req.Text,
+		)
+
+		writeJSON(w, map[string]bool{"success": true})
+	}
+}
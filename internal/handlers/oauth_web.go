@@ -1,17 +1,22 @@
 package handlers
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/m0rjc/OsmDeviceAdapter/internal/db"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db/allowedclient"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/db/devicecode"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/db/devicesession"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/middleware"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/services"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/templates"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/types"
 )
@@ -36,11 +41,27 @@ func normalizeUserCode(input string) (string, error) {
 	return fmt.Sprintf("%s-%s", cleaned[:4], cleaned[4:]), nil
 }
 
-// HomeHandler renders the home page with a welcome message and device code entry form
+// HomeHandler renders the home page with a welcome message and device code entry form.
+// It is mounted at "/" so it also receives every unrouted path, which get a 404.
+// Clients asking for JSON get a short service description instead of the page.
 func HomeHandler(deps *Dependencies) http.HandlerFunc {
+	notFound := NotFoundHandler(deps)
 	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			notFound(w, r)
+			return
+		}
+		if prefersJSON(r) {
+			writeJSON(w, ServiceInfoResponse{
+				Service:   "osm-device-adapter",
+				Status:    "ok",
+				DeviceURL: deps.Config.Paths.DevicePrefix,
+			})
+			return
+		}
+
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		if err := templates.RenderHome(w); err != nil {
+		if err := templates.RenderHome(w, deps.Config.Paths.DevicePrefix); err != nil {
 			slog.Error("template render failed", "error", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 		}
@@ -117,6 +138,11 @@ func OAuthAuthorizeHandler(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
+		if userCodeLocked(r.Context(), deps, userCode) {
+			renderUserCodeLocked(w)
+			return
+		}
+
 		// Look up the device code from user code
 		deviceCodeRecord, err := devicecode.FindByUserCode(deps.Conns, userCode)
 		if err != nil {
@@ -124,6 +150,7 @@ func OAuthAuthorizeHandler(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 		if deviceCodeRecord == nil {
+			recordUserCodeFailure(r.Context(), deps, userCode)
 			w.Header().Set("Content-Type", "text/html; charset=utf-8")
 			w.WriteHeader(http.StatusBadRequest)
 			if err := templates.RenderDeviceError(w, "This device code is invalid or has expired. Please check the code on your device and try again."); err != nil {
@@ -133,6 +160,7 @@ func OAuthAuthorizeHandler(deps *Dependencies) http.HandlerFunc {
 		}
 
 		if deviceCodeRecord.Status != "pending" {
+			recordUserCodeFailure(r.Context(), deps, userCode)
 			w.Header().Set("Content-Type", "text/html; charset=utf-8")
 			w.WriteHeader(http.StatusBadRequest)
 			if err := templates.RenderDeviceError(w, "This device code has already been used or is no longer valid. Please request a new code from your device."); err != nil {
@@ -173,6 +201,12 @@ func OAuthAuthorizeHandler(deps *Dependencies) http.HandlerFunc {
 			"user_country", remoteMetadata.Country,
 		)
 
+		// The device's stored IP is only a network when anonymizing, so show the
+		// user's own IP the same way for a like-for-like comparison
+		if deps.Config.Security.AnonymizeIPs {
+			remoteMetadata.IP = middleware.AnonymizeIP(remoteMetadata.IP)
+		}
+
 		// Show confirmation page instead of immediate OAuth redirect
 		showDeviceConfirmationPage(w, userCode, deviceCodeRecord, remoteMetadata, sessionID)
 	}
@@ -227,9 +261,17 @@ func OAuthConfirmHandler(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
+		if userCodeLocked(r.Context(), deps, userCode) {
+			renderUserCodeLocked(w)
+			return
+		}
+
 		// Lookup device code
 		deviceCodeRecord, err := devicecode.FindByUserCode(deps.Conns, userCode)
 		if err != nil || deviceCodeRecord == nil {
+			if err == nil {
+				recordUserCodeFailure(r.Context(), deps, userCode)
+			}
 			slog.Warn("device.confirmation.invalid_code",
 				"component", "oauth_web",
 				"event", "confirmation.invalid_code",
@@ -241,6 +283,7 @@ func OAuthConfirmHandler(deps *Dependencies) http.HandlerFunc {
 
 		// Verify session belongs to this device code (CSRF protection)
 		if session.DeviceCode != deviceCodeRecord.DeviceCode {
+			recordUserCodeFailure(r.Context(), deps, userCode)
 			slog.Error("device.confirmation.session_mismatch",
 				"component", "oauth_web",
 				"event", "confirmation.session_mismatch",
@@ -253,6 +296,7 @@ func OAuthConfirmHandler(deps *Dependencies) http.HandlerFunc {
 
 		// Check device code status
 		if deviceCodeRecord.Status != "pending" {
+			recordUserCodeFailure(r.Context(), deps, userCode)
 			slog.Warn("device.confirmation.already_used",
 				"component", "oauth_web",
 				"event", "confirmation.already_used",
@@ -286,6 +330,66 @@ func OAuthConfirmHandler(deps *Dependencies) http.HandlerFunc {
 	}
 }
 
+// userCodeLockWindow is how long failed attempts with a user code are counted.
+// It covers the longest a device code can live, so a locked code stays locked
+// until its device gives up on it.
+func userCodeLockWindow(deps *Dependencies) time.Duration {
+	return time.Duration(max(deps.Config.DeviceOAuth.DeviceCodeExpiry, allowedclient.MaxDeviceCodeExpirySeconds)) * time.Second
+}
+
+// userCodeLocked reports whether userCode has had too many failed attempts,
+// from any IP, to be used. Guessing is also limited per IP by the device
+// entry rate limit; this stops guesses spread across many IPs. Codes are not
+// locked when the count cannot be read.
+func userCodeLocked(ctx context.Context, deps *Dependencies, userCode string) bool {
+	if deps.Conns.Redis == nil {
+		return false
+	}
+	failures, err := deps.Conns.Redis.UserCodeFailures(ctx, userCode)
+	if err != nil {
+		slog.Error("device.entry.failure_count_error",
+			"component", "oauth_web",
+			"event", "entry.failure_count_error",
+			"error", err,
+		)
+		return false
+	}
+	return failures >= int64(deps.Config.RateLimit.UserCodeMaxFailures)
+}
+
+// recordUserCodeFailure counts a failed attempt with userCode towards locking it.
+func recordUserCodeFailure(ctx context.Context, deps *Dependencies, userCode string) {
+	if deps.Conns.Redis == nil {
+		return
+	}
+	failures, err := deps.Conns.Redis.RecordUserCodeFailure(ctx, userCode, userCodeLockWindow(deps))
+	if err != nil {
+		slog.Error("device.entry.failure_count_error",
+			"component", "oauth_web",
+			"event", "entry.failure_count_error",
+			"error", err,
+		)
+		return
+	}
+	if failures == int64(deps.Config.RateLimit.UserCodeMaxFailures) {
+		slog.Warn("device.entry.user_code_locked",
+			"component", "oauth_web",
+			"event", "entry.user_code_locked",
+			"user_code", userCode,
+			"failures", failures,
+		)
+	}
+}
+
+// renderUserCodeLocked tells the user a code can no longer be used.
+func renderUserCodeLocked(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusTooManyRequests)
+	if err := templates.RenderDeviceError(w, "This device code has been locked after too many failed attempts. Please request a new code from your device."); err != nil {
+		slog.Error("template render failed", "error", err)
+	}
+}
+
 func OAuthCancelHandler(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userCode := r.URL.Query().Get("user_code")
@@ -410,6 +514,9 @@ func OAuthCallbackHandler(deps *Dependencies) http.HandlerFunc {
 	}
 }
 
+// maxDeviceSections caps how many sections one device cycles through.
+const maxDeviceSections = 10
+
 func OAuthSelectSectionHandler(deps *Dependencies) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
@@ -432,6 +539,23 @@ func OAuthSelectSectionHandler(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
+		// Further sections for the scoreboard to cycle through, after the primary
+		sectionIDs := []int{sectionID}
+		for _, alsoStr := range r.Form["also_section_id"] {
+			alsoID, err := strconv.Atoi(alsoStr)
+			if err != nil {
+				http.Error(w, "Invalid section ID", http.StatusBadRequest)
+				return
+			}
+			if !slices.Contains(sectionIDs, alsoID) {
+				sectionIDs = append(sectionIDs, alsoID)
+			}
+		}
+		if len(sectionIDs) > maxDeviceSections {
+			http.Error(w, fmt.Sprintf("A device can show at most %d sections", maxDeviceSections), http.StatusBadRequest)
+			return
+		}
+
 		// Look up session to get device code
 		session, err := devicesession.FindByID(deps.Conns, sessionID)
 		if err != nil {
@@ -443,19 +567,107 @@ func OAuthSelectSectionHandler(deps *Dependencies) http.HandlerFunc {
 			return
 		}
 
+		// A device that gave up waiting for a section must start again
+		deviceCodeRecord, err := devicecode.FindByCode(deps.Conns, session.DeviceCode)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if deviceCodeRecord == nil {
+			http.Error(w, "Invalid or expired session", http.StatusBadRequest)
+			return
+		}
+		if deviceCodeRecord.Status == "abandoned" || (deviceCodeRecord.Status == "awaiting_section" && sectionSelectionTimedOut(deps, deviceCodeRecord, time.Now())) {
+			if deviceCodeRecord.Status != "abandoned" {
+				if err := devicecode.UpdateStatus(deps.Conns, deviceCodeRecord.DeviceCode, "abandoned"); err != nil {
+					slog.Error("device.select_section.abandon_failed",
+						"component", "oauth_web",
+						"event", "select_section.update_failed",
+						"user_code", deviceCodeRecord.UserCode,
+						"error", err,
+					)
+				}
+			}
+			http.Error(w, "Section selection timed out. Please start again on your device.", http.StatusBadRequest)
+			return
+		}
+
+		// Submitting the form again, e.g. after going back in the browser, keeps
+		// the existing authorization rather than issuing a new device token
+		if deviceCodeRecord.Status == "authorized" {
+			if deviceCodeRecord.SectionID == nil || *deviceCodeRecord.SectionID != sectionID {
+				http.Error(w, "This device has already been authorized for another section. Change its section from the admin pages.", http.StatusConflict)
+				return
+			}
+			slog.Info("device.select_section.already_authorized",
+				"component", "oauth_web",
+				"event", "select_section.repeat",
+				"user_code", deviceCodeRecord.UserCode,
+				"section_id", sectionID,
+			)
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			if err := templates.RenderAuthSuccess(w); err != nil {
+				slog.Error("template render failed", "error", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		// The form can be posted with any section ID, so check each against the
+		// sections the user holds in OSM
+		if deviceCodeRecord.OSMAccessToken == nil {
+			http.Error(w, "Invalid or expired session", http.StatusBadRequest)
+			return
+		}
+		profile, err := deps.OSM.FetchOSMProfile(types.NewUser(nil, *deviceCodeRecord.OSMAccessToken))
+		if err != nil || profile.Data == nil {
+			slog.Error("device.select_section.profile_fetch_failed",
+				"component", "oauth_web",
+				"event", "select_section.profile_error",
+				"user_code", deviceCodeRecord.UserCode,
+				"error", err,
+			)
+			http.Error(w, "Failed to fetch profile", http.StatusInternalServerError)
+			return
+		}
+		for _, id := range sectionIDs {
+			if !slices.ContainsFunc(profile.Data.Sections, func(section types.OSMSection) bool { return section.SectionID == id }) {
+				slog.Warn("device.select_section.forbidden",
+					"component", "oauth_web",
+					"event", "select_section.forbidden",
+					"user_code", deviceCodeRecord.UserCode,
+					"section_id", id,
+				)
+				http.Error(w, "You do not have access to this section", http.StatusForbidden)
+				return
+			}
+		}
+
 		// Generate device access token
-		deviceAccessToken, err := generateDeviceAccessToken()
+		deviceAccessToken, err := newDeviceAccessToken(deps, session.DeviceCode)
 		if err != nil {
 			http.Error(w, "Failed to generate device access token", http.StatusInternalServerError)
 			return
 		}
 
 		// Update device code with section ID, device access token, and mark as authorized
+		// The sections are recorded first so the device never sees a token
+		// without them
+		if err := devicecode.SetSections(deps.Conns, session.DeviceCode, sectionIDs); err != nil {
+			http.Error(w, "Failed to update device code", http.StatusInternalServerError)
+			return
+		}
 		if err := devicecode.UpdateWithSection(deps.Conns, session.DeviceCode, "authorized", sectionID, deviceAccessToken); err != nil {
 			http.Error(w, "Failed to update device code", http.StatusInternalServerError)
 			return
 		}
 
+		// Fetch the section's scores and settings now so the device's first
+		// poll is served from the cache rather than waiting on OSM
+		if deps.Config.DeviceOAuth.PrewarmOnPairing {
+			go prewarmDeviceCache(context.WithoutCancel(r.Context()), deps, session.DeviceCode)
+		}
+
 		// Show success page
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 		if err := templates.RenderAuthSuccess(w); err != nil {
@@ -465,6 +677,39 @@ func OAuthSelectSectionHandler(deps *Dependencies) http.HandlerFunc {
 	}
 }
 
+// prewarmDeviceCache loads scores for a newly paired device through the patrol
+// score service, which also discovers the term and caches the device settings.
+// Failures are only logged; the device will fetch the scores itself.
+func prewarmDeviceCache(ctx context.Context, deps *Dependencies, deviceCode string) {
+	device, err := devicecode.FindByCode(deps.Conns, deviceCode)
+	if err != nil || device == nil || device.OSMAccessToken == nil {
+		slog.Warn("device.select_section.prewarm_skipped",
+			"component", "oauth_web",
+			"event", "prewarm.skipped",
+			"device_code_hash", deviceCode[:8],
+			"error", err,
+		)
+		return
+	}
+
+	user := types.NewUser(device.OsmUserID, *device.OSMAccessToken)
+	patrolService := services.NewPatrolScoreService(deps.OSM, deps.Conns, deps.Config)
+	if _, err := patrolService.GetPatrolScores(ctx, user, device); err != nil {
+		slog.Warn("device.select_section.prewarm_failed",
+			"component", "oauth_web",
+			"event", "prewarm.error",
+			"device_code_hash", deviceCode[:8],
+			"error", err,
+		)
+		return
+	}
+	slog.Debug("device.select_section.prewarmed",
+		"component", "oauth_web",
+		"event", "prewarm.success",
+		"device_code_hash", deviceCode[:8],
+	)
+}
+
 func markDeviceCodeStatus(conns *db.Connections, sessionID, status string) {
 	session, err := devicesession.FindByID(conns, sessionID)
 	if err != nil || session == nil {
@@ -0,0 +1,364 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db/devicecode"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db/devicesession"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/middleware"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/osm"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/services"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/templates"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/types"
+)
+
+// updateGolden rewrites the golden files from the current output:
+//
+//	go test ./internal/handlers -run Golden -update
+var updateGolden = flag.Bool("update", false, "update golden HTML files in testdata/golden")
+
+// assertGolden compares rendered HTML with testdata/golden/<name>.html.
+func assertGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", "golden", name+".html")
+	if *updateGolden {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("failed to update golden file: %v", err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file (run with -update to create it): %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s does not match %s; run with -update if the change is intended\n--- got ---\n%s", name, path, got)
+	}
+}
+
+func TestGolden_DeviceConfirmationPage(t *testing.T) {
+	deviceIP := "203.0.113.7"
+	deviceCountry := "GB"
+	deviceTime := time.Date(2026, 3, 14, 18, 30, 0, 0, time.UTC)
+	deviceCode := &db.DeviceCode{
+		DeviceRequestIP:      &deviceIP,
+		DeviceRequestCountry: &deviceCountry,
+		DeviceRequestTime:    &deviceTime,
+	}
+	// A different country shows the mismatch warning
+	current := middleware.RemoteMetadata{IP: "198.51.100.2", Country: "FR"}
+
+	w := httptest.NewRecorder()
+	showDeviceConfirmationPage(w, "ABCD-EFGH", deviceCode, current, "session-<123>")
+	assertGolden(t, "device-confirm", w.Body.Bytes())
+}
+
+func TestGolden_SectionSelectionPage(t *testing.T) {
+	sections := []types.OSMSection{
+		{SectionID: 1001, SectionName: "1st Anytown Scouts", GroupName: "1st Anytown Group"},
+		{SectionID: 1002, SectionName: "Beavers & <Cubs>", GroupName: "O'Brien's Group"},
+		// View only, so the page warns that score changes will be refused
+		{SectionID: 1003, SectionName: "Explorers", GroupName: "District", Permissions: map[string]int{"member": 10}},
+	}
+
+	w := httptest.NewRecorder()
+	showSectionSelectionPage(w, "session-123", sections)
+	assertGolden(t, "section-select", w.Body.Bytes())
+}
+
+func TestGolden_AuthSuccessPage(t *testing.T) {
+	var buf bytes.Buffer
+	if err := templates.RenderAuthSuccess(&buf); err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	assertGolden(t, "auth-success", buf.Bytes())
+}
+
+func TestGolden_AuthCancelledPage(t *testing.T) {
+	var buf bytes.Buffer
+	if err := templates.RenderAuthCancelled(&buf); err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	assertGolden(t, "auth-cancelled", buf.Bytes())
+}
+
+func TestOAuthSelectSectionHandler_PrewarmsScoreCache(t *testing.T) {
+	deps := setupSettingsTestDeps(t)
+	deps.Config.DeviceOAuth.PrewarmOnPairing = true
+
+	osmToken := "osm-token"
+	userID := 12345
+	if err := devicecode.Create(deps.Conns, &db.DeviceCode{
+		DeviceCode:     "prewarm-device-code",
+		UserCode:       "PREW-ARM1",
+		ClientID:       "test-client",
+		Status:         "awaiting_section",
+		OSMAccessToken: &osmToken,
+		OsmUserID:      &userID,
+		ExpiresAt:      time.Now().Add(5 * time.Minute),
+	}); err != nil {
+		t.Fatalf("Failed to create device code: %v", err)
+	}
+	if err := devicesession.Create(deps.Conns, &db.DeviceSession{
+		SessionID:  "prewarm-session",
+		DeviceCode: "prewarm-device-code",
+		ExpiresAt:  time.Now().Add(15 * time.Minute),
+	}); err != nil {
+		t.Fatalf("Failed to create device session: %v", err)
+	}
+
+	form := url.Values{"session_id": {"prewarm-session"}, "section_id": {strconv.Itoa(settingsTestSectionID)}}
+	req := httptest.NewRequest(http.MethodPost, "/device/select-section", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	OAuthSelectSectionHandler(deps)(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Warming runs after the success page is sent
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, err := deps.Conns.Redis.Get(context.Background(), services.PatrolScoresCacheKey(settingsTestSectionID)).Result(); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the selected section's scores to be cached after pairing")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestOAuthSelectSectionHandler_RepeatSelectionKeepsDeviceToken(t *testing.T) {
+	deps := setupSettingsTestDeps(t)
+
+	deviceToken := "existing-device-token"
+	osmToken := "osm-token"
+	userID := 12345
+	sectionID := settingsTestSectionID
+	if err := devicecode.Create(deps.Conns, &db.DeviceCode{
+		DeviceCode:        "repeat-device-code",
+		UserCode:          "REPE-AT01",
+		ClientID:          "test-client",
+		Status:            "authorized",
+		DeviceAccessToken: &deviceToken,
+		OSMAccessToken:    &osmToken,
+		OsmUserID:         &userID,
+		SectionID:         &sectionID,
+		ExpiresAt:         time.Now().Add(5 * time.Minute),
+	}); err != nil {
+		t.Fatalf("Failed to create device code: %v", err)
+	}
+	if err := devicesession.Create(deps.Conns, &db.DeviceSession{
+		SessionID:  "repeat-session",
+		DeviceCode: "repeat-device-code",
+		ExpiresAt:  time.Now().Add(15 * time.Minute),
+	}); err != nil {
+		t.Fatalf("Failed to create device session: %v", err)
+	}
+
+	selectSection := func(sectionID int) *httptest.ResponseRecorder {
+		form := url.Values{"session_id": {"repeat-session"}, "section_id": {strconv.Itoa(sectionID)}}
+		req := httptest.NewRequest(http.MethodPost, "/device/select-section", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		OAuthSelectSectionHandler(deps)(w, req)
+		return w
+	}
+
+	if w := selectSection(settingsTestSectionID); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 for a repeated selection, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := selectSection(settingsTestSectionID + 1); w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for a different section, got %d: %s", w.Code, w.Body.String())
+	}
+
+	record, err := devicecode.FindByCode(deps.Conns, "repeat-device-code")
+	if err != nil || record == nil {
+		t.Fatalf("Failed to reload device code: %v", err)
+	}
+	if record.DeviceAccessToken == nil || *record.DeviceAccessToken != deviceToken {
+		t.Errorf("Expected the device token to be kept, got %v", record.DeviceAccessToken)
+	}
+	if record.Status != "authorized" || record.SectionID == nil || *record.SectionID != settingsTestSectionID {
+		t.Errorf("Expected the device to stay authorized for section %d, got status %q section %v", settingsTestSectionID, record.Status, record.SectionID)
+	}
+}
+
+func TestOAuthSelectSectionHandler_RecordsFurtherSections(t *testing.T) {
+	deps := setupAdminAPITestDeps(t, map[string]osm.PatrolData{}, 200, 300)
+	deps.Config.DeviceOAuth.PrewarmOnPairing = false
+
+	osmToken := "osm-token"
+	userID := 12345
+	if err := devicecode.Create(deps.Conns, &db.DeviceCode{
+		DeviceCode:     "multi-device-code",
+		UserCode:       "MULT-I001",
+		ClientID:       "test-client",
+		Status:         "awaiting_section",
+		OSMAccessToken: &osmToken,
+		OsmUserID:      &userID,
+		ExpiresAt:      time.Now().Add(5 * time.Minute),
+	}); err != nil {
+		t.Fatalf("Failed to create device code: %v", err)
+	}
+	if err := devicesession.Create(deps.Conns, &db.DeviceSession{
+		SessionID:  "multi-session",
+		DeviceCode: "multi-device-code",
+		ExpiresAt:  time.Now().Add(15 * time.Minute),
+	}); err != nil {
+		t.Fatalf("Failed to create device session: %v", err)
+	}
+
+	// The primary section ticked again is not listed twice
+	form := url.Values{
+		"session_id":      {"multi-session"},
+		"section_id":      {"100"},
+		"also_section_id": {"300", "100", "200"},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/device/select-section", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	OAuthSelectSectionHandler(deps)(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	record, err := devicecode.FindByCode(deps.Conns, "multi-device-code")
+	if err != nil || record == nil || record.SectionID == nil || *record.SectionID != 100 {
+		t.Fatalf("Expected section 100 as the primary, got %+v (%v)", record, err)
+	}
+	sections, err := devicecode.ListSections(deps.Conns, record)
+	if err != nil || len(sections) != 3 || sections[0] != 100 || sections[1] != 300 || sections[2] != 200 {
+		t.Errorf("Expected sections [100 300 200], got %v (%v)", sections, err)
+	}
+}
+
+func TestOAuthSelectSectionHandler_RejectsSectionsNotHeld(t *testing.T) {
+	deps := setupSettingsTestDeps(t)
+	deps.Config.DeviceOAuth.PrewarmOnPairing = false
+
+	osmToken := "osm-token"
+	userID := 12345
+	if err := devicecode.Create(deps.Conns, &db.DeviceCode{
+		DeviceCode:     "foreign-device-code",
+		UserCode:       "FORE-IGN1",
+		ClientID:       "test-client",
+		Status:         "awaiting_section",
+		OSMAccessToken: &osmToken,
+		OsmUserID:      &userID,
+		ExpiresAt:      time.Now().Add(5 * time.Minute),
+	}); err != nil {
+		t.Fatalf("Failed to create device code: %v", err)
+	}
+	if err := devicesession.Create(deps.Conns, &db.DeviceSession{
+		SessionID:  "foreign-session",
+		DeviceCode: "foreign-device-code",
+		ExpiresAt:  time.Now().Add(15 * time.Minute),
+	}); err != nil {
+		t.Fatalf("Failed to create device session: %v", err)
+	}
+
+	tooMany := make([]string, maxDeviceSections)
+	for i := range tooMany {
+		tooMany[i] = strconv.Itoa(1000 + i)
+	}
+	tests := []struct {
+		name string
+		form url.Values
+		want int
+	}{
+		{"primary not held", url.Values{"section_id": {"999"}}, http.StatusForbidden},
+		{"further section not held", url.Values{"section_id": {"100"}, "also_section_id": {"999"}}, http.StatusForbidden},
+		{"too many sections", url.Values{"section_id": {"100"}, "also_section_id": tooMany}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.form.Set("session_id", "foreign-session")
+			req := httptest.NewRequest(http.MethodPost, "/device/select-section", strings.NewReader(tt.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			OAuthSelectSectionHandler(deps)(w, req)
+			if w.Code != tt.want {
+				t.Errorf("Expected status %d, got %d: %s", tt.want, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	record, err := devicecode.FindByCode(deps.Conns, "foreign-device-code")
+	if err != nil || record == nil {
+		t.Fatalf("Failed to reload device code: %v", err)
+	}
+	if record.Status != "awaiting_section" || record.DeviceAccessToken != nil {
+		t.Errorf("Expected the device to stay unauthorized, got status %q", record.Status)
+	}
+}
+
+func TestOAuthUserCode_LocksAfterFailedConfirmations(t *testing.T) {
+	deps, mr := setupAdminTestDeps(t)
+	t.Cleanup(mr.Close)
+	deps.Config.DeviceOAuth.DeviceCodeExpiry = 600
+	deps.Config.RateLimit.DeviceEntryRateLimit = 1
+	deps.Config.RateLimit.UserCodeMaxFailures = 3
+
+	for _, code := range []*db.DeviceCode{
+		{DeviceCode: "victim-device-code", UserCode: "BCDF-GHJK", ClientID: "test-client", Status: "pending", ExpiresAt: time.Now().Add(10 * time.Minute)},
+		{DeviceCode: "attacker-device-code", UserCode: "LMNP-QRST", ClientID: "test-client", Status: "pending", ExpiresAt: time.Now().Add(10 * time.Minute)},
+	} {
+		if err := devicecode.Create(deps.Conns, code); err != nil {
+			t.Fatalf("Failed to create device code: %v", err)
+		}
+	}
+	if err := devicesession.Create(deps.Conns, &db.DeviceSession{
+		SessionID:  "attacker-session",
+		DeviceCode: "attacker-device-code",
+		ExpiresAt:  time.Now().Add(15 * time.Minute),
+	}); err != nil {
+		t.Fatalf("Failed to create device session: %v", err)
+	}
+
+	// Each attempt comes from a different IP, so only the per-code count stops them
+	for i := range deps.Config.RateLimit.UserCodeMaxFailures {
+		form := url.Values{"user_code": {"BCDF-GHJK"}, "session_id": {"attacker-session"}}
+		req := httptest.NewRequest(http.MethodPost, "/device/confirm", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = req.WithContext(middleware.ContextWithRemote(req.Context(), middleware.RemoteMetadata{IP: "203.0.113." + strconv.Itoa(i+1)}))
+		w := httptest.NewRecorder()
+		OAuthConfirmHandler(deps)(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("attempt %d: expected 400 for a mismatched session, got %d", i+1, w.Code)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/device?user_code=BCDF-GHJK", nil)
+	req = req.WithContext(middleware.ContextWithRemote(req.Context(), middleware.RemoteMetadata{IP: "198.51.100.7"}))
+	w := httptest.NewRecorder()
+	OAuthAuthorizeHandler(deps)(w, req)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 for a locked code, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "locked after too many failed attempts") {
+		t.Errorf("expected the locked message, got %s", w.Body.String())
+	}
+
+	// Other codes are unaffected
+	req = httptest.NewRequest(http.MethodGet, "/device?user_code=LMNP-QRST", nil)
+	req = req.WithContext(middleware.ContextWithRemote(req.Context(), middleware.RemoteMetadata{IP: "198.51.100.8"}))
+	w = httptest.NewRecorder()
+	OAuthAuthorizeHandler(deps)(w, req)
+	if w.Code == http.StatusTooManyRequests {
+		t.Errorf("expected another code to stay usable, got %d", w.Code)
+	}
+}
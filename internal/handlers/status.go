@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/m0rjc/OsmDeviceAdapter/internal/middleware"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/services"
+)
+
+const (
+	// osmProbeCacheKey caches the result of the last OSM reachability probe so that
+	// status page traffic cannot be turned into traffic against OSM.
+	osmProbeCacheKey = "status:osm_reachable"
+	osmProbeCacheTTL = 30 * time.Second
+	osmProbeTimeout  = 3 * time.Second
+)
+
+// StatusResponse is the public service status. It deliberately carries only
+// coarse health indicators; detailed internals live on the metrics server.
+type StatusResponse struct {
+	Status         string `json:"status"` // "ok", "degraded" or "down"
+	Database       string `json:"database"`
+	Redis          string `json:"redis"`
+	OSM            string `json:"osm"` // "ok", "blocked" or "unreachable"
+	RateLimitState string `json:"rate_limit_state"`
+}
+
+// StatusHandler serves the public status page. Requests are rate limited per IP.
+func StatusHandler(deps *Dependencies) http.HandlerFunc {
+	probeClient := &http.Client{Timeout: osmProbeTimeout}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		clientIP := middleware.RemoteFromContext(r.Context()).IP
+		rateLimitResult, err := deps.Conns.GetRateLimiter().CheckRateLimit(
+			r.Context(),
+			"status",
+			fmt.Sprintf("%s:/status", clientIP),
+			int64(deps.Config.RateLimit.StatusRateLimit),
+			time.Minute,
+		)
+		if err != nil {
+			slog.Error("status.rate_limit_error",
+				"component", "status",
+				"event", "rate_limit_error",
+				"client_ip", clientIP,
+				"error", err,
+			)
+		} else if !rateLimitResult.Allowed {
+			w.Header().Set("Retry-After", fmt.Sprintf("%d", int(rateLimitResult.RetryAfter.Seconds())))
+			http.Error(w, "Rate limit exceeded. Please try again later.", http.StatusTooManyRequests)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+		defer cancel()
+
+		response := StatusResponse{
+			Status:         "ok",
+			Database:       "ok",
+			Redis:          "ok",
+			OSM:            "ok",
+			RateLimitState: string(services.RateLimitStateNone),
+		}
+
+		sqlDB, err := deps.Conns.DB.DB()
+		if err != nil || sqlDB.PingContext(ctx) != nil {
+			response.Database = "error"
+			response.Status = "down"
+		}
+
+		redisOK := deps.Conns.Redis != nil && deps.Conns.Redis.Client().Ping(ctx).Err() == nil
+		if !redisOK {
+			response.Redis = "error"
+			response.Status = "down"
+		}
+
+		if redisOK && deps.Conns.Redis.IsOsmServiceBlocked(ctx) {
+			response.OSM = "blocked"
+			response.RateLimitState = string(services.RateLimitStateServiceBlocked)
+		} else if !osmReachable(r.Context(), deps, probeClient, redisOK) {
+			response.OSM = "unreachable"
+		}
+		if response.OSM != "ok" && response.Status == "ok" {
+			response.Status = "degraded"
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if response.Status == "down" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// osmReachable probes the OSM domain, reusing a recent result from Redis when available.
+func osmReachable(ctx context.Context, deps *Dependencies, client *http.Client, useCache bool) bool {
+	if useCache {
+		if val, err := deps.Conns.Redis.Get(ctx, osmProbeCacheKey).Result(); err == nil {
+			return val == "1"
+		}
+	}
+
+	reachable := false
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, deps.Config.ExternalDomains.OSMDomain, nil)
+	if err == nil {
+		resp, err := client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			reachable = resp.StatusCode < 500
+		} else {
+			slog.Warn("status.osm_probe_failed",
+				"component", "status",
+				"event", "osm_probe_failed",
+				"error", err,
+			)
+		}
+	}
+
+	if useCache {
+		val := "0"
+		if reachable {
+			val = "1"
+		}
+		deps.Conns.Redis.Set(ctx, osmProbeCacheKey, val, osmProbeCacheTTL)
+	}
+	return reachable
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/config"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/middleware"
+)
+
+func setupStatusTestDeps(t *testing.T, osmURL string) *Dependencies {
+	t.Helper()
+
+	conns := db.SetupTestDB(t)
+	mr := miniredis.RunT(t)
+	rc, err := db.NewRedisClient("redis://"+mr.Addr(), "test:")
+	if err != nil {
+		t.Fatalf("failed to create test redis client: %v", err)
+	}
+	conns.Redis = rc
+	conns.RateLimiter = db.NewMockRateLimiter()
+
+	return &Dependencies{
+		Config: &config.Config{
+			ExternalDomains: config.ExternalDomainsConfig{OSMDomain: osmURL},
+			RateLimit:       config.RateLimitConfig{StatusRateLimit: 30},
+		},
+		Conns: conns,
+	}
+}
+
+func getStatus(t *testing.T, deps *Dependencies) (*httptest.ResponseRecorder, StatusResponse) {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/status", nil)
+	req = req.WithContext(middleware.ContextWithRemote(req.Context(), middleware.RemoteMetadata{IP: "192.168.1.1"}))
+	w := httptest.NewRecorder()
+	StatusHandler(deps)(w, req)
+
+	var resp StatusResponse
+	if w.Code != http.StatusTooManyRequests {
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("failed to decode status response: %v", err)
+		}
+	}
+	return w, resp
+}
+
+func TestStatusHandler_AllHealthy(t *testing.T) {
+	osmServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer osmServer.Close()
+
+	w, resp := getStatus(t, setupStatusTestDeps(t, osmServer.URL))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d", w.Code)
+	}
+	if resp.Status != "ok" || resp.OSM != "ok" || resp.RateLimitState != "NONE" {
+		t.Errorf("unexpected status: %+v", resp)
+	}
+}
+
+func TestStatusHandler_OSMOutage(t *testing.T) {
+	osmServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer osmServer.Close()
+
+	w, resp := getStatus(t, setupStatusTestDeps(t, osmServer.URL))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200 for a degraded service, got %d", w.Code)
+	}
+	if resp.Status != "degraded" {
+		t.Errorf("expected status degraded, got %q", resp.Status)
+	}
+	if resp.OSM != "unreachable" {
+		t.Errorf("expected osm unreachable, got %q", resp.OSM)
+	}
+	if resp.Database != "ok" || resp.Redis != "ok" {
+		t.Errorf("expected database and redis ok, got %q / %q", resp.Database, resp.Redis)
+	}
+}
+
+func TestStatusHandler_OSMServiceBlocked(t *testing.T) {
+	osmServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("OSM should not be probed while the service is blocked")
+	}))
+	defer osmServer.Close()
+
+	deps := setupStatusTestDeps(t, osmServer.URL)
+	deps.Conns.Redis.MarkOsmServiceBlocked(t.Context())
+
+	_, resp := getStatus(t, deps)
+
+	if resp.OSM != "blocked" || resp.RateLimitState != "SERVICE_BLOCKED" {
+		t.Errorf("expected blocked OSM, got %+v", resp)
+	}
+}
+
+func TestStatusHandler_RateLimited(t *testing.T) {
+	deps := setupStatusTestDeps(t, "http://127.0.0.1:0")
+	deps.Conns.RateLimiter.(*db.MockRateLimiter).AlwaysAllow = false
+
+	w, _ := getStatus(t, deps)
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("expected 429, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("expected Retry-After header to be set")
+	}
+}
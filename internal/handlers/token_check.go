@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db/devicecode"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/deviceauth"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/osm"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/types"
+)
+
+// Outcomes of a token check.
+const (
+	// TokenValid means OSM accepted the stored access token.
+	TokenValid = "valid"
+	// TokenRefreshable means OSM rejected the stored access token but the
+	// refresh token produced one it accepts. The new tokens are stored.
+	TokenRefreshable = "refreshable"
+	// TokenRevoked means the user has withdrawn the device's access to OSM.
+	TokenRevoked = "revoked"
+	// TokenMissing means the device has not been given OSM tokens, for example
+	// because its authorization never completed.
+	TokenMissing = "missing"
+	// TokenUnknown means OSM could not be asked, so the token may still be good.
+	TokenUnknown = "unknown"
+)
+
+// TokenCheckResult reports whether one device's OSM tokens still work.
+type TokenCheckResult struct {
+	DeviceCode string `json:"deviceCode"` // first 8 characters
+	SectionID  *int   `json:"sectionId,omitempty"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+}
+
+// TokenCheckResponse is the response for GET /internal/token-check
+type TokenCheckResponse struct {
+	Devices []TokenCheckResult `json:"devices"`
+}
+
+// InternalTokenCheckHandler handles GET /internal/token-check on the internal
+// metrics server. Given ?device_code= or ?user_id= (every authorized device of
+// that OSM user) it fetches the OSM profile with each device's stored token to
+// tell support whether the token still works, refreshing it if OSM rejects it.
+func InternalTokenCheckHandler(deps *Dependencies) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeJSONError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed")
+			return
+		}
+
+		var devices []db.DeviceCode
+		query := r.URL.Query()
+		switch {
+		case query.Get("device_code") != "":
+			device, err := devicecode.FindByCode(deps.Conns, query.Get("device_code"))
+			if err != nil {
+				writeJSONError(w, http.StatusInternalServerError, "internal_error", "Failed to look up device")
+				return
+			}
+			if device == nil {
+				writeJSONError(w, http.StatusNotFound, "not_found", "Device not found")
+				return
+			}
+			devices = []db.DeviceCode{*device}
+		case query.Get("user_id") != "":
+			userID, err := strconv.Atoi(query.Get("user_id"))
+			if err != nil {
+				writeJSONError(w, http.StatusBadRequest, "bad_request", "Invalid user_id")
+				return
+			}
+			devices, err = devicecode.FindByUser(deps.Conns, userID)
+			if err != nil {
+				writeJSONError(w, http.StatusInternalServerError, "internal_error", "Failed to look up devices")
+				return
+			}
+		default:
+			writeJSONError(w, http.StatusBadRequest, "bad_request", "device_code or user_id is required")
+			return
+		}
+
+		response := TokenCheckResponse{Devices: make([]TokenCheckResult, 0, len(devices))}
+		for i := range devices {
+			result := checkDeviceToken(r.Context(), deps, &devices[i])
+			slog.Info("internal.token_check.result",
+				"component", "internal",
+				"event", "token_check.result",
+				"device_code_prefix", result.DeviceCode,
+				"status", result.Status,
+			)
+			response.Devices = append(response.Devices, result)
+		}
+		writeJSON(w, response)
+	}
+}
+
+// checkDeviceToken classifies a device's OSM tokens with a live profile fetch.
+// A rejected access token is refreshed through the device auth service, which
+// stores new tokens or marks the device revoked just as a device request would.
+func checkDeviceToken(ctx context.Context, deps *Dependencies, device *db.DeviceCode) TokenCheckResult {
+	result := TokenCheckResult{
+		DeviceCode: device.DeviceCode[:min(8, len(device.DeviceCode))],
+		SectionID:  device.SectionID,
+	}
+	if device.Status == "revoked" {
+		result.Status = TokenRevoked
+		return result
+	}
+	if device.OSMAccessToken == nil {
+		result.Status = TokenMissing
+		return result
+	}
+
+	_, err := deps.OSM.FetchOSMProfile(types.NewUser(device.OsmUserID, *device.OSMAccessToken))
+	if err == nil {
+		result.Status = TokenValid
+		return result
+	}
+	if !errors.Is(err, osm.ErrUnauthorized) {
+		result.Status = TokenUnknown
+		result.Error = err.Error()
+		return result
+	}
+
+	accessToken, err := deps.DeviceAuth.CreateRefreshFunc(device)(ctx)
+	if errors.Is(err, deviceauth.ErrTokenRevoked) {
+		result.Status = TokenRevoked
+		return result
+	}
+	if err != nil {
+		result.Status = TokenUnknown
+		result.Error = err.Error()
+		return result
+	}
+
+	if _, err := deps.OSM.FetchOSMProfile(types.NewUser(device.OsmUserID, accessToken)); err != nil {
+		result.Status = TokenUnknown
+		result.Error = err.Error()
+		return result
+	}
+	result.Status = TokenRefreshable
+	return result
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db/devicecode"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/deviceauth"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/types"
+)
+
+// tokenCheckRefresher swaps the refresh token "good-refresh" for the access
+// token "fresh-token" and treats any other refresh token as revoked.
+type tokenCheckRefresher struct{}
+
+func (tokenCheckRefresher) RefreshToken(_ context.Context, refreshToken, _ string, onSuccess func(string, string, time.Time) error, onRevoked func() error) (string, error) {
+	if refreshToken != "good-refresh" {
+		onRevoked() //nolint:errcheck
+		return "", deviceauth.ErrTokenRevoked
+	}
+	if err := onSuccess("fresh-token", "next-refresh", time.Now().Add(time.Hour)); err != nil {
+		return "", err
+	}
+	return "fresh-token", nil
+}
+
+func TestInternalTokenCheckHandler_ClassifiesTokens(t *testing.T) {
+	tests := []struct {
+		name         string
+		accessToken  string
+		refreshToken string
+		wantStatus   string
+	}{
+		{"valid", "fresh-token", "good-refresh", TokenValid},
+		{"expired but refreshable", "stale-token", "good-refresh", TokenRefreshable},
+		{"revoked", "stale-token", "withdrawn-refresh", TokenRevoked},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps, mr := setupAdminTestDeps(t)
+			t.Cleanup(mr.Close)
+			deps.DeviceAuth = deviceauth.NewService(deps.Conns, tokenCheckRefresher{})
+			// OSM accepts only the token the refresher hands out
+			useOSMHandler(t, deps, func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "Bearer fresh-token" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(types.OSMProfileResponse{Status: true, Data: &types.OSMProfileData{UserID: 12345}})
+			})
+
+			createTestDevice(t, deps, "checked", false)
+			if err := devicecode.UpdateTokensOnly(deps.Conns, "checked-device-code", tt.accessToken, tt.refreshToken, time.Now().Add(time.Hour)); err != nil {
+				t.Fatalf("Failed to set tokens: %v", err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/internal/token-check?device_code=checked-device-code", nil)
+			w := httptest.NewRecorder()
+			InternalTokenCheckHandler(deps)(w, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+			}
+
+			var resp TokenCheckResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if len(resp.Devices) != 1 || resp.Devices[0].Status != tt.wantStatus {
+				t.Fatalf("expected status %q, got %+v", tt.wantStatus, resp.Devices)
+			}
+
+			device, err := devicecode.FindByCode(deps.Conns, "checked-device-code")
+			if err != nil || device == nil {
+				t.Fatalf("Failed to reload device: %v", err)
+			}
+			if (device.Status == "revoked") != (tt.wantStatus == TokenRevoked) {
+				t.Errorf("unexpected device status %q after a %q check", device.Status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestInternalTokenCheckHandler_RequiresDeviceOrUser(t *testing.T) {
+	deps, mr := setupAdminTestDeps(t)
+	t.Cleanup(mr.Close)
+
+	w := httptest.NewRecorder()
+	InternalTokenCheckHandler(deps)(w, httptest.NewRequest(http.MethodGet, "/internal/token-check", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	InternalTokenCheckHandler(deps)(w, httptest.NewRequest(http.MethodGet, "/internal/token-check?device_code=missing", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", w.Code)
+	}
+}
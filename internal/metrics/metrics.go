@@ -81,6 +81,19 @@ var (
 		Help: "Total HTTP requests by method, route, status, auth_kind, and auth_result",
 	}, []string{"method", "route", "status", "auth_kind", "auth_result"})
 
+	// Score update metrics
+	ScoreUpdateCoalesceRatio = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "score_update_coalesce_ratio",
+		Help:    "Patrol score update entries per OSM write in a single sync; above 1 means repeated entries for a patrol were merged",
+		Buckets: []float64{1, 1.5, 2, 3, 5, 10},
+	})
+
+	ScoreUpdateSyncDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "score_update_sync_duration_seconds",
+		Help:    "Time from a score submission being accepted to OSM confirming it, by mode (interactive, background)",
+		Buckets: []float64{.1, .25, .5, 1, 2.5, 5, 10, 20, 30, 60, 120, 300},
+	}, []string{"mode"}) // mode: interactive when the client waited for the result, background after a 202
+
 	// WebSocket metrics
 	WebSocketConnectionsActive = prometheus.NewGauge(prometheus.GaugeOpts{
 		Name: "websocket_connections_active",
@@ -89,13 +102,23 @@ var (
 
 	WebSocketConnectionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: "websocket_connections_total",
-		Help: "Total number of WebSocket connections initiated, labeled by status (success, failure)",
+		Help: "Total number of WebSocket connections initiated, labeled by status (success, failure, rejected)",
 	}, []string{"status"})
 
 	WebSocketDisconnectionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: "websocket_disconnections_total",
 		Help: "Total number of WebSocket disconnections, labeled by reason (e.g., normal, error, read_error, write_error)",
 	}, []string{"reason"})
+
+	WebSocketReapedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "websocket_connections_reaped_total",
+		Help: "Total number of WebSocket connections closed by the hub for missing pongs",
+	})
+
+	WebSocketPubSubReconnectsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "websocket_pubsub_reconnects_total",
+		Help: "Total number of times the WebSocket hub re-established its Redis pub/sub subscription",
+	})
 )
 
 func init() {
@@ -112,7 +135,11 @@ func init() {
 	Registry.MustRegister(HTTPRequestsTotal)
 	Registry.MustRegister(HTTPRequestDurationClassified)
 	Registry.MustRegister(HTTPRequestsClassifiedTotal)
+	Registry.MustRegister(ScoreUpdateCoalesceRatio)
+	Registry.MustRegister(ScoreUpdateSyncDuration)
 	Registry.MustRegister(WebSocketConnectionsActive)
 	Registry.MustRegister(WebSocketConnectionsTotal)
 	Registry.MustRegister(WebSocketDisconnectionsTotal)
+	Registry.MustRegister(WebSocketReapedTotal)
+	Registry.MustRegister(WebSocketPubSubReconnectsTotal)
 }
@@ -13,6 +13,7 @@ const (
 	remoteIPKey       contextKey = "remote_ip"
 	remoteProtocolKey contextKey = "remote_protocol"
 	remoteCountryKey  contextKey = "remote_country"
+	forwardedHostKey  contextKey = "forwarded_host"
 )
 
 // RemoteMetadata holds remote request metadata (e.g., from reverse proxy headers)
@@ -20,6 +21,10 @@ type RemoteMetadata struct {
 	IP       string
 	Protocol string
 	Country  string
+	// ForwardedHost is the host the client used, as reported by a proxy. It is
+	// client-controlled unless the proxy overwrites it, so check it against an
+	// allowlist before use.
+	ForwardedHost string
 }
 
 // ContextWithUser adds a user to the context
@@ -38,15 +43,17 @@ func ContextWithRemote(ctx context.Context, metadata RemoteMetadata) context.Con
 	ctx = context.WithValue(ctx, remoteIPKey, metadata.IP)
 	ctx = context.WithValue(ctx, remoteProtocolKey, metadata.Protocol)
 	ctx = context.WithValue(ctx, remoteCountryKey, metadata.Country)
+	ctx = context.WithValue(ctx, forwardedHostKey, metadata.ForwardedHost)
 	return ctx
 }
 
 // RemoteFromContext retrieves remote metadata from the context
 func RemoteFromContext(ctx context.Context) RemoteMetadata {
 	return RemoteMetadata{
-		IP:       getStringFromContext(ctx, remoteIPKey),
-		Protocol: getStringFromContext(ctx, remoteProtocolKey),
-		Country:  getStringFromContext(ctx, remoteCountryKey),
+		IP:            getStringFromContext(ctx, remoteIPKey),
+		Protocol:      getStringFromContext(ctx, remoteProtocolKey),
+		Country:       getStringFromContext(ctx, remoteCountryKey),
+		ForwardedHost: getStringFromContext(ctx, forwardedHostKey),
 	}
 }
 
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+)
+
+// CORS headers sent to allowed origins. Devices authenticate with a bearer
+// token rather than cookies, so credentials are never allowed.
+const (
+	corsAllowMethods  = "GET, POST, OPTIONS"
+	corsAllowHeaders  = "Authorization, Content-Type"
+	corsExposeHeaders = "Retry-After, X-Cache"
+	corsMaxAge        = "600"
+)
+
+// CORSMiddleware lets browser-based scoreboards served from allowedOrigins
+// call the device-facing API cross-origin. Requests from other origins get no
+// CORS headers, so the browser blocks them; with no origins only same-origin
+// pages can call. Preflight requests are answered here, before authentication,
+// because browsers send them without the Authorization header.
+func CORSMiddleware(allowedOrigins []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Responses differ by origin, so caches must not share them
+			w.Header().Add("Vary", "Origin")
+			allowed := slices.Contains(allowedOrigins, origin)
+
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			if preflight {
+				w.Header().Add("Vary", "Access-Control-Request-Method")
+				w.Header().Add("Vary", "Access-Control-Request-Headers")
+				if !allowed {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Set("Access-Control-Allow-Methods", corsAllowMethods)
+				w.Header().Set("Access-Control-Allow-Headers", corsAllowHeaders)
+				w.Header().Set("Access-Control-Max-Age", corsMaxAge)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			if allowed {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Set("Access-Control-Expose-Headers", corsExposeHeaders)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
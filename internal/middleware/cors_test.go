@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testScoreboardOrigin = "https://scoreboard.example.com"
+
+// corsTestHandler wraps a handler that records whether it was reached.
+func corsTestHandler(reached *bool) http.Handler {
+	return CORSMiddleware([]string{testScoreboardOrigin})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*reached = true
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+func TestCORSMiddleware_AllowedOrigin(t *testing.T) {
+	var reached bool
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/patrols", nil)
+	req.Header.Set("Origin", testScoreboardOrigin)
+	rec := httptest.NewRecorder()
+	corsTestHandler(&reached).ServeHTTP(rec, req)
+
+	if !reached || rec.Code != http.StatusOK {
+		t.Fatalf("expected the request to reach the handler, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != testScoreboardOrigin {
+		t.Errorf("expected Access-Control-Allow-Origin %q, got %q", testScoreboardOrigin, got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("expected no Access-Control-Allow-Credentials, got %q", got)
+	}
+	if got := rec.Header().Get("Vary"); got != "Origin" {
+		t.Errorf("expected Vary: Origin, got %q", got)
+	}
+}
+
+func TestCORSMiddleware_DisallowedOrigin(t *testing.T) {
+	var reached bool
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/patrols", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	rec := httptest.NewRecorder()
+	corsTestHandler(&reached).ServeHTTP(rec, req)
+
+	// The request is served, but without CORS headers the browser hides the response
+	if !reached {
+		t.Fatal("expected the request to reach the handler")
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("expected no Access-Control-Allow-Origin, got %q", got)
+	}
+
+	preflight := httptest.NewRequest(http.MethodOptions, "/api/v1/patrols", nil)
+	preflight.Header.Set("Origin", "https://evil.example.com")
+	preflight.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	rec = httptest.NewRecorder()
+	reached = false
+	corsTestHandler(&reached).ServeHTTP(rec, preflight)
+	if reached || rec.Code != http.StatusForbidden {
+		t.Errorf("expected the preflight to be refused with 403, got %d (reached handler: %t)", rec.Code, reached)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("expected no Access-Control-Allow-Origin on a refused preflight, got %q", got)
+	}
+}
+
+func TestCORSMiddleware_Preflight(t *testing.T) {
+	var reached bool
+	req := httptest.NewRequest(http.MethodOptions, "/api/v1/patrols", nil)
+	req.Header.Set("Origin", testScoreboardOrigin)
+	req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	req.Header.Set("Access-Control-Request-Headers", "authorization")
+	rec := httptest.NewRecorder()
+	corsTestHandler(&reached).ServeHTTP(rec, req)
+
+	// Preflights carry no Authorization header, so they must not reach device auth
+	if reached {
+		t.Error("expected the preflight to be answered by the middleware")
+	}
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != testScoreboardOrigin {
+		t.Errorf("expected Access-Control-Allow-Origin %q, got %q", testScoreboardOrigin, got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Headers"); !strings.Contains(got, "Authorization") {
+		t.Errorf("expected Access-Control-Allow-Headers to include Authorization, got %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Methods"); !strings.Contains(got, http.MethodGet) {
+		t.Errorf("expected Access-Control-Allow-Methods to include GET, got %q", got)
+	}
+}
+
+func TestCORSMiddleware_NoOriginsMeansSameOriginOnly(t *testing.T) {
+	handler := CORSMiddleware(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/patrols", nil)
+	req.Header.Set("Origin", testScoreboardOrigin)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("expected no Access-Control-Allow-Origin without configured origins, got %q", got)
+	}
+}
@@ -2,9 +2,13 @@ package middleware
 
 import (
 	"encoding/json"
+	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/netip"
 	"net/url"
+	"strings"
 )
 
 // cfVisitor represents the JSON structure in CF-Visitor header
@@ -12,11 +16,20 @@ type cfVisitor struct {
 	Scheme string `json:"scheme"`
 }
 
+// CountryResolver looks up the country of a client IP address, for deployments
+// whose proxy does not send CF-IPCountry.
+type CountryResolver interface {
+	// Country returns the ISO 3166-1 alpha-2 code for ip, or "" if unknown.
+	Country(ip string) string
+}
+
 // RemoteMetadataMiddleware captures reverse proxy headers (Cloudflare Tunnel)
 // and adds them to the request context. Works for all routes.
 // It also enforces HTTPS by redirecting HTTP requests to the canonical HTTPS URL
-// and sets HSTS headers on HTTPS responses.
-func RemoteMetadataMiddleware(exposedDomain string) func(http.Handler) http.Handler {
+// and sets HSTS headers on HTTPS responses. A zero hstsMaxAge disables the HSTS header.
+// The country comes from CF-IPCountry, or from countries when that header is
+// absent; countries may be nil.
+func RemoteMetadataMiddleware(exposedDomain string, hstsMaxAge int, countries CountryResolver) func(http.Handler) http.Handler {
 	// Parse the exposed domain once at initialization for safety and efficiency
 	exposedURL, err := url.Parse(exposedDomain)
 	if err != nil {
@@ -30,10 +43,11 @@ func RemoteMetadataMiddleware(exposedDomain string) func(http.Handler) http.Hand
 		return func(next http.Handler) http.Handler {
 			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				metadata := RemoteMetadata{
-					IP:       extractRemoteIP(r),
-					Protocol: extractProtocol(r),
-					Country:  r.Header.Get("CF-IPCountry"),
+					IP:            extractRemoteIP(r),
+					Protocol:      extractProtocol(r),
+					ForwardedHost: extractForwardedHost(r),
 				}
+				metadata.Country = extractCountry(r, metadata.IP, countries)
 				ctx := ContextWithRemote(r.Context(), metadata)
 				next.ServeHTTP(w, r.WithContext(ctx))
 			})
@@ -41,14 +55,16 @@ func RemoteMetadataMiddleware(exposedDomain string) func(http.Handler) http.Hand
 	}
 
 	canonicalHost := exposedURL.Host
+	hstsHeader := buildHSTSHeader(hstsMaxAge)
 
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			metadata := RemoteMetadata{
-				IP:       extractRemoteIP(r),
-				Protocol: extractProtocol(r),
-				Country:  r.Header.Get("CF-IPCountry"),
+				IP:            extractRemoteIP(r),
+				Protocol:      extractProtocol(r),
+				ForwardedHost: extractForwardedHost(r),
 			}
+			metadata.Country = extractCountry(r, metadata.IP, countries)
 
 			// Add metadata to context
 			ctx := ContextWithRemote(r.Context(), metadata)
@@ -84,9 +100,9 @@ func RemoteMetadataMiddleware(exposedDomain string) func(http.Handler) http.Hand
 
 				http.Redirect(w, r, redirectURL.String(), http.StatusMovedPermanently)
 				return
-			} else if metadata.Protocol == "https" {
+			} else if metadata.Protocol == "https" && hstsHeader != "" {
 				// Set HSTS header for HTTPS requests
-				w.Header().Set("Strict-Transport-Security", "max-age=31536000; includeSubDomains; preload")
+				w.Header().Set("Strict-Transport-Security", hstsHeader)
 			}
 
 			// Log the remote metadata for debugging
@@ -104,6 +120,19 @@ func RemoteMetadataMiddleware(exposedDomain string) func(http.Handler) http.Hand
 	}
 }
 
+// buildHSTSHeader builds the Strict-Transport-Security header value.
+// The preload directive is only added when max-age meets the preload list minimum of one year.
+func buildHSTSHeader(maxAge int) string {
+	if maxAge <= 0 {
+		return ""
+	}
+	header := fmt.Sprintf("max-age=%d; includeSubDomains", maxAge)
+	if maxAge >= 31536000 {
+		header += "; preload"
+	}
+	return header
+}
+
 // extractRemoteIP extracts the client IP from Cloudflare headers or falls back to RemoteAddr
 func extractRemoteIP(r *http.Request) string {
 	// Try Cloudflare Connecting IP header first
@@ -145,3 +174,66 @@ func extractProtocol(r *http.Request) string {
 	// This prevents redirect loops when running without a reverse proxy
 	return ""
 }
+
+// extractCountry returns the Cloudflare CF-IPCountry header, falling back to
+// looking up the client IP when a resolver is configured.
+func extractCountry(r *http.Request, remoteIP string, countries CountryResolver) string {
+	if country := r.Header.Get("CF-IPCountry"); country != "" || countries == nil {
+		return country
+	}
+
+	return countries.Country(clientIP(remoteIP))
+}
+
+// clientIP reduces RemoteMetadata.IP to a bare address: X-Forwarded-For may
+// list several hops and RemoteAddr carries a port.
+func clientIP(remoteIP string) string {
+	ip, _, _ := strings.Cut(remoteIP, ",")
+	ip = strings.TrimSpace(ip)
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	return ip
+}
+
+// AnonymizeIP reduces a RemoteMetadata.IP to its network by zeroing the last
+// octet of an IPv4 address or the last 80 bits of an IPv6 address. A value
+// that is not an IP address yields "" so that it is never stored in full.
+func AnonymizeIP(remoteIP string) string {
+	addr, err := netip.ParseAddr(clientIP(remoteIP))
+	if err != nil {
+		return ""
+	}
+	addr = addr.Unmap()
+	bits := 48
+	if addr.Is4() {
+		bits = 24
+	}
+	prefix, err := addr.WithZone("").Prefix(bits)
+	if err != nil {
+		return ""
+	}
+	return prefix.Addr().String()
+}
+
+// extractForwardedHost returns the original host from X-Forwarded-Host, or from
+// the host parameter of an RFC 7239 Forwarded header. Only the first hop is
+// used, as that is the host the client asked for.
+func extractForwardedHost(r *http.Request) string {
+	if host := r.Header.Get("X-Forwarded-Host"); host != "" {
+		first, _, _ := strings.Cut(host, ",")
+		return strings.TrimSpace(first)
+	}
+
+	if forwarded := r.Header.Get("Forwarded"); forwarded != "" {
+		first, _, _ := strings.Cut(forwarded, ",")
+		for _, pair := range strings.Split(first, ";") {
+			name, value, found := strings.Cut(strings.TrimSpace(pair), "=")
+			if found && strings.EqualFold(name, "host") {
+				return strings.Trim(value, `"`)
+			}
+		}
+	}
+
+	return ""
+}
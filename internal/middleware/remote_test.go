@@ -15,7 +15,7 @@ func TestRemoteMetadataMiddleware_CloudflareHeaders(t *testing.T) {
 	})
 
 	// Wrap with middleware
-	handler := RemoteMetadataMiddleware("https://example.com")(testHandler)
+	handler := RemoteMetadataMiddleware("https://example.com", 31536000, nil)(testHandler)
 
 	// Create request with Cloudflare headers
 	req := httptest.NewRequest("GET", "/test", nil)
@@ -47,7 +47,7 @@ func TestRemoteMetadataMiddleware_XForwardedHeaders(t *testing.T) {
 		w.WriteHeader(http.StatusOK)
 	})
 
-	handler := RemoteMetadataMiddleware("https://example.com")(testHandler)
+	handler := RemoteMetadataMiddleware("https://example.com", 31536000, nil)(testHandler)
 
 	// Create request with X-Forwarded headers (no Cloudflare headers)
 	req := httptest.NewRequest("GET", "/test", nil)
@@ -74,7 +74,7 @@ func TestRemoteMetadataMiddleware_CloudflarePriority(t *testing.T) {
 		w.WriteHeader(http.StatusOK)
 	})
 
-	handler := RemoteMetadataMiddleware("https://example.com")(testHandler)
+	handler := RemoteMetadataMiddleware("https://example.com", 31536000, nil)(testHandler)
 
 	req := httptest.NewRequest("GET", "/test", nil)
 	req.Header.Set("CF-Connecting-IP", "203.0.113.5")
@@ -102,7 +102,7 @@ func TestRemoteMetadataMiddleware_InvalidCFVisitor(t *testing.T) {
 		w.WriteHeader(http.StatusOK)
 	})
 
-	handler := RemoteMetadataMiddleware("https://example.com")(testHandler)
+	handler := RemoteMetadataMiddleware("https://example.com", 31536000, nil)(testHandler)
 
 	req := httptest.NewRequest("GET", "/test", nil)
 	req.Header.Set("CF-Visitor", `invalid json`)
@@ -124,7 +124,7 @@ func TestRemoteMetadataMiddleware_NoHeaders(t *testing.T) {
 		w.WriteHeader(http.StatusOK)
 	})
 
-	handler := RemoteMetadataMiddleware("https://example.com")(testHandler)
+	handler := RemoteMetadataMiddleware("https://example.com", 31536000, nil)(testHandler)
 
 	req := httptest.NewRequest("GET", "/test", nil)
 	req.RemoteAddr = "192.0.2.1:54321"
@@ -151,7 +151,7 @@ func TestRemoteMetadataMiddleware_TLS(t *testing.T) {
 		w.WriteHeader(http.StatusOK)
 	})
 
-	handler := RemoteMetadataMiddleware("https://example.com")(testHandler)
+	handler := RemoteMetadataMiddleware("https://example.com", 31536000, nil)(testHandler)
 
 	req := httptest.NewRequest("GET", "https://example.com/test", nil)
 	// httptest.NewRequest with https:// scheme automatically sets TLS
@@ -170,7 +170,7 @@ func TestRemoteMetadataMiddleware_HTTPSRedirect(t *testing.T) {
 		t.Error("Handler should not be called for HTTP redirect")
 	})
 
-	handler := RemoteMetadataMiddleware("https://canonical.example.com")(testHandler)
+	handler := RemoteMetadataMiddleware("https://canonical.example.com", 31536000, nil)(testHandler)
 
 	req := httptest.NewRequest("GET", "/api/v1/patrols?section=123", nil)
 	req.Header.Set("X-Forwarded-Proto", "http")
@@ -197,7 +197,7 @@ func TestRemoteMetadataMiddleware_HSTSHeader(t *testing.T) {
 		w.WriteHeader(http.StatusOK)
 	})
 
-	handler := RemoteMetadataMiddleware("https://example.com")(testHandler)
+	handler := RemoteMetadataMiddleware("https://example.com", 31536000, nil)(testHandler)
 
 	req := httptest.NewRequest("GET", "/test", nil)
 	req.Header.Set("X-Forwarded-Proto", "https")
@@ -213,6 +213,36 @@ func TestRemoteMetadataMiddleware_HSTSHeader(t *testing.T) {
 	}
 }
 
+func TestRemoteMetadataMiddleware_HSTSConfigurable(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxAge   int
+		expected string
+	}{
+		{"disabled", 0, ""},
+		{"short max-age omits preload", 86400, "max-age=86400; includeSubDomains"},
+		{"two years", 63072000, "max-age=63072000; includeSubDomains; preload"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+			handler := RemoteMetadataMiddleware("https://example.com", tt.maxAge, nil)(testHandler)
+
+			req := httptest.NewRequest("GET", "/test", nil)
+			req.Header.Set("X-Forwarded-Proto", "https")
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if hsts := w.Header().Get("Strict-Transport-Security"); hsts != tt.expected {
+				t.Errorf("Expected HSTS header '%s', got '%s'", tt.expected, hsts)
+			}
+		})
+	}
+}
+
 func TestRemoteMetadataMiddleware_NoHSTSOnHTTP(t *testing.T) {
 	// Should NOT set HSTS header for HTTP requests (after redirect)
 	// This test uses a fallback middleware that doesn't redirect (invalid domain)
@@ -221,7 +251,7 @@ func TestRemoteMetadataMiddleware_NoHSTSOnHTTP(t *testing.T) {
 	})
 
 	// Use invalid domain to get fallback middleware without redirect
-	handler := RemoteMetadataMiddleware("://invalid")(testHandler)
+	handler := RemoteMetadataMiddleware("://invalid", 31536000, nil)(testHandler)
 
 	req := httptest.NewRequest("GET", "/test", nil)
 	req.Header.Set("X-Forwarded-Proto", "http")
@@ -242,7 +272,7 @@ func TestRemoteMetadataMiddleware_RedirectPreservesQueryAndFragment(t *testing.T
 		t.Error("Handler should not be called for HTTP redirect")
 	})
 
-	handler := RemoteMetadataMiddleware("https://example.com:8443")(testHandler)
+	handler := RemoteMetadataMiddleware("https://example.com:8443", 31536000, nil)(testHandler)
 
 	req := httptest.NewRequest("GET", "/path/to/resource?foo=bar&baz=qux", nil)
 	req.Header.Set("X-Forwarded-Proto", "http")
@@ -268,7 +298,7 @@ func TestRemoteMetadataMiddleware_UnknownProtocol(t *testing.T) {
 		w.WriteHeader(http.StatusOK)
 	})
 
-	handler := RemoteMetadataMiddleware("https://example.com")(testHandler)
+	handler := RemoteMetadataMiddleware("https://example.com", 31536000, nil)(testHandler)
 
 	// Request with no protocol headers and no TLS
 	req := httptest.NewRequest("GET", "/test", nil)
@@ -293,3 +323,105 @@ func TestRemoteMetadataMiddleware_UnknownProtocol(t *testing.T) {
 		t.Errorf("Expected empty protocol, got '%s'", capturedMetadata.Protocol)
 	}
 }
+
+func TestExtractForwardedHost(t *testing.T) {
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		expected string
+	}{
+		{"X-Forwarded-Host", map[string]string{"X-Forwarded-Host": "scores.example.org"}, "scores.example.org"},
+		{"first X-Forwarded-Host hop", map[string]string{"X-Forwarded-Host": "scores.example.org, internal.local"}, "scores.example.org"},
+		{"Forwarded host", map[string]string{"Forwarded": `for=192.0.2.60;proto=https;host="scores.example.org:8443"`}, "scores.example.org:8443"},
+		{"X-Forwarded-Host wins", map[string]string{"X-Forwarded-Host": "a.example.org", "Forwarded": "host=b.example.org"}, "a.example.org"},
+		{"none", map[string]string{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/test", nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			if got := extractForwardedHost(req); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+type fakeCountries map[string]string
+
+func (f fakeCountries) Country(ip string) string { return f[ip] }
+
+func TestRemoteMetadataMiddleware_CountryResolver(t *testing.T) {
+	countries := fakeCountries{"81.2.69.160": "GB"}
+
+	tests := []struct {
+		name      string
+		countries CountryResolver
+		headers   map[string]string
+		expected  string
+	}{
+		{"resolves the client IP", countries, map[string]string{"X-Forwarded-For": "81.2.69.160, 10.0.0.1"}, "GB"},
+		{"header preferred over resolver", countries, map[string]string{"X-Forwarded-For": "81.2.69.160", "CF-IPCountry": "FR"}, "FR"},
+		{"unknown IP", countries, map[string]string{"X-Forwarded-For": "192.0.2.1"}, ""},
+		{"header without resolver", nil, map[string]string{"X-Forwarded-For": "81.2.69.160", "CF-IPCountry": "FR"}, "FR"},
+		{"no header or resolver", nil, map[string]string{"X-Forwarded-For": "81.2.69.160"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var capturedMetadata RemoteMetadata
+			testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				capturedMetadata = RemoteFromContext(r.Context())
+			})
+			handler := RemoteMetadataMiddleware("https://example.com", 0, tt.countries)(testHandler)
+
+			req := httptest.NewRequest("GET", "/test", nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if capturedMetadata.Country != tt.expected {
+				t.Errorf("Expected country %q, got %q", tt.expected, capturedMetadata.Country)
+			}
+		})
+	}
+}
+
+func TestRemoteMetadataMiddleware_CountryResolverUsesRemoteAddr(t *testing.T) {
+	var capturedMetadata RemoteMetadata
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		capturedMetadata = RemoteFromContext(r.Context())
+	})
+	handler := RemoteMetadataMiddleware("https://example.com", 0, fakeCountries{"81.2.69.160": "GB"})(testHandler)
+
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.RemoteAddr = "81.2.69.160:54321"
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if capturedMetadata.Country != "GB" {
+		t.Errorf("Expected country GB from RemoteAddr, got %q", capturedMetadata.Country)
+	}
+}
+
+func TestAnonymizeIP(t *testing.T) {
+	tests := []struct {
+		ip       string
+		expected string
+	}{
+		{"203.0.113.77", "203.0.113.0"},
+		{"203.0.113.77:54321", "203.0.113.0"},
+		{"203.0.113.77, 10.0.0.1", "203.0.113.0"},
+		{"::ffff:203.0.113.77", "203.0.113.0"},
+		{"2001:db8:1234:5678:9abc::1", "2001:db8:1234::"},
+		{"[2001:db8:1234:5678::1]:443", "2001:db8:1234::"},
+		{"not-an-ip", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := AnonymizeIP(tt.ip); got != tt.expected {
+			t.Errorf("AnonymizeIP(%q) = %q, expected %q", tt.ip, got, tt.expected)
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package middleware
 
 import (
+	"crypto/rand"
+	"encoding/base64"
+	"log/slog"
 	"net/http"
 	"strings"
 )
@@ -9,14 +12,7 @@ import (
 // It should be applied to admin routes and API endpoints.
 func SecurityHeadersMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Prevent MIME type sniffing
-		w.Header().Set("X-Content-Type-Options", "nosniff")
-
-		// Prevent clickjacking by disallowing embedding in frames
-		w.Header().Set("X-Frame-Options", "DENY")
-
-		// Control what information is sent in the Referer header
-		w.Header().Set("Referrer-Policy", "strict-origin-when-cross-origin")
+		setBaseSecurityHeaders(w)
 
 		// Content Security Policy
 		// - script-src 'self': Only scripts from our domain
@@ -46,10 +42,80 @@ func SecurityHeadersMiddleware(next http.Handler) http.Handler {
 		}, "; ")
 		w.Header().Set("Content-Security-Policy", csp)
 
-		// Permissions Policy (formerly Feature-Policy)
-		// Restrict access to sensitive browser features
-		w.Header().Set("Permissions-Policy", "geolocation=(), microphone=(), camera=()")
-
 		next.ServeHTTP(w, r)
 	})
 }
+
+// PageSecurityHeadersMiddleware adds security headers to the server-rendered
+// HTML pages (home page and device authorization flow).
+// Scripts are only allowed with a per-request nonce, which is exposed to the
+// templates through the wrapped ResponseWriter's CSPNonce method. Inline event
+// handler attributes (onclick etc.) are therefore blocked. Styles remain inline.
+// form-action is deliberately not restricted because the device flow redirects
+// form submissions to OSM.
+func PageSecurityHeadersMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		setBaseSecurityHeaders(w)
+
+		nonce, err := generateNonce()
+		if err != nil {
+			slog.Error("middleware.security.nonce_failed",
+				"component", "middleware",
+				"error", err,
+			)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		csp := strings.Join([]string{
+			"default-src 'self'",
+			"script-src 'nonce-" + nonce + "'",
+			"style-src 'self' 'unsafe-inline'",
+			"img-src 'self' data:",
+			"object-src 'none'",
+			"base-uri 'self'",
+			"frame-ancestors 'none'",
+		}, "; ")
+		w.Header().Set("Content-Security-Policy", csp)
+
+		next.ServeHTTP(&nonceWriter{ResponseWriter: w, nonce: nonce}, r)
+	})
+}
+
+// nonceWriter carries the request's CSP nonce to the template renderer
+type nonceWriter struct {
+	http.ResponseWriter
+	nonce string
+}
+
+// CSPNonce returns the nonce permitted by this response's Content-Security-Policy
+func (w *nonceWriter) CSPNonce() string {
+	return w.nonce
+}
+
+// generateNonce returns a random base64 value for use as a CSP nonce
+func generateNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	// URL-safe alphabet: html/template escapes '+' in attributes, which would
+	// make the rendered nonce differ textually from the header.
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// setBaseSecurityHeaders sets the headers shared by all browser-facing responses
+func setBaseSecurityHeaders(w http.ResponseWriter) {
+	// Prevent MIME type sniffing
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	// Prevent clickjacking by disallowing embedding in frames
+	w.Header().Set("X-Frame-Options", "DENY")
+
+	// Control what information is sent in the Referer header
+	w.Header().Set("Referrer-Policy", "strict-origin-when-cross-origin")
+
+	// Permissions Policy (formerly Feature-Policy)
+	// Restrict access to sensitive browser features
+	w.Header().Set("Permissions-Policy", "geolocation=(), microphone=(), camera=()")
+}
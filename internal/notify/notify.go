@@ -0,0 +1,89 @@
+// Package notify tells a deployment's operator about device events that
+// happen outside any request they can see, such as OSM revoking a device's
+// access while it sits polling.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// webhookTimeout bounds each webhook call. Notifications are best effort, so a
+// slow receiver is abandoned rather than retried.
+const webhookTimeout = 5 * time.Second
+
+// DeviceRevoked describes a device whose OSM access was revoked.
+type DeviceRevoked struct {
+	// DeviceCode is the device code prefix used to identify devices in logs,
+	// not the full code.
+	DeviceCode string    `json:"deviceCode"`
+	OSMUserID  *int      `json:"osmUserID,omitempty"`
+	SectionID  *int      `json:"sectionId,omitempty"`
+	RevokedAt  time.Time `json:"revokedAt"`
+}
+
+// Notifier receives device events. Implementations must return promptly and
+// must not fail the caller; delivery is their own concern.
+type Notifier interface {
+	DeviceRevoked(event DeviceRevoked)
+}
+
+// Webhook posts events as JSON to a URL in the background.
+type Webhook struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhook creates a notifier that posts events to url.
+func NewWebhook(url string) *Webhook {
+	return &Webhook{
+		url:    url,
+		client: &http.Client{Timeout: webhookTimeout},
+	}
+}
+
+// DeviceRevoked posts the event without waiting for the receiver.
+func (w *Webhook) DeviceRevoked(event DeviceRevoked) {
+	go func() {
+		if err := w.post(event); err != nil {
+			slog.Warn("notify.webhook.failed",
+				"component", "notify",
+				"event", "webhook.error",
+				"device_code_hash", event.DeviceCode,
+				"error", err,
+			)
+		}
+	}()
+}
+
+func (w *Webhook) post(payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
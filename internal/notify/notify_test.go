@@ -0,0 +1,42 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWebhook_PostsDeviceRevoked(t *testing.T) {
+	received := make(chan map[string]any, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request %s with content type %q", r.Method, r.Header.Get("Content-Type"))
+		}
+		var payload map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("Failed to decode payload: %v", err)
+		}
+		received <- payload
+	}))
+	defer server.Close()
+
+	userID, sectionID := 7, 42
+	NewWebhook(server.URL).DeviceRevoked(DeviceRevoked{
+		DeviceCode: "abcd1234",
+		OSMUserID:  &userID,
+		SectionID:  &sectionID,
+		RevokedAt:  time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	})
+
+	select {
+	case payload := <-received:
+		if payload["deviceCode"] != "abcd1234" || payload["osmUserID"] != float64(7) ||
+			payload["sectionId"] != float64(42) || payload["revokedAt"] != "2026-01-02T03:04:05Z" {
+			t.Errorf("unexpected payload %v", payload)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook was not called")
+	}
+}
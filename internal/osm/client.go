@@ -10,6 +10,9 @@ type Client struct {
 	httpClient *http.Client
 	rlStore    RateLimitStore
 	recorder   LatencyRecorder
+	// inlineRetryMaxWait is the longest Retry-After waited out before retrying
+	// patrol score requests once; zero disables the retry
+	inlineRetryMaxWait time.Duration
 }
 
 func NewClient(baseURL string, rlStore RateLimitStore, recorder LatencyRecorder) *Client {
@@ -27,3 +30,17 @@ func NewClient(baseURL string, rlStore RateLimitStore, recorder LatencyRecorder)
 func (c *Client) OSMDomain() string {
 	return c.baseURL
 }
+
+// SetInlineRetry makes patrol score fetches and updates that OSM answers with
+// 429 wait for Retry-After and try once more, provided the wait is no longer
+// than maxWait. Longer waits return the rate limit error straight away so the
+// caller can fall back to its own retry handling. Zero turns this off.
+func (c *Client) SetInlineRetry(maxWait time.Duration) {
+	c.inlineRetryMaxWait = maxWait
+}
+
+// SetTransport replaces the transport used for OSM requests. Demo mode uses it to
+// answer requests in-process.
+func (c *Client) SetTransport(transport http.RoundTripper) {
+	c.httpClient.Transport = transport
+}
@@ -0,0 +1,271 @@
+// Package demo provides an in-process stand-in for OSM, used when the adapter
+// runs with DEMO_MODE. It serves the same sections and patrols as
+// cmd/mock-osm-server, accepts any credentials and keeps score changes in memory.
+package demo
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/m0rjc/OsmDeviceAdapter/internal/osm"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/types"
+)
+
+// PathPrefix is where the demo OSM is mounted on the adapter. In demo mode
+// OSM_DOMAIN must point here so browser redirects for login reach it.
+const PathPrefix = "/demo-osm"
+
+const (
+	demoUserID    = 12345
+	demoUserName  = "Demo User"
+	demoUserEmail = "demo@example.com"
+	tokenExpiry   = 3600
+)
+
+type section struct {
+	profile types.OSMSection
+	patrols map[string]osm.PatrolData
+}
+
+// Server is an in-memory fake of the OSM endpoints the adapter uses.
+type Server struct {
+	mu       sync.Mutex
+	sections []section
+	handler  http.Handler
+}
+
+// NewServer creates a demo OSM with freshly seeded data.
+func NewServer() *Server {
+	s := &Server{sections: demoSections()}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth/authorize", s.handleAuthorize)
+	mux.HandleFunc("/oauth/token", s.handleToken)
+	mux.HandleFunc("/oauth/resource", s.handleResource)
+	mux.HandleFunc("/ext/members/patrols/", s.handlePatrols)
+	s.handler = http.StripPrefix(PathPrefix, mux)
+	return s
+}
+
+// ServeHTTP serves requests under PathPrefix.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r)
+}
+
+// Transport returns a RoundTripper that answers OSM client requests from this
+// server without touching the network.
+func (s *Server) Transport() http.RoundTripper {
+	return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, r)
+		return recorder.Result(), nil
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// handleAuthorize approves every request immediately.
+func (s *Server) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	redirectURI := r.URL.Query().Get("redirect_uri")
+	if redirectURI == "" {
+		http.Error(w, "Missing redirect_uri", http.StatusBadRequest)
+		return
+	}
+	redirectURL := fmt.Sprintf("%s?code=%s&state=%s", redirectURI, url.QueryEscape("demo_code_"+randomHex(12)), url.QueryEscape(r.URL.Query().Get("state")))
+	http.Redirect(w, r, redirectURL, http.StatusFound)
+}
+
+// handleToken issues a fresh token pair for any code or refresh token.
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, map[string]any{
+		"access_token":  "demo_at_" + randomHex(16),
+		"token_type":    "Bearer",
+		"expires_in":    tokenExpiry,
+		"refresh_token": "demo_rt_" + randomHex(16),
+	})
+}
+
+func (s *Server) handleResource(w http.ResponseWriter, r *http.Request) {
+	if !hasBearerToken(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	setRateLimitHeaders(w)
+	s.mu.Lock()
+	sections := make([]types.OSMSection, len(s.sections))
+	for i, sec := range s.sections {
+		sections[i] = sec.profile
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, types.OSMProfileResponse{
+		Status: true,
+		Data: &types.OSMProfileData{
+			UserID:           demoUserID,
+			FullName:         demoUserName,
+			Email:            demoUserEmail,
+			Sections:         sections,
+			HasSectionAccess: true,
+		},
+	})
+}
+
+func (s *Server) handlePatrols(w http.ResponseWriter, r *http.Request) {
+	if !hasBearerToken(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	setRateLimitHeaders(w)
+	sectionID, err := strconv.Atoi(r.URL.Query().Get("sectionid"))
+	if err != nil {
+		http.Error(w, "Invalid sectionid", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sec := s.findSection(sectionID)
+	if sec == nil {
+		http.Error(w, "Section not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.URL.Query().Get("action") {
+	case "getPatrolsWithPeople":
+		writeJSON(w, sec.patrols)
+	case "updatePatrolPoints":
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Invalid form data", http.StatusBadRequest)
+			return
+		}
+		patrol, ok := sec.patrols[r.FormValue("patrolid")]
+		if !ok {
+			http.Error(w, "Patrol not found", http.StatusNotFound)
+			return
+		}
+		points, err := strconv.Atoi(r.FormValue("points"))
+		if err != nil {
+			http.Error(w, "Invalid points value", http.StatusBadRequest)
+			return
+		}
+		patrol.Points = strconv.Itoa(points)
+		sec.patrols[r.FormValue("patrolid")] = patrol
+		// OSM returns an empty array on success
+		writeJSON(w, []any{})
+	default:
+		http.Error(w, "Unknown action", http.StatusBadRequest)
+	}
+}
+
+// findSection must be called with s.mu held.
+func (s *Server) findSection(sectionID int) *section {
+	for i := range s.sections {
+		if s.sections[i].profile.SectionID == sectionID {
+			return &s.sections[i]
+		}
+	}
+	return nil
+}
+
+// demoSections mirrors the data served by cmd/mock-osm-server, including the
+// leader and unallocated entries the adapter filters out.
+func demoSections() []section {
+	now := time.Now()
+	term := func(termID int) []types.OSMTerm {
+		return []types.OSMTerm{{
+			Name:      "Current Term",
+			StartDate: now.AddDate(0, -3, 0).Format("2006-01-02"),
+			EndDate:   now.AddDate(0, 3, 0).Format("2006-01-02"),
+			TermID:    termID,
+		}}
+	}
+	members := func(n int) []any {
+		m := make([]any, n)
+		for i := range m {
+			m[i] = fmt.Sprintf("member%d", i+1)
+		}
+		return m
+	}
+
+	return []section{
+		{
+			profile: types.OSMSection{
+				SectionName: "1st Anytown Scouts",
+				GroupName:   "1st Anytown Group",
+				SectionID:   1001,
+				GroupID:     100,
+				SectionType: "scouts",
+				Terms:       term(5001),
+			},
+			patrols: map[string]osm.PatrolData{
+				"101":         {PatrolID: "101", Name: "Eagles", Points: "42", Members: members(3)},
+				"102":         {PatrolID: "102", Name: "Hawks", Points: "38", Members: members(2)},
+				"103":         {PatrolID: "103", Name: "Owls", Points: "45", Members: members(3)},
+				"-1":          {PatrolID: "-1", Name: "Leaders", Points: "0", Members: members(1)},
+				"unallocated": {PatrolID: "0", Name: "Unallocated", Points: "0", Members: members(0)},
+			},
+		},
+		{
+			profile: types.OSMSection{
+				SectionName: "2nd Anytown Scouts",
+				GroupName:   "2nd Anytown Group",
+				SectionID:   1002,
+				GroupID:     200,
+				SectionType: "scouts",
+				Terms:       term(5002),
+			},
+			patrols: map[string]osm.PatrolData{
+				"201":         {PatrolID: "201", Name: "Panthers", Points: "51", Members: members(2)},
+				"202":         {PatrolID: "202", Name: "Tigers", Points: "47", Members: members(3)},
+				"203":         {PatrolID: "203", Name: "Wolves", Points: "49", Members: members(2)},
+				"-3":          {PatrolID: "-3", Name: "Leaders", Points: "0", Members: members(1)},
+				"unallocated": {PatrolID: "0", Name: "Unallocated", Points: "0", Members: members(0)},
+			},
+		},
+	}
+}
+
+// setRateLimitHeaders reports a generous OSM rate limit so the adapter never
+// throttles itself in demo mode.
+func setRateLimitHeaders(w http.ResponseWriter) {
+	w.Header().Set("X-RateLimit-Limit", "1000")
+	w.Header().Set("X-RateLimit-Remaining", "1000")
+	w.Header().Set("X-RateLimit-Reset", "3600")
+}
+
+func hasBearerToken(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ")
+}
+
+func writeJSON(w http.ResponseWriter, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(data)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic("failed to generate random bytes: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
@@ -10,6 +10,7 @@ import (
 	"net/url"
 	"strings"
 
+	"github.com/m0rjc/OsmDeviceAdapter/internal/osm"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/types"
 )
 
@@ -35,6 +36,12 @@ type WebFlowClient struct {
 	httpClient   *http.Client
 }
 
+// SetTransport replaces the transport used for token requests. Demo mode uses it
+// to answer requests in-process.
+func (c *WebFlowClient) SetTransport(transport http.RoundTripper) {
+	c.httpClient.Transport = transport
+}
+
 func (c *WebFlowClient) RefreshToken(ctx context.Context, refreshToken string) (*types.OSMTokenResponse, error) {
 	data := url.Values{}
 	data.Set("grant_type", "refresh_token")
@@ -56,6 +63,10 @@ func (c *WebFlowClient) RefreshToken(ctx context.Context, refreshToken string) (
 	}
 	defer resp.Body.Close()
 
+	if err := checkServiceBlocked(resp); err != nil {
+		return nil, err
+	}
+
 	// Check for 401 (user revoked access)
 	if resp.StatusCode == http.StatusUnauthorized {
 		return nil, ErrAccessRevoked
@@ -113,6 +124,10 @@ func (c *WebFlowClient) ExchangeCodeForToken(code string) (*types.OSMTokenRespon
 	}
 	defer resp.Body.Close()
 
+	if err := checkServiceBlocked(resp); err != nil {
+		return nil, err
+	}
+
 	// Check for non-2xx status codes
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		body, _ := io.ReadAll(resp.Body)
@@ -127,3 +142,12 @@ func (c *WebFlowClient) ExchangeCodeForToken(code string) (*types.OSMTokenRespon
 
 	return &tokenResp, nil
 }
+
+// checkServiceBlocked returns osm.ErrServiceBlocked if OSM has set the X-Blocked header,
+// whatever the status code. The token endpoints bypass osm.Client, so they check it here.
+func checkServiceBlocked(resp *http.Response) error {
+	if blockedHeader := resp.Header.Get("X-Blocked"); blockedHeader != "" {
+		return fmt.Errorf("%w: %s", osm.ErrServiceBlocked, blockedHeader)
+	}
+	return nil
+}
@@ -50,6 +50,7 @@ func (c *Client) FetchPatrolScores(ctx context.Context, user types.User, section
 			"include_no_patrol": "y",
 		}),
 		WithUser(user),
+		withInlineRetry(),
 	)
 	if err != nil {
 		slog.Error("osm.patrol_scores.fetch_failed",
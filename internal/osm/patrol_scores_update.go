@@ -45,6 +45,7 @@ func (c *Client) UpdatePatrolScore(ctx context.Context, user types.User, section
 		}),
 		WithUrlEncodedBody(&formData),
 		WithUser(user),
+		withInlineRetry(),
 	)
 	if err != nil {
 		slog.Error("osm.patrol_scores.update_failed",
@@ -24,7 +24,12 @@ func (p *PrometheusRateLimitDecorator) MarkOsmServiceBlocked(ctx context.Context
 }
 
 func (p *PrometheusRateLimitDecorator) IsOsmServiceBlocked(ctx context.Context) bool {
-	return p.next.IsOsmServiceBlocked(ctx)
+	blocked := p.next.IsOsmServiceBlocked(ctx)
+	if !blocked {
+		// A cooldown block clears itself in the store
+		metrics.OSMServiceBlocked.Set(0)
+	}
+	return blocked
 }
 
 func (p *PrometheusRateLimitDecorator) MarkUserTemporarilyBlocked(ctx context.Context, userId int, blockedUntil time.Time) {
@@ -1,6 +1,8 @@
 package osm
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -18,8 +20,14 @@ import (
 var (
 	ErrServiceBlocked = fmt.Errorf("OSM service blocked")
 	ErrUnauthorized   = fmt.Errorf("unauthorized")
+	// ErrUnexpectedContentType indicates OSM (or something in front of it) returned
+	// a page rather than JSON, typically an HTML error or maintenance page.
+	ErrUnexpectedContentType = fmt.Errorf("unexpected content type from OSM")
 )
 
+// maxErrorSnippet bounds how much of an unexpected response body is quoted in errors.
+const maxErrorSnippet = 200
+
 // fallbackUserBlockTime is the last resort block time to apply if we cannot find a block time from headers.
 var fallbackUserBlockTime time.Duration = 10 * time.Minute
 
@@ -34,9 +42,9 @@ func (e *ErrUserBlocked) Error() string {
 
 // UserRateLimitInfo contains the current rate limit state for a user
 type UserRateLimitInfo struct {
-	Remaining int // Number of requests remaining in the current window
-	Limit     int // Total number of requests allowed per window
-	ResetsAt  time.Time
+	Remaining int       // Number of requests remaining in the current window
+	Limit     int       // Total number of requests allowed per window
+	ResetsAt  time.Time // When the current window resets; zero if OSM did not say
 }
 
 type RateLimitStore interface {
@@ -97,6 +105,8 @@ type requestConfig struct {
 	userId          *int
 	userToken       string
 	retryAttempted  bool
+	inlineRetry     bool // wait out a short Retry-After and retry once
+	inlineRetried   bool
 }
 
 // RequestOption defines a functional option for configuring an OSM API Request.
@@ -238,7 +248,8 @@ func (c *Client) Request(ctx context.Context, method string, target any, options
 	if err != nil {
 		return nil, fmt.Errorf("invalid base URL: %w", err)
 	}
-	u.Path = config.path
+	// Keep any path on the base URL so OSM can be served under a prefix (demo mode)
+	u.Path = strings.TrimSuffix(u.Path, "/") + config.path
 
 	if len(config.queryParameters) > 0 {
 		q := u.Query()
@@ -321,18 +332,39 @@ func (c *Client) Request(ctx context.Context, method string, target any, options
 
 	// Parse and store rate limit headers (per-user rate limits)
 	remaining, limit, resetSeconds := parseRateLimitHeaders(resp.Header)
-	if config.userId != nil {
+	if config.userId != nil && c.recorder != nil {
 		c.recorder.RecordRateLimit(config.userId, remaining, limit, resetSeconds)
 	}
 	osmResponse.Limits = UserRateLimitInfo{
 		Remaining: remaining,
 		Limit:     limit,
-		ResetsAt:  time.Now().Add(time.Duration(resetSeconds) * time.Second),
+	}
+	if resetSeconds > 0 {
+		osmResponse.Limits.ResetsAt = time.Now().Add(time.Duration(resetSeconds) * time.Second)
 	}
 
 	if resp.StatusCode == http.StatusTooManyRequests {
 		retryAfterStr := resp.Header.Get("Retry-After")
 		blockedUntil := parseRetryAfterHeader(retryAfterStr, resetSeconds)
+		// A short wait is cheaper than failing an interactive update; the
+		// user is only marked blocked if the retry is refused too
+		if config.inlineRetry && !config.inlineRetried && c.inlineRetryMaxWait > 0 {
+			if wait := time.Until(blockedUntil); wait <= c.inlineRetryMaxWait {
+				slog.Info("osm.api.rate_limited_retrying",
+					"component", "osm_api",
+					"event", "api.retry",
+					"endpoint", endpoint,
+					"wait_ms", wait.Milliseconds(),
+				)
+				resp.Body.Close()
+				select {
+				case <-time.After(wait):
+				case <-ctx.Done():
+					return osmResponse, ctx.Err()
+				}
+				return c.Request(ctx, method, target, append(options, withInlineRetried())...)
+			}
+		}
 		if config.userId != nil && c.rlStore != nil {
 			// Calculate the absolute time when the block expires
 			c.rlStore.MarkUserTemporarilyBlocked(ctx, *config.userId, blockedUntil)
@@ -381,11 +413,33 @@ func (c *Client) Request(ctx context.Context, method string, target any, options
 			"response_body", logBody,
 			"duration_ms", duration.Milliseconds(),
 		)
+		if !config.sensitive && looksLikeMarkup([]byte(logBody)) {
+			return osmResponse, unexpectedContentTypeError(resp, logBody)
+		}
 		return osmResponse, fmt.Errorf("OSM API error: %s - %s", resp.Status, logBody)
 	}
 
 	if target != nil {
-		if err := json.NewDecoder(resp.Body).Decode(target); err != nil {
+		// OSM does not always label its JSON correctly, so the body is sniffed
+		// rather than trusting Content-Type. JSON never starts with '<'.
+		body := bufio.NewReader(resp.Body)
+		if peeked, _ := body.Peek(maxErrorSnippet); looksLikeMarkup(peeked) {
+			snippet := string(peeked)
+			if config.sensitive {
+				snippet = "[REDACTED]"
+			}
+			slog.Error("osm.api.unexpected_content_type",
+				"component", "osm_api",
+				"event", "api.error",
+				"endpoint", endpoint,
+				"status_code", resp.StatusCode,
+				"content_type", resp.Header.Get("Content-Type"),
+				"response_body", snippet,
+				"duration_ms", duration.Milliseconds(),
+			)
+			return osmResponse, unexpectedContentTypeError(resp, snippet)
+		}
+		if err := json.NewDecoder(body).Decode(target); err != nil {
 			slog.Error("osm.api.decode_error",
 				"component", "osm_api",
 				"event", "api.error",
@@ -399,6 +453,26 @@ func (c *Client) Request(ctx context.Context, method string, target any, options
 	return osmResponse, nil
 }
 
+// looksLikeMarkup reports whether a response body is HTML or XML rather than JSON.
+func looksLikeMarkup(body []byte) bool {
+	trimmed := bytes.TrimSpace(body)
+	return len(trimmed) > 0 && trimmed[0] == '<'
+}
+
+// unexpectedContentTypeError describes a non-JSON response, quoting the start of
+// the body so logs show what OSM actually returned.
+func unexpectedContentTypeError(resp *http.Response, body string) error {
+	snippet := strings.Join(strings.Fields(body), " ")
+	if len(snippet) > maxErrorSnippet {
+		snippet = snippet[:maxErrorSnippet] + "..."
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "none"
+	}
+	return fmt.Errorf("%w: %s (status %s): %q", ErrUnexpectedContentType, contentType, resp.Status, snippet)
+}
+
 // attemptTokenRefreshAndRetry attempts to refresh an expired token and build retry options.
 // Returns the retry options if refresh succeeded, or nil if refresh failed or wasn't possible.
 // The returned options replay the original options with the new token appended (overriding the old one).
@@ -448,6 +522,21 @@ func withNewToken(token string) RequestOption {
 	}
 }
 
+// withInlineRetry lets a 429 with a short Retry-After be retried once; see
+// Client.SetInlineRetry.
+func withInlineRetry() RequestOption {
+	return func(c *requestConfig) {
+		c.inlineRetry = true
+	}
+}
+
+// withInlineRetried marks the request as the retry after a 429.
+func withInlineRetried() RequestOption {
+	return func(c *requestConfig) {
+		c.inlineRetried = true
+	}
+}
+
 // withRetryAttempted marks the request as a retry to prevent infinite loops.
 func withRetryAttempted() RequestOption {
 	return func(c *requestConfig) {
@@ -150,6 +150,39 @@ func TestClient_Request(t *testing.T) {
 		}
 	})
 
+	t.Run("detect service block from header on 200 response", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Blocked", "Service blocked")
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+		}))
+		defer server.Close()
+
+		store := &mockStore{}
+		client := NewClient(server.URL, store, store)
+
+		var target map[string]string
+		_, err := client.Request(context.Background(), http.MethodGet, &target, WithPath("/test"), WithUser(newMockUser(1, "utoken")))
+		if !errors.Is(err, ErrServiceBlocked) {
+			t.Errorf("expected ErrServiceBlocked, got %v", err)
+		}
+		if target != nil {
+			t.Errorf("expected body of a blocked response not to be decoded, got %v", target)
+		}
+		if !store.serviceBlocked {
+			t.Error("expected store to be marked as service blocked")
+		}
+
+		// Subsequent requests must not reach OSM
+		_, err = client.Request(context.Background(), http.MethodGet, nil, WithPath("/other"))
+		if !errors.Is(err, ErrServiceBlocked) {
+			t.Errorf("expected ErrServiceBlocked on follow-up request, got %v", err)
+		}
+		if len(store.latencies) != 1 {
+			t.Errorf("expected only the first request to reach OSM, got %d", len(store.latencies))
+		}
+	})
+
 	t.Run("detect user block from 429", func(t *testing.T) {
 		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("Retry-After", "30")
@@ -193,6 +226,51 @@ func TestClient_Request(t *testing.T) {
 		}
 	})
 
+	t.Run("html page is reported as unexpected content type", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("<!DOCTYPE html>\n<html><body><h1>Down for maintenance</h1></body></html>"))
+		}))
+		defer server.Close()
+
+		store := &mockStore{}
+		client := NewClient(server.URL, store, store)
+
+		_, err := client.FetchOSMProfile(newMockUser(1, "user-token"))
+		if !errors.Is(err, ErrUnexpectedContentType) {
+			t.Fatalf("expected ErrUnexpectedContentType, got %v", err)
+		}
+		for _, want := range []string{"text/html", "200", "Down for maintenance"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("expected error to contain %q, got %v", want, err)
+			}
+		}
+	})
+
+	t.Run("html error page is reported as unexpected content type", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte("<html><body>" + strings.Repeat("Bad Gateway ", 100) + "</body></html>"))
+		}))
+		defer server.Close()
+
+		store := &mockStore{}
+		client := NewClient(server.URL, store, store)
+
+		_, err := client.Request(context.Background(), http.MethodGet, nil, WithPath("/test"), WithUser(newMockUser(1, "user-token")))
+		if !errors.Is(err, ErrUnexpectedContentType) {
+			t.Fatalf("expected ErrUnexpectedContentType, got %v", err)
+		}
+		if !strings.Contains(err.Error(), "502") {
+			t.Errorf("expected error to contain the status, got %v", err)
+		}
+		if len(err.Error()) > 2*maxErrorSnippet {
+			t.Errorf("expected body snippet to be truncated, got %d bytes", len(err.Error()))
+		}
+	})
+
 	t.Run("withUser uses user token", func(t *testing.T) {
 		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			if r.Header.Get("Authorization") == "Bearer user-token" {
@@ -212,3 +290,62 @@ func TestClient_Request(t *testing.T) {
 		}
 	})
 }
+
+func TestClient_InlineRetryOn429(t *testing.T) {
+	t.Run("short Retry-After is waited out and retried once", func(t *testing.T) {
+		var calls int
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			if calls == 1 {
+				w.Header().Set("Retry-After", "1")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			if err := r.ParseForm(); err != nil || r.PostForm.Get("points") != "15" {
+				t.Errorf("expected the retry to resend the form, got %v (%v)", r.PostForm, err)
+			}
+			w.Write([]byte("[]"))
+		}))
+		defer server.Close()
+
+		store := &mockStore{}
+		client := NewClient(server.URL, store, store)
+		client.SetInlineRetry(2 * time.Second)
+
+		if err := client.UpdatePatrolScore(context.Background(), newMockUser(1, "utoken"), 100, "patrol-1", 15); err != nil {
+			t.Fatalf("expected the retry to succeed, got %v", err)
+		}
+		if calls != 2 {
+			t.Errorf("expected 2 calls to OSM, got %d", calls)
+		}
+		if store.userBlocked[1] {
+			t.Error("expected the user not to be marked as blocked after a successful retry")
+		}
+	})
+
+	t.Run("long Retry-After returns the rate limit error immediately", func(t *testing.T) {
+		var calls int
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.Header().Set("Retry-After", "30")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}))
+		defer server.Close()
+
+		store := &mockStore{}
+		client := NewClient(server.URL, store, store)
+		client.SetInlineRetry(2 * time.Second)
+
+		_, _, err := client.FetchPatrolScores(context.Background(), newMockUser(1, "utoken"), 100, 200)
+		var blockedErr *ErrUserBlocked
+		if !errors.As(err, &blockedErr) {
+			t.Fatalf("expected ErrUserBlocked, got %v", err)
+		}
+		if calls != 1 {
+			t.Errorf("expected a single call to OSM, got %d", calls)
+		}
+		if !store.userBlocked[1] {
+			t.Error("expected user1 to be marked as blocked in store")
+		}
+	})
+}
@@ -2,10 +2,13 @@ package server
 
 import (
 	"bufio"
+	"crypto/tls"
 	"fmt"
 	"log/slog"
 	"net"
 	"net/http"
+	"net/http/pprof"
+	rpprof "runtime/pprof"
 	"strconv"
 	"strings"
 	"time"
@@ -15,6 +18,7 @@ import (
 	"github.com/m0rjc/OsmDeviceAdapter/internal/handlers"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/metrics"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/middleware"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/osm/demo"
 	wsinternal "github.com/m0rjc/OsmDeviceAdapter/internal/websocket"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
@@ -22,25 +26,35 @@ import (
 func NewServer(cfg *config.Config, deps *handlers.Dependencies) *http.Server {
 	mux := http.NewServeMux()
 
+	// Security headers for server-rendered HTML pages
+	pageSecurityMw := middleware.PageSecurityHeadersMiddleware
+
 	// Home page
-	mux.HandleFunc("/", handlers.HomeHandler(deps))
+	mux.Handle("/", pageSecurityMw(handlers.HomeHandler(deps)))
+
+	// Public status page (detailed internals stay on the metrics server)
+	mux.HandleFunc("/status", handlers.StatusHandler(deps))
+
+	// Browser-based scoreboards may call the device-facing endpoints cross-origin
+	corsMw := middleware.CORSMiddleware(cfg.Security.ParseCORSOrigins())
 
 	// Device OAuth Flow endpoints (configurable path prefix)
-	mux.HandleFunc(fmt.Sprintf("%s/authorize", cfg.Paths.DevicePrefix), handlers.DeviceAuthorizeHandler(deps))
-	mux.HandleFunc(fmt.Sprintf("%s/token", cfg.Paths.DevicePrefix), handlers.DeviceTokenHandler(deps))
-	mux.HandleFunc(cfg.Paths.DevicePrefix, handlers.OAuthAuthorizeHandler(deps))                          // User verification page
-	mux.HandleFunc("/d/", handlers.ShortCodeRedirectHandler(deps))                                        // Short URL redirect for QR codes
-	mux.HandleFunc(fmt.Sprintf("%s/confirm", cfg.Paths.DevicePrefix), handlers.OAuthConfirmHandler(deps)) // Device authorization confirmation
-	mux.HandleFunc(fmt.Sprintf("%s/cancel", cfg.Paths.DevicePrefix), handlers.OAuthCancelHandler(deps))   // Device authorization cancellation
+	mux.Handle(fmt.Sprintf("%s/authorize", cfg.Paths.DevicePrefix), corsMw(handlers.DeviceAuthorizeHandler(deps)))
+	mux.Handle(fmt.Sprintf("%s/token", cfg.Paths.DevicePrefix), corsMw(handlers.DeviceTokenHandler(deps)))
+	mux.Handle(cfg.Paths.DevicePrefix, pageSecurityMw(handlers.OAuthAuthorizeHandler(deps)))                          // User verification page
+	mux.HandleFunc("/d/", handlers.ShortCodeRedirectHandler(deps))                                                    // Short URL redirect for QR codes
+	mux.Handle(fmt.Sprintf("%s/confirm", cfg.Paths.DevicePrefix), pageSecurityMw(handlers.OAuthConfirmHandler(deps))) // Device authorization confirmation
+	mux.Handle(fmt.Sprintf("%s/cancel", cfg.Paths.DevicePrefix), pageSecurityMw(handlers.OAuthCancelHandler(deps)))   // Device authorization cancellation
 
 	// OAuth Web Flow endpoints (for OSM) (configurable path prefix)
-	mux.HandleFunc(fmt.Sprintf("%s/authorize", cfg.Paths.OAuthPrefix), handlers.OAuthAuthorizeHandler(deps))
-	mux.HandleFunc(fmt.Sprintf("%s/callback", cfg.Paths.OAuthPrefix), handlers.OAuthCallbackHandler(deps))
-	mux.HandleFunc(fmt.Sprintf("%s/select-section", cfg.Paths.DevicePrefix), handlers.OAuthSelectSectionHandler(deps))
+	mux.Handle(fmt.Sprintf("%s/authorize", cfg.Paths.OAuthPrefix), pageSecurityMw(handlers.OAuthAuthorizeHandler(deps)))
+	mux.Handle(fmt.Sprintf("%s/callback", cfg.Paths.OAuthPrefix), pageSecurityMw(handlers.OAuthCallbackHandler(deps)))
+	mux.Handle(fmt.Sprintf("%s/select-section", cfg.Paths.DevicePrefix), pageSecurityMw(handlers.OAuthSelectSectionHandler(deps)))
 
 	// API endpoints for scoreboard (requires authentication) (configurable path prefix)
 	deviceAuthMiddleware := middleware.DeviceAuthMiddleware(deps.DeviceAuth)
-	mux.Handle(fmt.Sprintf("%s/v1/patrols", cfg.Paths.APIPrefix), deviceAuthMiddleware(handlers.GetPatrolScoresHandler(deps)))
+	mux.Handle(fmt.Sprintf("%s/v1/patrols", cfg.Paths.APIPrefix), corsMw(deviceAuthMiddleware(handlers.GetPatrolScoresHandler(deps))))
+	mux.Handle(fmt.Sprintf("%s/device/sections/scores", cfg.Paths.APIPrefix), corsMw(deviceAuthMiddleware(handlers.PostDeviceScoresHandler(deps))))
 
 	// Device WebSocket endpoint — token auth via query param
 	if deps.WebSocketHub != nil {
@@ -56,58 +70,96 @@ func NewServer(cfg *config.Config, deps *handlers.Dependencies) *http.Server {
 	mux.HandleFunc("/admin/callback", handlers.AdminCallbackHandler(deps))
 	mux.HandleFunc("/admin/logout", handlers.AdminLogoutHandler(deps))
 
-	// Admin API endpoints (authenticated via session cookie)
-	adminSessionMw := middleware.SessionMiddleware(deps.Conns, handlers.AdminSessionCookieName)
+	// Admin API endpoints (authenticated via session cookie) (configurable path prefix)
+	adminSessionMw := middleware.SessionMiddleware(deps.Conns, cfg.Admin.SessionCookieName)
 	adminTokenMw := middleware.TokenRefreshMiddleware(deps.Conns, deps.WebAuth)
 	adminSecurityMw := middleware.SecurityHeadersMiddleware
 	adminMiddleware := func(h http.Handler) http.Handler {
 		return adminSecurityMw(adminSessionMw(adminTokenMw(h)))
 	}
 
-	mux.Handle("/api/admin/session", adminMiddleware(handlers.AdminSessionHandler(deps)))
-	mux.Handle("/api/admin/sections", adminMiddleware(handlers.AdminSectionsHandler(deps)))
+	mux.Handle(fmt.Sprintf("%s/session", cfg.Paths.AdminAPIPrefix), adminMiddleware(handlers.AdminSessionHandler(deps)))
+	mux.Handle(fmt.Sprintf("%s/sections", cfg.Paths.AdminAPIPrefix), adminMiddleware(handlers.AdminSectionsHandler(deps)))
 	// Route settings before scores - Go's mux uses longest match, but we need to check path suffix
 	// Settings endpoint: /api/admin/sections/{id}/settings
 	// Scores endpoint: /api/admin/sections/{id}/scores
-	mux.Handle("/api/admin/sections/", adminMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	// Audit summary endpoint: /api/admin/sections/{id}/audit/summary
+	// Audit list endpoint: /api/admin/sections/{id}/audit
+	// Refresh endpoint: /api/admin/sections/{id}/refresh
+	// Archives endpoint: /api/admin/sections/{id}/archives
+	mux.Handle(fmt.Sprintf("%s/sections/", cfg.Paths.AdminAPIPrefix), adminMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		path := r.URL.Path
 		if strings.HasSuffix(path, "/settings") {
 			handlers.AdminSettingsHandler(deps).ServeHTTP(w, r)
+		} else if strings.HasSuffix(path, "/audit/summary") {
+			handlers.AdminAuditSummaryHandler(deps).ServeHTTP(w, r)
+		} else if strings.HasSuffix(path, "/audit") {
+			handlers.AdminAuditListHandler(deps).ServeHTTP(w, r)
+		} else if strings.HasSuffix(path, "/refresh") {
+			handlers.AdminSectionRefreshHandler(deps).ServeHTTP(w, r)
+		} else if strings.HasSuffix(path, "/archives") {
+			handlers.AdminArchivesHandler(deps).ServeHTTP(w, r)
 		} else {
 			handlers.AdminScoresHandler(deps).ServeHTTP(w, r)
 		}
 	})))
 
+	// Audit entry annotation: /api/admin/audit/{id}
+	mux.Handle(fmt.Sprintf("%s/audit/", cfg.Paths.AdminAPIPrefix), adminMiddleware(handlers.AdminAuditEntryHandler(deps)))
+
+	// Batch score updates across several sections
+	mux.Handle(fmt.Sprintf("%s/scores/batch", cfg.Paths.AdminAPIPrefix), adminMiddleware(handlers.AdminBatchScoresHandler(deps)))
+	mux.Handle(fmt.Sprintf("%s/batches/", cfg.Paths.AdminAPIPrefix), adminMiddleware(handlers.AdminBatchHandler(deps)))
+
 	// Ad-hoc patrol CRUD endpoints
-	mux.Handle("/api/admin/adhoc/patrols", adminMiddleware(handlers.AdminAdhocPatrolsHandler(deps)))
-	mux.Handle("/api/admin/adhoc/patrols/", adminMiddleware(handlers.AdminAdhocPatrolHandler(deps)))
+	mux.Handle(fmt.Sprintf("%s/adhoc/patrols", cfg.Paths.AdminAPIPrefix), adminMiddleware(handlers.AdminAdhocPatrolsHandler(deps)))
+	mux.Handle(fmt.Sprintf("%s/adhoc/patrols/", cfg.Paths.AdminAPIPrefix), adminMiddleware(handlers.AdminAdhocPatrolHandler(deps)))
 
 	// Scoreboard management endpoints
-	mux.Handle("/api/admin/scoreboards", adminMiddleware(handlers.AdminScoreboardsHandler(deps)))
-	mux.Handle("/api/admin/scoreboards/", adminMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	mux.Handle(fmt.Sprintf("%s/scoreboards", cfg.Paths.AdminAPIPrefix), adminMiddleware(handlers.AdminScoreboardsHandler(deps)))
+	mux.Handle(fmt.Sprintf("%s/scoreboards/", cfg.Paths.AdminAPIPrefix), adminMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		path := r.URL.Path
 		if strings.HasSuffix(path, "/timer") {
 			handlers.AdminScoreboardTimerHandler(deps).ServeHTTP(w, r)
+		} else if strings.HasSuffix(path, "/status") {
+			handlers.AdminScoreboardStatusHandler(deps).ServeHTTP(w, r)
 		} else {
 			handlers.AdminScoreboardSectionHandler(deps).ServeHTTP(w, r)
 		}
 	})))
 
+	// In demo mode the fake OSM is mounted so login redirects reach it
+	if deps.DemoOSM != nil {
+		mux.Handle(demo.PathPrefix+"/", deps.DemoOSM)
+	}
+
 	// Admin SPA (serves static files for /admin/*)
 	// Note: More specific routes (/admin/login, /admin/callback, /admin/logout, /api/admin/*)
 	// are registered above and take precedence over this catch-all
 	mux.Handle("/admin/", adminSecurityMw(admin.NewSPAHandler()))
 
 	// Apply middleware chain:
-	// 1. Remote metadata (Cloudflare headers, HTTPS redirect, HSTS) - applied to all routes
+	// 1. Remote metadata (Cloudflare headers or GeoIP, HTTPS redirect, HSTS) - applied to all routes
 	// 2. Logging middleware - applied to all routes
 	handler := loggingMiddleware(
-		middleware.RemoteMetadataMiddleware(cfg.ExternalDomains.ExposedDomain)(routeCapturingMux(mux)),
+		middleware.RemoteMetadataMiddleware(cfg.ExternalDomains.ExposedDomain, cfg.Security.HSTSMaxAge, deps.Countries)(routeCapturingMux(mux)),
 	)
 
+	// Only applies when the server terminates TLS itself (TLS_CERT_FILE and
+	// TLS_KEY_FILE set); behind a tunnel or proxy the TLS policy is set there.
+	minTLSVersion, err := config.ParseTLSVersion(cfg.Security.TLSMinVersion)
+	if err != nil {
+		slog.Warn("server.tls.invalid_min_version",
+			"component", "server",
+			"error", err,
+		)
+		minTLSVersion = tls.VersionTLS12
+	}
+
 	return &http.Server{
-		Addr:    fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port),
-		Handler: handler,
+		Addr:      fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port),
+		Handler:   handler,
+		TLSConfig: &tls.Config{MinVersion: minTLSVersion},
 	}
 }
 
@@ -119,10 +171,31 @@ func NewMetricsServer(deps *handlers.Dependencies) *http.Server {
 	// Health check endpoints
 	mux.HandleFunc("/health", handlers.HealthHandler)
 	mux.HandleFunc("/ready", handlers.ReadyHandler(deps))
+	mux.HandleFunc("/readyz", handlers.ReadyHandler(deps))
 
 	// Prometheus metrics endpoint (using custom registry without Go runtime metrics)
 	mux.Handle("/metrics", promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{}))
 
+	// Service announcements to all connected scoreboards (internal only)
+	mux.HandleFunc("/internal/notice", handlers.InternalNoticeHandler(deps))
+
+	// Live check of a device's stored OSM token, for support (internal only)
+	mux.HandleFunc("/internal/token-check", handlers.InternalTokenCheckHandler(deps))
+
+	// Profiling endpoints (opt-in, for diagnosing goroutine leaks in the WebSocket hub etc.)
+	if deps.Config.Server.EnablePprof {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		// Shortcut for a full goroutine dump with stack traces
+		mux.HandleFunc("/debug/goroutines", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			_ = rpprof.Lookup("goroutine").WriteTo(w, 2)
+		})
+	}
+
 	return &http.Server{
 		Addr:    ":9090",
 		Handler: mux,
@@ -0,0 +1,245 @@
+package server
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/m0rjc/OsmDeviceAdapter/internal/config"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db/devicecode"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/handlers"
+)
+
+// newDeviceFlowTestServer creates a server with a pending device code whose
+// user code is ABCD-EFGH, so that the device confirmation page can be fetched.
+func newDeviceFlowTestServer(t *testing.T) *http.Server {
+	t.Helper()
+
+	conns := db.SetupTestDB(t)
+	conns.RateLimiter = db.NewMockRateLimiter()
+
+	if err := devicecode.Create(conns, &db.DeviceCode{
+		DeviceCode: "test-device-code",
+		UserCode:   "ABCD-EFGH",
+		ClientID:   "test-client",
+		Status:     "pending",
+		ExpiresAt:  time.Now().Add(5 * time.Minute),
+	}); err != nil {
+		t.Fatalf("Failed to create device code: %v", err)
+	}
+
+	cfg := &config.Config{
+		ExternalDomains: config.ExternalDomainsConfig{ExposedDomain: "https://example.com"},
+		RateLimit:       config.RateLimitConfig{DeviceEntryRateLimit: 5},
+		Paths: config.PathConfig{
+			OAuthPrefix:    "/oauth",
+			DevicePrefix:   "/device",
+			APIPrefix:      "/api",
+			AdminAPIPrefix: "/api/admin",
+		},
+		Admin:    config.AdminConfig{SessionCookieName: handlers.AdminSessionCookieName},
+		Security: config.SecurityConfig{HSTSMaxAge: 31536000},
+	}
+	return NewServer(cfg, &handlers.Dependencies{Config: cfg, Conns: conns})
+}
+
+// getDeviceConfirmationPage fetches the device confirmation page over "HTTPS"
+func getDeviceConfirmationPage(t *testing.T, srv *http.Server) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/device?user_code=ABCD-EFGH", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	w := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "ABCD-EFGH") {
+		t.Fatal("Expected the device confirmation page to be rendered")
+	}
+	return w
+}
+
+func TestServer_DeviceConfirmationPageSecurityHeaders(t *testing.T) {
+	srv := newDeviceFlowTestServer(t)
+	w := getDeviceConfirmationPage(t, srv)
+
+	expected := map[string]string{
+		"X-Content-Type-Options":    "nosniff",
+		"X-Frame-Options":           "DENY",
+		"Referrer-Policy":           "strict-origin-when-cross-origin",
+		"Strict-Transport-Security": "max-age=31536000; includeSubDomains; preload",
+	}
+	for header, want := range expected {
+		if got := w.Header().Get(header); got != want {
+			t.Errorf("header %s: expected %q, got %q", header, want, got)
+		}
+	}
+	if csp := w.Header().Get("Content-Security-Policy"); !strings.Contains(csp, "frame-ancestors 'none'") {
+		t.Errorf("Expected CSP with frame-ancestors 'none', got %q", csp)
+	}
+	if srv.TLSConfig == nil || srv.TLSConfig.MinVersion != tls.VersionTLS12 {
+		t.Error("Expected server TLS config to enforce TLS 1.2 minimum")
+	}
+}
+
+func TestServer_EnforcesMinimumTLSVersion(t *testing.T) {
+	cfg := &config.Config{
+		ExternalDomains: config.ExternalDomainsConfig{ExposedDomain: "https://example.com"},
+		Paths:           config.PathConfig{DevicePrefix: "/device", APIPrefix: "/api", AdminAPIPrefix: "/api/admin"},
+		Security:        config.SecurityConfig{TLSMinVersion: "1.3"},
+	}
+	srv := NewServer(cfg, &handlers.Dependencies{Config: cfg, Conns: db.SetupTestDB(t)})
+
+	// Serve with the server's own TLS config, as ListenAndServeTLS would
+	ts := httptest.NewUnstartedServer(srv.Handler)
+	ts.TLS = srv.TLSConfig
+	ts.StartTLS()
+	defer ts.Close()
+	addr := strings.TrimPrefix(ts.URL, "https://")
+
+	old, err := tls.Dial("tcp", addr, &tls.Config{InsecureSkipVerify: true, MaxVersion: tls.VersionTLS12})
+	if err == nil {
+		old.Close()
+		t.Error("Expected a TLS 1.2 handshake to be refused")
+	}
+
+	current, err := tls.Dial("tcp", addr, &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatalf("Expected a TLS 1.3 handshake to succeed: %v", err)
+	}
+	defer current.Close()
+	if current.ConnectionState().Version != tls.VersionTLS13 {
+		t.Errorf("Expected TLS 1.3, got %x", current.ConnectionState().Version)
+	}
+}
+
+func TestMetricsServer_PprofOnlyWhenEnabled(t *testing.T) {
+	paths := []string{"/debug/pprof/", "/debug/pprof/cmdline", "/debug/goroutines"}
+
+	tests := []struct {
+		name       string
+		enabled    bool
+		wantStatus int
+	}{
+		{"disabled", false, http.StatusNotFound},
+		{"enabled", true, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := &handlers.Dependencies{
+				Config: &config.Config{
+					Server: config.ServerConfig{EnablePprof: tt.enabled},
+				},
+			}
+			srv := NewMetricsServer(deps)
+
+			for _, path := range paths {
+				req := httptest.NewRequest(http.MethodGet, path, nil)
+				w := httptest.NewRecorder()
+				srv.Handler.ServeHTTP(w, req)
+
+				if w.Code != tt.wantStatus {
+					t.Errorf("%s: expected status %d, got %d", path, tt.wantStatus, w.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestServer_DeviceConfirmationPageNonceCSP(t *testing.T) {
+	srv := newDeviceFlowTestServer(t)
+	w := getDeviceConfirmationPage(t, srv)
+
+	csp := w.Header().Get("Content-Security-Policy")
+	match := regexp.MustCompile(`script-src 'nonce-([^']+)'`).FindStringSubmatch(csp)
+	if match == nil {
+		t.Fatalf("Expected nonce-based script-src in CSP, got %q", csp)
+	}
+	if strings.Contains(csp, "script-src 'self' 'unsafe-inline'") {
+		t.Errorf("Expected CSP not to allow inline scripts, got %q", csp)
+	}
+	nonce := match[1]
+
+	body := w.Body.String()
+	if regexp.MustCompile(`(?i)\son[a-z]+\s*=`).MatchString(body) {
+		t.Error("Expected no inline event handler attributes on the page")
+	}
+	scripts := regexp.MustCompile(`<script[^>]*>`).FindAllString(body, -1)
+	if len(scripts) == 0 {
+		t.Fatal("Expected the page to include its script")
+	}
+	for _, tag := range scripts {
+		if !strings.Contains(tag, `nonce="`+nonce+`"`) {
+			t.Errorf("Expected script tag to carry the CSP nonce, got %s", tag)
+		}
+	}
+
+	// Each response gets a fresh nonce
+	w2 := getDeviceConfirmationPage(t, newDeviceFlowTestServer(t))
+	if w2.Header().Get("Content-Security-Policy") == csp {
+		t.Error("Expected a different nonce per response")
+	}
+}
+
+func TestServer_UnknownPathsNegotiateNotFound(t *testing.T) {
+	srv := newDeviceFlowTestServer(t)
+
+	tests := []struct {
+		name            string
+		path            string
+		accept          string
+		wantContentType string
+	}{
+		{"unknown API path", "/api/v1/nothing-here", "", "application/json"},
+		{"API client on browser path", "/nothing-here", "application/json", "application/json"},
+		{"browser path", "/nothing-here", "text/html,application/xhtml+xml,*/*;q=0.8", "text/html"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("X-Forwarded-Proto", "https")
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			srv.Handler.ServeHTTP(w, req)
+
+			if w.Code != http.StatusNotFound {
+				t.Fatalf("Expected status 404, got %d", w.Code)
+			}
+			if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, tt.wantContentType) {
+				t.Errorf("Expected Content-Type %s, got %q", tt.wantContentType, got)
+			}
+		})
+	}
+}
+
+func TestServer_RootPointsToDeviceEntry(t *testing.T) {
+	srv := newDeviceFlowTestServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	w := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `action="/device"`) {
+		t.Fatalf("Expected the home page with the device code form, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	req.Header.Set("Accept", "application/json")
+	w = httptest.NewRecorder()
+	srv.Handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"device_url":"/device"`) {
+		t.Errorf("Expected a JSON service description, got %d: %s", w.Code, w.Body.String())
+	}
+}
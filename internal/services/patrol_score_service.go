@@ -6,16 +6,19 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"math"
 	"time"
 
 	"github.com/m0rjc/OsmDeviceAdapter/internal/config"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/db"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/db/adhocpatrol"
-	"github.com/m0rjc/OsmDeviceAdapter/internal/db/devicecode"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db/scorearchive"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/db/sectionsettings"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/osm"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/term"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/types"
 	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
 )
 
 // RateLimitState represents the current rate limiting state
@@ -31,11 +34,58 @@ const (
 // CachedPatrolScores represents cached patrol score data with metadata
 type CachedPatrolScores struct {
 	Patrols        []types.PatrolScore `json:"patrols"`
+	TermID         int                 `json:"term_id,omitempty"`
+	TermEndDate    time.Time           `json:"term_end_date,omitempty"`
 	CachedAt       time.Time           `json:"cached_at"`
 	ValidUntil     time.Time           `json:"valid_until"`
 	RateLimitState RateLimitState      `json:"rate_limit_state"`
+	RateLimit      *RateLimitInfo      `json:"rate_limit,omitempty"`
 }
 
+// RateLimitInfo gives devices OSM's rate limit as it stood when the scores
+// were fetched, so they can widen their poll interval as Remaining drops.
+type RateLimitInfo struct {
+	Remaining int        `json:"remaining"`
+	Limit     int        `json:"limit"`
+	ResetAt   *time.Time `json:"reset_at,omitempty"`
+	// RetryAfterSeconds is set while OSM is refusing requests
+	RetryAfterSeconds int `json:"retry_after_seconds,omitempty"`
+}
+
+// newRateLimitInfo converts the limits OSM reported with a response.
+func newRateLimitInfo(info osm.UserRateLimitInfo) *RateLimitInfo {
+	rateLimit := &RateLimitInfo{Remaining: info.Remaining, Limit: info.Limit}
+	if !info.ResetsAt.IsZero() {
+		resetAt := info.ResetsAt
+		rateLimit.ResetAt = &resetAt
+	}
+	return rateLimit
+}
+
+// blockedRateLimitInfo describes a rate limit that is refusing requests until
+// blockedUntil, keeping the window size last seen if there is one.
+func blockedRateLimitInfo(last *RateLimitInfo, blockedUntil time.Time) *RateLimitInfo {
+	rateLimit := &RateLimitInfo{
+		ResetAt:           &blockedUntil,
+		RetryAfterSeconds: max(1, int(math.Ceil(time.Until(blockedUntil).Seconds()))),
+	}
+	if last != nil {
+		rateLimit.Limit = last.Limit
+	}
+	return rateLimit
+}
+
+// PatrolScoresCacheKey is the Redis key holding a section's cached patrol
+// scores. Every device showing the section shares it.
+func PatrolScoresCacheKey(sectionID int) string {
+	return fmt.Sprintf("patrol_scores:section:%d", sectionID)
+}
+
+// sectionFetches collapses concurrent cache misses for the same section and
+// term into one OSM request. It is package level because a PatrolScoreService
+// is created for each request.
+var sectionFetches singleflight.Group
+
 // WebSocketInfo is included in every patrol score response to signal WebSocket availability.
 type WebSocketInfo struct {
 	Requested bool `json:"requested"`
@@ -50,6 +100,13 @@ type PatrolScoreResponse struct {
 	RateLimitState RateLimitState        `json:"rate_limit_state"`
 	Settings       *types.DeviceSettings `json:"settings,omitempty"`
 	WebSocket      WebSocketInfo         `json:"websocket"`
+	// RateLimit is absent for ad-hoc sections, which do not use OSM
+	RateLimit *RateLimitInfo `json:"rate_limit,omitempty"`
+	// HiddenCount is the number of patrols left out by Settings.HideBelowScore
+	HiddenCount int `json:"hidden_count,omitempty"`
+	// Sections lists the sections a multi-section device may show, primary
+	// first, for it to cycle through with ?section=
+	Sections []int `json:"sections,omitempty"`
 }
 
 // PatrolScoreService orchestrates patrol score fetching with caching and rate limiting
@@ -57,6 +114,7 @@ type PatrolScoreService struct {
 	osmClient *osm.Client
 	conns     *db.Connections
 	config    *config.Config
+	terms     *term.Service
 }
 
 // NewPatrolScoreService creates a new patrol score service
@@ -69,30 +127,102 @@ func NewPatrolScoreService(
 		osmClient: osmClient,
 		conns:     conns,
 		config:    cfg,
+		terms:     term.NewService(osmClient, conns),
 	}
 }
 
 // GetPatrolScores fetches patrol scores for a device, managing term discovery,
 // caching, and rate limiting automatically.
 // Accepts user and device from the authentication middleware to avoid redundant database queries.
+// Patrols below the section's HideBelowScore setting are left out; the cache
+// always holds the full list.
 func (s *PatrolScoreService) GetPatrolScores(ctx context.Context, user types.User, device *db.DeviceCode) (*PatrolScoreResponse, error) {
-	var err error
-
 	if device.SectionID == nil {
 		return nil, osm.ErrNoSectionConfigured
 	}
+	return s.GetSectionPatrolScores(ctx, user, device, *device.SectionID)
+}
+
+// GetSectionPatrolScores is GetPatrolScores for one of the sections a
+// multi-section device may show. The caller checks that the device may show
+// sectionID.
+func (s *PatrolScoreService) GetSectionPatrolScores(ctx context.Context, user types.User, device *db.DeviceCode, sectionID int) (*PatrolScoreResponse, error) {
+	response, err := s.getPatrolScores(ctx, user, device, sectionID)
+	if err != nil {
+		return nil, err
+	}
+	if response.Settings != nil && response.Settings.HideBelowScore != nil {
+		response.Patrols, response.HiddenCount = hidePatrolsBelow(response.Patrols, *response.Settings.HideBelowScore)
+	}
+	return response, nil
+}
+
+// hidePatrolsBelow returns the patrols scoring at least threshold, in their
+// original order, and the number left out.
+func hidePatrolsBelow(patrols []types.PatrolScore, threshold int) ([]types.PatrolScore, int) {
+	shown := make([]types.PatrolScore, 0, len(patrols))
+	for _, patrol := range patrols {
+		if patrol.Score >= threshold {
+			shown = append(shown, patrol)
+		}
+	}
+	return shown, len(patrols) - len(shown)
+}
+
+func (s *PatrolScoreService) getPatrolScores(ctx context.Context, user types.User, device *db.DeviceCode, sectionID int) (*PatrolScoreResponse, error) {
+	var err error
 
 	// Ad-hoc section: serve from local database instead of OSM
-	if *device.SectionID == 0 {
+	if sectionID == 0 {
 		return s.getAdhocPatrolScores(ctx, device)
 	}
 
 	// Fetch device settings (best effort - settings errors don't fail the request)
-	settings := s.fetchDeviceSettings(device)
+	settings := s.fetchDeviceSettings(ctx, device, sectionID)
 
-	// Check patrol scores cache
-	cached, err := s.getCachedPatrolScores(ctx, device.DeviceCode)
-	if err == nil && time.Now().Before(cached.ValidUntil) {
+	// The device's own record of the section's term, if that term has ended
+	endedTermID := 0
+	if device.SectionID != nil && *device.SectionID == sectionID && device.TermID != nil &&
+		device.TermEndDate != nil && time.Now().After(*device.TermEndDate) {
+		endedTermID = *device.TermID
+	}
+
+	// Term information is normally held on the device, so this only reaches
+	// OSM about once a day
+	termID, termEnd, err := s.activeTerm(ctx, user, device, sectionID)
+	if err == nil {
+		s.recordSectionReader(ctx, sectionID, device)
+	} else if !isOSMBlockError(err) {
+		// Nothing shows the user can still read the section, so the shared
+		// cache is not served, not even as a stale fallback
+		return nil, fmt.Errorf("failed to fetch patrol scores: %w", err)
+	}
+
+	// Check the section's patrol scores cache, ignoring scores from another
+	// term once the current term is known. The cache is shared by every user
+	// showing the section, so it is only served to users OSM has let read it.
+	cached, cacheErr := s.getCachedPatrolScores(ctx, sectionID)
+	if cacheErr != nil || !s.isSectionReader(ctx, sectionID, device) {
+		cached = nil
+	} else if err == nil && cached.TermID != termID {
+		if cached.TermEndDate.After(termEnd) {
+			// Another device has already seen the section's next term, which
+			// this one has not checked for yet. Follow it rather than replace
+			// the newer term's scores.
+			termID, termEnd = cached.TermID, cached.TermEndDate
+		} else {
+			if termEnded(cached) {
+				s.archiveEndedTerm(ctx, user, sectionID, cached.TermID, cached)
+			}
+			cached = nil
+		}
+	}
+	if err == nil && endedTermID != 0 && endedTermID != termID {
+		// The device last saw a term that has since ended; its final scores
+		// may no longer be cached if they were refreshed after it ended
+		s.archiveEndedTerm(ctx, user, sectionID, endedTermID, cached)
+	}
+	if cached != nil && time.Now().Before(cached.ValidUntil) {
 		// Cache is still valid
 		return &PatrolScoreResponse{
 			Patrols:        cached.Patrols,
@@ -100,20 +230,17 @@ func (s *PatrolScoreService) GetPatrolScores(ctx context.Context, user types.Use
 			CachedAt:       cached.CachedAt,
 			CacheExpiresAt: cached.ValidUntil,
 			RateLimitState: cached.RateLimitState,
+			RateLimit:      cached.RateLimit,
 			Settings:       settings,
 			WebSocket:      WebSocketInfo{Requested: true},
 		}, nil
 	}
 
-	// Cache miss or expired - need to fetch fresh data
-	// First, ensure we have term information
-	var termID int
-	var patrols []types.PatrolScore
-	var rateLimitInfo osm.UserRateLimitInfo
-	termID, err = s.ensureTermInfo(ctx, user, device)
+	// Cache miss or expired - fetch fresh data, sharing the fetch with any
+	// other device showing the section
+	var fresh *CachedPatrolScores
 	if err == nil {
-		// Fetch patrol scores from OSM
-		patrols, rateLimitInfo, err = s.osmClient.FetchPatrolScores(ctx, user, *device.SectionID, termID)
+		fresh, err = s.fetchSectionScores(ctx, user, sectionID, termID, termEnd)
 	}
 	if err != nil {
 		// Try to make the cache last long enough if we have one
@@ -133,7 +260,7 @@ func (s *PatrolScoreService) GetPatrolScores(ctx context.Context, user types.Use
 			// Extend the cache time if needed to cover any block
 			if cached.ValidUntil.Before(cacheUntil) {
 				cached.ValidUntil = cacheUntil
-				s.cachePatrolScores(ctx, device.DeviceCode, cached)
+				s.cachePatrolScores(ctx, sectionID, cached)
 			}
 			return &PatrolScoreResponse{
 				Patrols:        cached.Patrols,
@@ -141,6 +268,7 @@ func (s *PatrolScoreService) GetPatrolScores(ctx context.Context, user types.Use
 				CachedAt:       cached.CachedAt,
 				CacheExpiresAt: cached.ValidUntil,
 				RateLimitState: rateLimitState,
+				RateLimit:      blockedRateLimitInfo(cached.RateLimit, cacheUntil),
 				Settings:       settings,
 				WebSocket:      WebSocketInfo{Requested: true},
 			}, nil
@@ -148,40 +276,198 @@ func (s *PatrolScoreService) GetPatrolScores(ctx context.Context, user types.Use
 		return nil, fmt.Errorf("failed to fetch patrol scores: %w", err)
 	}
 
-	// Determine cache TTL based on current rate limiting state
-	rateLimitState := s.determineRateLimitState(rateLimitInfo.Remaining)
-	cacheTTL := s.calculateCacheTTL(rateLimitInfo.Remaining)
-
-	// Cache the results with two-tier strategy
-	// Caching is best effort
-	now := time.Now()
-	validUntil := now.Add(cacheTTL)
-	s.cachePatrolScores(ctx, device.DeviceCode, &CachedPatrolScores{
-		Patrols:        patrols,
-		CachedAt:       now,
-		ValidUntil:     validUntil,
-		RateLimitState: rateLimitState,
-	})
-
 	return &PatrolScoreResponse{
-		Patrols:        patrols,
+		Patrols:        fresh.Patrols,
 		FromCache:      false,
-		CachedAt:       now,
-		CacheExpiresAt: validUntil,
-		RateLimitState: rateLimitState,
+		CachedAt:       fresh.CachedAt,
+		CacheExpiresAt: fresh.ValidUntil,
+		RateLimitState: fresh.RateLimitState,
+		RateLimit:      fresh.RateLimit,
 		Settings:       settings,
 		WebSocket:      WebSocketInfo{Requested: true},
 	}, nil
 }
 
-// fetchDeviceSettings fetches user settings for the device's section.
+// termEnded reports whether cached holds the scores of a term that has ended.
+// Entries cached before scores recorded their term's end are never taken to
+// have ended.
+func termEnded(cached *CachedPatrolScores) bool {
+	return cached.TermID != 0 && !cached.TermEndDate.IsZero() && time.Now().After(cached.TermEndDate)
+}
+
+// archiveEndedTerm saves a section's final scores for a term that has ended:
+// cached's scores if they are for the term, otherwise the term's scores read
+// from OSM as user, unless the term is already archived.
+// Best effort: a failure is logged and returned, and the scores are served as usual.
+func (s *PatrolScoreService) archiveEndedTerm(ctx context.Context, user types.User, sectionID, termID int, cached *CachedPatrolScores) error {
+	scoresAt := time.Now()
+	var patrols []types.PatrolScore
+	if cached != nil && cached.TermID == termID {
+		scoresAt, patrols = cached.CachedAt, cached.Patrols
+	} else {
+		archived, err := scorearchive.Exists(s.conns, sectionID, termID)
+		if err == nil && !archived {
+			patrols, _, err = s.osmClient.FetchPatrolScores(ctx, user, sectionID, termID)
+		}
+		if err != nil {
+			slog.Error("patrol_score_service.archive_fetch_failed",
+				"component", "patrol_scores",
+				"event", "archive.error",
+				"section_id", sectionID,
+				"term_id", termID,
+				"error", err,
+			)
+			return err
+		}
+	}
+
+	saved, err := scorearchive.Save(s.conns, sectionID, termID, scoresAt, patrols)
+	if err != nil {
+		slog.Error("patrol_score_service.archive_failed",
+			"component", "patrol_scores",
+			"event", "archive.error",
+			"section_id", sectionID,
+			"term_id", termID,
+			"error", err,
+		)
+		return err
+	}
+	if saved {
+		slog.Info("patrol_score_service.term_archived",
+			"component", "patrol_scores",
+			"event", "archive.saved",
+			"section_id", sectionID,
+			"term_id", termID,
+			"patrol_count", len(patrols),
+		)
+	}
+	return nil
+}
+
+// isOSMBlockError reports whether err is OSM refusing requests for a while,
+// rather than refusing this user.
+func isOSMBlockError(err error) bool {
+	var blockedError *osm.ErrUserBlocked
+	return errors.As(err, &blockedError) || errors.Is(err, osm.ErrServiceBlocked)
+}
+
+// sectionReadersKey is the Redis set of users whose access to a section OSM
+// has confirmed, who may be served the section's shared cached scores.
+func sectionReadersKey(sectionID int) string {
+	return fmt.Sprintf("patrol_scores:section:%d:readers", sectionID)
+}
+
+// recordSectionReader notes that the device's user has been given the
+// section's term by OSM. The set lasts as long as the cached scores.
+// Best effort: a user not recorded is refused the cache and fetches instead.
+func (s *PatrolScoreService) recordSectionReader(ctx context.Context, sectionID int, device *db.DeviceCode) {
+	if device.OsmUserID == nil {
+		return
+	}
+	key := sectionReadersKey(sectionID)
+	if err := s.conns.Redis.SAdd(ctx, key, *device.OsmUserID).Err(); err != nil {
+		slog.Warn("patrol_score_service.reader_record_failed",
+			"component", "patrol_score_service",
+			"event", "readers.cache.error",
+			"section_id", sectionID,
+			"error", err,
+		)
+		return
+	}
+	s.conns.Redis.Expire(ctx, key, time.Duration(s.config.Cache.CacheFallbackTTL)*time.Second)
+}
+
+// isSectionReader reports whether the device's user is recorded by
+// recordSectionReader for the section.
+func (s *PatrolScoreService) isSectionReader(ctx context.Context, sectionID int, device *db.DeviceCode) bool {
+	if device.OsmUserID == nil {
+		return false
+	}
+	reader, err := s.conns.Redis.SIsMember(ctx, sectionReadersKey(sectionID), *device.OsmUserID).Result()
+	return err == nil && reader
+}
+
+// activeTerm returns the active term and its end date for one of the
+// device's sections. The device record holds the term for its primary section
+// only; other sections use the per-user term cache.
+func (s *PatrolScoreService) activeTerm(ctx context.Context, user types.User, device *db.DeviceCode, sectionID int) (int, time.Time, error) {
+	if device.SectionID != nil && *device.SectionID == sectionID {
+		termID, err := s.terms.GetActiveTerm(ctx, user, device)
+		if err != nil {
+			return 0, time.Time{}, err
+		}
+		var termEnd time.Time
+		if device.TermEndDate != nil {
+			termEnd = *device.TermEndDate
+		}
+		return termID, termEnd, nil
+	}
+	termInfo, err := s.terms.GetActiveTermForSection(ctx, user, sectionID)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return termInfo.TermID, termInfo.EndDate, nil
+}
+
+// fetchSectionScores fetches a section's patrol scores for a term ending at
+// termEnd from OSM and caches them, unless a later term's scores are already
+// cached. Concurrent calls for the same section and term wait for a single OSM
+// request, made with the first caller's credentials. The fetch is not
+// cancelled with the caller's context as other devices may be waiting on it.
+func (s *PatrolScoreService) fetchSectionScores(ctx context.Context, user types.User, sectionID, termID int, termEnd time.Time) (*CachedPatrolScores, error) {
+	ctx = context.WithoutCancel(ctx)
+	result, err, _ := sectionFetches.Do(fmt.Sprintf("%d:%d", sectionID, termID), func() (any, error) {
+		// Another device may have refreshed the cache since our check
+		cached, err := s.getCachedPatrolScores(ctx, sectionID)
+		if err == nil && cached.TermID == termID && time.Now().Before(cached.ValidUntil) {
+			return cached, nil
+		}
+
+		patrols, rateLimitInfo, err := s.osmClient.FetchPatrolScores(ctx, user, sectionID, termID)
+		if err != nil {
+			return nil, err
+		}
+
+		// Determine cache TTL based on current rate limiting state
+		now := time.Now()
+		fresh := &CachedPatrolScores{
+			Patrols:        patrols,
+			TermID:         termID,
+			TermEndDate:    termEnd,
+			CachedAt:       now,
+			ValidUntil:     now.Add(s.calculateCacheTTL(rateLimitInfo.Remaining, rateLimitInfo.ResetsAt)),
+			RateLimitState: s.determineRateLimitState(rateLimitInfo.Remaining),
+			RateLimit:      newRateLimitInfo(rateLimitInfo),
+		}
+
+		// Cache the results with two-tier strategy
+		// Caching is best effort
+		if cached == nil || !cached.TermEndDate.After(termEnd) {
+			s.cachePatrolScores(ctx, sectionID, fresh)
+		}
+		return fresh, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*CachedPatrolScores), nil
+}
+
+// deviceSettingsFallbackTTL is how long last-known settings are kept to cover
+// brief database outages.
+const deviceSettingsFallbackTTL = 10 * time.Minute
+
+// fetchDeviceSettings fetches user settings for one of the device's sections.
 // Returns nil if settings cannot be fetched (best effort - never fails the request).
-func (s *PatrolScoreService) fetchDeviceSettings(device *db.DeviceCode) *types.DeviceSettings {
-	if device.OsmUserID == nil || device.SectionID == nil {
+// If the database read fails, the last settings successfully read are served from
+// Redis so patrol colours don't flicker off during a short outage.
+func (s *PatrolScoreService) fetchDeviceSettings(ctx context.Context, device *db.DeviceCode, sectionID int) *types.DeviceSettings {
+	if device.OsmUserID == nil {
 		return nil
 	}
+	cacheKey := fmt.Sprintf("device_settings:%d:%d", *device.OsmUserID, sectionID)
 
-	settings, err := sectionsettings.GetParsed(s.conns, *device.OsmUserID, *device.SectionID)
+	settings, err := sectionsettings.GetParsed(s.conns, *device.OsmUserID, sectionID)
 	if err != nil {
 		slog.Error("patrol_score_service.settings_fetch_failed",
 			"component", "patrol_score_service",
@@ -189,17 +475,54 @@ func (s *PatrolScoreService) fetchDeviceSettings(device *db.DeviceCode) *types.D
 			"device_code_hash", device.DeviceCode[:8],
 			"error", err,
 		)
-		return nil
+		return s.getFallbackDeviceSettings(ctx, cacheKey)
 	}
 
+	var deviceSettings *types.DeviceSettings
 	// Only return settings if there's actual content
-	if len(settings.PatrolColors) == 0 {
-		return nil
+	if len(settings.PatrolColors) > 0 || len(settings.PatrolIcons) > 0 || settings.Layout != "" || settings.HideBelowScore != nil {
+		deviceSettings = &types.DeviceSettings{
+			Layout:         settings.Layout,
+			HideBelowScore: settings.HideBelowScore,
+		}
+		if len(settings.PatrolColors) > 0 {
+			deviceSettings.PatrolColors = settings.PatrolColors
+		}
+		if len(settings.PatrolIcons) > 0 {
+			deviceSettings.PatrolIcons = settings.PatrolIcons
+		}
 	}
 
-	return &types.DeviceSettings{
-		PatrolColors: settings.PatrolColors,
+	if data, err := json.Marshal(deviceSettings); err == nil {
+		if err := s.conns.Redis.Set(ctx, cacheKey, data, deviceSettingsFallbackTTL).Err(); err != nil {
+			slog.Warn("patrol_score_service.settings_cache_failed",
+				"component", "patrol_score_service",
+				"event", "settings.cache.error",
+				"error", err,
+			)
+		}
 	}
+	return deviceSettings
+}
+
+// getFallbackDeviceSettings returns the last settings cached by fetchDeviceSettings,
+// or nil if there are none.
+func (s *PatrolScoreService) getFallbackDeviceSettings(ctx context.Context, cacheKey string) *types.DeviceSettings {
+	data, err := s.conns.Redis.Get(ctx, cacheKey).Result()
+	if err != nil {
+		return nil
+	}
+	var deviceSettings *types.DeviceSettings
+	if json.Unmarshal([]byte(data), &deviceSettings) != nil {
+		return nil
+	}
+	if deviceSettings != nil {
+		slog.Info("patrol_score_service.settings_served_stale",
+			"component", "patrol_score_service",
+			"event", "settings.fetch.fallback",
+		)
+	}
+	return deviceSettings
 }
 
 // getAdhocPatrolScores returns patrol scores from the local ad-hoc patrols table.
@@ -279,65 +602,38 @@ func (s *PatrolScoreService) getAdhocPatrolScores(ctx context.Context, device *d
 	}, nil
 }
 
-// ensureTermInfo ensures that the device has valid term information.
-// It refreshes the term if needed (24 hours old or expired).
-// TODO: Move this into a separate Term Service. We're going to have to fix dependency injection. I think we'll
-// need to borrow GPS-Game's Command Pattern
-func (s *PatrolScoreService) ensureTermInfo(ctx context.Context, user types.User, device *db.DeviceCode) (int, error) {
-	now := time.Now()
-
-	// Check if we need to refresh term information
-	needsRefresh := device.TermID == nil ||
-		device.TermCheckedAt == nil ||
-		device.TermEndDate == nil ||
-		now.After(device.TermCheckedAt.Add(24*time.Hour)) ||
-		now.After(*device.TermEndDate)
-
-	if !needsRefresh {
-		return *device.TermID, nil
-	}
-
-	// Fetch fresh term information
-	termInfo, err := s.osmClient.FetchActiveTermForSection(ctx, user, *device.SectionID)
-	if err != nil {
-		return 0, err
-	}
-
-	// Update device with new term information
-	now = time.Now()
-	if err := devicecode.UpdateTermInfo(s.conns, device.DeviceCode, termInfo.UserID, termInfo.TermID, now, termInfo.EndDate); err != nil {
-		slog.Error("patrol_score_service.term_update_failed",
-			"component", "patrol_score_service",
-			"event", "term.update.error",
-			"device_code_hash", device.DeviceCode[:8],
-			"error", err,
-		)
-		// Continue anyway - we have the term ID
-	}
-
-	return termInfo.TermID, nil
-}
-
 // calculateCacheTTL calculates the cache TTL based on absolute rate limit remaining count.
-// Uses adaptive caching strategy with absolute thresholds:
+// The configured tiers (config.DefaultCacheTTLTiers unless CACHE_TTL_TIERS is set)
+// cache for longer as the remaining count falls. By default:
 // - > 500 remaining: 1 minute (fresh data when capacity available)
 // - 200-500: 5 minutes (baseline)
 // - 100-200: 10 minutes (starting to conserve)
 // - 50-100: 15 minutes (more conservative)
 // - < 50: 30 minutes (very conservative)
-func (s *PatrolScoreService) calculateCacheTTL(remaining int) time.Duration {
-	switch {
-	case remaining > 500:
-		return 1 * time.Minute
-	case remaining >= 200:
-		return 5 * time.Minute
-	case remaining >= 100:
-		return 10 * time.Minute
-	case remaining >= 50:
-		return 15 * time.Minute
-	default:
-		return 30 * time.Minute
+//
+// If the rate limit window resets before that TTL would expire, the TTL is cut to
+// the reset (but not below the first tier's fresh-data TTL) so the new budget is
+// used promptly. A zero resetsAt means OSM did not report a reset time.
+func (s *PatrolScoreService) calculateCacheTTL(remaining int, resetsAt time.Time) time.Duration {
+	tiers := config.DefaultCacheTTLTiers
+	if s.config != nil && len(s.config.Cache.TTLTiers) > 0 {
+		tiers = s.config.Cache.TTLTiers
+	}
+
+	// Tiers end at 0 remaining; a negative count falls through to the last.
+	ttl := tiers[len(tiers)-1].TTL
+	for _, tier := range tiers {
+		if remaining >= tier.MinRemaining {
+			ttl = tier.TTL
+			break
+		}
 	}
+
+	if !resetsAt.IsZero() {
+		untilReset := max(time.Until(resetsAt), tiers[0].TTL)
+		ttl = min(ttl, untilReset)
+	}
+	return ttl
 }
 
 // determineRateLimitState determines the rate limit state based on remaining requests.
@@ -349,11 +645,17 @@ func (s *PatrolScoreService) determineRateLimitState(remaining int) RateLimitSta
 	return RateLimitStateDegraded
 }
 
-// getCachedPatrolScores retrieves patrol scores from cache
-func (s *PatrolScoreService) getCachedPatrolScores(ctx context.Context, deviceCode string) (*CachedPatrolScores, error) {
+// getCachedPatrolScores retrieves a section's patrol scores from cache
+func (s *PatrolScoreService) getCachedPatrolScores(ctx context.Context, sectionID int) (*CachedPatrolScores, error) {
+	return GetCachedPatrolScores(ctx, s.conns, sectionID)
+}
+
+// GetCachedPatrolScores retrieves a section's patrol scores from cache without
+// checking who may read them. Callers must have checked the user's access to
+// the section.
+func GetCachedPatrolScores(ctx context.Context, conns *db.Connections, sectionID int) (*CachedPatrolScores, error) {
 	// TODO: This needs to be a store method
-	key := fmt.Sprintf("patrol_scores:%s", deviceCode)
-	data, err := s.conns.Redis.Get(ctx, key).Result()
+	data, err := conns.Redis.Get(ctx, PatrolScoresCacheKey(sectionID)).Result()
 	if err != nil {
 		if err == redis.Nil {
 			return nil, fmt.Errorf("cache miss")
@@ -373,7 +675,7 @@ func (s *PatrolScoreService) getCachedPatrolScores(ctx context.Context, deviceCo
 // This is a best effort. Errors are logged but not returned as loss of cache is not fatal.
 func (s *PatrolScoreService) cachePatrolScores(
 	ctx context.Context,
-	deviceCode string,
+	sectionID int,
 	cacheRecord *CachedPatrolScores,
 ) {
 	data, err := json.Marshal(cacheRecord)
@@ -381,7 +683,7 @@ func (s *PatrolScoreService) cachePatrolScores(
 		slog.Error("patrol_score_service.cachePatrolScores", "message", "cannot marshal cache record", "error", err)
 	}
 
-	key := fmt.Sprintf("patrol_scores:%s", deviceCode)
+	key := PatrolScoresCacheKey(sectionID)
 	// Use fallback TTL for Redis (8 days) to keep stale data for emergency use
 	// TODO: Configure this as a Duration
 	fallbackTTL := time.Duration(s.config.Cache.CacheFallbackTTL) * time.Second
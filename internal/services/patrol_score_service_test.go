@@ -6,12 +6,15 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/alicebob/miniredis/v2"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/config"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/db"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db/scorearchive"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/db/sectionsettings"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/osm"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/types"
@@ -36,6 +39,8 @@ type testHarness struct {
 	mr        *miniredis.Miniredis
 	device    *db.DeviceCode
 	user      types.User
+	// patrolFetches counts patrol score requests made to the mock OSM server
+	patrolFetches *atomic.Int32
 }
 
 const (
@@ -53,6 +58,7 @@ func newTestHarness(t *testing.T, patrolMap map[string]osm.PatrolData) *testHarn
 
 	// ---------- mock OSM HTTP server ----------
 	now := time.Now()
+	patrolFetches := &atomic.Int32{}
 	osmServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		// Rate-limit headers expected by the client
@@ -86,6 +92,7 @@ func newTestHarness(t *testing.T, patrolMap map[string]osm.PatrolData) *testHarn
 			json.NewEncoder(w).Encode(resp)
 
 		case "/ext/members/patrols/":
+			patrolFetches.Add(1)
 			json.NewEncoder(w).Encode(patrolMap)
 
 		default:
@@ -139,12 +146,13 @@ func newTestHarness(t *testing.T, patrolMap map[string]osm.PatrolData) *testHarn
 	user := types.NewUser(&userID, testToken)
 
 	return &testHarness{
-		conns:     conns,
-		osmServer: osmServer,
-		service:   svc,
-		mr:        mr,
-		device:    device,
-		user:      user,
+		conns:         conns,
+		osmServer:     osmServer,
+		service:       svc,
+		mr:            mr,
+		device:        device,
+		user:          user,
+		patrolFetches: patrolFetches,
 	}
 }
 
@@ -288,6 +296,7 @@ func TestGetPatrolScores_SettingsReturnedOnCachedScoreResponse(t *testing.T) {
 			{ID: "1", Name: "Eagles", Score: 45},
 			{ID: "2", Name: "Hawks", Score: 30},
 		},
+		TermID:         testTermID,
 		CachedAt:       time.Now(),
 		ValidUntil:     time.Now().Add(10 * time.Minute),
 		RateLimitState: RateLimitStateNone,
@@ -296,7 +305,7 @@ func TestGetPatrolScores_SettingsReturnedOnCachedScoreResponse(t *testing.T) {
 	if err != nil {
 		t.Fatalf("failed to marshal cache data: %v", err)
 	}
-	cacheKey := fmt.Sprintf("patrol_scores:%s", testDevCode)
+	cacheKey := PatrolScoresCacheKey(testSectionID)
 	if err := h.conns.Redis.Set(context.Background(), cacheKey, cacheData, 10*time.Minute).Err(); err != nil {
 		t.Fatalf("failed to pre-populate redis cache: %v", err)
 	}
@@ -332,3 +341,452 @@ func TestGetPatrolScores_SettingsReturnedOnCachedScoreResponse(t *testing.T) {
 		t.Errorf("expected patrol 2 color 'blue', got %q", resp.Settings.PatrolColors["2"])
 	}
 }
+
+func TestGetPatrolScores_LayoutIncludedInResponse(t *testing.T) {
+	h := newTestHarness(t, samplePatrolMap())
+	defer h.osmServer.Close()
+
+	// Layout alone (no colors) must still produce settings
+	if err := sectionsettings.UpsertLayout(h.conns, testUserID, testSectionID, types.LayoutPortrait); err != nil {
+		t.Fatalf("failed to upsert layout: %v", err)
+	}
+
+	resp, err := h.service.GetPatrolScores(context.Background(), h.user, h.device)
+	if err != nil {
+		t.Fatalf("GetPatrolScores failed: %v", err)
+	}
+
+	if resp.Settings == nil {
+		t.Fatal("expected Settings to be non-nil when a layout is configured")
+	}
+	if resp.Settings.Layout != types.LayoutPortrait {
+		t.Errorf("expected layout %q, got %q", types.LayoutPortrait, resp.Settings.Layout)
+	}
+	if resp.Settings.PatrolColors != nil {
+		t.Errorf("expected no patrol colors, got %v", resp.Settings.PatrolColors)
+	}
+
+	// Check the wire format the device sees
+	body, err := json.Marshal(resp)
+	if err != nil {
+		t.Fatalf("failed to marshal response: %v", err)
+	}
+	var wire struct {
+		Settings map[string]any `json:"settings"`
+	}
+	if err := json.Unmarshal(body, &wire); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if wire.Settings["layout"] != "portrait" {
+		t.Errorf("expected settings.layout=portrait in JSON, got %v", wire.Settings["layout"])
+	}
+}
+
+func TestCalculateCacheTTL_UsesRateLimitReset(t *testing.T) {
+	s := &PatrolScoreService{}
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		remaining int
+		resetsAt  time.Time
+		want      time.Duration
+		tolerance time.Duration
+	}{
+		{"no reset reported uses remaining only", 10, time.Time{}, 30 * time.Minute, 0},
+		{"imminent reset shortens low-budget TTL", 10, now.Add(3 * time.Minute), 3 * time.Minute, time.Second},
+		{"reset within a minute still caches a minute", 10, now.Add(10 * time.Second), 1 * time.Minute, 0},
+		{"distant reset keeps remaining-only TTL", 10, now.Add(2 * time.Hour), 30 * time.Minute, 0},
+		{"reset never lengthens a short TTL", 600, now.Add(10 * time.Minute), 1 * time.Minute, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := s.calculateCacheTTL(tt.remaining, tt.resetsAt)
+			if diff := got - tt.want; diff < -tt.tolerance || diff > tt.tolerance {
+				t.Errorf("calculateCacheTTL(%d, %v) = %v, want %v", tt.remaining, tt.resetsAt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetPatrolScores_CachedSettingsServedWhenDBFails(t *testing.T) {
+	h := newTestHarness(t, samplePatrolMap())
+	defer h.osmServer.Close()
+
+	colors := map[string]string{"1": "red", "2": "blue"}
+	if err := sectionsettings.UpsertPatrolColors(h.conns, testUserID, testSectionID, colors); err != nil {
+		t.Fatalf("failed to upsert patrol colors: %v", err)
+	}
+
+	// First call reads settings from the database and remembers them
+	if _, err := h.service.GetPatrolScores(context.Background(), h.user, h.device); err != nil {
+		t.Fatalf("first GetPatrolScores failed: %v", err)
+	}
+
+	// Simulate a database failure
+	if err := h.conns.DB.Migrator().DropTable(&db.SectionSettings{}); err != nil {
+		t.Fatalf("failed to drop section settings table: %v", err)
+	}
+
+	resp, err := h.service.GetPatrolScores(context.Background(), h.user, h.device)
+	if err != nil {
+		t.Fatalf("GetPatrolScores failed: %v", err)
+	}
+	if resp.Settings == nil {
+		t.Fatal("expected last-known settings while the database is failing")
+	}
+	for id, wantColor := range colors {
+		if got := resp.Settings.PatrolColors[id]; got != wantColor {
+			t.Errorf("patrol %s: want color %q, got %q", id, wantColor, got)
+		}
+	}
+}
+
+func TestGetPatrolScores_DevicesInSectionShareOneFetch(t *testing.T) {
+	h := newTestHarness(t, samplePatrolMap())
+	defer h.osmServer.Close()
+
+	// Ten devices showing the same section, with current term information so
+	// only the patrol scores need fetching
+	now := time.Now()
+	termID := testTermID
+	termEnd := now.AddDate(0, 1, 0)
+	devices := make([]*db.DeviceCode, 10)
+	for i := range devices {
+		device := *h.device
+		device.DeviceCode = fmt.Sprintf("%s-%02d", testDevCode, i)
+		device.TermID = &termID
+		device.TermCheckedAt = &now
+		device.TermEndDate = &termEnd
+		devices[i] = &device
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(devices))
+	for _, device := range devices {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := h.service.GetPatrolScores(context.Background(), h.user, device)
+			if err == nil && len(resp.Patrols) != 3 {
+				err = fmt.Errorf("device %s: expected 3 patrols, got %d", device.DeviceCode, len(resp.Patrols))
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if got := h.patrolFetches.Load(); got != 1 {
+		t.Errorf("expected exactly one OSM patrol fetch, got %d", got)
+	}
+}
+
+func TestCalculateCacheTTL_UsesConfiguredTiers(t *testing.T) {
+	s := &PatrolScoreService{config: &config.Config{Cache: config.CacheConfig{
+		TTLTiers: []config.CacheTTLTier{
+			{MinRemaining: 1000, TTL: 2 * time.Minute},
+			{MinRemaining: 0, TTL: time.Hour},
+		},
+	}}}
+
+	if got := s.calculateCacheTTL(1500, time.Time{}); got != 2*time.Minute {
+		t.Errorf("expected the first tier above 1000 remaining, got %v", got)
+	}
+	if got := s.calculateCacheTTL(600, time.Time{}); got != time.Hour {
+		t.Errorf("expected the last tier below 1000 remaining, got %v", got)
+	}
+	if got := s.calculateCacheTTL(600, time.Now().Add(10*time.Second)); got != 2*time.Minute {
+		t.Errorf("expected an imminent reset to cut to the first tier's TTL, got %v", got)
+	}
+}
+
+// blockedUserStore reports the user as blocked by OSM until blockedUntil.
+type blockedUserStore struct {
+	mockStore
+	blockedUntil time.Time
+}
+
+func (s *blockedUserStore) GetUserBlockEndTime(ctx context.Context, userId int) time.Time {
+	return s.blockedUntil
+}
+
+// cacheScores stores scores for the test section as a previous fetch by the
+// test user would.
+func cacheScores(t *testing.T, h *testHarness, cached *CachedPatrolScores) {
+	t.Helper()
+	cacheSectionScores(t, h, testSectionID, cached)
+	if err := h.conns.Redis.SAdd(context.Background(), sectionReadersKey(testSectionID), testUserID).Err(); err != nil {
+		t.Fatalf("failed to record the test user as a reader: %v", err)
+	}
+}
+
+// cacheSectionScores stores scores for a section without recording who may read them.
+func cacheSectionScores(t *testing.T, h *testHarness, sectionID int, cached *CachedPatrolScores) {
+	t.Helper()
+	cacheData, err := json.Marshal(cached)
+	if err != nil {
+		t.Fatalf("failed to marshal cache data: %v", err)
+	}
+	if err := h.conns.Redis.Set(context.Background(), PatrolScoresCacheKey(sectionID), cacheData, time.Hour).Err(); err != nil {
+		t.Fatalf("failed to pre-populate redis cache: %v", err)
+	}
+}
+
+func TestGetPatrolScores_RateLimitOnFreshResponse(t *testing.T) {
+	h := newTestHarness(t, samplePatrolMap())
+	defer h.osmServer.Close()
+
+	resp, err := h.service.GetPatrolScores(context.Background(), h.user, h.device)
+	if err != nil {
+		t.Fatalf("GetPatrolScores failed: %v", err)
+	}
+	if resp.FromCache {
+		t.Fatal("expected a fresh response")
+	}
+	if resp.RateLimit == nil {
+		t.Fatal("expected rate limit details on a fresh response")
+	}
+	if resp.RateLimit.Remaining != 500 || resp.RateLimit.Limit != 1000 {
+		t.Errorf("expected 500 of 1000 remaining, got %d of %d", resp.RateLimit.Remaining, resp.RateLimit.Limit)
+	}
+	if resp.RateLimit.ResetAt == nil || time.Until(*resp.RateLimit.ResetAt) > time.Minute {
+		t.Errorf("expected the window to reset within a minute, got %v", resp.RateLimit.ResetAt)
+	}
+	if resp.RateLimit.RetryAfterSeconds != 0 {
+		t.Errorf("expected no retry-after while not blocked, got %d", resp.RateLimit.RetryAfterSeconds)
+	}
+}
+
+func TestGetPatrolScores_RateLimitOnCachedResponse(t *testing.T) {
+	h := newTestHarness(t, samplePatrolMap())
+	defer h.osmServer.Close()
+
+	resetAt := time.Now().Add(20 * time.Minute).UTC().Truncate(time.Second)
+	cacheScores(t, h, &CachedPatrolScores{
+		Patrols:        []types.PatrolScore{{ID: "1", Name: "Eagles", Score: 45}},
+		TermID:         testTermID,
+		CachedAt:       time.Now(),
+		ValidUntil:     time.Now().Add(10 * time.Minute),
+		RateLimitState: RateLimitStateDegraded,
+		RateLimit:      &RateLimitInfo{Remaining: 150, Limit: 1000, ResetAt: &resetAt},
+	})
+
+	resp, err := h.service.GetPatrolScores(context.Background(), h.user, h.device)
+	if err != nil {
+		t.Fatalf("GetPatrolScores failed: %v", err)
+	}
+	if !resp.FromCache {
+		t.Fatal("expected response to be from cache")
+	}
+	if resp.RateLimitState != RateLimitStateDegraded {
+		t.Errorf("expected the cached state DEGRADED, got %s", resp.RateLimitState)
+	}
+	if resp.RateLimit == nil || resp.RateLimit.Remaining != 150 || resp.RateLimit.Limit != 1000 ||
+		resp.RateLimit.ResetAt == nil || !resp.RateLimit.ResetAt.Equal(resetAt) {
+		t.Errorf("expected the cached rate limit 150 of 1000 resetting at %v, got %+v", resetAt, resp.RateLimit)
+	}
+}
+
+func TestGetPatrolScores_RateLimitOnBlockedResponse(t *testing.T) {
+	h := newTestHarness(t, samplePatrolMap())
+	defer h.osmServer.Close()
+
+	blockedUntil := time.Now().Add(30 * time.Minute)
+	store := &blockedUserStore{blockedUntil: blockedUntil}
+	h.service = NewPatrolScoreService(osm.NewClient(h.osmServer.URL, store, store), h.conns, h.service.config)
+
+	// The cached scores have expired, so the service has to ask OSM
+	cacheScores(t, h, &CachedPatrolScores{
+		Patrols:        []types.PatrolScore{{ID: "1", Name: "Eagles", Score: 45}},
+		TermID:         testTermID,
+		CachedAt:       time.Now().Add(-time.Hour),
+		ValidUntil:     time.Now().Add(-time.Minute),
+		RateLimitState: RateLimitStateDegraded,
+		RateLimit:      &RateLimitInfo{Remaining: 3, Limit: 1000},
+	})
+
+	resp, err := h.service.GetPatrolScores(context.Background(), h.user, h.device)
+	if err != nil {
+		t.Fatalf("GetPatrolScores failed: %v", err)
+	}
+	if resp.RateLimitState != RateLimitStateUserTemporaryBlock {
+		t.Errorf("expected USER_TEMPORARY_BLOCK, got %s", resp.RateLimitState)
+	}
+	if resp.RateLimit == nil {
+		t.Fatal("expected rate limit details on a blocked response")
+	}
+	if resp.RateLimit.Remaining != 0 || resp.RateLimit.Limit != 1000 {
+		t.Errorf("expected 0 of 1000 remaining, got %d of %d", resp.RateLimit.Remaining, resp.RateLimit.Limit)
+	}
+	if resp.RateLimit.RetryAfterSeconds < 29*60 || resp.RateLimit.RetryAfterSeconds > 30*60 {
+		t.Errorf("expected to retry in about 30 minutes, got %ds", resp.RateLimit.RetryAfterSeconds)
+	}
+	if resp.RateLimit.ResetAt == nil || !resp.RateLimit.ResetAt.Equal(blockedUntil) {
+		t.Errorf("expected the block to end at %v, got %v", blockedUntil, resp.RateLimit.ResetAt)
+	}
+}
+
+func TestGetPatrolScores_TermEndArchivesFinalScores(t *testing.T) {
+	h := newTestHarness(t, samplePatrolMap())
+	defer h.osmServer.Close()
+
+	// The device last saw term 998, which ended yesterday
+	const endedTermID = 998
+	endedTerm, checkedAt, endedAt := endedTermID, time.Now().Add(-time.Hour), time.Now().Add(-24*time.Hour)
+	h.device.TermID, h.device.TermCheckedAt, h.device.TermEndDate = &endedTerm, &checkedAt, &endedAt
+	finalAt := time.Now().Add(-25 * time.Hour).UTC().Truncate(time.Second)
+	final := []types.PatrolScore{{ID: "1", Name: "Eagles", Score: 120}, {ID: "2", Name: "Hawks", Score: 95}}
+	cacheScores(t, h, &CachedPatrolScores{
+		Patrols:     final,
+		TermID:      endedTermID,
+		TermEndDate: endedAt,
+		CachedAt:    finalAt,
+		ValidUntil:  time.Now().Add(10 * time.Minute),
+	})
+	// An archive of earlier scores, as an early check could have left, is replaced
+	if _, err := scorearchive.Save(h.conns, testSectionID, endedTermID, finalAt.Add(-48*time.Hour), final[:1]); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	resp, err := h.service.GetPatrolScores(context.Background(), h.user, h.device)
+	if err != nil {
+		t.Fatalf("GetPatrolScores failed: %v", err)
+	}
+	if resp.FromCache || len(resp.Patrols) != 3 {
+		t.Errorf("expected fresh scores for the new term, got %+v", resp)
+	}
+
+	archived, err := scorearchive.ListBySection(h.conns, testSectionID)
+	if err != nil {
+		t.Fatalf("ListBySection failed: %v", err)
+	}
+	if len(archived) != 1 || archived[0].TermID != endedTermID {
+		t.Fatalf("expected one archive for term %d, got %+v", endedTermID, archived)
+	}
+	if !archived[0].ScoresAt.Equal(finalAt) {
+		t.Errorf("expected the scores to be dated %v, got %v", finalAt, archived[0].ScoresAt)
+	}
+	if len(archived[0].Patrols) != 2 || archived[0].Patrols[0] != final[0] || archived[0].Patrols[1] != final[1] {
+		t.Errorf("expected the final scores %+v, got %+v", final, archived[0].Patrols)
+	}
+
+	// Later requests, now in the new term, archive nothing more
+	if _, err := h.service.GetPatrolScores(context.Background(), h.user, h.device); err != nil {
+		t.Fatalf("GetPatrolScores failed: %v", err)
+	}
+	if archived, _ := scorearchive.ListBySection(h.conns, testSectionID); len(archived) != 1 {
+		t.Errorf("expected still one archive, got %d", len(archived))
+	}
+}
+
+func TestGetPatrolScores_CacheNotServedWithoutSectionAccess(t *testing.T) {
+	h := newTestHarness(t, samplePatrolMap())
+	defer h.osmServer.Close()
+
+	// Another user's device cached scores for a section the test user does
+	// not hold in OSM
+	const otherSectionID = 54321
+	cacheSectionScores(t, h, otherSectionID, &CachedPatrolScores{
+		Patrols:    []types.PatrolScore{{ID: "1", Name: "Secret Patrol", Score: 99}},
+		TermID:     testTermID,
+		CachedAt:   time.Now(),
+		ValidUntil: time.Now().Add(10 * time.Minute),
+	})
+	sectionID := otherSectionID
+	device := *h.device
+	device.SectionID = &sectionID
+
+	if resp, err := h.service.GetPatrolScores(context.Background(), h.user, &device); err == nil {
+		t.Errorf("expected an error for a section the user cannot read, got %+v", resp.Patrols)
+	}
+
+	// Nor while OSM is refusing the user, who has never been seen to read it
+	store := &blockedUserStore{blockedUntil: time.Now().Add(30 * time.Minute)}
+	h.service = NewPatrolScoreService(osm.NewClient(h.osmServer.URL, store, store), h.conns, h.service.config)
+	if resp, err := h.service.GetPatrolScores(context.Background(), h.user, &device); err == nil {
+		t.Errorf("expected an error for a blocked user not known to read the section, got %+v", resp.Patrols)
+	}
+}
+
+func TestGetPatrolScores_DeviceOnEarlierTermFollowsNewerCachedTerm(t *testing.T) {
+	h := newTestHarness(t, samplePatrolMap())
+	defer h.osmServer.Close()
+
+	// Both terms are current as far as each device knows: one device has
+	// seen the new term, the other checked before it started
+	now := time.Now()
+	newTerm, newEnd := testTermID, now.AddDate(0, 3, 0)
+	oldTerm, oldEnd := testTermID-1, now.Add(24*time.Hour)
+	onNew, onOld := *h.device, *h.device
+	onNew.TermID, onNew.TermCheckedAt, onNew.TermEndDate = &newTerm, &now, &newEnd
+	onOld.TermID, onOld.TermCheckedAt, onOld.TermEndDate = &oldTerm, &now, &oldEnd
+
+	for i, device := range []*db.DeviceCode{&onNew, &onOld, &onNew, &onOld} {
+		if _, err := h.service.GetPatrolScores(context.Background(), h.user, device); err != nil {
+			t.Fatalf("request %d: GetPatrolScores failed: %v", i, err)
+		}
+		cached, err := h.service.getCachedPatrolScores(context.Background(), testSectionID)
+		if err != nil {
+			t.Fatalf("request %d: expected cached scores: %v", i, err)
+		}
+		if cached.TermID != newTerm {
+			t.Errorf("request %d: expected the newer term %d to stay cached, got %d", i, newTerm, cached.TermID)
+		}
+
+		// Expire the scores so the next device fetches them
+		cached.ValidUntil = time.Now().Add(-time.Second)
+		cacheScores(t, h, cached)
+	}
+	if got := h.patrolFetches.Load(); got != 4 {
+		t.Errorf("expected each request to fetch, got %d fetches", got)
+	}
+
+	if archived, _ := scorearchive.ListBySection(h.conns, testSectionID); len(archived) != 0 {
+		t.Errorf("expected no term archived while neither has ended, got %+v", archived)
+	}
+}
+
+func TestTermArchiver_ArchivesTermEndedUnseen(t *testing.T) {
+	h := newTestHarness(t, samplePatrolMap())
+	defer h.osmServer.Close()
+
+	// The device's term ended yesterday and it has not fetched scores since,
+	// nor are the scores cached any more
+	endedTerm, checkedAt, endedAt := testTermID-1, time.Now().Add(-48*time.Hour), time.Now().Add(-24*time.Hour)
+	if err := h.conns.DB.Model(h.device).Updates(map[string]any{
+		"term_id": endedTerm, "term_checked_at": checkedAt, "term_end_date": endedAt,
+	}).Error; err != nil {
+		t.Fatalf("failed to set the device's term: %v", err)
+	}
+
+	archiver := &TermArchiver{
+		scores:  h.service,
+		refresh: func(*db.DeviceCode) types.TokenRefreshFunc { return nil },
+	}
+	if err := archiver.ArchiveEndedTerms(context.Background()); err != nil {
+		t.Fatalf("ArchiveEndedTerms failed: %v", err)
+	}
+
+	archived, err := scorearchive.ListBySection(h.conns, testSectionID)
+	if err != nil {
+		t.Fatalf("ListBySection failed: %v", err)
+	}
+	if len(archived) != 1 || archived[0].TermID != endedTerm || len(archived[0].Patrols) != 3 {
+		t.Fatalf("expected term %d archived with 3 patrols from OSM, got %+v", endedTerm, archived)
+	}
+
+	// Later passes leave the archived term alone
+	if err := archiver.ArchiveEndedTerms(context.Background()); err != nil {
+		t.Fatalf("ArchiveEndedTerms failed: %v", err)
+	}
+	if got := h.patrolFetches.Load(); got != 1 {
+		t.Errorf("expected one OSM patrol fetch, got %d", got)
+	}
+}
@@ -3,25 +3,63 @@ package scoreupdateservice
 import (
 	"context"
 	"errors"
+	"log/slog"
 	"time"
 
 	"github.com/m0rjc/OsmDeviceAdapter/internal/db"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/metrics"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/osm"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/services"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/types"
 )
 
+// DefaultUpdateTimeout bounds how long an interactive UpdateScores call may spend
+// talking to OSM. When it expires, in-flight OSM requests are cancelled rather than
+// left running after the caller has given up.
+const DefaultUpdateTimeout = 30 * time.Second
+
+// retryBudgetWindow is the window over which the global OSM retry budget is counted.
+const retryBudgetWindow = time.Minute
+
 type ScoreUpdateService struct {
 	osmClient *osm.Client
 	conns     *db.Connections
+	timeout   time.Duration
+	// osmSlots is a semaphore capping concurrent OSM patrol score updates across
+	// all callers, so a burst of large batches cannot exhaust the OSM rate limit.
+	osmSlots chan struct{}
+	// retryBudget is the number of failed OSM writes that may be retried per
+	// retryBudgetWindow across all users, so an OSM incident is not multiplied by
+	// every caller retrying at once.
+	retryBudget int
+	retryDelay  time.Duration
 }
 
-func New(osmClient *osm.Client, conns *db.Connections) *ScoreUpdateService {
-	return &ScoreUpdateService{osmClient: osmClient, conns: conns}
+// New creates a ScoreUpdateService. maxConcurrentUpdates caps the number of OSM
+// patrol score update calls in flight at once; values below 1 are treated as 1.
+// retryBudget caps retries of failed OSM writes per minute across all users;
+// zero disables retries.
+func New(osmClient *osm.Client, conns *db.Connections, maxConcurrentUpdates int, retryBudget int) *ScoreUpdateService {
+	if maxConcurrentUpdates < 1 {
+		maxConcurrentUpdates = 1
+	}
+	return &ScoreUpdateService{
+		osmClient:   osmClient,
+		conns:       conns,
+		timeout:     DefaultUpdateTimeout,
+		osmSlots:    make(chan struct{}, maxConcurrentUpdates),
+		retryBudget: retryBudget,
+		retryDelay:  time.Second,
+	}
 }
 
 type UpdateRequest struct {
 	PatrolID string
 	Delta    int
+	// MinScore, when set, is a floor the update may not take the patrol below.
+	// It applies to the coalesced delta, and a score already under the floor
+	// is not raised.
+	MinScore *int
 }
 
 type UpdateResponse struct {
@@ -33,9 +71,41 @@ type UpdateResponse struct {
 	ErrorMessage     *string
 	PreviousScore    *int
 	NewScore         *int
+	// Pending is set when the update timed out before OSM confirmed it. The write
+	// may or may not have been applied, so callers should re-read scores before
+	// resubmitting rather than replaying the delta.
+	Pending bool
 }
 
+// UpdateScores applies score deltas to patrols in OSM. Several requests for the
+// same patrol are coalesced into one OSM write, so the result has one entry per
+// distinct patrol in order of first appearance.
 func (srv *ScoreUpdateService) UpdateScores(ctx context.Context, user types.User, sectionId int, requests []UpdateRequest) ([]UpdateResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, srv.timeout)
+	defer cancel()
+
+	entries := len(requests)
+	requests = coalesceRequests(requests)
+	if len(requests) > 0 {
+		metrics.ScoreUpdateCoalesceRatio.Observe(float64(entries) / float64(len(requests)))
+	}
+
+	// While OSM has blocked the service, don't claim any patrols or send writes that
+	// would only re-trigger the block. Callers retry once the cooldown ends.
+	// The patrols are described from the section's cached scores, if any, as
+	// OSM cannot be asked.
+	if blocked, blockedUntil := srv.conns.Redis.GetOsmServiceBlockEndTime(ctx); blocked {
+		var currentScores []types.PatrolScore
+		if cached, err := services.GetCachedPatrolScores(ctx, srv.conns, sectionId); err == nil {
+			currentScores = cached.Patrols
+		}
+		results := make([]UpdateResponse, len(requests))
+		for i := range requests {
+			results[i] = newServiceBlockedResponse(&requests[i], findPatrolScore(currentScores, requests[i].PatrolID), blockedUntil)
+		}
+		return results, nil
+	}
+
 	termInfo, err := srv.osmClient.FetchActiveTermForSection(ctx, user, sectionId)
 	if err != nil {
 		return nil, err
@@ -49,7 +119,9 @@ func (srv *ScoreUpdateService) UpdateScores(ctx context.Context, user types.User
 	if err != nil {
 		return nil, err
 	}
-	defer locks.Release(ctx)
+	// Release must still run if the timeout has expired, otherwise the locks
+	// would be held until their TTL.
+	defer locks.Release(context.WithoutCancel(ctx))
 
 	currentScores, _, err := srv.osmClient.FetchPatrolScores(ctx, user, sectionId, termInfo.TermID)
 	if err != nil {
@@ -69,10 +141,28 @@ func (srv *ScoreUpdateService) UpdateScores(ctx context.Context, user types.User
 			continue
 		}
 
-		newScore := currentScore.Score + request.Delta
-		err = srv.osmClient.UpdatePatrolScore(ctx, user, sectionId, request.PatrolID, newScore)
+		newScore := clampScore(currentScore.Score, currentScore.Score+request.Delta, request.MinScore)
+		err = srv.updatePatrolScore(ctx, user, sectionId, request.PatrolID, newScore)
+		var deferredUntil time.Time
+		if err != nil && isRetryableError(ctx, err) {
+			if allowed, retryAfter := srv.takeRetry(ctx); allowed {
+				err = srv.retryPatrolScore(ctx, user, sectionId, request.PatrolID, newScore)
+			} else {
+				deferredUntil = time.Now().Add(retryAfter)
+			}
+		}
 		if err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				// Only the write in flight may have reached OSM; later patrols
+				// were never sent, so they can safely be resubmitted.
+				results[i] = *newPendingUpdateResponse(&request, currentScore)
+				abandonRemainingWork(requests, results, currentScores, i+1, newNotSentUpdateResponse())
+				break
+			}
 			modelResponse := newOsmErrorUpdateResponse(&request, currentScore, err)
+			if modelResponse.RetryAfter.Before(deferredUntil) {
+				modelResponse.RetryAfter = toPtr(deferredUntil)
+			}
 			abandonRemainingWork(requests, results, currentScores, i, modelResponse)
 			break
 		}
@@ -89,12 +179,77 @@ func (srv *ScoreUpdateService) UpdateScores(ctx context.Context, user types.User
 	return results, nil
 }
 
+// updatePatrolScore writes a patrol score to OSM once a concurrency slot is free.
+func (srv *ScoreUpdateService) updatePatrolScore(ctx context.Context, user types.User, sectionId int, patrolId string, newScore int) error {
+	select {
+	case srv.osmSlots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-srv.osmSlots }()
+
+	return srv.osmClient.UpdatePatrolScore(ctx, user, sectionId, patrolId, newScore)
+}
+
+// retryPatrolScore repeats a failed OSM write after retryDelay.
+func (srv *ScoreUpdateService) retryPatrolScore(ctx context.Context, user types.User, sectionId int, patrolId string, newScore int) error {
+	select {
+	case <-time.After(srv.retryDelay):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return srv.updatePatrolScore(ctx, user, sectionId, patrolId, newScore)
+}
+
+// takeRetry consumes one retry from the global budget. When the budget is spent
+// it returns false with the time until the window resets; callers defer the
+// retry rather than adding to the load on a struggling OSM.
+func (srv *ScoreUpdateService) takeRetry(ctx context.Context) (bool, time.Duration) {
+	if srv.retryBudget <= 0 {
+		return false, 0
+	}
+	result, err := srv.conns.GetRateLimiter().CheckRateLimit(ctx, "osm_retry_budget", "global", int64(srv.retryBudget), retryBudgetWindow)
+	if err != nil {
+		slog.Error("score_update_service.retry_budget_error",
+			"component", "score_update_service",
+			"event", "retry_budget.error",
+			"error", err,
+		)
+		return false, 0
+	}
+	if !result.Allowed {
+		slog.Warn("score_update_service.retry_budget_exhausted",
+			"component", "score_update_service",
+			"event", "retry_budget.exhausted",
+			"retry_after", result.RetryAfter,
+		)
+		return false, result.RetryAfter
+	}
+	return true, 0
+}
+
+// isRetryableError reports whether a failed OSM write is worth repeating. Blocks,
+// revoked access and expired deadlines would fail again in the same way.
+func isRetryableError(ctx context.Context, err error) bool {
+	var userBlock *osm.ErrUserBlocked
+	switch {
+	case ctx.Err() != nil:
+		return false
+	case errors.As(err, &userBlock), errors.Is(err, osm.ErrServiceBlocked), errors.Is(err, osm.ErrUnauthorized):
+		return false
+	}
+	return true
+}
+
+// newPatrolNotFoundResponse reports a submitted patrol that is missing from the
+// scores just fetched from OSM, typically because it was deleted since the
+// caller loaded it. No previous or new score is given as there is nothing to show.
 func newPatrolNotFoundResponse(request *UpdateRequest) UpdateResponse {
 	return UpdateResponse{
 		PatrolID:         request.PatrolID,
 		Success:          false,
 		IsTemporaryError: toPtr(false),
-		ErrorMessage:     toPtr("Patrol not found"),
+		ErrorMessage:     toPtr("Patrol no longer exists"),
 	}
 }
 
@@ -133,6 +288,87 @@ func newOsmErrorUpdateResponse(request *UpdateRequest, currentScore *types.Patro
 	return &response
 }
 
+// newServiceBlockedResponse reports an update not sent because OSM has blocked
+// the service. currentScore may be nil if the patrol's score is not known, in
+// which case no scores are given.
+func newServiceBlockedResponse(request *UpdateRequest, currentScore *types.PatrolScore, blockedUntil time.Time) UpdateResponse {
+	if blockedUntil.IsZero() {
+		blockedUntil = time.Now().Add(6 * time.Hour)
+	}
+	response := UpdateResponse{
+		PatrolID:         request.PatrolID,
+		Success:          false,
+		IsTemporaryError: toPtr(true),
+		RetryAfter:       toPtr(blockedUntil),
+		ErrorMessage:     toPtr(osm.ErrServiceBlocked.Error()),
+	}
+	if currentScore != nil {
+		response.PatrolName = currentScore.Name
+		response.PreviousScore = toPtr(currentScore.Score)
+		response.NewScore = toPtr(currentScore.Score)
+	}
+	return response
+}
+
+func newPendingUpdateResponse(request *UpdateRequest, currentScore *types.PatrolScore) *UpdateResponse {
+	return &UpdateResponse{
+		PatrolID:         request.PatrolID,
+		PatrolName:       currentScore.Name,
+		Success:          false,
+		IsTemporaryError: toPtr(true),
+		RetryAfter:       toPtr(time.Now().Add(30 * time.Second)),
+		ErrorMessage:     toPtr("Timed out waiting for OSM. Refresh scores before trying again."),
+		PreviousScore:    toPtr(currentScore.Score),
+		NewScore:         toPtr(currentScore.Score),
+		Pending:          true,
+	}
+}
+
+// AcceptedResponses returns the results to report for requests still being sent
+// to OSM in the background: one per distinct patrol, as UpdateScores returns
+// them, all pending. Patrols found in currentScores, typically the section's
+// cached scores, are given their name, previous score and the score they are
+// expected to reach. Other patrols are given no scores.
+func AcceptedResponses(requests []UpdateRequest, currentScores []types.PatrolScore) []UpdateResponse {
+	requests = coalesceRequests(requests)
+	results := make([]UpdateResponse, len(requests))
+	for i := range requests {
+		results[i] = newAcceptedUpdateResponse(&requests[i], findPatrolScore(currentScores, requests[i].PatrolID))
+	}
+	return results
+}
+
+// newAcceptedUpdateResponse reports an update that is still being sent to OSM.
+// currentScore may be nil if the patrol's score is not known.
+func newAcceptedUpdateResponse(request *UpdateRequest, currentScore *types.PatrolScore) UpdateResponse {
+	response := UpdateResponse{
+		PatrolID:         request.PatrolID,
+		Success:          false,
+		IsTemporaryError: toPtr(true),
+		RetryAfter:       toPtr(time.Now().Add(30 * time.Second)),
+		ErrorMessage:     toPtr("Still being sent to OSM. Wait for the batch to finish before trying again."),
+		Pending:          true,
+	}
+	if currentScore != nil {
+		response.PatrolName = currentScore.Name
+		response.PreviousScore = toPtr(currentScore.Score)
+		response.NewScore = toPtr(clampScore(currentScore.Score, currentScore.Score+request.Delta, request.MinScore))
+	}
+	return response
+}
+
+// newNotSentUpdateResponse is the model for patrols that were never sent to OSM
+// because the update timed out first. Unlike a pending write, they were
+// definitely not applied.
+func newNotSentUpdateResponse() *UpdateResponse {
+	return &UpdateResponse{
+		Success:          false,
+		IsTemporaryError: toPtr(true),
+		RetryAfter:       toPtr(time.Now().Add(30 * time.Second)),
+		ErrorMessage:     toPtr("Timed out before this update was sent to OSM. It was not applied; please try again."),
+	}
+}
+
 func abandonRemainingWork(requests []UpdateRequest, results []UpdateResponse, currentScores []types.PatrolScore, abandonFromIndex int, modelResponse *UpdateResponse) {
 	for i := abandonFromIndex; i < len(results); i++ {
 		currentScore := findPatrolScore(currentScores, requests[i].PatrolID)
@@ -148,11 +384,37 @@ func abandonRemainingWork(requests []UpdateRequest, results []UpdateResponse, cu
 				RetryAfter:       modelResponse.RetryAfter,
 				PreviousScore:    toPtr(currentScore.Score),
 				NewScore:         toPtr(currentScore.Score),
+				Pending:          modelResponse.Pending,
 			}
 		}
 	}
 }
 
+// coalesceRequests merges requests for the same patrol by summing their deltas,
+// keeping the order in which patrols first appear.
+func coalesceRequests(requests []UpdateRequest) []UpdateRequest {
+	coalesced := make([]UpdateRequest, 0, len(requests))
+	index := make(map[string]int, len(requests))
+	for _, request := range requests {
+		if i, ok := index[request.PatrolID]; ok {
+			coalesced[i].Delta += request.Delta
+			continue
+		}
+		index[request.PatrolID] = len(coalesced)
+		coalesced = append(coalesced, request)
+	}
+	return coalesced
+}
+
+// clampScore stops newScore falling below minScore, or below currentScore if
+// that is already under the floor.
+func clampScore(currentScore, newScore int, minScore *int) int {
+	if minScore == nil {
+		return newScore
+	}
+	return max(newScore, min(*minScore, currentScore))
+}
+
 func findPatrolScore(scores []types.PatrolScore, patrolId string) *types.PatrolScore {
 	for _, score := range scores {
 		if score.ID == patrolId {
@@ -0,0 +1,605 @@
+package scoreupdateservice
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/metrics"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/osm"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/services"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/types"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// mockStore implements osm.RateLimitStore and osm.LatencyRecorder for tests.
+type mockStore struct{}
+
+func (m *mockStore) MarkOsmServiceBlocked(ctx context.Context)                                   {}
+func (m *mockStore) IsOsmServiceBlocked(ctx context.Context) bool                                { return false }
+func (m *mockStore) MarkUserTemporarilyBlocked(ctx context.Context, userId int, until time.Time) {}
+func (m *mockStore) GetUserBlockEndTime(ctx context.Context, userId int) time.Time {
+	return time.Time{}
+}
+func (m *mockStore) RecordOsmLatency(endpoint string, statusCode int, latency time.Duration) {}
+func (m *mockStore) RecordRateLimit(userId *int, limitRemaining int, limitTotal int, limitResetSeconds int) {
+}
+
+const (
+	testSectionID = 12345
+	testTermID    = 999
+	testUserID    = 42
+)
+
+// newTestService creates a ScoreUpdateService backed by a mock OSM server.
+// Profile and patrol-score reads are answered directly; patrol score updates
+// (POST) are passed to updateHandler. The miniredis instance is returned so
+// tests can advance time past Redis TTLs.
+func newTestService(t *testing.T, patrolMap map[string]osm.PatrolData, maxConcurrentUpdates int, updateHandler http.HandlerFunc) (*ScoreUpdateService, *miniredis.Miniredis) {
+	t.Helper()
+
+	now := time.Now()
+	osmServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-RateLimit-Remaining", "500")
+		w.Header().Set("X-RateLimit-Limit", "1000")
+		w.Header().Set("X-RateLimit-Reset", "60")
+
+		switch {
+		case r.URL.Path == "/oauth/resource":
+			json.NewEncoder(w).Encode(types.OSMProfileResponse{
+				Status: true,
+				Data: &types.OSMProfileData{
+					UserID: testUserID,
+					Sections: []types.OSMSection{{
+						SectionID: testSectionID,
+						Terms: []types.OSMTerm{{
+							TermID:    testTermID,
+							StartDate: now.AddDate(0, -1, 0).Format("2006-01-02"),
+							EndDate:   now.AddDate(0, 1, 0).Format("2006-01-02"),
+						}},
+					}},
+				},
+			})
+		case r.URL.Path == "/ext/members/patrols/" && r.Method == http.MethodGet:
+			json.NewEncoder(w).Encode(patrolMap)
+		case r.URL.Path == "/ext/members/patrols/" && r.Method == http.MethodPost:
+			updateHandler(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(osmServer.Close)
+
+	conns := db.SetupTestDB(t)
+	mr := miniredis.RunT(t)
+	rc, err := db.NewRedisClient(fmt.Sprintf("redis://%s", mr.Addr()), "test:")
+	if err != nil {
+		t.Fatalf("failed to create test redis client: %v", err)
+	}
+	conns.Redis = rc
+	conns.RateLimiter = rc
+
+	store := &mockStore{}
+	return New(osm.NewClient(osmServer.URL, store, store), conns, maxConcurrentUpdates, 0), mr
+}
+
+func samplePatrolMap() map[string]osm.PatrolData {
+	return map[string]osm.PatrolData{
+		"1": {PatrolID: "1", Name: "Eagles", Points: "45", Members: []any{"a"}},
+		"2": {PatrolID: "2", Name: "Hawks", Points: "30", Members: []any{"b"}},
+	}
+}
+
+func TestUpdateScores_TimeoutCancelsInFlightOSMCalls(t *testing.T) {
+	cancelled := make(chan struct{}, 1)
+	svc, _ := newTestService(t, samplePatrolMap(), 4, func(w http.ResponseWriter, r *http.Request) {
+		// Simulate a slow OSM: hold the request until the client gives up.
+		// The body must be drained for the server to notice the client disconnecting.
+		io.Copy(io.Discard, r.Body)
+		select {
+		case <-r.Context().Done():
+			cancelled <- struct{}{}
+		case <-time.After(5 * time.Second):
+			w.Write([]byte("[]"))
+		}
+	})
+	svc.timeout = 100 * time.Millisecond
+
+	user := types.NewUser(toPtr(testUserID), "test-token")
+	start := time.Now()
+	results, err := svc.UpdateScores(context.Background(), user, testSectionID, []UpdateRequest{
+		{PatrolID: "1", Delta: 5},
+		{PatrolID: "2", Delta: 3},
+	})
+	if err != nil {
+		t.Fatalf("UpdateScores returned error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("UpdateScores took %v, expected it to give up after the timeout", elapsed)
+	}
+
+	select {
+	case <-cancelled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the in-flight OSM update to be cancelled")
+	}
+
+	for _, result := range results {
+		if result.Success {
+			t.Errorf("patrol %s: expected failure after timeout", result.PatrolID)
+		}
+		if result.IsTemporaryError == nil || !*result.IsTemporaryError {
+			t.Errorf("patrol %s: expected a temporary error after timeout", result.PatrolID)
+		}
+	}
+
+	// Locks must be released even though the context had expired.
+	locks := NewPatrolLockSet(svc.conns.Redis, testUserID, time.Minute)
+	locks.AddPatrol(testSectionID, "1")
+	locks.AddPatrol(testSectionID, "2")
+	if err := locks.Acquire(context.Background()); err != nil {
+		t.Fatalf("failed to acquire locks: %v", err)
+	}
+	if !locks.IsHeld(testSectionID, "1") || !locks.IsHeld(testSectionID, "2") {
+		t.Error("expected patrol locks to be released after timeout")
+	}
+}
+
+func TestUpdateScores_Success(t *testing.T) {
+	svc, _ := newTestService(t, samplePatrolMap(), 4, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("[]"))
+	})
+
+	user := types.NewUser(toPtr(testUserID), "test-token")
+	results, err := svc.UpdateScores(context.Background(), user, testSectionID, []UpdateRequest{
+		{PatrolID: "1", Delta: 5},
+	})
+	if err != nil {
+		t.Fatalf("UpdateScores returned error: %v", err)
+	}
+	if len(results) != 1 || !results[0].Success {
+		t.Fatalf("expected one successful result, got %+v", results)
+	}
+	if *results[0].PreviousScore != 45 || *results[0].NewScore != 50 {
+		t.Errorf("expected 45 -> 50, got %d -> %d", *results[0].PreviousScore, *results[0].NewScore)
+	}
+}
+
+func TestUpdateScores_PatrolMissingFromOSM(t *testing.T) {
+	var writes atomic.Int32
+	svc, _ := newTestService(t, samplePatrolMap(), 4, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.FormValue("patrolid") == "99" {
+			t.Errorf("unexpected OSM write for a patrol that does not exist")
+		}
+		writes.Add(1)
+		w.Write([]byte("[]"))
+	})
+
+	user := types.NewUser(toPtr(testUserID), "test-token")
+	results, err := svc.UpdateScores(context.Background(), user, testSectionID, []UpdateRequest{
+		{PatrolID: "99", Delta: 5},
+		{PatrolID: "1", Delta: 2},
+	})
+	if err != nil {
+		t.Fatalf("UpdateScores returned error: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected two results, got %+v", results)
+	}
+
+	missing := results[0]
+	if missing.Success {
+		t.Error("expected the missing patrol to fail")
+	}
+	if missing.ErrorMessage == nil || *missing.ErrorMessage != "Patrol no longer exists" {
+		t.Errorf("expected 'Patrol no longer exists', got %v", missing.ErrorMessage)
+	}
+	if missing.IsTemporaryError == nil || *missing.IsTemporaryError {
+		t.Error("expected a permanent error for the missing patrol")
+	}
+	if missing.PreviousScore != nil || missing.NewScore != nil {
+		t.Errorf("expected no scores for the missing patrol, got %v -> %v", missing.PreviousScore, missing.NewScore)
+	}
+
+	if !results[1].Success || *results[1].NewScore != 47 {
+		t.Errorf("expected patrol 1 to be updated to 47, got %+v", results[1])
+	}
+	if writes.Load() != 1 {
+		t.Errorf("expected 1 OSM write, got %d", writes.Load())
+	}
+}
+
+func TestUpdateScores_CapsConcurrentOSMUpdates(t *testing.T) {
+	const (
+		maxConcurrent = 3
+		callers       = 10
+		perCaller     = 5
+	)
+
+	patrolMap := make(map[string]osm.PatrolData)
+	for i := 0; i < callers*perCaller; i++ {
+		id := strconv.Itoa(i)
+		patrolMap[id] = osm.PatrolData{PatrolID: id, Name: "Patrol " + id, Points: "0", Members: []any{"m"}}
+	}
+
+	var inFlight, peak atomic.Int32
+	svc, _ := newTestService(t, patrolMap, maxConcurrent, func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		w.Write([]byte("[]"))
+	})
+
+	user := types.NewUser(toPtr(testUserID), "test-token")
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for c := 0; c < callers; c++ {
+		requests := make([]UpdateRequest, perCaller)
+		for i := range requests {
+			requests[i] = UpdateRequest{PatrolID: strconv.Itoa(c*perCaller + i), Delta: 1}
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results, err := svc.UpdateScores(context.Background(), user, testSectionID, requests)
+			if err != nil {
+				errs <- err
+				return
+			}
+			for _, result := range results {
+				if !result.Success {
+					errs <- fmt.Errorf("patrol %s: update failed", result.PatrolID)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+	if got := peak.Load(); got > maxConcurrent {
+		t.Errorf("expected at most %d concurrent OSM updates, saw %d", maxConcurrent, got)
+	}
+}
+
+func TestUpdateScores_PartialCompletionBeforeTimeout(t *testing.T) {
+	patrolMap := map[string]osm.PatrolData{
+		"1": {PatrolID: "1", Name: "Eagles", Points: "10", Members: []any{"a"}},
+		"2": {PatrolID: "2", Name: "Hawks", Points: "20", Members: []any{"b"}},
+		"3": {PatrolID: "3", Name: "Owls", Points: "30", Members: []any{"c"}},
+		"4": {PatrolID: "4", Name: "Kites", Points: "40", Members: []any{"d"}},
+	}
+
+	var calls atomic.Int32
+	svc, _ := newTestService(t, patrolMap, 4, func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		if calls.Add(1) <= 2 {
+			w.Write([]byte("[]"))
+			return
+		}
+		// OSM stalls from the third update onwards
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+			w.Write([]byte("[]"))
+		}
+	})
+	svc.timeout = 500 * time.Millisecond
+
+	user := types.NewUser(toPtr(testUserID), "test-token")
+	results, err := svc.UpdateScores(context.Background(), user, testSectionID, []UpdateRequest{
+		{PatrolID: "1", Delta: 1},
+		{PatrolID: "2", Delta: 2},
+		{PatrolID: "3", Delta: 3},
+		{PatrolID: "4", Delta: 4},
+	})
+	if err != nil {
+		t.Fatalf("UpdateScores returned error: %v", err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("expected 4 results, got %d", len(results))
+	}
+
+	wantSynced := map[string]int{"1": 11, "2": 22}
+	for _, result := range results[:2] {
+		if !result.Success || result.Pending {
+			t.Errorf("patrol %s: expected synced result, got success=%v pending=%v", result.PatrolID, result.Success, result.Pending)
+			continue
+		}
+		if *result.NewScore != wantSynced[result.PatrolID] {
+			t.Errorf("patrol %s: expected new score %d, got %d", result.PatrolID, wantSynced[result.PatrolID], *result.NewScore)
+		}
+	}
+
+	// Only the write in flight when the timeout hit is of unknown outcome
+	inFlight := results[2]
+	if inFlight.Success || !inFlight.Pending {
+		t.Errorf("patrol 3: expected pending result, got success=%v pending=%v", inFlight.Success, inFlight.Pending)
+	} else if *inFlight.NewScore != 30 {
+		t.Errorf("patrol 3: expected last known score 30, got %d", *inFlight.NewScore)
+	}
+
+	// Patrols never sent were not applied and may be resubmitted
+	notSent := results[3]
+	if notSent.Success || notSent.Pending {
+		t.Errorf("patrol 4: expected a not-applied result, got success=%v pending=%v", notSent.Success, notSent.Pending)
+	}
+	if notSent.IsTemporaryError == nil || !*notSent.IsTemporaryError || notSent.RetryAfter == nil {
+		t.Errorf("patrol 4: expected a retryable error, got temporary=%v retryAfter=%v", notSent.IsTemporaryError, notSent.RetryAfter)
+	}
+	if notSent.ErrorMessage == nil || !strings.Contains(*notSent.ErrorMessage, "not applied") {
+		t.Errorf("patrol 4: expected a not-applied message, got %v", notSent.ErrorMessage)
+	}
+	if *notSent.NewScore != 40 {
+		t.Errorf("patrol 4: expected last known score 40, got %d", *notSent.NewScore)
+	}
+}
+
+func TestUpdateScores_SkipsOSMWritesWhileServiceBlocked(t *testing.T) {
+	var writes atomic.Int32
+	svc, mr := newTestService(t, samplePatrolMap(), 4, func(w http.ResponseWriter, r *http.Request) {
+		writes.Add(1)
+		w.Write([]byte("[]"))
+	})
+
+	blockedUntil := time.Now().Add(time.Hour)
+	svc.conns.Redis.MarkOsmServiceBlockedUntil(context.Background(), blockedUntil)
+
+	// Only patrol 1 has a cached score to describe it with
+	cached, _ := json.Marshal(services.CachedPatrolScores{
+		Patrols: []types.PatrolScore{{ID: "1", Name: "Eagles", Score: 45}},
+	})
+	svc.conns.Redis.Set(context.Background(), services.PatrolScoresCacheKey(testSectionID), cached, time.Hour)
+
+	user := types.NewUser(toPtr(testUserID), "test-token")
+	requests := []UpdateRequest{{PatrolID: "1", Delta: 5}, {PatrolID: "2", Delta: 3}}
+	results, err := svc.UpdateScores(context.Background(), user, testSectionID, requests)
+	if err != nil {
+		t.Fatalf("UpdateScores returned error: %v", err)
+	}
+	if n := writes.Load(); n != 0 {
+		t.Fatalf("expected no OSM writes while blocked, got %d", n)
+	}
+	if r := results[0]; r.PatrolName != "Eagles" || r.PreviousScore == nil || *r.PreviousScore != 45 || r.NewScore == nil || *r.NewScore != 45 {
+		t.Errorf("patrol 1: expected the cached name and unchanged score 45, got %+v", r)
+	}
+	if r := results[1]; r.PatrolName != "" || r.PreviousScore != nil || r.NewScore != nil {
+		t.Errorf("patrol 2: expected no scores for an uncached patrol, got %+v", r)
+	}
+	for _, result := range results {
+		if result.Success || result.IsTemporaryError == nil || !*result.IsTemporaryError {
+			t.Errorf("patrol %s: expected a temporary error while blocked", result.PatrolID)
+		}
+		if result.RetryAfter == nil || !result.RetryAfter.Equal(blockedUntil.Truncate(time.Second)) {
+			t.Errorf("patrol %s: expected retry after %v, got %v", result.PatrolID, blockedUntil, result.RetryAfter)
+		}
+	}
+
+	// Once the cooldown expires the flag clears and writes resume
+	mr.FastForward(2 * time.Hour)
+	results, err = svc.UpdateScores(context.Background(), user, testSectionID, requests)
+	if err != nil {
+		t.Fatalf("UpdateScores returned error: %v", err)
+	}
+	if n := writes.Load(); n != 2 {
+		t.Errorf("expected 2 OSM writes after the block cleared, got %d", n)
+	}
+	for _, result := range results {
+		if !result.Success {
+			t.Errorf("patrol %s: expected success after the block cleared", result.PatrolID)
+		}
+	}
+}
+
+func TestUpdateScores_CoalescesEntriesForOnePatrol(t *testing.T) {
+	var writes atomic.Int32
+	var written string
+	svc, _ := newTestService(t, samplePatrolMap(), 4, func(w http.ResponseWriter, r *http.Request) {
+		writes.Add(1)
+		r.ParseForm()
+		written = r.PostForm.Get("points")
+		w.Write([]byte("[]"))
+	})
+
+	countBefore, sumBefore := coalesceRatioSnapshot(t)
+
+	user := types.NewUser(toPtr(testUserID), "test-token")
+	results, err := svc.UpdateScores(context.Background(), user, testSectionID, []UpdateRequest{
+		{PatrolID: "1", Delta: 5},
+		{PatrolID: "1", Delta: 3},
+		{PatrolID: "1", Delta: -1},
+	})
+	if err != nil {
+		t.Fatalf("UpdateScores returned error: %v", err)
+	}
+
+	if got := writes.Load(); got != 1 {
+		t.Fatalf("expected 1 OSM write, got %d", got)
+	}
+	if written != "52" {
+		t.Errorf("expected OSM to be sent 45+7=52, got %q", written)
+	}
+	if len(results) != 1 || !results[0].Success || *results[0].NewScore != 52 {
+		t.Fatalf("expected one successful result ending at 52, got %+v", results)
+	}
+
+	countAfter, sumAfter := coalesceRatioSnapshot(t)
+	if countAfter-countBefore != 1 {
+		t.Fatalf("expected one coalesce ratio observation, got %d", countAfter-countBefore)
+	}
+	if ratio := sumAfter - sumBefore; ratio != 3 {
+		t.Errorf("expected coalesce ratio 3, got %v", ratio)
+	}
+}
+
+// coalesceRatioSnapshot reads the cumulative sample count and sum of the coalesce ratio histogram.
+func coalesceRatioSnapshot(t *testing.T) (uint64, float64) {
+	t.Helper()
+	var m dto.Metric
+	if err := metrics.ScoreUpdateCoalesceRatio.Write(&m); err != nil {
+		t.Fatalf("failed to read coalesce ratio metric: %v", err)
+	}
+	return m.GetHistogram().GetSampleCount(), m.GetHistogram().GetSampleSum()
+}
+
+func TestUpdateScores_DefersRetriesOnceGlobalBudgetIsSpent(t *testing.T) {
+	var writes atomic.Int32
+	svc, mr := newTestService(t, samplePatrolMap(), 4, func(w http.ResponseWriter, r *http.Request) {
+		writes.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	svc.retryBudget = 1
+	svc.retryDelay = 0
+
+	user := types.NewUser(toPtr(testUserID), "test-token")
+
+	// The first failure spends the only retry in the window
+	results, err := svc.UpdateScores(context.Background(), user, testSectionID, []UpdateRequest{{PatrolID: "1", Delta: 5}})
+	if err != nil {
+		t.Fatalf("UpdateScores returned error: %v", err)
+	}
+	if n := writes.Load(); n != 2 {
+		t.Fatalf("expected the failed write to be retried once, got %d writes", n)
+	}
+	if results[0].Success {
+		t.Fatal("expected failure while OSM is erroring")
+	}
+
+	// Further failures in the window are not retried
+	results, err = svc.UpdateScores(context.Background(), user, testSectionID, []UpdateRequest{{PatrolID: "2", Delta: 3}})
+	if err != nil {
+		t.Fatalf("UpdateScores returned error: %v", err)
+	}
+	if n := writes.Load(); n != 3 {
+		t.Fatalf("expected no retry once the budget was spent, got %d writes", n)
+	}
+	if results[0].IsTemporaryError == nil || !*results[0].IsTemporaryError || results[0].RetryAfter == nil {
+		t.Fatal("expected a temporary error with a retry time")
+	}
+
+	// A new window brings a fresh budget
+	mr.FastForward(retryBudgetWindow)
+	if _, err := svc.UpdateScores(context.Background(), user, testSectionID, []UpdateRequest{{PatrolID: "2", Delta: 3}}); err != nil {
+		t.Fatalf("UpdateScores returned error: %v", err)
+	}
+	if n := writes.Load(); n != 5 {
+		t.Errorf("expected the write to be retried in the next window, got %d writes", n)
+	}
+}
+
+func TestUpdateScores_SkipsPatrolsLockedElsewhere(t *testing.T) {
+	var writes atomic.Int32
+	svc, _ := newTestService(t, samplePatrolMap(), 4, func(w http.ResponseWriter, r *http.Request) {
+		writes.Add(1)
+		w.Write([]byte("[]"))
+	})
+
+	// Another caller holds the lock for patrol 2
+	other := NewPatrolLockSet(svc.conns.Redis, 99, time.Minute)
+	other.AddPatrol(testSectionID, "2")
+	if err := other.Acquire(context.Background()); err != nil {
+		t.Fatalf("failed to acquire locks: %v", err)
+	}
+	defer other.Release(context.Background())
+
+	user := types.NewUser(toPtr(testUserID), "test-token")
+	results, err := svc.UpdateScores(context.Background(), user, testSectionID, []UpdateRequest{
+		{PatrolID: "1", Delta: 5},
+		{PatrolID: "2", Delta: 3},
+	})
+	if err != nil {
+		t.Fatalf("UpdateScores returned error: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected two results, got %+v", results)
+	}
+	if !results[0].Success {
+		t.Errorf("expected patrol 1 to be updated, got %+v", results[0])
+	}
+	if results[1].Success || results[1].IsTemporaryError == nil || !*results[1].IsTemporaryError {
+		t.Errorf("expected patrol 2 to be skipped with a temporary error, got %+v", results[1])
+	}
+	if *results[1].NewScore != 30 {
+		t.Errorf("expected skipped patrol to keep its score, got %d", *results[1].NewScore)
+	}
+	if got := writes.Load(); got != 1 {
+		t.Errorf("expected one OSM write, got %d", got)
+	}
+}
+
+func TestUpdateScores_ClampsCoalescedDeltaToMinScore(t *testing.T) {
+	tests := []struct {
+		name     string
+		deltas   []int
+		minScore int
+		want     int
+	}{
+		// 45 - 20 - 30 would be -5; the floor applies to the sum, not each entry
+		{name: "clamp triggers", deltas: []int{-20, -30}, minScore: 0, want: 0},
+		{name: "clamp not needed", deltas: []int{-20, -5}, minScore: 0, want: 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var written string
+			svc, _ := newTestService(t, samplePatrolMap(), 4, func(w http.ResponseWriter, r *http.Request) {
+				r.ParseForm()
+				written = r.PostForm.Get("points")
+				w.Write([]byte("[]"))
+			})
+
+			requests := make([]UpdateRequest, len(tt.deltas))
+			for i, delta := range tt.deltas {
+				requests[i] = UpdateRequest{PatrolID: "1", Delta: delta, MinScore: toPtr(tt.minScore)}
+			}
+			user := types.NewUser(toPtr(testUserID), "test-token")
+			results, err := svc.UpdateScores(context.Background(), user, testSectionID, requests)
+			if err != nil {
+				t.Fatalf("UpdateScores returned error: %v", err)
+			}
+			if len(results) != 1 || !results[0].Success || *results[0].NewScore != tt.want {
+				t.Fatalf("expected one successful result ending at %d, got %+v", tt.want, results)
+			}
+			if written != strconv.Itoa(tt.want) {
+				t.Errorf("expected OSM to be sent %d, got %q", tt.want, written)
+			}
+		})
+	}
+}
+
+func TestClampScore(t *testing.T) {
+	floor := toPtr(0)
+	if got := clampScore(10, -5, nil); got != -5 {
+		t.Errorf("expected no clamp without a floor, got %d", got)
+	}
+	if got := clampScore(10, -5, floor); got != 0 {
+		t.Errorf("expected clamp to the floor, got %d", got)
+	}
+	// A score already under the floor is neither raised nor lowered further
+	if got := clampScore(-8, -3, floor); got != -3 {
+		t.Errorf("expected an increase below the floor to stand, got %d", got)
+	}
+	if got := clampScore(-8, -12, floor); got != -8 {
+		t.Errorf("expected a decrease below the floor to stop at the current score, got %d", got)
+	}
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/m0rjc/OsmDeviceAdapter/internal/config"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db/devicecode"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db/scorearchive"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/osm"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/types"
+)
+
+// termArchiveLockKey keeps a pass of TermArchiver.Run to one server.
+const termArchiveLockKey = "term_archive:lock"
+
+// TermArchiver archives the final scores of terms that ended while none of
+// their section's devices fetched scores. Sections that are polled are
+// archived by GetPatrolScores as their devices move to the next term.
+type TermArchiver struct {
+	scores *PatrolScoreService
+	// refresh refreshes a device's OSM token if OSM refuses it
+	refresh func(*db.DeviceCode) types.TokenRefreshFunc
+}
+
+// NewTermArchiver creates a term archiver reading scores as the devices'
+// users, refreshing their tokens with refresh.
+func NewTermArchiver(osmClient *osm.Client, conns *db.Connections, cfg *config.Config, refresh func(*db.DeviceCode) types.TokenRefreshFunc) *TermArchiver {
+	return &TermArchiver{
+		scores:  NewPatrolScoreService(osmClient, conns, cfg),
+		refresh: refresh,
+	}
+}
+
+// Run archives ended terms every interval until ctx is cancelled. Each pass
+// runs on only one server.
+func (a *TermArchiver) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		claimed, err := a.scores.conns.Redis.SetNX(ctx, termArchiveLockKey, 1, interval/2).Result()
+		if err != nil || !claimed {
+			continue
+		}
+		if err := a.ArchiveEndedTerms(ctx); err != nil {
+			slog.Error("term_archiver.pass_failed",
+				"component", "term_archiver",
+				"event", "archive.error",
+				"error", err,
+			)
+		}
+	}
+}
+
+// ArchiveEndedTerms archives each ended term still recorded on a device and
+// not yet archived, from the cached scores if they are for the term, otherwise
+// from OSM as the device's user. A term that cannot be archived is tried again
+// on the next pass.
+func (a *TermArchiver) ArchiveEndedTerms(ctx context.Context) error {
+	devices, err := devicecode.ListWithEndedTerm(a.scores.conns, time.Now())
+	if err != nil {
+		return err
+	}
+
+	type sectionTerm struct{ sectionID, termID int }
+	done := make(map[sectionTerm]bool)
+	for i := range devices {
+		device := &devices[i]
+		key := sectionTerm{*device.SectionID, *device.TermID}
+		if done[key] || device.OSMAccessToken == nil {
+			continue
+		}
+		if archived, err := scorearchive.Exists(a.scores.conns, key.sectionID, key.termID); err != nil || archived {
+			done[key] = err == nil
+			continue
+		}
+
+		cached, err := a.scores.getCachedPatrolScores(ctx, key.sectionID)
+		if err != nil {
+			cached = nil
+		}
+		deviceCtx := context.WithValue(ctx, types.TokenRefreshFuncKey, a.refresh(device))
+		user := types.NewUser(device.OsmUserID, *device.OSMAccessToken)
+		if a.scores.archiveEndedTerm(deviceCtx, user, key.sectionID, key.termID, cached) == nil {
+			done[key] = true
+		}
+	}
+	return nil
+}
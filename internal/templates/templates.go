@@ -14,9 +14,15 @@ var templateFS embed.FS
 
 var templates *template.Template
 
+// NonceWriter is implemented by response writers that carry a per-request
+// Content-Security-Policy nonce (see middleware.PageSecurityHeadersMiddleware).
+type NonceWriter interface {
+	CSPNonce() string
+}
+
 func init() {
 	var err error
-	templates, err = template.New("").ParseFS(templateFS, "*.html")
+	templates, err = template.New("").Funcs(nonceFuncs("")).ParseFS(templateFS, "*.html")
 	if err != nil {
 		panic(err)
 	}
@@ -30,6 +36,13 @@ func Render(w io.Writer, name string, data interface{}) error {
 		return err
 	}
 
+	// Inline scripts are tagged with the request's CSP nonce so the browser will run them
+	nonce := ""
+	if nw, ok := w.(NonceWriter); ok {
+		nonce = nw.CSPNonce()
+	}
+	t.Funcs(nonceFuncs(nonce))
+
 	// Extract template name from filename (e.g., "device-auth.html" -> "device-auth")
 	templateName := name
 	if len(templateName) > 5 && templateName[len(templateName)-5:] == ".html" {
@@ -48,6 +61,12 @@ func Render(w io.Writer, name string, data interface{}) error {
 	return t.ExecuteTemplate(w, "base.html", data)
 }
 
+func nonceFuncs(nonce string) template.FuncMap {
+	return template.FuncMap{
+		"cspNonce": func() string { return nonce },
+	}
+}
+
 // DeviceAuthData is the data structure for the device authorization form
 type DeviceAuthData struct {
 	Title string
@@ -76,7 +95,14 @@ type AuthSuccessData struct {
 
 // HomeData is the data structure for the home page
 type HomeData struct {
-	Title string
+	Title      string
+	DevicePath string
+}
+
+// NotFoundData is the data structure for the page not found page
+type NotFoundData struct {
+	Title      string
+	DevicePath string
 }
 
 // DeviceErrorData is the data structure for device code error page
@@ -178,14 +204,24 @@ func RenderSectionSelect(w io.Writer, sessionID string, sections []types.OSMSect
 	return Render(w, "section-select.html", data)
 }
 
-// RenderHome renders the home page
-func RenderHome(w io.Writer) error {
+// RenderHome renders the home page, whose code entry form submits to devicePath
+func RenderHome(w io.Writer, devicePath string) error {
 	data := HomeData{
-		Title: "OSM Device Adapter",
+		Title:      "OSM Device Adapter",
+		DevicePath: devicePath,
 	}
 	return Render(w, "home.html", data)
 }
 
+// RenderNotFound renders the page not found page
+func RenderNotFound(w io.Writer, devicePath string) error {
+	data := NotFoundData{
+		Title:      "Page Not Found",
+		DevicePath: devicePath,
+	}
+	return Render(w, "not-found.html", data)
+}
+
 // RenderDeviceError renders the device code error page
 func RenderDeviceError(w io.Writer, errorMessage string) error {
 	data := DeviceErrorData{
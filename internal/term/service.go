@@ -0,0 +1,116 @@
+// Package term finds the active OSM term for a section. Term discovery needs a
+// profile fetch from OSM, so the result is kept for a day: on the device record
+// for devices, and in Redis for the admin API.
+package term
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db/devicecode"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/osm"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/types"
+)
+
+// RefreshInterval is how long term information is trusted before OSM is asked
+// again. Terms rarely change mid-term, but a leader may add or move one.
+const RefreshInterval = 24 * time.Hour
+
+// Service looks up active terms, fetching from OSM only when the stored term
+// information is stale.
+type Service struct {
+	osmClient *osm.Client
+	conns     *db.Connections
+}
+
+// NewService creates a term service
+func NewService(osmClient *osm.Client, conns *db.Connections) *Service {
+	return &Service{
+		osmClient: osmClient,
+		conns:     conns,
+	}
+}
+
+// NeedsRefresh reports whether term information checked at checkedAt, for a
+// term ending at endDate, should be fetched again at now. Missing information
+// always needs fetching.
+func NeedsRefresh(termID *int, checkedAt, endDate *time.Time, now time.Time) bool {
+	return termID == nil ||
+		checkedAt == nil ||
+		endDate == nil ||
+		now.After(checkedAt.Add(RefreshInterval)) ||
+		now.After(*endDate)
+}
+
+// GetActiveTerm returns the active term ID for the device's section. The term
+// stored on the device is used while fresh; otherwise it is fetched from OSM and
+// written back to the device record, and to device itself.
+func (s *Service) GetActiveTerm(ctx context.Context, user types.User, device *db.DeviceCode) (int, error) {
+	if device.SectionID == nil {
+		return 0, osm.ErrNoSectionConfigured
+	}
+	if !NeedsRefresh(device.TermID, device.TermCheckedAt, device.TermEndDate, time.Now()) {
+		return *device.TermID, nil
+	}
+
+	termInfo, err := s.osmClient.FetchActiveTermForSection(ctx, user, *device.SectionID)
+	if err != nil {
+		return 0, err
+	}
+
+	checkedAt := time.Now()
+	device.TermID, device.TermCheckedAt, device.TermEndDate = &termInfo.TermID, &checkedAt, &termInfo.EndDate
+	if err := devicecode.UpdateTermInfo(s.conns, device.DeviceCode, termInfo.UserID, termInfo.TermID, checkedAt, termInfo.EndDate); err != nil {
+		slog.Error("term.service.device_update_failed",
+			"component", "term_service",
+			"event", "term.update.error",
+			"device_code_hash", device.DeviceCode[:8],
+			"error", err,
+		)
+		// Continue anyway - we have the term ID
+	}
+
+	return termInfo.TermID, nil
+}
+
+// GetActiveTermForSection returns the active term for a section on behalf of a
+// user with no device, such as an admin UI session. Results are cached in Redis
+// per user and section under the same freshness rules as devices.
+func (s *Service) GetActiveTermForSection(ctx context.Context, user types.User, sectionID int) (*osm.TermInfo, error) {
+	userID := user.UserID()
+	if userID == nil || s.conns.Redis == nil {
+		return s.osmClient.FetchActiveTermForSection(ctx, user, sectionID)
+	}
+	key := fmt.Sprintf("term:%d:%d", *userID, sectionID)
+
+	if data, err := s.conns.Redis.Get(ctx, key).Result(); err == nil {
+		var cached osm.TermInfo
+		if json.Unmarshal([]byte(data), &cached) == nil && !time.Now().After(cached.EndDate) {
+			return &cached, nil
+		}
+	}
+
+	termInfo, err := s.osmClient.FetchActiveTermForSection(ctx, user, sectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if data, err := json.Marshal(termInfo); err == nil {
+		ttl := min(RefreshInterval, time.Until(termInfo.EndDate))
+		if ttl > 0 {
+			if err := s.conns.Redis.Set(ctx, key, data, ttl).Err(); err != nil {
+				slog.Warn("term.service.cache_failed",
+					"component", "term_service",
+					"event", "term.cache.error",
+					"section_id", sectionID,
+					"error", err,
+				)
+			}
+		}
+	}
+	return termInfo, nil
+}
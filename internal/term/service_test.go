@@ -0,0 +1,119 @@
+package term
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/db/devicecode"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/osm"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/types"
+)
+
+func TestNeedsRefresh(t *testing.T) {
+	now := time.Now()
+	termID := 7
+	ptr := func(t time.Time) *time.Time { return &t }
+
+	tests := []struct {
+		name      string
+		termID    *int
+		checkedAt *time.Time
+		endDate   *time.Time
+		want      bool
+	}{
+		{"fresh term", &termID, ptr(now.Add(-time.Hour)), ptr(now.AddDate(0, 1, 0)), false},
+		{"stale check", &termID, ptr(now.Add(-25 * time.Hour)), ptr(now.AddDate(0, 1, 0)), true},
+		{"expired term", &termID, ptr(now.Add(-time.Hour)), ptr(now.Add(-time.Minute)), true},
+		{"never checked", nil, nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NeedsRefresh(tt.termID, tt.checkedAt, tt.endDate, now); got != tt.want {
+				t.Errorf("NeedsRefresh() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// newTermOSMServer serves a profile with section 100 in term 555 and counts
+// profile fetches.
+func newTermOSMServer(t *testing.T) (*osm.Client, *atomic.Int32) {
+	t.Helper()
+	fetches := &atomic.Int32{}
+	now := time.Now()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(types.OSMProfileResponse{
+			Status: true,
+			Data: &types.OSMProfileData{
+				UserID: 42,
+				Sections: []types.OSMSection{{
+					SectionID: 100,
+					Terms: []types.OSMTerm{{
+						TermID:    555,
+						StartDate: now.AddDate(0, -1, 0).Format("2006-01-02"),
+						EndDate:   now.AddDate(0, 1, 0).Format("2006-01-02"),
+					}},
+				}},
+			},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return osm.NewClient(server.URL, nil, nil), fetches
+}
+
+func TestGetActiveTerm_UsesStoredTermUntilStale(t *testing.T) {
+	conns := db.SetupTestDB(t)
+	client, fetches := newTermOSMServer(t)
+	service := NewService(client, conns)
+	user := types.NewUser(nil, "token")
+
+	sectionID := 100
+	storedTerm := 444
+	checkedAt := time.Now().Add(-time.Hour)
+	endDate := time.Now().AddDate(0, 1, 0)
+	device := &db.DeviceCode{
+		DeviceCode:    "term-device-code",
+		UserCode:      "TERM-0001",
+		ClientID:      "test-client",
+		Status:        "authorized",
+		ExpiresAt:     time.Now().Add(time.Hour),
+		SectionID:     &sectionID,
+		TermID:        &storedTerm,
+		TermCheckedAt: &checkedAt,
+		TermEndDate:   &endDate,
+	}
+	if err := devicecode.Create(conns, device); err != nil {
+		t.Fatalf("Failed to create device: %v", err)
+	}
+
+	termID, err := service.GetActiveTerm(context.Background(), user, device)
+	if err != nil || termID != storedTerm {
+		t.Fatalf("expected the stored term %d, got %d (%v)", storedTerm, termID, err)
+	}
+	if fetches.Load() != 0 {
+		t.Errorf("expected no OSM fetch for a fresh term, got %d", fetches.Load())
+	}
+
+	stale := time.Now().Add(-25 * time.Hour)
+	device.TermCheckedAt = &stale
+	termID, err = service.GetActiveTerm(context.Background(), user, device)
+	if err != nil || termID != 555 {
+		t.Fatalf("expected term 555 from OSM, got %d (%v)", termID, err)
+	}
+	if fetches.Load() != 1 {
+		t.Errorf("expected one OSM fetch for a stale term, got %d", fetches.Load())
+	}
+
+	stored, err := devicecode.FindByCode(conns, device.DeviceCode)
+	if err != nil || stored == nil || stored.TermID == nil || *stored.TermID != 555 {
+		t.Errorf("expected term 555 to be written to the device, got %+v (%v)", stored, err)
+	}
+}
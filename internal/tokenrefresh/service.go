@@ -3,6 +3,7 @@ package tokenrefresh
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
 	"time"
 
@@ -76,6 +77,18 @@ func (s *Service) RefreshToken(
 			return "", ErrTokenRevoked
 		}
 
+		// OSM has blocked the whole service. This is still a temporary refresh failure,
+		// but keep the cause visible so callers can back off.
+		if errors.Is(err, osm.ErrServiceBlocked) {
+			slog.Error("tokenrefresh.service_blocked",
+				"component", "tokenrefresh",
+				"event", "token.refresh_error",
+				"identifier", identifier,
+				"error", err,
+			)
+			return "", fmt.Errorf("%w: %w", ErrTokenRefreshFailed, err)
+		}
+
 		// Temporary error (network, OSM server issue, etc.)
 		slog.Error("tokenrefresh.failed",
 			"component", "tokenrefresh",
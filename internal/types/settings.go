@@ -7,8 +7,26 @@ type DeviceSettings struct {
 	// PatrolColors maps patrol IDs to color names (e.g., "red", "blue")
 	// Colors represent the hue/theme - device firmware controls actual brightness
 	PatrolColors map[string]string `json:"patrolColors,omitempty"`
+
+	// PatrolIcons maps patrol IDs to icon names (e.g., "eagle", "hawk").
+	// Devices map the names to their own glyphs.
+	PatrolIcons map[string]string `json:"patrolIcons,omitempty"`
+
+	// Layout tells the device how the scoreboard is mounted (see Layout* constants).
+	// Empty means the device should use its own default.
+	Layout string `json:"layout,omitempty"`
+
+	// HideBelowScore is the score below which patrols are left out of the
+	// response; the number left out is reported alongside the patrols.
+	HideBelowScore *int `json:"hideBelowScore,omitempty"`
 }
 
+// Layout values for DeviceSettings.Layout.
+const (
+	LayoutLandscape = "landscape" // e.g. a TV on a wall
+	LayoutPortrait  = "portrait"  // e.g. a kiosk display
+)
+
 // PatrolInfo contains basic patrol information for settings UI.
 // This provides a canonical list of patrols that exist in OSM,
 // allowing the UI to display patrols even if no color is set.
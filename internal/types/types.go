@@ -92,6 +92,23 @@ type OSMSection struct {
 	GroupID     int       `json:"group_id"`
 	SectionType string    `json:"section_type"`
 	Terms       []OSMTerm `json:"terms"`
+	// Permissions maps OSM areas (member, badge, events, ...) to the user's
+	// access level in this section: 10 read, 20 write, 100 administer.
+	Permissions map[string]int `json:"permissions,omitempty"`
+}
+
+// OSMPermissionWrite is the OSM permission level that allows changes.
+const OSMPermissionWrite = 20
+
+// CanEditScores reports whether the user may change patrol points in the
+// section, which OSM grants with write access to the member area. Sections
+// without permission flags are assumed editable; OSM will still refuse the
+// update if they are not.
+func (s OSMSection) CanEditScores() bool {
+	if s.Permissions == nil {
+		return true
+	}
+	return s.Permissions["member"] >= OSMPermissionWrite
 }
 
 type OSMProfileData struct {
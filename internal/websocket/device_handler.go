@@ -2,6 +2,7 @@ package websocket
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"net/http"
 	"strconv"
@@ -90,8 +91,9 @@ func DeviceWebSocketHandler(hub *Hub, deviceAuth deviceAuthenticator, exposedDom
 
 		// Ad-hoc devices (sectionID == 0) are scoped per user to avoid cross-user
 		// notifications. Regular sections are globally unique in OSM so no user
-		// scoping is required there.
-		var routingKey string
+		// scoping is required there. A device showing several sections listens
+		// on each of them.
+		var channelKeys []string
 		if *device.SectionID == 0 {
 			if device.OsmUserID == nil {
 				slog.Error("websocket.handler.adhoc_no_user",
@@ -101,12 +103,28 @@ func DeviceWebSocketHandler(hub *Hub, deviceAuth deviceAuthenticator, exposedDom
 				http.Error(w, "Internal server error", http.StatusInternalServerError)
 				return
 			}
-			routingKey = "adhoc:" + strconv.Itoa(*device.OsmUserID)
+			channelKeys = append(channelKeys, "adhoc:"+strconv.Itoa(*device.OsmUserID))
 		} else {
-			routingKey = "section:" + sectionID
+			for _, id := range hub.deviceSections(device) {
+				channelKeys = append(channelKeys, "section:"+strconv.Itoa(id))
+			}
 		}
+		channelKeys = append(channelKeys, "device:"+device.DeviceCode)
 
-		channelKeys := []string{routingKey, "device:" + device.DeviceCode}
+		// Refuse before upgrading while the hub or the section is full, so the
+		// client gets a proper HTTP response it can back off from.
+		if hub.AtCapacity(device.DeviceCode, channelKeys...) {
+			metrics.WebSocketConnectionsTotal.WithLabelValues("rejected").Inc()
+			slog.Warn("websocket.handler.at_capacity",
+				"component", "websocket",
+				"event", "handler.rejected",
+				"section_id", sectionID,
+				"remote_addr", r.RemoteAddr,
+			)
+			w.Header().Set("Retry-After", strconv.Itoa(capacityRetryAfter))
+			http.Error(w, "Too many connections", http.StatusServiceUnavailable)
+			return
+		}
 
 		// --- WebSocket upgrade ---
 		conn, err := upgrader.Upgrade(w, r, nil)
@@ -143,6 +161,9 @@ func DeviceWebSocketHandler(hub *Hub, deviceAuth deviceAuthenticator, exposedDom
 		defer cancel()
 
 		if err := hub.RegisterDeviceAndSubscribe(subCtx, device.DeviceCode, dc, channelKeys...); err != nil {
+			if errors.Is(err, ErrTooManyConnections) {
+				metrics.WebSocketConnectionsTotal.WithLabelValues("rejected").Inc()
+			}
 			// Can't "refuse" HTTP after upgrade; close the WS so the client retries.
 			_ = conn.WriteMessage(ws.CloseMessage, ws.FormatCloseMessage(ws.CloseTryAgainLater, "temporarily unavailable"))
 			_ = conn.Close()
@@ -154,3 +175,33 @@ func DeviceWebSocketHandler(hub *Hub, deviceAuth deviceAuthenticator, exposedDom
 		dc.readPump()
 	}
 }
+
+// deviceSections returns the OSM sections device shows, primary first. If they
+// cannot be listed the device listens on its primary section alone.
+func (h *Hub) deviceSections(device *db.DeviceCode) []int {
+	primary := []int{*device.SectionID}
+	if h.listSections == nil {
+		return primary
+	}
+	sectionIDs, err := h.listSections(device)
+	if err != nil {
+		slog.Warn("websocket.handler.sections_unavailable",
+			"component", "websocket",
+			"event", "handler.sections_error",
+			"device_code_prefix", device.DeviceCode[:min(8, len(device.DeviceCode))],
+			"error", err,
+		)
+		return primary
+	}
+	var sections []int
+	for _, id := range sectionIDs {
+		// Ad-hoc teams are routed per user, never as a section
+		if id != 0 {
+			sections = append(sections, id)
+		}
+	}
+	if len(sections) == 0 {
+		return primary
+	}
+	return sections
+}
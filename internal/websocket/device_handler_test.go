@@ -2,6 +2,7 @@ package websocket
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -10,7 +11,9 @@ import (
 
 	wslib "github.com/gorilla/websocket"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/db"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/metrics"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/types"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -129,7 +132,42 @@ func TestDeviceHandler_ReceivesRefreshScores(t *testing.T) {
 	time.Sleep(100 * time.Millisecond)
 
 	// Broadcast a refresh-scores from the server side.
-	hub.BroadcastToSection("77", RefreshScoresMessage())
+	hub.BroadcastToSection("77", RefreshScoresMessage(nil))
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second)) //nolint:errcheck
+	var msg Message
+	err = conn.ReadJSON(&msg)
+	require.NoError(t, err)
+	assert.Equal(t, "refresh-scores", msg.Type)
+}
+
+func TestDeviceHandler_ReceivesRefreshForEverySection(t *testing.T) {
+	hub := newTestHub(t)
+	hub.SetSectionLister(func(device *db.DeviceCode) ([]int, error) {
+		return []int{77, 78}, nil
+	})
+
+	sectionID := 77
+	osmUserID := 3
+	device := &db.DeviceCode{
+		DeviceCode:        "multi-section-device",
+		DeviceAccessToken: strPtr("multi-token"),
+		SectionID:         &sectionID,
+		OsmUserID:         &osmUserID,
+	}
+	auth := &stubAuthenticator{user: &stubUser{deviceCode: device}}
+	srv := httptest.NewServer(DeviceWebSocketHandler(hub, auth, "http://localhost"))
+	defer srv.Close()
+
+	conn, _, err := wslib.DefaultDialer.Dial(wsDialURL(srv.URL, "/ws/device?token=multi-token"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// Wait for the device to register and the hub to subscribe.
+	time.Sleep(100 * time.Millisecond)
+
+	// A change to the device's second section reaches it too.
+	hub.BroadcastToSection("78", RefreshScoresMessage(nil))
 
 	conn.SetReadDeadline(time.Now().Add(2 * time.Second)) //nolint:errcheck
 	var msg Message
@@ -164,3 +202,324 @@ type stubError string
 func (e stubError) Error() string { return string(e) }
 
 func strPtr(s string) *string { return &s }
+
+func TestDeviceHandler_StoresRichStatus(t *testing.T) {
+	hub := newTestHub(t)
+
+	sectionID := 88
+	osmUserID := 4
+	device := &db.DeviceCode{
+		DeviceCode:        "status-test-device",
+		DeviceAccessToken: strPtr("status-token"),
+		SectionID:         &sectionID,
+		OsmUserID:         &osmUserID,
+	}
+	auth := &stubAuthenticator{user: &stubUser{deviceCode: device}}
+	srv := httptest.NewServer(DeviceWebSocketHandler(hub, auth, "http://localhost"))
+	defer srv.Close()
+
+	conn, _, err := wslib.DefaultDialer.Dial(wsDialURL(srv.URL, "/ws/device?token=status-token"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	err = conn.WriteMessage(wslib.TextMessage, []byte(`{
+		"type": "status",
+		"uptime": 3600,
+		"firmwareVersion": "1.4.2",
+		"freeMemory": 81920,
+		"lastRenderError": "font not found",
+		"connectionQuality": 72
+	}`))
+	require.NoError(t, err)
+
+	var status *DeviceStatus
+	require.Eventually(t, func() bool {
+		status, err = hub.DeviceStatus(context.Background(), "status-test-device")
+		return err == nil && status != nil
+	}, 2*time.Second, 20*time.Millisecond, "status should be stored")
+
+	assert.Equal(t, int64(3600), status.Uptime)
+	assert.Equal(t, "1.4.2", status.FirmwareVersion)
+	assert.Equal(t, int64(81920), status.FreeMemory)
+	assert.Equal(t, "font not found", status.LastRenderError)
+	assert.Equal(t, 72, status.ConnectionQuality)
+	assert.False(t, status.ReportedAt.IsZero())
+}
+
+func TestDeviceHandler_AcceptsStatusUpToReadLimit(t *testing.T) {
+	hub := newTestHub(t)
+	hub.SetReadLimit(4096)
+
+	sectionID := 88
+	device := &db.DeviceCode{
+		DeviceCode:        "large-status-device",
+		DeviceAccessToken: strPtr("large-status-token"),
+		SectionID:         &sectionID,
+	}
+	auth := &stubAuthenticator{user: &stubUser{deviceCode: device}}
+	srv := httptest.NewServer(DeviceWebSocketHandler(hub, auth, "http://localhost"))
+	defer srv.Close()
+
+	conn, _, err := wslib.DefaultDialer.Dial(wsDialURL(srv.URL, "/ws/device?token=large-status-token"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// Padding takes the message past the default limit but not the configured one
+	payload := fmt.Sprintf(`{"type":"status","firmwareVersion":"2.0.0","lastRenderError":%q}`, strings.Repeat("x", 2*defaultReadLimit))
+	require.Greater(t, len(payload), defaultReadLimit)
+	require.NoError(t, conn.WriteMessage(wslib.TextMessage, []byte(payload)))
+
+	var status *DeviceStatus
+	require.Eventually(t, func() bool {
+		status, err = hub.DeviceStatus(context.Background(), "large-status-device")
+		return err == nil && status != nil
+	}, 2*time.Second, 20*time.Millisecond, "status should be stored")
+	assert.Equal(t, "2.0.0", status.FirmwareVersion)
+	assert.True(t, hub.IsConnected("large-status-device"))
+}
+
+func TestHub_ShutdownSendsDisconnect(t *testing.T) {
+	rc, _ := newTestRedis(t)
+	hub := NewHub(rc)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go hub.Run(ctx)
+	time.Sleep(20 * time.Millisecond)
+
+	auth := multiDeviceAuthenticator{}
+	srv := httptest.NewServer(DeviceWebSocketHandler(hub, auth, "http://localhost"))
+	defer srv.Close()
+
+	conn, _, err := wslib.DefaultDialer.Dial(wsDialURL(srv.URL, "/ws/device?token=leaving"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// Wait for the device to register.
+	time.Sleep(100 * time.Millisecond)
+
+	// The server's shutdown sequence
+	cancel()
+	hub.Close()
+	hub.Close()
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second)) //nolint:errcheck
+	var msg Message
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.Equal(t, "disconnect", msg.Type)
+	assert.Equal(t, DisconnectShuttingDown, msg.Reason)
+	assert.Equal(t, ReconnectWithBackoff, msg.Reconnect)
+
+	drainCtx, drainCancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer drainCancel()
+	assert.NoError(t, hub.Drain(drainCtx))
+}
+
+func TestDeviceHandler_ReceivesNoticeBroadcastToAll(t *testing.T) {
+	hub := newTestHub(t)
+
+	sectionID := 91
+	osmUserID := 5
+	device := &db.DeviceCode{
+		DeviceCode:        "notice-test-device",
+		DeviceAccessToken: strPtr("notice-token"),
+		SectionID:         &sectionID,
+		OsmUserID:         &osmUserID,
+	}
+	auth := &stubAuthenticator{user: &stubUser{deviceCode: device}}
+	srv := httptest.NewServer(DeviceWebSocketHandler(hub, auth, "http://localhost"))
+	defer srv.Close()
+
+	conn, _, err := wslib.DefaultDialer.Dial(wsDialURL(srv.URL, "/ws/device?token=notice-token"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// Wait for the device to register.
+	time.Sleep(100 * time.Millisecond)
+
+	hub.BroadcastToAll(NoticeMessage("Maintenance tonight at 9pm"))
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second)) //nolint:errcheck
+	var msg Message
+	err = conn.ReadJSON(&msg)
+	require.NoError(t, err)
+	assert.Equal(t, "notice", msg.Type)
+	assert.Equal(t, "Maintenance tonight at 9pm", msg.Text)
+}
+
+// multiDeviceAuthenticator authenticates each token as its own device, in the
+// section sections gives for the token or section 42.
+type multiDeviceAuthenticator struct {
+	sections map[string]int
+}
+
+func (a multiDeviceAuthenticator) Authenticate(_ context.Context, authHeader string) (types.User, error) {
+	token := strings.TrimPrefix(authHeader, "Bearer ")
+	sectionID, ok := a.sections[token]
+	if !ok {
+		sectionID = 42
+	}
+	osmUserID := 7
+	return &stubUser{deviceCode: &db.DeviceCode{
+		DeviceCode:        "device-" + token,
+		DeviceAccessToken: strPtr(token),
+		SectionID:         &sectionID,
+		OsmUserID:         &osmUserID,
+	}}, nil
+}
+
+func TestDeviceHandler_RejectsConnectionsOverLimit(t *testing.T) {
+	hub := newTestHub(t)
+	hub.SetMaxConnections(2)
+	srv := httptest.NewServer(DeviceWebSocketHandler(hub, multiDeviceAuthenticator{}, "http://localhost"))
+	defer srv.Close()
+
+	for _, token := range []string{"a", "b"} {
+		conn, _, err := wslib.DefaultDialer.Dial(wsDialURL(srv.URL, "/ws/device?token="+token), nil)
+		require.NoError(t, err, "connection %s should be accepted", token)
+		defer conn.Close()
+	}
+	time.Sleep(30 * time.Millisecond)
+
+	_, resp, err := wslib.DefaultDialer.Dial(wsDialURL(srv.URL, "/ws/device?token=c"), nil)
+	require.Error(t, err, "third connection should be refused")
+	require.NotNil(t, resp)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.NotEmpty(t, resp.Header.Get("Retry-After"))
+
+	assert.True(t, hub.IsConnected("device-a"), "existing connections should persist")
+	assert.True(t, hub.IsConnected("device-b"), "existing connections should persist")
+	assert.False(t, hub.IsConnected("device-c"))
+
+	// A connected device reconnecting replaces its connection rather than
+	// counting against the limit.
+	conn, _, err := wslib.DefaultDialer.Dial(wsDialURL(srv.URL, "/ws/device?token=a"), nil)
+	require.NoError(t, err, "reconnecting device should be accepted at the limit")
+	conn.Close()
+}
+
+func TestDeviceHandler_RejectsConnectionsOverSectionLimit(t *testing.T) {
+	hub := newTestHub(t)
+	hub.SetMaxSectionConnections(1)
+	auth := multiDeviceAuthenticator{sections: map[string]int{"other": 43}}
+	srv := httptest.NewServer(DeviceWebSocketHandler(hub, auth, "http://localhost"))
+	defer srv.Close()
+
+	conn, _, err := wslib.DefaultDialer.Dial(wsDialURL(srv.URL, "/ws/device?token=a"), nil)
+	require.NoError(t, err, "first connection to the section should be accepted")
+	defer conn.Close()
+	time.Sleep(30 * time.Millisecond)
+
+	_, resp, err := wslib.DefaultDialer.Dial(wsDialURL(srv.URL, "/ws/device?token=b"), nil)
+	require.Error(t, err, "second connection to the section should be refused")
+	require.NotNil(t, resp)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.NotEmpty(t, resp.Header.Get("Retry-After"))
+
+	other, _, err := wslib.DefaultDialer.Dial(wsDialURL(srv.URL, "/ws/device?token=other"), nil)
+	require.NoError(t, err, "another section should be unaffected")
+	defer other.Close()
+	time.Sleep(30 * time.Millisecond)
+
+	assert.True(t, hub.IsConnected("device-a"))
+	assert.False(t, hub.IsConnected("device-b"))
+	assert.True(t, hub.IsConnected("device-other"))
+}
+
+func TestHub_ReapsConnectionWithoutPong(t *testing.T) {
+	hub := newTestHub(t)
+	srv := httptest.NewServer(DeviceWebSocketHandler(hub, multiDeviceAuthenticator{}, "http://localhost"))
+	defer srv.Close()
+
+	// The client never reads, so it never answers a ping
+	conn, _, err := wslib.DefaultDialer.Dial(wsDialURL(srv.URL, "/ws/device?token=silent"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Eventually(t, func() bool { return hub.IsConnected("device-silent") }, time.Second, 10*time.Millisecond)
+
+	counterValue := func() float64 {
+		var m dto.Metric
+		_ = metrics.WebSocketReapedTotal.Write(&m)
+		return m.GetCounter().GetValue()
+	}
+	initial := counterValue()
+
+	assert.Equal(t, 0, hub.reapStale(time.Now()), "a fresh connection is not stale")
+	assert.Equal(t, 1, hub.reapStale(time.Now().Add(pongTimeout+time.Second)), "a connection with no pong past the timeout is reaped")
+	assert.Equal(t, initial+1, counterValue())
+
+	assert.Eventually(t, func() bool { return !hub.IsConnected("device-silent") }, time.Second, 10*time.Millisecond,
+		"the reaped connection should be unregistered")
+}
+
+// unackedCount returns how many critical messages deviceCode has yet to acknowledge.
+func unackedCount(hub *Hub, deviceCode string) int {
+	hub.mu.RLock()
+	dc := hub.deviceConns[deviceCode]
+	hub.mu.RUnlock()
+	if dc == nil {
+		return 0
+	}
+	dc.ackMu.Lock()
+	defer dc.ackMu.Unlock()
+	return len(dc.unacked)
+}
+
+func TestHub_RetriesUnacknowledgedCriticalMessages(t *testing.T) {
+	hub := newTestHub(t)
+	srv := httptest.NewServer(DeviceWebSocketHandler(hub, multiDeviceAuthenticator{}, "http://localhost"))
+	defer srv.Close()
+
+	dial := func(token string) *wslib.Conn {
+		conn, _, err := wslib.DefaultDialer.Dial(wsDialURL(srv.URL, "/ws/device?token="+token), nil)
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		require.Eventually(t, func() bool { return hub.IsConnected("device-" + token) }, time.Second, 10*time.Millisecond)
+		return conn
+	}
+	read := func(conn *wslib.Conn) Message {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second)) //nolint:errcheck
+		var msg Message
+		require.NoError(t, conn.ReadJSON(&msg))
+		return msg
+	}
+	acker := dial("acker")
+	ignorer := dial("ignorer")
+
+	notice := NoticeMessage("Final scores at 8pm")
+	require.NotEmpty(t, notice.ID, "notices must ask for acknowledgment")
+	hub.BroadcastToAll(notice)
+	// Score refreshes stay fire-and-forget
+	hub.BroadcastToAll(RefreshMessage())
+
+	for _, conn := range []*wslib.Conn{acker, ignorer} {
+		assert.Equal(t, notice.ID, read(conn).ID)
+		assert.Empty(t, read(conn).ID)
+	}
+
+	require.NoError(t, acker.WriteJSON(Message{Type: "ack", ID: notice.ID}))
+	require.Eventually(t, func() bool { return unackedCount(hub, "device-acker") == 0 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, unackedCount(hub, "device-ignorer"))
+
+	now := time.Now()
+	assert.Equal(t, 0, hub.retryUnacked(now), "nothing is resent before the ack timeout")
+	now = now.Add(ackTimeout + time.Second)
+	assert.Equal(t, 1, hub.retryUnacked(now), "only the unacknowledged device is sent the notice again")
+
+	resent := read(ignorer)
+	assert.Equal(t, "notice", resent.Type)
+	assert.Equal(t, notice.ID, resent.ID)
+
+	acker.SetReadDeadline(time.Now().Add(100 * time.Millisecond)) //nolint:errcheck
+	var extra Message
+	assert.Error(t, acker.ReadJSON(&extra), "the device that acknowledged should not be sent the notice again")
+
+	// The hub gives up after maxAckRetries resends
+	for range maxAckRetries - 1 {
+		now = now.Add(ackTimeout)
+		assert.Equal(t, 1, hub.retryUnacked(now))
+		read(ignorer)
+	}
+	now = now.Add(ackTimeout)
+	assert.Equal(t, 0, hub.retryUnacked(now))
+	assert.Equal(t, 0, unackedCount(hub, "device-ignorer"))
+}
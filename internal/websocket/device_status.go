@@ -0,0 +1,74 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// deviceStatusTTL keeps the last report long enough to show the health of a
+	// device that has recently gone offline.
+	deviceStatusTTL = 24 * time.Hour
+	// maxRenderErrorLength bounds the stored render error; devices send free text.
+	maxRenderErrorLength = 200
+)
+
+// DeviceStatus is the most recent health report received from a device.
+type DeviceStatus struct {
+	Uptime            int64     `json:"uptime"`
+	FirmwareVersion   string    `json:"firmwareVersion,omitempty"`
+	FreeMemory        int64     `json:"freeMemory,omitempty"`
+	LastRenderError   string    `json:"lastRenderError,omitempty"`
+	ConnectionQuality int       `json:"connectionQuality,omitempty"`
+	ReportedAt        time.Time `json:"reportedAt"`
+}
+
+func deviceStatusKey(deviceCode string) string {
+	return "device_status:" + deviceCode
+}
+
+// newDeviceStatus extracts the status fields from a "status" message.
+func newDeviceStatus(msg Message, reportedAt time.Time) DeviceStatus {
+	renderError := msg.LastRenderError
+	if len(renderError) > maxRenderErrorLength {
+		renderError = renderError[:maxRenderErrorLength]
+	}
+	return DeviceStatus{
+		Uptime:            msg.Uptime,
+		FirmwareVersion:   msg.FirmwareVersion,
+		FreeMemory:        msg.FreeMemory,
+		LastRenderError:   renderError,
+		ConnectionQuality: msg.ConnectionQuality,
+		ReportedAt:        reportedAt,
+	}
+}
+
+// RecordDeviceStatus persists a device's latest status report.
+func (h *Hub) RecordDeviceStatus(ctx context.Context, deviceCode string, status DeviceStatus) error {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	return h.redis.Set(ctx, deviceStatusKey(deviceCode), data, deviceStatusTTL).Err()
+}
+
+// DeviceStatus returns the last status reported by a device, or nil if it has
+// not reported one recently.
+func (h *Hub) DeviceStatus(ctx context.Context, deviceCode string) (*DeviceStatus, error) {
+	data, err := h.redis.Get(ctx, deviceStatusKey(deviceCode)).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var status DeviceStatus
+	if err := json.Unmarshal([]byte(data), &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
@@ -3,8 +3,11 @@ package websocket
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	ws "github.com/gorilla/websocket"
@@ -17,13 +20,37 @@ const (
 	pongTimeout    = 60 * time.Second
 	idleTimeout    = 30 * time.Minute
 	writeTimeout   = 10 * time.Second
-	readLimit      = 512
 	sendBufferSize = 16
+	// defaultReadLimit caps the size of a message read from a device, in bytes,
+	// unless SetReadLimit chooses otherwise. maxReadLimit bounds that choice.
+	defaultReadLimit = 1024
+	maxReadLimit     = 64 * 1024
 	// redisChanPrefix is the prefix for pub/sub channel names. Not a key prefix.
 	// Full channel names: ws:section:{sectionID} or ws:adhoc:{osmUserID}
 	redisChanPrefix = "ws:"
+	// allDevicesKey is the routing key every hub subscribes to for messages that
+	// go to all connected devices.
+	allDevicesKey = "all"
+	// Default backoff between attempts to re-establish a dropped pub/sub
+	// subscription. Doubles on each consecutive failure up to the maximum.
+	defaultReconnectMinBackoff = time.Second
+	defaultReconnectMaxBackoff = 30 * time.Second
+	// capacityRetryAfter is the Retry-After, in seconds, sent to devices turned
+	// away because the hub is at its connection limit.
+	capacityRetryAfter = 30
+	// drainPollInterval is how often Drain checks for remaining connections.
+	drainPollInterval = 20 * time.Millisecond
+	// ackTimeout is how long a device has to acknowledge a critical message
+	// before it is resent, and maxAckRetries how many times it is resent
+	// before the hub gives up.
+	ackTimeout    = 10 * time.Second
+	maxAckRetries = 3
 )
 
+// ErrTooManyConnections is returned when the hub, or a channel a device would
+// join, is at its connection limit.
+var ErrTooManyConnections = errors.New("websocket hub is at its connection limit")
+
 type subscribeReq struct {
 	channel string
 	respCh  chan error // buffered (size 1) so hub.Run never blocks
@@ -36,6 +63,27 @@ type deviceConn struct {
 	send        chan Message
 	deviceCode  string
 	channelKeys []string // routing keys, e.g. ["section:42", "device:abc123"]
+
+	// lastPong is when the device last answered a ping, in Unix nanoseconds,
+	// starting from when it registered with the hub.
+	lastPong atomic.Int64
+
+	// sendMu guards closing send, so a resend from the retry goroutine never
+	// races the connection being closed.
+	sendMu     sync.Mutex
+	sendClosed bool
+
+	// unacked holds critical messages sent to the device that it has not yet
+	// acknowledged, keyed by message ID.
+	ackMu   sync.Mutex
+	unacked map[string]*unackedMessage
+}
+
+// unackedMessage is a critical message awaiting the device's acknowledgment.
+type unackedMessage struct {
+	msg      Message
+	sentAt   time.Time
+	attempts int // resends so far
 }
 
 // Hub is the in-memory registry of active device WebSocket connections.
@@ -52,6 +100,29 @@ type Hub struct {
 	unsubCh   chan string
 	closeCh   chan struct{}
 	closeOnce sync.Once
+
+	reconnectMinBackoff time.Duration
+	reconnectMaxBackoff time.Duration
+
+	// reapInterval is how often connections are checked for missed pongs, and
+	// staleAfter how long without a pong marks one stale.
+	reapInterval time.Duration
+	staleAfter   time.Duration
+
+	// readLimit is the largest message, in bytes, read from a device.
+	readLimit int64
+
+	// maxConnections caps the devices connected at once, and
+	// maxChannelConnections those on any one routing channel; 0 means no limit.
+	maxConnections        int
+	maxChannelConnections int
+
+	// listSections returns the sections a device shows; nil means only its
+	// primary section.
+	listSections func(*db.DeviceCode) ([]int, error)
+
+	// pubSub is the live subscription owned by Run, exposed for tests.
+	pubSub atomic.Pointer[db.PubSub]
 }
 
 // NewHub creates a new Hub backed by the given RedisClient.
@@ -63,7 +134,83 @@ func NewHub(redis *db.RedisClient) *Hub {
 		subCh:          make(chan subscribeReq, 8),
 		unsubCh:        make(chan string, 8),
 		closeCh:        make(chan struct{}),
+
+		reconnectMinBackoff: defaultReconnectMinBackoff,
+		reconnectMaxBackoff: defaultReconnectMaxBackoff,
+
+		reapInterval: pingInterval,
+		staleAfter:   pongTimeout,
+
+		readLimit: defaultReadLimit,
+	}
+}
+
+// SetReconnectBackoff sets the delay before the first attempt to re-establish
+// a dropped pub/sub subscription and the cap it doubles up to. Call before Run.
+func (h *Hub) SetReconnectBackoff(initial, maximum time.Duration) {
+	h.reconnectMinBackoff = initial
+	h.reconnectMaxBackoff = max(maximum, initial)
+}
+
+// SetMaxConnections caps the number of devices connected to this hub at once.
+// Each connection holds a write-pump goroutine and a send buffer, so an
+// unbounded count lets a flood of connections exhaust memory. 0 means no limit.
+// Call before the hub accepts connections.
+func (h *Hub) SetMaxConnections(n int) {
+	h.maxConnections = n
+}
+
+// SetMaxSectionConnections caps the number of devices connected to any one
+// section, or to one user's ad-hoc teams, so a fleet misconfigured to point at
+// a single section cannot take every connection. 0 means no limit. Call before
+// the hub accepts connections.
+func (h *Hub) SetMaxSectionConnections(n int) {
+	h.maxChannelConnections = n
+}
+
+// SetSectionLister sets how the hub finds every section a device shows, so a
+// device showing several sections is told about changes to any of them.
+// Without one, devices hear only about their primary section. Call before the
+// hub accepts connections.
+func (h *Hub) SetSectionLister(listSections func(*db.DeviceCode) ([]int, error)) {
+	h.listSections = listSections
+}
+
+// SetReadLimit sets the largest message, in bytes, accepted from a device;
+// larger messages close the connection. Values below 1 restore the default and
+// values above 64 KiB are capped. Call before the hub accepts connections.
+func (h *Hub) SetReadLimit(n int) {
+	if n < 1 {
+		n = defaultReadLimit
+	}
+	h.readLimit = int64(min(n, maxReadLimit))
+}
+
+// AtCapacity reports whether a new connection for deviceCode on channelKeys
+// would exceed the hub's connection limit or the limit for one of its
+// channels. A device that is already connected is never at capacity, as its
+// new connection replaces the old one.
+func (h *Hub) AtCapacity(deviceCode string, channelKeys ...string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.atCapacityLocked(deviceCode, channelKeys)
+}
+
+func (h *Hub) atCapacityLocked(deviceCode string, channelKeys []string) bool {
+	if _, ok := h.deviceConns[deviceCode]; ok {
+		return false
+	}
+	if h.maxConnections > 0 && len(h.deviceConns) >= h.maxConnections {
+		return true
+	}
+	if h.maxChannelConnections > 0 {
+		for _, channelKey := range channelKeys {
+			if len(h.channelDevices[channelKey]) >= h.maxChannelConnections {
+				return true
+			}
+		}
 	}
+	return false
 }
 
 func (h *Hub) subscribeSync(ctx context.Context, channel string) error {
@@ -89,7 +236,9 @@ func (h *Hub) subscribeSync(ctx context.Context, channel string) error {
 
 // RegisterDeviceAndSubscribe registers dc and ensures required Redis subscriptions
 // are in place before returning. If subscription fails, the connection is
-// unregistered so the client can retry cleanly.
+// unregistered so the client can retry cleanly. ErrTooManyConnections is
+// returned without registering when the hub or one of the channels is at its
+// connection limit.
 func (h *Hub) RegisterDeviceAndSubscribe(ctx context.Context, deviceCode string, dc *deviceConn, channelKeys ...string) error {
 	needsSub := make([]bool, len(channelKeys))
 	var toUnsub []string
@@ -97,6 +246,13 @@ func (h *Hub) RegisterDeviceAndSubscribe(ctx context.Context, deviceCode string,
 
 	h.mu.Lock()
 
+	// The handler checks capacity before upgrading; check again here as other
+	// connections may have registered in the meantime.
+	if h.atCapacityLocked(deviceCode, channelKeys) {
+		h.mu.Unlock()
+		return ErrTooManyConnections
+	}
+
 	// If this device is already connected, replace the old connection.
 	if old := h.deviceConns[deviceCode]; old != nil && old != dc {
 		replaced = old
@@ -114,6 +270,7 @@ func (h *Hub) RegisterDeviceAndSubscribe(ctx context.Context, deviceCode string,
 	}
 
 	// Register the new connection.
+	dc.lastPong.Store(time.Now().UnixNano())
 	h.deviceConns[deviceCode] = dc
 	for i, channelKey := range channelKeys {
 		if h.channelDevices[channelKey] == nil {
@@ -163,13 +320,12 @@ func (h *Hub) RegisterDeviceAndSubscribe(ctx context.Context, deviceCode string,
 		}
 	}
 
-	// Ask the replaced connection to disconnect (outside the hub lock).
+	// Ask the replaced connection to disconnect (outside the hub lock). Critical
+	// messages it had not acknowledged are resent on the new connection.
 	if replaced != nil {
-		select {
-		case replaced.send <- DisconnectMessage("replaced by new connection"):
-		default:
-		}
-		close(replaced.send)
+		dc.adoptUnacked(replaced)
+		replaced.trySend(DisconnectMessage(DisconnectReplaced))
+		replaced.closeSend()
 		if replaced.conn != nil {
 			replaced.conn.Close() //nolint:errcheck
 		}
@@ -256,6 +412,11 @@ func (h *Hub) BroadcastToDevice(deviceCode string, msg Message) {
 	h.publish("device:"+deviceCode, msg)
 }
 
+// BroadcastToAll publishes msg to every connected device on every instance.
+func (h *Hub) BroadcastToAll(msg Message) {
+	h.publish(allDevicesKey, msg)
+}
+
 // publish sends msg to the Redis pub/sub channel for channelKey.
 func (h *Hub) publish(channelKey string, msg Message) {
 	channel := redisChanPrefix + channelKey
@@ -271,9 +432,186 @@ func (h *Hub) publish(channelKey string, msg Message) {
 
 // Run starts the hub's Redis pub/sub listener. Call it in a goroutine.
 // It blocks until ctx is cancelled or Close is called.
+//
+// If the pub/sub event stream ends unexpectedly, Run re-subscribes to every
+// channel currently in use, backing off between attempts.
 func (h *Hub) Run(ctx context.Context) {
-	pubSub := h.redis.Subscribe(ctx)
+	// pendingSubs maps a fully-prefixed channel name to the response channel
+	// of the subscribeSync call awaiting Redis confirmation. It survives
+	// reconnects: the channel is tracked in channelDevices, so the new
+	// subscription includes it and its confirmation unblocks the caller.
+	pendingSubs := make(map[string]chan<- error)
+
+	go h.runReaper(ctx)
+	go h.runAckRetries(ctx)
+
+	backoff := h.reconnectMinBackoff
+	for {
+		reconnect, healthy := h.listen(ctx, pendingSubs)
+		if !reconnect {
+			return
+		}
+		if healthy {
+			backoff = h.reconnectMinBackoff
+		}
+
+		slog.Warn("websocket.hub.pubsub_closed",
+			"component", "websocket",
+			"event", "hub.pubsub_closed",
+			"retry_in", backoff,
+		)
+		if !h.waitForReconnect(ctx, backoff, pendingSubs) {
+			return
+		}
+		backoff = min(backoff*2, h.reconnectMaxBackoff)
+
+		metrics.WebSocketPubSubReconnectsTotal.Inc()
+		slog.Info("websocket.hub.pubsub_reconnecting",
+			"component", "websocket",
+			"event", "hub.pubsub_reconnect",
+		)
+	}
+}
+
+// runReaper closes stale connections every reapInterval until ctx is cancelled
+// or the hub is closed.
+func (h *Hub) runReaper(ctx context.Context) {
+	ticker := time.NewTicker(h.reapInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-h.closeCh:
+			return
+		case now := <-ticker.C:
+			h.reapStale(now)
+		}
+	}
+}
+
+// reapStale closes every connection that has not answered a ping within
+// staleAfter of now, returning how many it closed. readPump's read deadline
+// normally catches these first; the reaper covers a connection whose deadline
+// failed to fire, such as one left half-open without a FIN. Closing the
+// connection ends its pumps, which unregister it.
+func (h *Hub) reapStale(now time.Time) int {
+	h.mu.RLock()
+	var stale []*deviceConn
+	for _, dc := range h.deviceConns {
+		if dc.conn != nil && now.Sub(time.Unix(0, dc.lastPong.Load())) > h.staleAfter {
+			stale = append(stale, dc)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, dc := range stale {
+		metrics.WebSocketReapedTotal.Inc()
+		slog.Warn("websocket.hub.reaped",
+			"component", "websocket",
+			"event", "hub.reaped",
+			"device_code_prefix", dc.deviceCode[:min(8, len(dc.deviceCode))],
+			"last_pong", time.Unix(0, dc.lastPong.Load()),
+		)
+		_ = dc.conn.Close()
+	}
+	return len(stale)
+}
+
+// runAckRetries resends unacknowledged critical messages until ctx is
+// cancelled or the hub is closed.
+func (h *Hub) runAckRetries(ctx context.Context) {
+	ticker := time.NewTicker(ackTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-h.closeCh:
+			return
+		case now := <-ticker.C:
+			h.retryUnacked(now)
+		}
+	}
+}
+
+// retryUnacked resends every critical message that has gone unacknowledged
+// for ackTimeout as of now, returning how many it resent. A message still
+// unacknowledged after maxAckRetries resends is given up on.
+func (h *Hub) retryUnacked(now time.Time) int {
+	h.mu.RLock()
+	conns := make([]*deviceConn, 0, len(h.deviceConns))
+	for _, dc := range h.deviceConns {
+		conns = append(conns, dc)
+	}
+	h.mu.RUnlock()
+
+	resent := 0
+	for _, dc := range conns {
+		for _, msg := range dc.dueForResend(now) {
+			if dc.trySend(msg) {
+				resent++
+			}
+		}
+	}
+	return resent
+}
+
+// subscribedChannels returns the prefixed pub/sub channels the hub needs:
+// the all-devices channel plus every channel with a local device.
+func (h *Hub) subscribedChannels() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	channels := make([]string, 0, len(h.channelDevices)+1)
+	channels = append(channels, redisChanPrefix+allDevicesKey)
+	for channelKey := range h.channelDevices {
+		channels = append(channels, redisChanPrefix+channelKey)
+	}
+	return channels
+}
+
+// waitForReconnect sleeps for backoff before a reconnect attempt. Subscribe
+// requests arriving meanwhile are held in pendingSubs for the new subscription
+// to confirm; unsubscribe requests are dropped as the new subscription only
+// covers channels still in use. It returns false if the hub shut down.
+func (h *Hub) waitForReconnect(ctx context.Context, backoff time.Duration, pendingSubs map[string]chan<- error) bool {
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			h.closeAllConnections(DisconnectShuttingDown)
+			return false
+		case <-h.closeCh:
+			h.closeAllConnections(DisconnectHubClosed)
+			return false
+		case req := <-h.subCh:
+			pendingSubs[req.channel] = req.respCh
+		case <-h.unsubCh:
+		case <-timer.C:
+			return true
+		}
+	}
+}
+
+// listen subscribes to the hub's channels and dispatches events until the hub
+// shuts down or the event stream ends. reconnect reports whether the stream
+// ended unexpectedly; healthy whether any event was received before it did.
+func (h *Hub) listen(ctx context.Context, pendingSubs map[string]chan<- error) (reconnect, healthy bool) {
+	channels := h.subscribedChannels()
+	pubSub := h.redis.Subscribe(ctx, channels...)
 	defer pubSub.Close()
+	h.pubSub.Store(pubSub)
+
+	// subscribed tracks what this pubSub is subscribed to. Devices may come and
+	// go while the initial subscription is in flight, so once Redis has
+	// confirmed all of it the set is reconciled against channelDevices.
+	subscribed := make(map[string]struct{}, len(channels))
+	unconfirmed := make(map[string]struct{}, len(channels))
+	for _, channel := range channels {
+		subscribed[channel] = struct{}{}
+		unconfirmed[channel] = struct{}{}
+	}
 
 	// Events() uses ChannelWithSubscriptions so we receive both subscription
 	// confirmations and actual messages. This allows subscribeSync to wait
@@ -281,18 +619,14 @@ func (h *Hub) Run(ctx context.Context) {
 	// eliminating the race between SUBSCRIBE and a subsequent PUBLISH.
 	eventCh := pubSub.Events()
 
-	// pendingSubs maps a fully-prefixed channel name to the response channel
-	// of the subscribeSync call awaiting Redis confirmation.
-	pendingSubs := make(map[string]chan<- error)
-
 	for {
 		select {
 		case <-ctx.Done():
-			h.closeAllConnections("server shutting down")
-			return
+			h.closeAllConnections(DisconnectShuttingDown)
+			return false, healthy
 		case <-h.closeCh:
-			h.closeAllConnections("hub closed")
-			return
+			h.closeAllConnections(DisconnectHubClosed)
+			return false, healthy
 
 		case req := <-h.subCh:
 			err := pubSub.Subscribe(ctx, req.channel)
@@ -305,6 +639,7 @@ func (h *Hub) Run(ctx context.Context) {
 			} else {
 				// Confirmation will arrive as a PubSubSubscribed event.
 				pendingSubs[req.channel] = req.respCh
+				subscribed[req.channel] = struct{}{}
 			}
 
 		case channel := <-h.unsubCh:
@@ -315,12 +650,15 @@ func (h *Hub) Run(ctx context.Context) {
 					"channel", channel,
 					"error", err,
 				)
+			} else {
+				delete(subscribed, channel)
 			}
 
 		case event, ok := <-eventCh:
 			if !ok {
-				return
+				return true, healthy
 			}
+			healthy = true
 			switch event.Kind {
 			case db.PubSubSubscribed:
 				// Redis confirmed the subscription; unblock the waiting subscribeSync.
@@ -331,6 +669,12 @@ func (h *Hub) Run(ctx context.Context) {
 					default:
 					}
 				}
+				if _, ok := unconfirmed[event.Channel]; ok {
+					delete(unconfirmed, event.Channel)
+					if len(unconfirmed) == 0 {
+						h.reconcileSubscriptions(ctx, pubSub, subscribed)
+					}
+				}
 
 			case db.PubSubMessage:
 				if len(event.Channel) <= len(redisChanPrefix) {
@@ -353,25 +697,96 @@ func (h *Hub) Run(ctx context.Context) {
 	}
 }
 
+// reconcileSubscriptions diffs subscribed against the channels devices are
+// currently registered on, subscribing to any that are missing and dropping
+// any left over from devices that have gone. subscribed is updated to match.
+func (h *Hub) reconcileSubscriptions(ctx context.Context, pubSub *db.PubSub, subscribed map[string]struct{}) {
+	wanted := make(map[string]struct{})
+	for _, channel := range h.subscribedChannels() {
+		wanted[channel] = struct{}{}
+	}
+
+	var missing, stale []string
+	for channel := range wanted {
+		if _, ok := subscribed[channel]; !ok {
+			missing = append(missing, channel)
+		}
+	}
+	for channel := range subscribed {
+		if _, ok := wanted[channel]; !ok {
+			stale = append(stale, channel)
+		}
+	}
+	if len(missing) == 0 && len(stale) == 0 {
+		return
+	}
+
+	slog.Warn("websocket.hub.subscriptions_reconciled",
+		"component", "websocket",
+		"event", "hub.reconcile",
+		"missing", missing,
+		"stale", stale,
+	)
+	if len(missing) > 0 {
+		if err := pubSub.Subscribe(ctx, missing...); err != nil {
+			slog.Error("websocket.hub.reconcile_subscribe_failed",
+				"component", "websocket",
+				"event", "hub.subscribe_error",
+				"channels", missing,
+				"error", err,
+			)
+		} else {
+			for _, channel := range missing {
+				subscribed[channel] = struct{}{}
+			}
+		}
+	}
+	if len(stale) > 0 {
+		if err := pubSub.Unsubscribe(ctx, stale...); err != nil {
+			slog.Error("websocket.hub.reconcile_unsubscribe_failed",
+				"component", "websocket",
+				"event", "hub.unsubscribe_error",
+				"channels", stale,
+				"error", err,
+			)
+		} else {
+			for _, channel := range stale {
+				delete(subscribed, channel)
+			}
+		}
+	}
+}
+
 // deliverToChannel sends msg to all locally-registered devices for channelKey.
 func (h *Hub) deliverToChannel(channelKey string, msg Message) {
 	h.mu.RLock()
-	devs := h.channelDevices[channelKey]
-	conns := make([]*deviceConn, 0, len(devs))
-	for code := range devs {
-		if dc, ok := h.deviceConns[code]; ok {
+	var conns []*deviceConn
+	if channelKey == allDevicesKey {
+		conns = make([]*deviceConn, 0, len(h.deviceConns))
+		for _, dc := range h.deviceConns {
 			conns = append(conns, dc)
 		}
+	} else {
+		devs := h.channelDevices[channelKey]
+		conns = make([]*deviceConn, 0, len(devs))
+		for code := range devs {
+			if dc, ok := h.deviceConns[code]; ok {
+				conns = append(conns, dc)
+			}
+		}
 	}
 	h.mu.RUnlock()
 
 	for _, dc := range conns {
+		// Critical messages are tracked before sending, so one dropped below
+		// is still resent until the device acknowledges it.
+		if msg.ID != "" {
+			dc.trackAck(msg, time.Now())
+		}
 		// Per-connection send is deliberately non-blocking:
 		// a slow/unhealthy client must not stall delivery to all other clients.
 		// When the buffer is full we drop the message and rely on the next refresh/update.
-		select {
-		case dc.send <- msg:
-		default:
+		if !dc.trySend(msg) {
 			slog.Warn("websocket.hub.send_buffer_full",
 				"component", "websocket",
 				"event", "hub.drop_message",
@@ -382,6 +797,104 @@ func (h *Hub) deliverToChannel(channelKey string, msg Message) {
 	}
 }
 
+// trySend queues msg for the device without blocking, reporting whether it
+// was queued. It is a no-op once the send channel is closed.
+func (dc *deviceConn) trySend(msg Message) bool {
+	dc.sendMu.Lock()
+	defer dc.sendMu.Unlock()
+	if dc.sendClosed {
+		return false
+	}
+	select {
+	case dc.send <- msg:
+		return true
+	default:
+		return false
+	}
+}
+
+// closeSend closes the send channel, ending the write pump. Safe to call more
+// than once.
+func (dc *deviceConn) closeSend() {
+	dc.sendMu.Lock()
+	defer dc.sendMu.Unlock()
+	if !dc.sendClosed {
+		dc.sendClosed = true
+		close(dc.send)
+	}
+}
+
+// trackAck records that msg was sent at now and awaits acknowledgment.
+func (dc *deviceConn) trackAck(msg Message, now time.Time) {
+	dc.ackMu.Lock()
+	defer dc.ackMu.Unlock()
+	if dc.unacked == nil {
+		dc.unacked = make(map[string]*unackedMessage)
+	}
+	dc.unacked[msg.ID] = &unackedMessage{msg: msg, sentAt: now}
+}
+
+// acknowledge stops resending the message with id, reporting whether it was
+// awaiting acknowledgment.
+func (dc *deviceConn) acknowledge(id string) bool {
+	dc.ackMu.Lock()
+	defer dc.ackMu.Unlock()
+	if _, ok := dc.unacked[id]; !ok {
+		return false
+	}
+	delete(dc.unacked, id)
+	return true
+}
+
+// dueForResend returns the messages unacknowledged for ackTimeout as of now,
+// counting this as a resend, and gives up on those resent maxAckRetries times.
+func (dc *deviceConn) dueForResend(now time.Time) []Message {
+	dc.ackMu.Lock()
+	defer dc.ackMu.Unlock()
+	var due []Message
+	for id, pending := range dc.unacked {
+		if now.Sub(pending.sentAt) < ackTimeout {
+			continue
+		}
+		if pending.attempts >= maxAckRetries {
+			delete(dc.unacked, id)
+			slog.Warn("websocket.hub.ack_abandoned",
+				"component", "websocket",
+				"event", "hub.ack_abandoned",
+				"device_code_prefix", dc.deviceCode[:min(8, len(dc.deviceCode))],
+				"message_type", pending.msg.Type,
+				"message_id", id,
+			)
+			continue
+		}
+		pending.attempts++
+		pending.sentAt = now
+		due = append(due, pending.msg)
+	}
+	return due
+}
+
+// adoptUnacked takes over the messages old was waiting on the device to
+// acknowledge, so a reconnecting device still receives them.
+func (dc *deviceConn) adoptUnacked(old *deviceConn) {
+	old.ackMu.Lock()
+	pending := old.unacked
+	old.unacked = nil
+	old.ackMu.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+
+	dc.ackMu.Lock()
+	defer dc.ackMu.Unlock()
+	if dc.unacked == nil {
+		dc.unacked = make(map[string]*unackedMessage, len(pending))
+	}
+	for id, msg := range pending {
+		dc.unacked[id] = msg
+	}
+}
+
 // closeAllConnections sends a disconnect message to every connected device and
 // closes their send channels, causing their write pumps to terminate.
 func (h *Hub) closeAllConnections(reason string) {
@@ -394,11 +907,8 @@ func (h *Hub) closeAllConnections(reason string) {
 
 	msg := DisconnectMessage(reason)
 	for _, dc := range conns {
-		select {
-		case dc.send <- msg:
-		default:
-		}
-		close(dc.send)
+		dc.trySend(msg)
+		dc.closeSend()
 	}
 }
 
@@ -407,6 +917,27 @@ func (h *Hub) Close() {
 	h.closeOnce.Do(func() { close(h.closeCh) })
 }
 
+// Drain waits until every device has disconnected from the hub, or ctx is done.
+// Call it after cancelling Run's context or calling Close, which tell devices
+// to go, so their disconnect messages are written before the process exits.
+func (h *Hub) Drain(ctx context.Context) error {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for {
+		h.mu.RLock()
+		remaining := len(h.deviceConns)
+		h.mu.RUnlock()
+		if remaining == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%d websocket connections still open: %w", remaining, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
 // writePump runs in a goroutine per device. It writes outgoing messages,
 // sends periodic pings, and closes the connection after idleTimeout.
 func (dc *deviceConn) writePump() {
@@ -447,24 +978,26 @@ func (dc *deviceConn) writePump() {
 			}
 
 		case <-idleTimer.C:
-			dc.conn.SetWriteDeadline(time.Now().Add(writeTimeout)) //nolint:errcheck
-			dc.conn.WriteJSON(DisconnectMessage("idle timeout"))   //nolint:errcheck
+			dc.conn.SetWriteDeadline(time.Now().Add(writeTimeout))      //nolint:errcheck
+			dc.conn.WriteJSON(DisconnectMessage(DisconnectIdleTimeout)) //nolint:errcheck
 			return
 		}
 	}
 }
 
 // readPump runs in the handler goroutine. It reads incoming messages from the
-// device, logging "status" payloads. When it returns the device is unregistered.
+// device, recording "status" payloads and acknowledgments of critical
+// messages. When it returns the device is unregistered.
 func (dc *deviceConn) readPump() {
 	defer func() {
 		dc.hub.UnregisterDeviceConn(dc)
 		dc.conn.Close()
 	}()
 
-	dc.conn.SetReadLimit(readLimit)
+	dc.conn.SetReadLimit(dc.hub.readLimit)
 	dc.conn.SetReadDeadline(time.Now().Add(pongTimeout)) //nolint:errcheck
 	dc.conn.SetPongHandler(func(string) error {
+		dc.lastPong.Store(time.Now().UnixNano())
 		return dc.conn.SetReadDeadline(time.Now().Add(pongTimeout))
 	})
 
@@ -485,14 +1018,34 @@ func (dc *deviceConn) readPump() {
 			break
 		}
 
-		if msg.Type == "status" {
+		switch msg.Type {
+		case "ack":
+			if !dc.acknowledge(msg.ID) {
+				slog.Debug("websocket.device.unexpected_ack",
+					"component", "websocket",
+					"event", "device.unexpected_ack",
+					"device_code_prefix", dc.deviceCode[:min(8, len(dc.deviceCode))],
+					"message_id", msg.ID,
+				)
+			}
+		case "status":
 			slog.Debug("websocket.device.status",
 				"component", "websocket",
 				"event", "device.status",
 				"device_code_prefix", dc.deviceCode[:min(8, len(dc.deviceCode))],
 				"channel_keys", dc.channelKeys,
 				"uptime", msg.Uptime,
+				"firmware_version", msg.FirmwareVersion,
 			)
+			status := newDeviceStatus(msg, time.Now())
+			if err := dc.hub.RecordDeviceStatus(context.Background(), dc.deviceCode, status); err != nil {
+				slog.Warn("websocket.device.status_store_failed",
+					"component", "websocket",
+					"event", "device.status_error",
+					"device_code_prefix", dc.deviceCode[:min(8, len(dc.deviceCode))],
+					"error", err,
+				)
+			}
 		}
 	}
 }
@@ -8,6 +8,7 @@ import (
 	"github.com/alicebob/miniredis/v2"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/db"
 	"github.com/m0rjc/OsmDeviceAdapter/internal/metrics"
+	"github.com/m0rjc/OsmDeviceAdapter/internal/types"
 	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -181,11 +182,12 @@ func TestBroadcastToSectionDelivery(t *testing.T) {
 	defer regCancel()
 	require.NoError(t, hub.RegisterDeviceAndSubscribe(regCtx, "device-xyz", dc, "section:99", "device:device-xyz"))
 
-	hub.BroadcastToSection("99", RefreshScoresMessage())
+	hub.BroadcastToSection("99", RefreshScoresMessage([]types.PatrolScore{{ID: "1", Name: "Eagles", Score: 50}}))
 
 	select {
 	case msg := <-send:
 		assert.Equal(t, "refresh-scores", msg.Type)
+		assert.Equal(t, []types.PatrolScore{{ID: "1", Name: "Eagles", Score: 50}}, msg.Patrols)
 	case <-time.After(2 * time.Second):
 		t.Fatal("timed out waiting for refresh-scores message")
 	}
@@ -219,7 +221,7 @@ func TestBroadcastToUnknownSectionIsNoop(t *testing.T) {
 	_ = startHub(t, hub)
 
 	// No device registered for section 55 — BroadcastToSection must not panic.
-	hub.BroadcastToSection("55", RefreshScoresMessage())
+	hub.BroadcastToSection("55", RefreshScoresMessage(nil))
 }
 
 func TestCloseDisconnectsDevices(t *testing.T) {
@@ -246,3 +248,96 @@ func TestCloseDisconnectsDevices(t *testing.T) {
 		t.Fatal("timed out waiting for disconnect on hub.Close()")
 	}
 }
+
+func TestPubSubReconnectResubscribes(t *testing.T) {
+	rc, _ := newTestRedis(t)
+	hub := NewHub(rc)
+	hub.SetReconnectBackoff(10*time.Millisecond, 50*time.Millisecond)
+	ctx := startHub(t, hub)
+
+	send := make(chan Message, 16)
+	dc := &deviceConn{hub: hub, send: send, deviceCode: "device-rc", channelKeys: []string{"section:77", "device:device-rc"}}
+
+	regCtx, regCancel := context.WithTimeout(ctx, 2*time.Second)
+	defer regCancel()
+	require.NoError(t, hub.RegisterDeviceAndSubscribe(regCtx, "device-rc", dc, "section:77", "device:device-rc"))
+
+	reconnectsBefore := testCounterValue(metrics.WebSocketPubSubReconnectsTotal)
+
+	// Closing the subscription ends its event channel, as a dropped connection would.
+	original := hub.pubSub.Load()
+	require.NotNil(t, original)
+	require.NoError(t, original.Close())
+
+	require.Eventually(t, func() bool {
+		current := hub.pubSub.Load()
+		return current != nil && current != original
+	}, 2*time.Second, 10*time.Millisecond, "hub should re-subscribe after the event channel closes")
+	assert.Equal(t, reconnectsBefore+1, testCounterValue(metrics.WebSocketPubSubReconnectsTotal))
+
+	// The new subscription is confirmed asynchronously, so keep publishing
+	// until a message gets through.
+	require.Eventually(t, func() bool {
+		hub.BroadcastToSection("77", RefreshScoresMessage(nil))
+		select {
+		case msg := <-send:
+			return msg.Type == "refresh-scores"
+		case <-time.After(50 * time.Millisecond):
+			return false
+		}
+	}, 2*time.Second, 10*time.Millisecond, "delivery should resume on re-subscribed channels")
+}
+
+func testCounterValue(c interface{ Write(*dto.Metric) error }) float64 {
+	var m dto.Metric
+	_ = c.Write(&m)
+	return m.GetCounter().GetValue()
+}
+
+func TestPubSubReconnectSubscribesOnlyLiveChannels(t *testing.T) {
+	rc, mr := newTestRedis(t)
+	hub := NewHub(rc)
+	hub.SetReconnectBackoff(200*time.Millisecond, 200*time.Millisecond)
+	ctx := startHub(t, hub)
+
+	regCtx, regCancel := context.WithTimeout(ctx, 2*time.Second)
+	defer regCancel()
+	stays := &deviceConn{hub: hub, send: make(chan Message, 4), deviceCode: "device-stays", channelKeys: []string{"section:1", "device:device-stays"}}
+	leaves := &deviceConn{hub: hub, send: make(chan Message, 4), deviceCode: "device-leaves", channelKeys: []string{"section:2", "device:device-leaves"}}
+	require.NoError(t, hub.RegisterDeviceAndSubscribe(regCtx, "device-stays", stays, stays.channelKeys...))
+	require.NoError(t, hub.RegisterDeviceAndSubscribe(regCtx, "device-leaves", leaves, leaves.channelKeys...))
+
+	original := hub.pubSub.Load()
+	require.NoError(t, original.Close())
+
+	// Disconnect while the hub is backing off, before it re-subscribes.
+	hub.UnregisterDeviceConn(leaves)
+
+	require.Eventually(t, func() bool {
+		current := hub.pubSub.Load()
+		return current != original
+	}, 2*time.Second, 10*time.Millisecond)
+
+	want := []string{"ws:all", "ws:device:device-stays", "ws:section:1"}
+	require.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual(want, mr.PubSubChannels(""))
+	}, 2*time.Second, 10*time.Millisecond, "only live channels should be subscribed, got %v", mr.PubSubChannels(""))
+}
+
+func TestReconcileSubscriptions(t *testing.T) {
+	rc, mr := newTestRedis(t)
+	hub := NewHub(rc)
+	hub.channelDevices["section:1"] = map[string]struct{}{"device-a": {}}
+
+	ctx := context.Background()
+	pubSub := rc.Subscribe(ctx, "ws:all", "ws:section:stale")
+	defer pubSub.Close()
+	subscribed := map[string]struct{}{"ws:all": {}, "ws:section:stale": {}}
+
+	hub.reconcileSubscriptions(ctx, pubSub, subscribed)
+
+	assert.Equal(t, map[string]struct{}{"ws:all": {}, "ws:section:1": {}}, subscribed)
+	require.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual([]string{"ws:all", "ws:section:1"}, mr.PubSubChannels(""))
+	}, 2*time.Second, 10*time.Millisecond, "got %v", mr.PubSubChannels(""))
+}
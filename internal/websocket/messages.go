@@ -1,21 +1,95 @@
 package websocket
 
+import (
+	"crypto/rand"
+
+	"github.com/m0rjc/OsmDeviceAdapter/internal/types"
+)
+
 // Message is a JSON message sent or received on the device WebSocket.
 type Message struct {
 	Type     string `json:"type"`
 	Reason   string `json:"reason,omitempty"`   // used in "disconnect" messages
 	Uptime   int64  `json:"uptime,omitempty"`   // used in "status" messages (device→server)
 	Duration int    `json:"duration,omitempty"` // used in "timer-start" messages (seconds)
+	Text     string `json:"text,omitempty"`     // used in "notice" messages
+
+	// ID marks a critical server→device message that the device must
+	// acknowledge by sending back an "ack" message with the same ID. The hub
+	// resends it until then. Also used in "ack" messages (device→server).
+	ID string `json:"id,omitempty"`
+
+	// Reconnect guidance, sent in "disconnect" messages: whether the device
+	// should reconnect (one of the Reconnect constants) and, if so, how many
+	// seconds to wait first.
+	Reconnect      string `json:"reconnect,omitempty"`
+	ReconnectAfter int    `json:"reconnectAfter,omitempty"`
+
+	// Patrols changed by a score update, with their new scores, sent in
+	// "refresh-scores" messages so devices can show them before reloading.
+	Patrols []types.PatrolScore `json:"patrols,omitempty"`
+
+	// Device health, also sent in "status" messages. All are optional so older
+	// firmware that only reports uptime keeps working.
+	FirmwareVersion   string `json:"firmwareVersion,omitempty"`
+	FreeMemory        int64  `json:"freeMemory,omitempty"`        // bytes
+	LastRenderError   string `json:"lastRenderError,omitempty"`   // most recent display error, if any
+	ConnectionQuality int    `json:"connectionQuality,omitempty"` // signal quality, 0-100
 }
 
 // RefreshScoresMessage creates a server→device message asking the device to reload scores.
-func RefreshScoresMessage() Message {
-	return Message{Type: "refresh-scores"}
+// patrols, which may be empty, are the patrols whose scores just changed.
+func RefreshScoresMessage(patrols []types.PatrolScore) Message {
+	return Message{Type: "refresh-scores", Patrols: patrols}
+}
+
+// RefreshMessage creates a server→device message asking the device to reload
+// all scores now, for when a leader wants boards to catch up with a change
+// made outside this service, such as a correction in OSM.
+func RefreshMessage() Message {
+	return RefreshScoresMessage(nil)
 }
 
-// DisconnectMessage creates a server→device message indicating the connection is closing.
+// Reasons given in "disconnect" messages.
+const (
+	DisconnectReplaced     = "replaced by new connection"
+	DisconnectIdleTimeout  = "idle timeout"
+	DisconnectShuttingDown = "server shutting down"
+	DisconnectHubClosed    = "hub closed"
+)
+
+// Values for Message.Reconnect.
+const (
+	// ReconnectNever: another connection has taken over; reconnecting would
+	// only displace it.
+	ReconnectNever = "never"
+	// ReconnectWhenActive: reconnect once there is something to show, such as
+	// after the next score poll.
+	ReconnectWhenActive = "when-active"
+	// ReconnectWithBackoff: reconnect after ReconnectAfter seconds, backing off
+	// if that fails, as the server may still be restarting.
+	ReconnectWithBackoff = "backoff"
+)
+
+// restartReconnectDelay is the suggested wait, in seconds, before reconnecting
+// to a server that is going away. A replacement is usually up within it.
+const restartReconnectDelay = 5
+
+// DisconnectMessage creates a server→device message indicating the connection is
+// closing, with guidance on whether and when the device should reconnect. An
+// unrecognised reason gets the cautious guidance for a restart.
 func DisconnectMessage(reason string) Message {
-	return Message{Type: "disconnect", Reason: reason}
+	msg := Message{Type: "disconnect", Reason: reason}
+	switch reason {
+	case DisconnectReplaced:
+		msg.Reconnect = ReconnectNever
+	case DisconnectIdleTimeout:
+		msg.Reconnect = ReconnectWhenActive
+	default:
+		msg.Reconnect = ReconnectWithBackoff
+		msg.ReconnectAfter = restartReconnectDelay
+	}
+	return msg
 }
 
 // ReconnectMessage creates a server→device message asking the device to drop
@@ -44,3 +118,18 @@ func TimerResumeMessage() Message {
 func TimerResetMessage() Message {
 	return Message{Type: "timer-reset"}
 }
+
+// NoticeMessage creates a server→device message asking the device to show a
+// service announcement banner, e.g. for planned maintenance. Notices are
+// critical, so devices must acknowledge them.
+func NoticeMessage(text string) Message {
+	return CriticalMessage(Message{Type: "notice", Text: text})
+}
+
+// CriticalMessage gives msg a new ID so devices must acknowledge it and the
+// hub resends it to any device that does not. Keep it for messages a device
+// must not miss; routine updates are fire-and-forget.
+func CriticalMessage(msg Message) Message {
+	msg.ID = rand.Text()
+	return msg
+}
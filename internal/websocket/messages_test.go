@@ -0,0 +1,30 @@
+package websocket
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDisconnectMessage_ReconnectGuidance(t *testing.T) {
+	tests := []struct {
+		reason    string
+		reconnect string
+		after     int
+	}{
+		{DisconnectReplaced, ReconnectNever, 0},
+		{DisconnectIdleTimeout, ReconnectWhenActive, 0},
+		{DisconnectShuttingDown, ReconnectWithBackoff, restartReconnectDelay},
+		{DisconnectHubClosed, ReconnectWithBackoff, restartReconnectDelay},
+		{"something unexpected", ReconnectWithBackoff, restartReconnectDelay},
+	}
+	for _, tt := range tests {
+		t.Run(tt.reason, func(t *testing.T) {
+			msg := DisconnectMessage(tt.reason)
+			assert.Equal(t, "disconnect", msg.Type)
+			assert.Equal(t, tt.reason, msg.Reason)
+			assert.Equal(t, tt.reconnect, msg.Reconnect)
+			assert.Equal(t, tt.after, msg.ReconnectAfter)
+		})
+	}
+}
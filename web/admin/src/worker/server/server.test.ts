@@ -273,8 +273,8 @@ describe('OsmAdapterApiService', () => {
     it('should fetch sections successfully', async () => {
       const sectionsResponse: api.SectionsResponse = {
         sections: [
-          { id: 1, name: 'Beavers', groupName: 'Test Group' },
-          { id: 2, name: 'Cubs', groupName: 'Test Group' },
+          { id: 1, name: 'Beavers', groupName: 'Test Group', canEditScores: true },
+          { id: 2, name: 'Cubs', groupName: 'Test Group', canEditScores: false },
         ],
       };
 
@@ -289,8 +289,8 @@ describe('OsmAdapterApiService', () => {
         credentials: 'same-origin',
       });
       expect(sections).toEqual([
-        { id: 1, name: 'Beavers', groupName: 'Test Group' },
-        { id: 2, name: 'Cubs', groupName: 'Test Group' },
+        { id: 1, name: 'Beavers', groupName: 'Test Group', canEditScores: true },
+        { id: 2, name: 'Cubs', groupName: 'Test Group', canEditScores: false },
       ]);
     });
 
@@ -7,12 +7,14 @@ import * as model from '../../types/model';
 export class ApiError extends Error {
   statusCode: number;
   errorCode: string;
+  fields?: api.FieldError[];
 
-  constructor(statusCode: number, errorCode: string, message: string) {
+  constructor(statusCode: number, errorCode: string, message: string, fields?: api.FieldError[]) {
     super(message);
     this.name = 'ApiError';
     this.statusCode = statusCode;
     this.errorCode = errorCode;
+    this.fields = fields;
   }
 }
 
@@ -173,7 +175,7 @@ export class OsmAdapterApiService {
       } catch {
         throw new ApiError(response.status, 'unknown_error', 'An unexpected error occurred');
       }
-      throw new ApiError(response.status, errorData.error, errorData.message);
+      throw new ApiError(response.status, errorData.error, errorData.message, errorData.fields);
     }
     return response.json();
   }
@@ -369,7 +371,7 @@ export class OsmAdapterApiService {
       } catch {
         throw new ApiError(response.status, 'unknown_error', 'Failed to delete patrol');
       }
-      throw new ApiError(response.status, errorData.error, errorData.message);
+      throw new ApiError(response.status, errorData.error, errorData.message, errorData.fields);
     }
   }
 
@@ -20,6 +20,8 @@ export interface Section {
   id: number;
   name: string;
   groupName: string;
+  /** False when the user can view the section in OSM but not change points. */
+  canEditScores: boolean;
 }
 
 export interface ScoresResponse {
@@ -51,6 +53,8 @@ export interface UpdateRequest {
 
 export interface UpdateResponse {
   patrols: PatrolResult[];
+  /** Set when the request sent Prefer: wait; await it via /batches/{batchId}?wait=N */
+  batchId?: string;
 }
 
 export interface PatrolResult {
@@ -62,17 +66,40 @@ export interface PatrolResult {
   isTemporaryError?: boolean;
   retryAfter?: string;
   errorMessage?: string;
+  /** OSM did not confirm the update before the server timed out; re-read scores before retrying */
+  pending?: boolean;
 }
 
 export interface ErrorResponse {
   error: string;
   message: string;
+  /** Set when the user must log in again, e.g. error "access_revoked" */
+  reauthenticate?: boolean;
+  /** The invalid inputs behind a "validation_error" */
+  fields?: FieldError[];
+}
+
+/** One invalid input in a request, so the UI can highlight it */
+export interface FieldError {
+  /** JSON path of the input, e.g. "updates[0].points" or "patrolColors.12" */
+  field: string;
+  constraint: 'range' | 'required' | 'max_length' | 'one_of' | 'multiple_of' | 'conflict';
+  /** Bounds of a "range" constraint */
+  min?: number;
+  max?: number;
+  message: string;
 }
 
 // Settings API types
 export interface SettingsResponse {
   sectionId: number;
   patrolColors: Record<string, string>;
+  patrolIcons?: Record<string, string>;
+  layout?: 'landscape' | 'portrait';
+  pointsStep?: number;
+  enforcePointsStep?: boolean;
+  minScore?: number;
+  hideBelowScore?: number;
   patrols: PatrolInfo[];
 }
 
@@ -82,7 +109,15 @@ export interface PatrolInfo {
 }
 
 export interface SettingsUpdateRequest {
-  patrolColors: Record<string, string>;
+  patrolColors?: Record<string, string>;
+  patrolIcons?: Record<string, string>;
+  layout?: '' | 'landscape' | 'portrait';
+  pointsStep?: number; // 0 clears the step
+  enforcePointsStep?: boolean;
+  minScore?: number;
+  clearMinScore?: boolean;
+  hideBelowScore?: number;
+  clearHideBelowScore?: boolean;
 }
 
 // Ad-hoc patrol API types
@@ -106,6 +141,17 @@ export interface Scoreboard {
   sectionName: string;
   clientId: string;
   lastUsedAt?: string;
+  status?: DeviceStatus;
+}
+
+// Last health report sent by a scoreboard device over its WebSocket
+export interface DeviceStatus {
+  uptime: number;
+  firmwareVersion?: string;
+  freeMemory?: number;
+  lastRenderError?: string;
+  connectionQuality?: number;
+  reportedAt: string;
 }
 
 export interface ScoreboardSectionUpdate {